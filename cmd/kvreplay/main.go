@@ -0,0 +1,149 @@
+// kvreplay replays newline-delimited kayvee JSON logs into an analytics Firehose stream.
+// Inputs are local files or s3://bucket/key URLs, optionally gzipped:
+//
+//	kvreplay -db mydb -env production -region us-west-1 -titles signup,login \
+//		-filter 'level>=warning' -since 2024-01-01T00:00:00Z -until 2024-01-02T00:00:00Z -rate 200 \
+//		s3://my-bucket/logs/2024-01-01.json.gz
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/caido/dependency-kayvee-go/v6/logger/analytics"
+	"github.com/caido/dependency-kayvee-go/v6/logger/filter"
+)
+
+func main() {
+	dbName := flag.String("db", "", "ark db to replay into (either -db or -stream is required)")
+	streamName := flag.String("stream", "", "Firehose stream to replay into")
+	env := flag.String("env", "", "environment of the ark db (defaults to _DEPLOY_ENV)")
+	region := flag.String("region", os.Getenv("_POD_REGION"), "AWS region of the Firehose stream")
+	titles := flag.String("titles", "", "comma-separated titles to replay (default: all)")
+	filterExpr := flag.String("filter", "", "only replay entries matching this filter expression, e.g. 'level>=error'")
+	timeField := flag.String("time-field", "", "field holding entry times (default: timestamp)")
+	since := flag.String("since", "", "only replay entries at or after this RFC 3339 time")
+	until := flag.String("until", "", "only replay entries before this RFC 3339 time")
+	rate := flag.Float64("rate", 0, "maximum entries replayed per second (default: unlimited)")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("usage: kvreplay [flags] <file or s3://bucket/key>...")
+	}
+
+	opts := analytics.ReplayOptions{
+		TimeField:     *timeField,
+		RatePerSecond: *rate,
+	}
+	if *titles != "" {
+		opts.Titles = strings.Split(*titles, ",")
+	}
+	var err error
+	if *filterExpr != "" {
+		if opts.Filter, err = filter.Parse(*filterExpr); err != nil {
+			log.Fatalf("invalid -filter: %s", err)
+		}
+	}
+	if opts.Since, err = parseTime(*since); err != nil {
+		log.Fatalf("invalid -since: %s", err)
+	}
+	if opts.Until, err = parseTime(*until); err != nil {
+		log.Fatalf("invalid -until: %s", err)
+	}
+
+	al, err := analytics.New(analytics.Config{
+		DBName:      *dbName,
+		StreamName:  *streamName,
+		Environment: *env,
+		Region:      *region,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	total := analytics.ReplayStats{}
+	for _, input := range flag.Args() {
+		r, err := open(ctx, input, *region)
+		if err != nil {
+			log.Fatalf("error opening %s: %s", input, err)
+		}
+		stats, err := analytics.Replay(ctx, r, al, opts)
+		r.Close()
+		total.Read += stats.Read
+		total.Replayed += stats.Replayed
+		total.Skipped += stats.Skipped
+		total.Invalid += stats.Invalid
+		if err != nil {
+			al.Close()
+			log.Fatalf("error replaying %s: %s", input, err)
+		}
+	}
+	al.Close()
+	fmt.Printf("read=%d replayed=%d skipped=%d invalid=%d\n", total.Read, total.Replayed, total.Skipped, total.Invalid)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// open returns a reader for a local file or an s3://bucket/key URL, transparently
+// decompressing inputs ending in .gz.
+func open(ctx context.Context, input, region string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	if strings.HasPrefix(input, "s3://") {
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(input, "s3://"), "/")
+		sess, err := session.NewSession(aws.NewConfig().WithRegion(region))
+		if err != nil {
+			return nil, err
+		}
+		out, err := s3.New(sess).GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		rc = out.Body
+	} else {
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, err
+		}
+		rc = f
+	}
+	if !strings.HasSuffix(input, ".gz") {
+		return rc, nil
+	}
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return gzipReadCloser{gz, rc}, nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	underlying io.Closer
+}
+
+func (g gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.underlying.Close()
+}
@@ -0,0 +1,116 @@
+// kvroutediff reports the log lines whose routing would change between two versions of a
+// kvconfig.yml. The corpus is read from files of kayvee JSON or logfmt lines, like samples of
+// production logs or the output of logger.DumpRecent, or from stdin:
+//
+//	git show HEAD:kvconfig.yml > /tmp/kvconfig.old.yml
+//	kvroutediff -old /tmp/kvconfig.old.yml -new kvconfig.yml sample.json
+//
+// It exits with status 1 if any routing changes, so it can gate config changes in CI.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger/parser"
+	"github.com/caido/dependency-kayvee-go/v6/router"
+)
+
+func main() {
+	oldConfig := flag.String("old", "", "current kvconfig.yml (required)")
+	newConfig := flag.String("new", "", "proposed kvconfig.yml (required)")
+	verbose := flag.Bool("v", false, "print the full outputs added and removed, not just their rules")
+	flag.Parse()
+	if *oldConfig == "" || *newConfig == "" {
+		log.Fatal("usage: kvroutediff -old <kvconfig.yml> -new <kvconfig.yml> [file]...")
+	}
+
+	before, err := router.NewFromConfig(*oldConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	after, err := router.NewFromConfig(*newConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	corpus := []map[string]interface{}{}
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	for _, input := range inputs {
+		msgs, err := read(input)
+		if err != nil {
+			log.Fatalf("error reading %s: %s", input, err)
+		}
+		corpus = append(corpus, msgs...)
+	}
+
+	changes := router.DiffRoutes(before, after, corpus)
+	for _, change := range changes {
+		fmt.Printf("entry %d: %s\n", change.Index+1, change.Msg["title"])
+		for _, output := range change.Removed {
+			fmt.Printf("  - %s\n", describe(output, *verbose))
+		}
+		for _, output := range change.Added {
+			fmt.Printf("  + %s\n", describe(output, *verbose))
+		}
+	}
+	fmt.Printf("entries=%d changed=%d\n", len(corpus), len(changes))
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+// read parses the lines of `input`, or of stdin if it's "-". Lines that can't be parsed are
+// reported and skipped.
+func read(input string) ([]map[string]interface{}, error) {
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	msgs := []map[string]interface{}{}
+	pr := parser.NewReader(r)
+	for {
+		e, err := pr.Read()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		var perr *parser.ParseError
+		if errors.As(err, &perr) {
+			log.Printf("%s: skipping %s", input, perr)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		msg := make(map[string]interface{}, len(e.Fields)+3)
+		for k, v := range e.Fields {
+			msg[k] = v
+		}
+		msg["title"] = e.Title
+		msg["source"] = e.Source
+		msg["level"] = e.Level.String()
+		msgs = append(msgs, msg)
+	}
+}
+
+// describe returns the rule of `output`, or all of it if `verbose`.
+func describe(output map[string]interface{}, verbose bool) string {
+	if !verbose {
+		return fmt.Sprintf("%s (%s)", output["rule"], output["type"])
+	}
+	bs, _ := json.Marshal(output)
+	return string(bs)
+}
@@ -0,0 +1,50 @@
+// kvtestvectors generates test vectors from a kvconfig.yml: example log lines for each routing
+// rule, and the outputs the Go router routes them to, as JSON. Kayvee implementations in other
+// languages can route the lines with the same config and compare, to check parity:
+//
+//	kvtestvectors -config kvconfig.yml > vectors.json
+//
+// The environment variables substituted in the outputs are read from the environment, and
+// listed in the "env" field of the vectors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/caido/dependency-kayvee-go/v6/router"
+)
+
+func main() {
+	config := flag.String("config", "", "kvconfig.yml (required)")
+	out := flag.String("o", "", "file to write the vectors to (default stdout)")
+	flag.Parse()
+	if *config == "" {
+		log.Fatal("usage: kvtestvectors -config <kvconfig.yml> [-o vectors.json]")
+	}
+
+	fileBytes, err := ioutil.ReadFile(*config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	vectors, err := router.GenerateTestVectors(fileBytes)
+	if err != nil {
+		log.Fatalf("error generating test vectors from %s: %s", *config, err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			log.Fatal(err)
+		}
+		defer w.Close()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(vectors); err != nil {
+		log.Fatal(err)
+	}
+}
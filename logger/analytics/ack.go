@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/firehose"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// ErrNotLogged is passed to the Ack of entries that weren't written, e.g. because they're
+// below the log level.
+var ErrNotLogged = errors.New("entry wasn't logged")
+
+// Ack is called once for a record: with nil when it's delivered, or with the error it was
+// dropped for. It's called from the goroutine sending the record, or from the writing one when
+// the record is rejected or dropped to make room.
+type Ack func(err error)
+
+// ackField carries the id of the Ack of an entry from InfoDAck to Write.
+const ackField = "_kv_ack"
+
+// InfoDAck logs like InfoD, and calls `ack` once the record is delivered or dropped.
+func (al *Logger) InfoDAck(title string, data logger.M, ack Ack) {
+	id := atomic.AddUint64(&al.lastAckID, 1)
+	al.pendingAcks.Store(id, ack)
+	withAck := make(logger.M, len(data)+1)
+	for k, v := range data {
+		withAck[k] = v
+	}
+	withAck[ackField] = id
+	al.KayveeLogger.InfoD(title, withAck)
+	// entries are written synchronously, so the ack is still pending if it wasn't
+	if _, ok := al.pendingAcks.LoadAndDelete(id); ok {
+		ack(ErrNotLogged)
+	}
+}
+
+// WriteAck writes a record like Write, and calls `ack` once it's delivered or dropped.
+func (al *Logger) WriteAck(bs []byte, ack Ack) (int, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(bs, &m); err != nil {
+		if ack != nil {
+			ack(err)
+		}
+		return 0, err
+	}
+	if entryAck := al.takeAck(m); ack == nil {
+		ack = entryAck
+	}
+	return al.write(context.Background(), m, ack)
+}
+
+// takeAck removes the id InfoDAck added to `m`, and returns the Ack it stands for.
+func (al *Logger) takeAck(m map[string]interface{}) Ack {
+	v, ok := m[ackField]
+	if !ok {
+		return nil
+	}
+	delete(m, ackField)
+	id, ok := v.(float64)
+	if !ok {
+		return nil
+	}
+	ack, ok := al.pendingAcks.LoadAndDelete(uint64(id))
+	if !ok {
+		return nil
+	}
+	return ack.(Ack)
+}
+
+// rejectRecord calls `ack` with `err` if it's set, for records rejected before being buffered,
+// and returns `err`.
+func rejectRecord(ack Ack, err error) (int, error) {
+	if ack != nil {
+		ack(err)
+	}
+	return 0, err
+}
+
+// acked calls the Ack of `r`, if it has one.
+func (al *Logger) acked(r *firehose.Record, err error) {
+	if ack, ok := al.recordAcks.LoadAndDelete(r); ok {
+		ack.(Ack)(err)
+	}
+}
@@ -0,0 +1,170 @@
+package analytics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// ackRecorder collects the results passed to acks.
+type ackRecorder struct {
+	mu      sync.Mutex
+	results map[string]error
+}
+
+func (r *ackRecorder) ack(name string) Ack {
+	return func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.results[name]; ok {
+			panic("ack called twice for " + name)
+		}
+		r.results[name] = err
+	}
+}
+
+func TestAcks(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	noStream := awserr.New(firehose.ErrCodeResourceNotFoundException, "no such stream", nil)
+	gomock.InOrder(
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+			FailedPutCount: aws.Int64(1),
+			RequestResponses: []*firehose.PutRecordBatchResponseEntry{
+				{RecordId: aws.String("rec-1")},
+				{ErrorCode: aws.String("InternalFailure")},
+				{RecordId: aws.String("rec-3")},
+			},
+		}, nil),
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil, noStream),
+	)
+
+	al, err := New(Config{
+		Environment:   "testenv",
+		DBName:        "testdb",
+		FirehoseAPI:   mf,
+		ErrLogger:     logger.NewMockCountLogger("errors"),
+		PartitionKeys: []PartitionKey{{Field: "team", Derive: DeriveConstant("eng")}},
+	})
+	require.NoError(t, err)
+	al.SetLogLevel(logger.Info)
+
+	r := &ackRecorder{results: map[string]error{}}
+	al.InfoDAck("test-title", logger.M{"i": 1}, r.ack("delivered"))
+	al.InfoDAck("test-title", logger.M{"i": 2}, r.ack("dropped"))
+	al.InfoD("test-title", logger.M{"i": 3})
+	_, err = al.WriteAck([]byte(`{"team":{"not":"valid"}}`), r.ack("rejected"))
+	assert.Error(t, err)
+	al.SetLogLevel(logger.Error)
+	al.InfoDAck("test-title", logger.M{"i": 4}, r.ack("filtered"))
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, map[string]error{
+		"delivered": nil,
+		"dropped":   noStream,
+		"rejected":  &MissingPartitionKeyError{Field: "team"},
+		"filtered":  ErrNotLogged,
+	}, r.results)
+}
+
+func TestWriteAck(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+		FailedPutCount:   aws.Int64(0),
+		RequestResponses: []*firehose.PutRecordBatchResponseEntry{{RecordId: aws.String("rec-1")}},
+	}, nil)
+
+	al, err := New(Config{
+		Environment:   "testenv",
+		DBName:        "testdb",
+		FirehoseAPI:   mf,
+		FlushInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	acked := make(chan error, 1)
+	_, err = al.WriteAck([]byte(`{"foo":"bar"}`), func(err error) { acked <- err })
+	require.NoError(t, err)
+	select {
+	case err := <-acked:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("record wasn't acknowledged")
+	}
+}
+
+func TestAckOnce(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		responses := make([]*firehose.PutRecordBatchResponseEntry, len(input.Records))
+		for i := range responses {
+			responses[i] = &firehose.PutRecordBatchResponseEntry{RecordId: aws.String("rec")}
+		}
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0), RequestResponses: responses}, nil
+	}).AnyTimes()
+
+	transformErr := errors.New("can't transform")
+	al, err := New(Config{
+		Environment:      "testenv",
+		DBName:           "testdb",
+		FirehoseAPI:      mf,
+		ErrLogger:        logger.NewMockCountLogger("errors"),
+		MaxBufferedBytes: 30,
+		Transform: func(m map[string]interface{}) (map[string]interface{}, error) {
+			if _, ok := m["untransformable"]; ok {
+				return nil, transformErr
+			}
+			return m, nil
+		},
+		StreamSelector: func(record map[string]interface{}) string {
+			if _, ok := record["selected"]; ok {
+				return "testenv--selected"
+			}
+			return ""
+		},
+	})
+	require.NoError(t, err)
+
+	// every record is acknowledged once, whether the error is returned by the writer or not
+	r := &ackRecorder{results: map[string]error{}}
+	for _, w := range []struct {
+		name, record string
+	}{
+		{"unparsable", `{`},
+		{"untransformable", `{"untransformable":true}`},
+		{"buffered", `{"pad":"xxxxxxxxxx"}`},
+		{"full", `{"pad":"xxxxxxxxxx"}`},
+		{"selected", `{"selected":"xxxxx"}`},
+		{"selected-full", `{"selected":"xxxxx"}`},
+	} {
+		_, err := al.WriteAck([]byte(w.record), r.ack(w.name))
+		r.mu.Lock()
+		assert.Equal(t, r.results[w.name], err, w.name)
+		r.mu.Unlock()
+	}
+	require.NoError(t, al.Close())
+
+	assert.Len(t, r.results, 6)
+	assert.Error(t, r.results["unparsable"])
+	assert.Equal(t, transformErr, r.results["untransformable"])
+	assert.NoError(t, r.results["buffered"])
+	assert.Equal(t, ErrBufferFull, r.results["full"])
+	assert.NoError(t, r.results["selected"])
+	assert.Equal(t, ErrBufferFull, r.results["selected-full"])
+}
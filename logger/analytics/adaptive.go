@@ -0,0 +1,134 @@
+package analytics
+
+import "time"
+
+// defaultAdaptiveInterval is the default AdaptiveBatchingConfig.TargetInterval.
+const defaultAdaptiveInterval = time.Second
+
+// defaultAdaptiveSmoothing is the default AdaptiveBatchingConfig.Smoothing.
+const defaultAdaptiveSmoothing = 0.3
+
+// AdaptiveBatchingConfig configures adaptive batching. The logger measures its write
+// throughput every TargetInterval and sets the flush threshold to the number of records it
+// expects to receive in one TargetInterval, bounded by MinBatchRecords and the configured
+// maximum batch size. Under low volume batches are flushed quickly (low latency), under high
+// volume they grow toward the Firehose limits (fewer, fuller requests).
+//
+// It also backs off while Firehose throttles: every TargetInterval with throttled requests
+// doubles the flush interval, up to MaxFlushInterval, and halves the number of batches sent
+// concurrently, down to MinConcurrency. Every TargetInterval without steps back toward the
+// configured values.
+type AdaptiveBatchingConfig struct {
+	// MinBatchRecords is the lowest flush threshold. Defaults to 1.
+	MinBatchRecords int
+	// TargetInterval is both how often throughput is sampled and roughly how long a batch
+	// should take to fill up. Defaults to one second.
+	TargetInterval time.Duration
+	// Smoothing is the weight (0, 1] given to the latest throughput sample in the moving
+	// average. Lower values react slower to bursts. Defaults to 0.3.
+	Smoothing float64
+	// MaxFlushInterval is the longest flush interval while backing off. Defaults to 8 times
+	// the FlushInterval.
+	MaxFlushInterval time.Duration
+	// MinConcurrency is the fewest batches sent concurrently while backing off. Defaults to 1.
+	MinConcurrency int
+}
+
+func (al *Logger) startAdaptiveBatching(c AdaptiveBatchingConfig) {
+	if c.MinBatchRecords <= 0 {
+		c.MinBatchRecords = 1
+	}
+	c.MinBatchRecords = min(c.MinBatchRecords, al.maxBatchRecords)
+	if c.TargetInterval <= 0 {
+		c.TargetInterval = defaultAdaptiveInterval
+	}
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		c.Smoothing = defaultAdaptiveSmoothing
+	}
+	if c.MaxFlushInterval < al.flushInterval {
+		c.MaxFlushInterval = 8 * al.flushInterval
+	}
+	if c.MinConcurrency <= 0 {
+		c.MinConcurrency = 1
+	}
+	c.MinConcurrency = min(c.MinConcurrency, al.pool.workers)
+	al.adaptive = &c
+	al.flushRecords = c.MinBatchRecords
+	al.lastTick = time.Now()
+
+	ticker := time.NewTicker(c.TargetInterval)
+	done := al.done
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				al.adapt(now)
+			}
+		}
+	}()
+}
+
+// adapt folds the throughput observed since the last call into the moving average and
+// recomputes the flush threshold.
+func (al *Logger) adapt(now time.Time) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	elapsed := now.Sub(al.lastTick).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	rate := float64(al.writtenSinceTick) / elapsed
+	al.writtenSinceTick = 0
+	al.lastTick = now
+	al.recordsPerSecond = al.adaptive.Smoothing*rate + (1-al.adaptive.Smoothing)*al.recordsPerSecond
+
+	threshold := int(al.recordsPerSecond * al.adaptive.TargetInterval.Seconds())
+	al.flushRecords = max(al.adaptive.MinBatchRecords, min(threshold, al.maxBatchRecords))
+
+	al.adaptBackoff(al.throttle.takeSignals() > 0)
+}
+
+// adaptBackoff backs off one step if Firehose throttled since the last tick, or recovers one
+// step otherwise. al.mu must be held.
+func (al *Logger) adaptBackoff(throttled bool) {
+	steps := al.backoffSteps
+	if throttled {
+		if al.backoffInterval(steps) < al.adaptive.MaxFlushInterval ||
+			al.backoffConcurrency(steps) > al.adaptive.MinConcurrency {
+			steps++
+		}
+	} else if steps > 0 {
+		steps--
+	}
+	if steps == al.backoffSteps {
+		return
+	}
+	al.backoffSteps = steps
+	al.sendingTicker.Reset(al.backoffInterval(steps))
+	al.pool.setLimit(al.backoffConcurrency(steps))
+}
+
+// backoffInterval is the flush interval after backing off `steps` times.
+func (al *Logger) backoffInterval(steps int) time.Duration {
+	d := al.flushInterval
+	for i := 0; i < steps && d < al.adaptive.MaxFlushInterval; i++ {
+		d *= 2
+	}
+	if d > al.adaptive.MaxFlushInterval {
+		return al.adaptive.MaxFlushInterval
+	}
+	return d
+}
+
+// backoffConcurrency is the number of batches sent concurrently after backing off `steps`
+// times.
+func (al *Logger) backoffConcurrency(steps int) int {
+	n := al.pool.workers
+	for i := 0; i < steps && n > al.adaptive.MinConcurrency; i++ {
+		n /= 2
+	}
+	return max(n, al.adaptive.MinConcurrency)
+}
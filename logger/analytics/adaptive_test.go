@@ -0,0 +1,145 @@
+package analytics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveBatching(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		FirehosePutRecordBatchMaxRecords: 100,
+		AdaptiveBatching: &AdaptiveBatchingConfig{
+			MinBatchRecords: 2,
+			TargetInterval:  time.Hour, // ticks are driven by the test
+			Smoothing:       0.5,
+		},
+	})
+	require.NoError(t, err)
+	defer al.Close()
+	assert.Equal(t, Stats{FlushRecordsThreshold: 2}, al.Stats(), "starts at the low-latency threshold")
+
+	start := al.lastTick
+	al.mu.Lock()
+	al.writtenSinceTick = 2400
+	al.mu.Unlock()
+	al.adapt(start.Add(time.Minute)) // 40 records/s
+	assert.Equal(t, 20.0, al.Stats().RecordsPerSecond)
+	assert.Equal(t, 100, al.Stats().FlushRecordsThreshold, "high volume grows to the configured maximum")
+
+	al.adapt(start.Add(2 * time.Minute)) // silence
+	assert.Equal(t, 10.0, al.Stats().RecordsPerSecond)
+	al.adapt(start.Add(3 * time.Minute))
+	al.adapt(start.Add(4 * time.Minute))
+	al.adapt(start.Add(5 * time.Minute))
+	al.adapt(start.Add(6 * time.Minute))
+	al.adapt(start.Add(7 * time.Minute))
+	al.adapt(start.Add(8 * time.Minute))
+	assert.InDelta(t, 0.156, al.Stats().RecordsPerSecond, 0.001)
+	assert.Equal(t, 100, al.Stats().FlushRecordsThreshold)
+
+	al.adaptive.TargetInterval = time.Second
+	al.adapt(start.Add(9 * time.Minute))
+	assert.Equal(t, 2, al.Stats().FlushRecordsThreshold, "low volume shrinks back to the minimum")
+}
+
+func TestAdaptiveBackoff(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+
+	al, err := New(Config{
+		Environment:   "testenv",
+		DBName:        "testdb",
+		FirehoseAPI:   mf,
+		FlushInterval: time.Second,
+		SendPool:      &SendPoolConfig{Workers: 8},
+		AdaptiveBatching: &AdaptiveBatchingConfig{
+			TargetInterval:   time.Hour, // ticks are driven by the test
+			MaxFlushInterval: 5 * time.Second,
+			MinConcurrency:   2,
+		},
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	state := func() (int, time.Duration, int) {
+		al.mu.Lock()
+		defer al.mu.Unlock()
+		al.pool.mu.Lock()
+		defer al.pool.mu.Unlock()
+		return al.backoffSteps, al.backoffInterval(al.backoffSteps), al.pool.limit
+	}
+	tick := func(throttled bool) {
+		if throttled {
+			al.throttle.throttled()
+		}
+		al.adapt(time.Now())
+	}
+
+	steps, interval, limit := state()
+	assert.Equal(t, 0, steps)
+	assert.Equal(t, time.Second, interval)
+	assert.Equal(t, 8, limit)
+
+	tick(true)
+	steps, interval, limit = state()
+	assert.Equal(t, 1, steps)
+	assert.Equal(t, 2*time.Second, interval)
+	assert.Equal(t, 4, limit)
+	assert.Equal(t, 1, al.Stats().ThrottleBackoff)
+
+	tick(true)
+	tick(true)
+	tick(true)
+	steps, interval, limit = state()
+	assert.Equal(t, 3, steps, "stops backing off once both bounds are reached")
+	assert.Equal(t, 5*time.Second, interval)
+	assert.Equal(t, 2, limit)
+
+	tick(false)
+	tick(false)
+	steps, interval, limit = state()
+	assert.Equal(t, 1, steps)
+	assert.Equal(t, 2*time.Second, interval)
+	assert.Equal(t, 4, limit)
+
+	tick(false)
+	tick(false)
+	steps, interval, limit = state()
+	assert.Equal(t, 0, steps, "recovers to the configured values")
+	assert.Equal(t, time.Second, interval)
+	assert.Equal(t, 8, limit)
+}
+
+func TestSendPoolLimit(t *testing.T) {
+	p := &sendPool{workers: 2, limit: 1}
+	p.cond = sync.NewCond(&p.mu)
+	p.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		p.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired over the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.setLimit(2)
+	<-acquired
+	p.release()
+	p.release()
+	assert.Equal(t, 0, p.active)
+}
@@ -1,6 +1,9 @@
 package analytics
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,38 +14,108 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/firehose"
 	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/eapache/go-resiliency/breaker"
 	"github.com/eapache/go-resiliency/retrier"
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 //go:generate mockgen -package $GOPACKAGE -destination mock_firehose.go github.com/aws/aws-sdk-go/service/firehose/firehoseiface FirehoseAPI
 
-// Logger writes to Firehose.
+// Logger writes to Firehose, or to Kinesis with BackendKinesis.
 type Logger struct {
 	logger.KayveeLogger
 	errLogger       logger.KayveeLogger
 	fhStream        string
 	fhAPI           firehoseiface.FirehoseAPI
+	clientMu        sync.RWMutex
+	newClient       func() (firehoseiface.FirehoseAPI, error)
+	credentials     *credentials.Credentials
 	batch           []*firehose.Record
+	batchLevels     []logger.LogLevel
 	batchBytes      int
 	maxBatchRecords int
 	maxBatchBytes   int
 	sendingTicker   *time.Ticker
-	done            chan bool
+	flushInterval   time.Duration
+	done            chan struct{}
+	closeOnce       sync.Once
 	mu              sync.Mutex
-	sendBatchWG     sync.WaitGroup
+	sendBatchWG     batchGroup
+	pool            *sendPool
+	spool           *spool
+	sendCtx         context.Context
+	cancelSends     context.CancelFunc
+	flushTimeout    time.Duration
+	sendTimeout     time.Duration
+	retryBackoff    []time.Duration
+	retryClassifier retrier.Classifier
+
+	logDeliveryReceipts bool
+	throttle            *throttle
+	breaker             *breaker.Breaker
+	failover            *failover
+	onSendError         func(SendError)
+	partitionKeys       []PartitionKey
+	codec               logger.Codec
+	oversize            OversizeConfig
+	ignoredFields       []string
+	envelope            *EnvelopeConfig
+	transform           func(map[string]interface{}) (map[string]interface{}, error)
+	redactor            *redactor
+	contextFields       func(ctx context.Context) map[string]interface{}
+	targets             []fanoutTarget
+	schemas             *schemaValidator
+	sampler             *sampler
+	bufferCap           *bufferCap
+	synchronous         bool
+	unregisterFork      func()
+	// pending are the batches to send with Synchronous, protected by mu
+	pending []sendJob
+
+	// dynamic stream routing, see streamselector.go
+	streamSelector StreamSelector
+	config         Config
+	streamsMu      sync.Mutex
+	streams        map[string]*Logger
+
+	// record acknowledgments, see ack.go
+	lastAckID   uint64
+	pendingAcks sync.Map
+	recordAcks  sync.Map
+
+	// per event type batches, see eventtypes.go
+	eventBatches map[string]*eventBatch
+	eventCounts  map[string]uint64
+
+	// adaptive batching state, see adaptive.go
+	adaptive         *AdaptiveBatchingConfig
+	flushRecords     int
+	writtenSinceTick int
+	lastTick         time.Time
+	recordsPerSecond float64
+	backoffSteps     int
 }
 
 var _ logger.KayveeLogger = &Logger{}
 var _ io.WriteCloser = &Logger{}
 
-var ignoredFields = []string{"level", "source", "title", "deploy_env", "wf_id"}
+// DefaultIgnoredFields are the fields added by kayvee that are stripped from records, unless
+// Config.IgnoredFields is set.
+var DefaultIgnoredFields = []string{"level", "source", "title", "deploy_env", "wf_id"}
 
 const timeoutForSendingBatches = time.Minute
 
+// Retry defaults, see Config.RetryAttempts and Config.RetryBaseDelay.
+const (
+	defaultRetryAttempts  = 5
+	defaultRetryBaseDelay = 100 * time.Millisecond
+)
+
 // firehosePutRecordBatchMaxRecords is an AWS limit.
 // https://docs.aws.amazon.com/firehose/latest/APIReference/API_PutRecordBatch.html
 const firehosePutRecordBatchMaxRecords = 500
@@ -69,17 +142,181 @@ type Config struct {
 	FirehosePutRecordBatchMaxRecords int
 	// FirehosePutRecordBatchMaxBytes overrides the default value (4000000) for the maximum number of bytes to send in a firehose batch.
 	FirehosePutRecordBatchMaxBytes int
+	// FlushInterval is how often whatever is buffered is sent, so that low-volume services
+	// don't hold partial batches until a record or byte threshold is reached. Defaults to 10
+	// minutes.
+	FlushInterval time.Duration
 	// FirehosePutRecordBatchMaxTime overrides the default value (10 minutes) for the maximum amount of time between writing an event and sending to the firehose.
+	//
+	// Deprecated: use FlushInterval, which takes precedence.
 	FirehosePutRecordBatchMaxTime time.Duration
+	// Backend is the kind of stream to send to. Defaults to BackendFirehose.
+	Backend Backend
+	// KinesisAPI defaults to an API object configured with Region, but can be overriden here.
+	// Only used with BackendKinesis.
+	KinesisAPI kinesisiface.KinesisAPI
+	// PartitionKeyFunc derives the partition key of records sent to Kinesis from their values.
+	// Defaults to DefaultPartitionKey. Only used with BackendKinesis.
+	PartitionKeyFunc func(logger.M) string
 	// FirehoseAPI defaults to an API object configured with Region, but can be overriden here.
 	FirehoseAPI firehoseiface.FirehoseAPI
+	// FirehoseClient is an aws-sdk-go-v2 client to send with instead of FirehoseAPI. Requests
+	// are bounded by the deadline of the batch, and retried as configured by the Retryer of the
+	// client. It can't be used with AutoProvision.
+	FirehoseClient FirehoseClient
+	// Credentials are used by the API objects configured with Region, e.g. auto-refreshing
+	// stscreds credentials. Defaults to the default credential chain. When AWS rejects expired
+	// credentials, they're expired so they're fetched again, and API objects configured with
+	// Region are rebuilt.
+	Credentials *credentials.Credentials
+	// CredentialsProvider provides the credentials when Credentials isn't set, e.g.
+	// credentials.StaticProvider for LocalStack.
+	CredentialsProvider credentials.Provider
+	// RoleARN, when Credentials isn't set, is the ARN of an IAM role to assume with STS, e.g. to
+	// write to a stream of another AWS account. The role is assumed with the credentials of
+	// CredentialsProvider, or of the default credential chain, and its temporary credentials
+	// are refreshed before they expire.
+	RoleARN string
+	// ExternalID is the external id the trust policy of RoleARN requires, if any.
+	ExternalID string
+	// RoleSessionName names the sessions of RoleARN in CloudTrail. Defaults to a timestamp.
+	RoleSessionName string
+	// Endpoint overrides the endpoint of the API objects configured with Region, e.g.
+	// "http://localhost:4566" to send to LocalStack or to a fake server in integration tests.
+	// Region defaults to us-east-1 when it's set.
+	Endpoint string
+	// DisableSSL makes the API objects configured with Region use HTTP instead of HTTPS.
+	DisableSSL bool
+	// UserAgent is appended to the user agent of AWS requests, e.g. "my-service/1.2", so the
+	// traffic of every service can be told apart in CloudTrail and access logs.
+	UserAgent string
+	// RequestHeaders are added to AWS requests.
+	RequestHeaders map[string]string
 	// ErrLogger is a logger used to make sure errors from goroutines still get surfaced. Defaults to basic logger.Logger
 	ErrLogger logger.KayveeLogger
+	// FirehoseMaxRequestsPerSecond caps the PutRecordBatch calls made by all of the logger's sending
+	// goroutines together. The effective rate is halved every time Firehose throttles and recovers
+	// gradually afterwards. Defaults to 2000.
+	FirehoseMaxRequestsPerSecond float64
+	// CircuitBreaker, when set, stops sending (and drops) batches while the stream keeps failing.
+	CircuitBreaker *CircuitBreakerConfig
+	// AdaptiveBatching, when set, makes the record threshold that triggers a flush follow the observed
+	// throughput instead of always waiting for FirehosePutRecordBatchMaxRecords records.
+	AdaptiveBatching *AdaptiveBatchingConfig
+	// LogDeliveryReceipts logs a "send-batch-receipt" entry to ErrLogger for every batch delivered, with the
+	// batch_id and the RecordId Firehose assigned to each record, so missing events can be traced.
+	LogDeliveryReceipts bool
+	// OnSendError, when set, is called with the records of every batch that couldn't be
+	// delivered, after retries are exhausted or when the circuit breaker drops it, so they can
+	// be alerted on or spooled elsewhere. It's called from the goroutine sending the batch, or
+	// from the writing one when the batch is dropped to make room.
+	OnSendError func(SendError)
+	// SendPool configures the workers sending batches. Defaults to 4 workers and a queue of 16
+	// batches, with writes blocking while the queue is full.
+	SendPool *SendPoolConfig
+	// RetryAttempts is the number of times a failed PutRecordBatch call is retried, with
+	// exponential backoff. Defaults to 5, and -1 disables retries.
+	RetryAttempts int
+	// RetryBaseDelay is the delay before the first retry, doubled for every retry after it.
+	// Defaults to 100ms.
+	RetryBaseDelay time.Duration
+	// RetryClassifier decides which errors are retried. Defaults to RequestErrorClassifier.
+	RetryClassifier retrier.Classifier
+	// SendTimeout bounds the time spent sending a batch, retries and records failing within
+	// a batch included. Defaults to a minute.
+	SendTimeout time.Duration
+	// FlushTimeout bounds how long Flush and Close wait for batches being sent. By default they
+	// wait until every batch has been delivered or has failed, which takes at most SendTimeout.
+	// Close cancels the batches still being sent after it.
+	FlushTimeout time.Duration
+	// BatchByEventType buffers the records of every event type, i.e. the title of the entry,
+	// in a separate batch so that a burst of one type doesn't delay the others. The byte
+	// threshold is shared by all the batches, and the largest one is sent when it's reached.
+	// Stats reports the records of every type.
+	BatchByEventType bool
+	// Compression compresses records before they're sent, to reduce the bytes ingested, with
+	// a codec of the kayvee logger package, e.g. CompressionZstd. The batch thresholds apply to
+	// the compressed sizes. Defaults to CompressionNone.
+	Compression Compression
+	// PartitionKeys are the fields Firehose dynamic partitioning reads from records. Missing
+	// keys are derived when possible, and Write rejects records that still lack one.
+	PartitionKeys []PartitionKey
+	// IgnoredFields are the fields stripped from records, e.g. to also strip fields added with
+	// AddContext, or to keep the title. Defaults to DefaultIgnoredFields, and an empty,
+	// non-nil slice keeps every field.
+	IgnoredFields []string
+	// ContextFields returns the fields WriteContext and InfoDContext add to records from their
+	// context, e.g. request-scoped trace ids. Fields already in the record take precedence.
+	// Defaults to the DefaultContextFields of the kayvee logger of the context.
+	ContextFields func(ctx context.Context) map[string]interface{}
+	// Oversize configures what Write does with records over the Firehose limit of 1,000 KiB.
+	// By default they're rejected.
+	Oversize *OversizeConfig
+	// AutoProvision, when set, creates the stream in dev and test environments if it doesn't
+	// exist.
+	AutoProvision *AutoProvisionConfig
+	// Spool, when set, saves batches that couldn't be sent to disk, and sends them again later.
+	Spool *SpoolConfig
+	// Failover, when set, sends batches to secondary streams, e.g. in other regions, while the
+	// primary stream keeps failing.
+	Failover *FailoverConfig
+	// Targets are additional streams every record is fanned out to, e.g. a sampled debugging
+	// stream next to the production one. Each has its own batches, and its own sampling rate.
+	// They're sent like the stream of the Config, but without Spool or Failover.
+	Targets []StreamTarget
+	// StreamSelector, when set, routes every record to the stream it returns, e.g. from a shard
+	// field like "district_id" or from the event type. Each stream has its own batches, sent
+	// like the stream of the Config but without Spool or Failover.
+	StreamSelector StreamSelector
+	// Schema, when set, validates the records of some titles against JSON Schemas before
+	// they're buffered, so that malformed events don't break downstream loads.
+	Schema *SchemaConfig
+	// SampleRates are the fractions of the records of some titles that are sent, between 0 and
+	// 1, e.g. 0.01 for high-volume per-request telemetry; 0 drops them all. Records of other
+	// titles are sent at DefaultSampleRate. The records sent have the SampledCountField.
+	// Sampling happens before records are fanned out to Targets or selected streams.
+	SampleRates map[string]float64
+	// DefaultSampleRate defaults to 1, i.e. every record.
+	DefaultSampleRate float64
+	// MaxBufferedBytes, when positive, bounds the bytes of the records buffered, queued or being
+	// sent, so that the memory of the logger doesn't grow without bound when Firehose is slow.
+	// What happens to the records written past it is DropPolicy.
+	MaxBufferedBytes int
+	// DropPolicy defaults to DropNewest.
+	DropPolicy DropPolicy
+	// Transform, when set, is called with every record after the IgnoredFields are stripped
+	// and before it's encoded, e.g. to rename fields or coerce types. It returns the record to
+	// send, which may be `m` itself, or nil to drop it. Write returns its errors, and the record
+	// isn't sent. It's called concurrently by the goroutines writing records.
+	Transform func(m map[string]interface{}) (map[string]interface{}, error)
+	// Redaction, when set, masks or hashes the values of PII fields, and the matches of PII
+	// patterns, after Transform. Records sent to Targets and selected streams are redacted too.
+	Redaction *RedactionConfig
+	// Envelope, when set, wraps every record in an envelope with its timestamp, schema version
+	// and source, and static fields.
+	Envelope *EnvelopeConfig
+	// Synchronous sends batches from the goroutine writing the record that fills them, or
+	// calling Flush, instead of from background workers, and returns their delivery errors
+	// as SendErrors, e.g. for short-lived Lambda-style processes. Buffered records aren't sent
+	// every FlushInterval: call Flush before the process is frozen or exits.
+	Synchronous bool
 }
 
 // New returns a logger that writes to an analytics ark db.
 // It takes as input the db name and the ark db config file.
 func New(c Config) (*Logger, error) {
+	if c.Endpoint != "" && c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Credentials == nil && c.RoleARN != "" {
+		creds, err := assumeRoleCredentials(c)
+		if err != nil {
+			return nil, err
+		}
+		c.Credentials = creds
+	} else if c.Credentials == nil && c.CredentialsProvider != nil {
+		c.Credentials = credentials.NewCredentials(c.CredentialsProvider)
+	}
 	l := logger.New(c.DBName)
 	al := &Logger{KayveeLogger: l}
 	l.SetOutput(al)
@@ -106,35 +343,69 @@ func New(c Config) (*Logger, error) {
 	} else {
 		al.maxBatchRecords = firehosePutRecordBatchMaxRecords
 	}
+	al.flushRecords = al.maxBatchRecords
 	if v := c.FirehosePutRecordBatchMaxBytes; v != 0 {
 		al.maxBatchBytes = min(v, firehosePutRecordBatchMaxBytes)
 	} else {
 		al.maxBatchBytes = firehosePutRecordBatchMaxBytes
 	}
-	if v := c.FirehosePutRecordBatchMaxTime; v > 0 {
-		al.sendingTicker = time.NewTicker(v)
+	if v := c.FlushInterval; v > 0 {
+		al.flushInterval = v
+	} else if v := c.FirehosePutRecordBatchMaxTime; v > 0 {
+		al.flushInterval = v
 	} else {
-		al.sendingTicker = time.NewTicker(firehosePutRecordBatchMaxTime)
+		al.flushInterval = firehosePutRecordBatchMaxTime
 	}
-	al.done = make(chan bool)
+	al.sendingTicker = time.NewTicker(al.flushInterval)
+	al.done = make(chan struct{})
+	al.sendCtx, al.cancelSends = context.WithCancel(context.Background())
 
-	if c.FirehoseAPI != nil {
-		// make an effort to override endpoint resolver
-		if f, ok := c.FirehoseAPI.(*firehose.Firehose); ok {
-			f.Client.Config.EndpointResolver = EndpointResolver
-			al.fhAPI = f
+	switch c.Backend {
+	case BackendKinesis:
+		if err := al.useKinesis(c); err != nil {
+			return nil, err
+		}
+	case "", BackendFirehose:
+		if c.FirehoseAPI != nil && c.FirehoseClient != nil {
+			return nil, errors.New("cannot specify both FirehoseAPI and FirehoseClient in logger config")
+		}
+		if c.FirehoseAPI != nil {
+			// make an effort to override endpoint resolver
+			if f, ok := c.FirehoseAPI.(*firehose.Firehose); ok {
+				f.Client.Config.EndpointResolver = EndpointResolver
+				newClientOptions(c).addHandlers(&f.Handlers)
+				al.fhAPI = f
+			} else {
+				al.fhAPI = c.FirehoseAPI
+			}
+		} else if c.FirehoseClient != nil {
+			if c.AutoProvision != nil {
+				return nil, errors.New("cannot use AutoProvision with FirehoseClient")
+			}
+			al.fhAPI = &clientV2{client: c.FirehoseClient, options: newClientOptions(c).v2Options()}
+		} else if c.Region != "" {
+			al.newClient = func() (firehoseiface.FirehoseAPI, error) {
+				return newFirehoseClient(c.Region, newClientOptions(c))
+			}
+			api, err := al.newClient()
+			if err != nil {
+				return nil, err
+			}
+			al.fhAPI = api
 		} else {
-			al.fhAPI = c.FirehoseAPI
+			return nil, errors.New("must provide FirehoseAPI, FirehoseClient or Region")
 		}
-	} else if c.Region != "" {
-		config := aws.NewConfig().WithRegion(c.Region).WithEndpointResolver(EndpointResolver)
-		sess, err := session.NewSession(config)
-		if err != nil {
-			return nil, fmt.Errorf("error creating firehose client: %v", err)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", c.Backend)
+	}
+
+	if c.AutoProvision != nil {
+		if c.AutoProvision.Parquet != nil && c.Compression != CompressionNone {
+			return nil, errors.New("cannot use Compression with Parquet")
+		}
+		if err := provisionStream(al.fhAPI, al.fhStream, env, *c.AutoProvision); err != nil {
+			return nil, err
 		}
-		al.fhAPI = firehose.New(sess)
-	} else {
-		return nil, errors.New("must provide FirehoseAPI or Region")
 	}
 
 	if c.ErrLogger != nil {
@@ -142,92 +413,464 @@ func New(c Config) (*Logger, error) {
 	} else {
 		al.errLogger = logger.New(al.fhStream)
 	}
+	al.credentials = c.Credentials
+	al.logDeliveryReceipts = c.LogDeliveryReceipts
+	al.flushTimeout = c.FlushTimeout
+	al.sendTimeout = timeoutForSendingBatches
+	if c.SendTimeout > 0 {
+		al.sendTimeout = c.SendTimeout
+	}
+	attempts, delay := defaultRetryAttempts, defaultRetryBaseDelay
+	if c.RetryAttempts < 0 {
+		attempts = 0
+	} else if c.RetryAttempts > 0 {
+		attempts = c.RetryAttempts
+	}
+	if c.RetryBaseDelay > 0 {
+		delay = c.RetryBaseDelay
+	}
+	al.retryBackoff = retrier.ExponentialBackoff(attempts, delay)
+	al.retryClassifier = RequestErrorClassifier{}
+	if c.RetryClassifier != nil {
+		al.retryClassifier = c.RetryClassifier
+	}
+	al.onSendError = c.OnSendError
+	if err := validatePartitionKeys(c.PartitionKeys); err != nil {
+		return nil, err
+	}
+	al.partitionKeys = c.PartitionKeys
+	codec, err := compressionCodec(c.Compression)
+	if err != nil {
+		return nil, err
+	}
+	al.codec = codec
+	sampler, err := newSampler(c)
+	if err != nil {
+		return nil, err
+	}
+	al.sampler = sampler
+	if c.MaxBufferedBytes > 0 {
+		b, err := newBufferCap(c.MaxBufferedBytes, c.DropPolicy)
+		if err != nil {
+			return nil, err
+		}
+		al.bufferCap = b
+	}
+	al.ignoredFields = DefaultIgnoredFields
+	if c.IgnoredFields != nil {
+		al.ignoredFields = c.IgnoredFields
+	}
+	if c.Envelope != nil {
+		if err := validateEnvelope(*c.Envelope); err != nil {
+			return nil, err
+		}
+		al.envelope = c.Envelope
+	}
+	al.transform = c.Transform
+	if c.Redaction != nil {
+		r, err := newRedactor(*c.Redaction)
+		if err != nil {
+			return nil, err
+		}
+		al.redactor = r
+	}
+	al.contextFields = defaultContextFields
+	if c.ContextFields != nil {
+		al.contextFields = c.ContextFields
+	}
+	if c.Oversize != nil {
+		if err := validateOversize(*c.Oversize); err != nil {
+			return nil, err
+		}
+		al.oversize = *c.Oversize
+	}
+	if al.oversize.MaxRecordBytes <= 0 {
+		al.oversize.MaxRecordBytes = firehoseMaxRecordBytes
+	}
+	if c.BatchByEventType {
+		al.eventBatches = map[string]*eventBatch{}
+		al.eventCounts = map[string]uint64{}
+	}
+	if v := c.FirehoseMaxRequestsPerSecond; v > 0 {
+		al.throttle = newThrottle(v)
+	} else {
+		al.throttle = newThrottle(firehoseMaxRequestsPerSecond)
+	}
+	if c.CircuitBreaker != nil {
+		al.breaker = newBreaker(*c.CircuitBreaker)
+	}
+	if c.Failover != nil {
+		f, err := newFailover(destination{stream: al.fhStream, region: c.Region}, *c.Failover, newClientOptions(c))
+		if err != nil {
+			return nil, err
+		}
+		al.failover = f
+	}
+	if c.Spool != nil {
+		if err := al.startSpool(*c.Spool); err != nil {
+			return nil, err
+		}
+	}
+	pool := SendPoolConfig{}
+	if c.SendPool != nil {
+		pool = *c.SendPool
+	}
+	if err := al.startSendPool(pool); err != nil {
+		return nil, err
+	}
+	if c.AdaptiveBatching != nil {
+		al.startAdaptiveBatching(*c.AdaptiveBatching)
+	}
+	if err := al.startTargets(c); err != nil {
+		return nil, err
+	}
+	if c.Schema != nil {
+		v, err := newSchemaValidator(*c.Schema, c)
+		if err != nil {
+			return nil, err
+		}
+		al.schemas = v
+	}
+	if c.StreamSelector != nil {
+		al.streamSelector = c.StreamSelector
+		al.config = c
+		al.streams = map[string]*Logger{}
+	}
+
+	al.unregisterFork = logger.RegisterForkResetter(al)
+	if c.Synchronous {
+		al.synchronous = true
+		return al, nil
+	}
+	al.startFlushing()
 
+	return al, nil
+}
+
+// startFlushing starts flushing every flushInterval until the logger is closed.
+func (al *Logger) startFlushing() {
+	done, ticker := al.done, al.sendingTicker
 	go func() {
 		for {
 			select {
-			case <-al.done:
+			case <-done:
 				return
-			case <-al.sendingTicker.C:
+			case <-ticker.C:
 				al.flush()
 			}
 		}
 	}()
-
-	return al, nil
 }
 
 // Write a log.
 func (al *Logger) Write(bs []byte) (int, error) {
-	var m map[string]interface{}
-	if err := json.Unmarshal(bs, &m); err != nil {
-		return 0, err
+	return al.WriteAck(bs, nil)
+}
+
+// write buffers the record of the entry `m`, and sets it up to call `ack` if it's set. `ack` is
+// called with the error returned, if any. `ctx` bounds how long it blocks on a full send
+// queue.
+func (al *Logger) write(ctx context.Context, m map[string]interface{}, ack Ack) (int, error) {
+	if al.schemas != nil {
+		title, _ := m["title"].(string)
+		if invalid := al.validate(title, m); invalid != nil {
+			return al.writeInvalid(ctx, m, invalid, ack)
+		}
 	}
+	if al.sampler != nil {
+		title, _ := m["title"].(string)
+		if !al.sampler.sample(title, m) {
+			if ack != nil {
+				ack(ErrNotLogged)
+			}
+			return 0, nil
+		}
+	}
+	al.fanOut(ctx, m)
+	if sl, err := al.selectedStream(m); err != nil {
+		return rejectRecord(ack, err)
+	} else if sl != nil {
+		return sl.write(ctx, m, ack)
+	}
+	eventType, _ := m["title"].(string)
+	source, _ := m["source"].(string)
+	levelName, _ := m["level"].(string)
+	// entries without a level, e.g. written with Write, are treated as Info
+	level, _ := logger.ParseLevel(levelName)
 	// delete kv-added fields we don't care about. We only want the logger.M values.
-	for _, f := range ignoredFields {
+	for _, f := range al.ignoredFields {
 		delete(m, f)
 	}
-	bs, err := json.Marshal(m)
+	if al.transform != nil {
+		var err error
+		if m, err = al.transform(m); err != nil {
+			return rejectRecord(ack, err)
+		} else if m == nil {
+			if ack != nil {
+				ack(ErrNotLogged)
+			}
+			return 0, nil
+		}
+	}
+	if al.redactor != nil {
+		al.redactor.redact(m)
+	}
+	if err := al.ensurePartitionKeys(m); err != nil {
+		return rejectRecord(ack, err)
+	}
+	if al.envelope != nil {
+		m = al.envelope.wrap(eventType, source, m, al.partitionKeys)
+	}
+	bs, err := al.marshalRecord(m)
 	if err != nil {
+		return rejectRecord(ack, err)
+	}
+	if len(bs) > al.oversize.MaxRecordBytes {
+		return al.writeOversize(ctx, eventType, level, m, bs, ack)
+	}
+	if err := al.buffer(ctx, eventType, level, bs, ack); err != nil {
 		return 0, err
 	}
-	bs = append(bs, '\n')
+	return len(bs), nil
+}
+
+// marshalRecord returns the record data of `m`: a line of JSON, compressed if configured.
+func (al *Logger) marshalRecord(m map[string]interface{}) ([]byte, error) {
+	bs, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return compress(al.codec, append(bs, '\n'))
+}
+
+// buffer buffers a record of the entry titled `eventType` at `level` with the data `bs`, and
+// sets it up to call `ack` if it's set. It returns ErrBufferFull if the record is dropped, or with
+// Synchronous the error of the batch holding the record if it's sent, which `ack` is called
+// with.
+func (al *Logger) buffer(ctx context.Context, eventType string, level logger.LogLevel, bs []byte, ack Ack) error {
+	if err := al.reserve(ctx, len(bs), level); err != nil {
+		if ack != nil {
+			ack(err)
+		}
+		return err
+	}
+	record := &firehose.Record{Data: bs}
+	if ack != nil {
+		al.recordAcks.Store(record, ack)
+	}
 	al.mu.Lock()
+	al.writtenSinceTick++
+	if al.eventBatches != nil {
+		al.bufferEvent(eventType, level, record)
+		al.mu.Unlock()
+		return al.flushPending(ctx, record)
+	}
 	al.batchBytes += len(bs)
-	al.batch = append(al.batch, &firehose.Record{Data: bs})
-	shouldSendBatch := len(al.batch) == al.maxBatchRecords ||
+	al.batch = append(al.batch, record)
+	al.batchLevels = append(al.batchLevels, level)
+	shouldSendBatch := len(al.batch) >= al.flushRecords ||
 		al.batchBytes > int(0.9*float64(al.maxBatchBytes))
 	al.mu.Unlock()
 
 	if shouldSendBatch {
-		al.flush()
+		return al.flushBatches(ctx, record)
 	}
-	return len(bs), nil
+	return nil
 }
 
 // flush asynchronously flushes a batch to kinesis
 func (al *Logger) flush() {
+	al.flushBatches(context.Background(), nil)
+}
+
+// flushBatches queues the buffered batches for sending, blocking on a full send queue until
+// `ctx` is done. With Synchronous, it sends them, and returns the error of the batch holding
+// `record`, or the first error if `record` is nil.
+func (al *Logger) flushBatches(ctx context.Context, record *firehose.Record) error {
 	al.mu.Lock()
-	defer al.mu.Unlock()
 	if len(al.batch) > 0 {
-		batch := al.batch
-		al.batch = nil
+		batch, level := al.batch, maxLevel(al.batchLevels)
+		al.batch, al.batchLevels = nil, nil
 		al.batchBytes = 0
-		// be careful not to send al.batch, since we will unlock before we finish sending the batch
-		al.sendBatchWG.Add(1)
-		go func() {
-			defer al.sendBatchWG.Done()
-			err := sendBatch(batch, al.fhAPI, al.fhStream, time.Now().Add(timeoutForSendingBatches))
-			if err != nil {
-				al.errLogger.ErrorD("send-batch-error", logger.M{
-					"stream": al.fhStream,
-					"error":  err.Error(),
-				})
-			}
-		}()
+		al.cutBatch(batch, level)
+	}
+	for eventType := range al.eventBatches {
+		al.sendEventBatch(eventType)
+	}
+	al.mu.Unlock()
+	return al.flushPending(ctx, record)
+}
+
+// cutBatch adds `batch`, whose highest record level is `level`, to the batches sent by
+// flushPending. al.mu must be held.
+func (al *Logger) cutBatch(batch []*firehose.Record, level logger.LogLevel) {
+	// be careful not to send al.batch, since we will unlock before we finish sending the batch
+	al.sendBatchWG.Add(1)
+	al.pending = append(al.pending, sendJob{batchID: newBatchID(), batch: batch, level: level})
+}
+
+// flushPending queues the batches cut for the send workers, or with Synchronous sends them and
+// returns the error of the batch holding `record`, or the first error if `record` is nil.
+// al.mu must not be held, since queueing blocks on a full send queue until `ctx` is done.
+func (al *Logger) flushPending(ctx context.Context, record *firehose.Record) error {
+	if al.synchronous {
+		return al.sendPending(record)
 	}
+	al.submitPending(ctx)
+	return nil
 }
 
-// Close flushes all logs to Firehose.
+// sendPending sends the batches queued with Synchronous from the calling goroutine, and
+// returns the error of the batch holding `record`, or the first error if `record` is nil. The
+// records of the other batches are only told about their errors by their acks.
+func (al *Logger) sendPending(record *firehose.Record) error {
+	al.mu.Lock()
+	jobs := al.pending
+	al.pending = nil
+	al.mu.Unlock()
+	var result error
+	for _, job := range jobs {
+		holdsRecord := record == nil
+		for _, r := range job.batch {
+			holdsRecord = holdsRecord || r == record
+		}
+		if err := al.send(job); holdsRecord && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// send sends the batch of `job`, and returns a SendError if it couldn't be delivered, unless
+// it's spooled.
+func (al *Logger) send(job sendJob) error {
+	defer al.sendBatchWG.Done()
+	defer al.unbuffer(job.batch)
+	batchID, batch := job.batchID, job.batch
+	send := &batchSend{records: batch}
+	err := al.deliver(func() error {
+		ctx, cancel := context.WithTimeout(al.sendCtx, al.sendTimeout)
+		defer cancel()
+		return al.sendBatch(ctx, send)
+	})
+	if err != nil && al.spool != nil && al.spoolBatch(batchID, send, err) {
+		return nil
+	}
+	if err != nil {
+		al.sendFailed(batchID, send, err)
+	}
+	if err == breaker.ErrBreakerOpen {
+		al.errLogger.ErrorD("send-batch-dropped", logger.M{
+			"stream":   al.fhStream,
+			"batch_id": batchID,
+			"records":  len(batch),
+			"error":    err.Error(),
+		})
+		return SendError{BatchID: batchID, Stream: al.fhStream, Records: recordData(send.records), Err: err}
+	} else if err != nil {
+		al.errLogger.ErrorD("send-batch-error", logger.M{
+			"stream":   al.fhStream,
+			"batch_id": batchID,
+			"records":  len(send.records),
+			"attempts": send.attempts,
+			"error":    err.Error(),
+		})
+		return SendError{
+			BatchID:  batchID,
+			Stream:   al.fhStream,
+			Records:  recordData(send.records),
+			Attempts: send.attempts,
+			Err:      err,
+		}
+	}
+	if al.logDeliveryReceipts {
+		al.errLogger.InfoD("send-batch-receipt", logger.M{
+			"stream":       al.fhStream,
+			"batch_id":     batchID,
+			"record_count": len(send.recordIDs),
+			"record_ids":   send.recordIDs,
+		})
+	}
+	return nil
+}
+
+// Close flushes all logs to Firehose, and blocks like Flush until they have been delivered. The
+// batches still being sent after FlushTimeout are canceled. Closing it again returns nil.
 func (al *Logger) Close() error {
+	var err error
+	al.closeOnce.Do(func() { err = al.close() })
+	return err
+}
+
+func (al *Logger) close() error {
+	al.unregisterFork()
 	al.sendingTicker.Stop()
-	al.done <- true
-	al.flush()
-	al.sendBatchWG.Wait()
-	return nil
+	close(al.done)
+	err := al.Flush()
+	if err == ErrFlushTimeout {
+		// don't let the batches still being sent outlive the logger
+		al.cancelSends()
+	}
+	al.stopSendPool()
+	if terr := al.closeTargets(); err == nil {
+		err = terr
+	}
+	return err
 }
 
-func sendBatch(batch []*firehose.Record, fhAPI firehoseiface.FirehoseAPI, fhStream string, timeout time.Time) error {
+// newBatchID returns a random identifier for a batch, used to correlate diagnostics.
+func newBatchID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// batchSend is the progress of sending a batch.
+type batchSend struct {
+	// records are the records not delivered yet.
+	records []*firehose.Record
+	// recordIDs are the RecordIds of the delivered records.
+	recordIDs []string
+	// attempts is the number of PutRecordBatch calls made.
+	attempts int
+}
+
+// sendBatch sends the records of `s` to Firehose.
+func (al *Logger) sendBatch(ctx context.Context, s *batchSend) error {
+	if al.failover != nil {
+		return al.sendBatchWithFailover(ctx, s)
+	}
+	return al.putBatch(ctx, destination{stream: al.fhStream}, s)
+}
+
+// putBatch sends the records of `s` to `dest`, until `ctx` is done.
+func (al *Logger) putBatch(ctx context.Context, dest destination, s *batchSend) error {
+	api := al.apiFor(dest)
 	// call PutRecordBatch until all records in the batch have been sent successfully
-	for time.Now().Before(timeout) {
+	for ctx.Err() == nil {
 		var result *firehose.PutRecordBatchOutput
-		r := retrier.New(retrier.ExponentialBackoff(5, 100*time.Millisecond), RequestErrorClassifier{})
-		if err := r.Run(func() error {
-			out, err := fhAPI.PutRecordBatch(&firehose.PutRecordBatchInput{
-				DeliveryStreamName: aws.String(fhStream),
-				Records:            batch,
+		backoff := al.retryBackoff
+		if _, ok := api.(*clientV2); ok {
+			// aws-sdk-go-v2 clients retry requests themselves, as configured by their Retryer
+			backoff = nil
+		}
+		r := retrier.New(backoff, al.retryClassifier)
+		if err := r.RunCtx(ctx, func(ctx context.Context) error {
+			// all senders share the throttle, so they back off together when Firehose throttles
+			if err := al.throttle.wait(ctx); err != nil {
+				return err
+			}
+			s.attempts++
+			out, err := putRecordBatch(ctx, api, &firehose.PutRecordBatchInput{
+				DeliveryStreamName: aws.String(dest.stream),
+				Records:            s.records,
 			})
 			if err != nil {
+				if isThrottlingError(err) {
+					al.throttle.throttled()
+				} else if isExpiredTokenError(err) {
+					api = al.renewCredentials(dest, api)
+				}
 				return err
 			}
 			result = out
@@ -235,20 +878,35 @@ func sendBatch(batch []*firehose.Record, fhAPI firehoseiface.FirehoseAPI, fhStre
 		}); err != nil {
 			return err
 		}
-		if aws.Int64Value(result.FailedPutCount) == 0 {
-			return nil
-		}
 		// formulate a new batch consisting of the unprocessed items
 		newbatch := []*firehose.Record{}
+		recordsThrottled := false
 		for i, res := range result.RequestResponses {
 			if aws.StringValue(res.ErrorCode) == "" {
+				s.recordIDs = append(s.recordIDs, aws.StringValue(res.RecordId))
+				al.acked(s.records[i], nil)
 				continue
 			}
-			newbatch = append(newbatch, batch[i])
+			if isThrottlingErrorCode(aws.StringValue(res.ErrorCode)) {
+				recordsThrottled = true
+			}
+			newbatch = append(newbatch, s.records[i])
 		}
-		batch = newbatch
+		if recordsThrottled {
+			al.throttle.throttled()
+		} else {
+			al.throttle.succeeded()
+		}
+		if aws.Int64Value(result.FailedPutCount) == 0 {
+			s.records = nil
+			return nil
+		}
+		s.records = newbatch
+	}
+	if ctx.Err() == context.Canceled {
+		return fmt.Errorf("canceled sending events: %d remaining", len(s.records))
 	}
-	return fmt.Errorf("timed out sending events: %d remaining", len(batch))
+	return fmt.Errorf("timed out sending events: %d remaining", len(s.records))
 }
 
 func min(a, b int) int {
@@ -272,5 +930,13 @@ func (RequestErrorClassifier) Classify(err error) retrier.Action {
 	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "RequestError" {
 		return retrier.Retry
 	}
+	if isThrottlingError(err) {
+		// the shared throttle has already backed off; try again once it lets us through
+		return retrier.Retry
+	}
+	if isExpiredTokenError(err) {
+		// the credentials have been renewed; try again with them
+		return retrier.Retry
+	}
 	return retrier.Fail
 }
@@ -1,12 +1,19 @@
 package analytics
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/firehose"
 	gomock "github.com/golang/mock/gomock"
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 func TestLogger(t *testing.T) {
@@ -54,3 +61,135 @@ func TestLogger(t *testing.T) {
 		})
 	}
 }
+
+func TestDeliveryReceipts(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+		FailedPutCount: aws.Int64(1),
+		RequestResponses: []*firehose.PutRecordBatchResponseEntry{
+			{RecordId: aws.String("rec-1")},
+			{ErrorCode: aws.String("ServiceUnavailableException")},
+		},
+	}, nil)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+		FailedPutCount: aws.Int64(0),
+		RequestResponses: []*firehose.PutRecordBatchResponseEntry{
+			{RecordId: aws.String("rec-2")},
+		},
+	}, nil)
+
+	errBuf := &bytes.Buffer{}
+	errLogger := logger.New("errors")
+	errLogger.SetOutput(errBuf)
+	al, err := New(Config{
+		Environment:         "testenv",
+		DBName:              "testdb",
+		FirehoseAPI:         mf,
+		ErrLogger:           errLogger,
+		LogDeliveryReceipts: true,
+	})
+	require.NoError(t, err)
+	al.InfoD("a", logger.M{"n": 1})
+	al.InfoD("b", logger.M{"n": 2})
+	al.Close()
+
+	var receipt map[string]interface{}
+	require.NoError(t, json.Unmarshal(errBuf.Bytes(), &receipt))
+	assert.Equal(t, "send-batch-receipt", receipt["title"])
+	assert.Equal(t, "testenv--testdb", receipt["stream"])
+	assert.Equal(t, []interface{}{"rec-1", "rec-2"}, receipt["record_ids"])
+	assert.Equal(t, 2.0, receipt["record_count"])
+	assert.Len(t, receipt["batch_id"], 16)
+}
+
+func TestCloseTwice(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Close())
+	assert.NoError(t, al.Close())
+	assert.Len(t, *records, 1)
+}
+
+func TestIgnoredFields(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		ignored []string
+		kept    []string
+		removed []string
+	}{
+		{"defaults", nil, []string{"foo", "request_id"}, DefaultIgnoredFields},
+		{"configured", []string{"level", "source", "request_id"}, []string{"foo", "title"}, []string{"level", "source", "request_id"}},
+		{"none", []string{}, []string{"foo", "title", "level", "source", "request_id"}, nil},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			mf, records := deliveredRecords(t, c)
+			al, err := New(Config{
+				Environment:   "testenv",
+				DBName:        "testdb",
+				FirehoseAPI:   mf,
+				IgnoredFields: test.ignored,
+			})
+			require.NoError(t, err)
+			al.AddContext("request_id", "abc")
+			al.InfoD("test-title", logger.M{"foo": "bar"})
+			require.NoError(t, al.Close())
+
+			require.Len(t, *records, 1)
+			for _, f := range test.kept {
+				assert.Contains(t, (*records)[0], f)
+			}
+			for _, f := range test.removed {
+				assert.NotContains(t, (*records)[0], f)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	var acks []error
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		Transform: func(m map[string]interface{}) (map[string]interface{}, error) {
+			assert.NotContains(t, m, "title", "fields are stripped first")
+			if m["drop"] == true {
+				return nil, nil
+			}
+			id, ok := m["user_id"].(string)
+			if !ok {
+				return nil, errors.New("user_id is missing")
+			}
+			n, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"userId": n}, nil
+		},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("login", logger.M{"user_id": "42"})
+	al.InfoDAck("login", logger.M{"drop": true}, func(err error) { acks = append(acks, err) })
+	_, err = al.Write([]byte(`{"title":"login"}`))
+	assert.EqualError(t, err, "user_id is missing")
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, []map[string]interface{}{{"userId": 42.0}}, *records)
+	assert.Equal(t, []error{ErrNotLogged}, acks)
+}
@@ -0,0 +1,171 @@
+// Package analyticstest provides an in-memory Firehose to test code logging analytics with.
+package analyticstest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+)
+
+// ThrottlingErrorCode is the error code of the errors of ThrottleNext, which analytics loggers
+// back off on.
+const ThrottlingErrorCode = firehose.ErrCodeServiceUnavailableException
+
+// Firehose is a firehoseiface.FirehoseAPI keeping the records put in memory, to be set as the
+// FirehoseAPI of an analytics.Config. It's safe for concurrent use. Only PutRecordBatch is
+// implemented: the other methods of the interface panic.
+type Firehose struct {
+	firehoseiface.FirehoseAPI
+
+	mu      sync.Mutex
+	calls   []*firehose.PutRecordBatchInput
+	records map[string][][]byte
+	nextID  int
+	// errs are returned by the next calls, in order
+	errs []error
+	// failRecords is the number of records to fail with failCode
+	failRecords int
+	failCode    string
+}
+
+var _ firehoseiface.FirehoseAPI = &Firehose{}
+
+// NewFirehose returns an empty Firehose.
+func NewFirehose() *Firehose {
+	return &Firehose{records: map[string][][]byte{}}
+}
+
+// PutRecordBatch records `input`, and keeps the records that don't fail.
+func (f *Firehose) PutRecordBatch(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, input)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+
+	stream := aws.StringValue(input.DeliveryStreamName)
+	out := &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}
+	for _, r := range input.Records {
+		if f.failRecords > 0 {
+			f.failRecords--
+			*out.FailedPutCount++
+			out.RequestResponses = append(out.RequestResponses, &firehose.PutRecordBatchResponseEntry{
+				ErrorCode:    aws.String(f.failCode),
+				ErrorMessage: aws.String("injected failure"),
+			})
+			continue
+		}
+		f.nextID++
+		f.records[stream] = append(f.records[stream], r.Data)
+		out.RequestResponses = append(out.RequestResponses, &firehose.PutRecordBatchResponseEntry{
+			RecordId: aws.String(fmt.Sprintf("record-%d", f.nextID)),
+		})
+	}
+	return out, nil
+}
+
+// PutRecordBatchWithContext is PutRecordBatch.
+func (f *Firehose) PutRecordBatchWithContext(_ aws.Context, input *firehose.PutRecordBatchInput, _ ...request.Option) (*firehose.PutRecordBatchOutput, error) {
+	return f.PutRecordBatch(input)
+}
+
+// FailNext makes the next PutRecordBatch call return `err`. Calls queue their errors.
+func (f *Firehose) FailNext(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs = append(f.errs, err)
+}
+
+// ThrottleNext makes the next `n` PutRecordBatch calls fail with the ThrottlingErrorCode
+// error Firehose returns when the stream is over its limits.
+func (f *Firehose) ThrottleNext(n int) {
+	for i := 0; i < n; i++ {
+		f.FailNext(awserr.New(ThrottlingErrorCode, "Slow down.", nil))
+	}
+}
+
+// FailRecords makes the next `n` records put fail with the error code `code`, e.g.
+// ThrottlingErrorCode or "InternalFailure", like partially failed batches do.
+func (f *Firehose) FailRecords(n int, code string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failRecords, f.failCode = n, code
+}
+
+// Calls returns the inputs of the PutRecordBatch calls made so far, including the failed ones.
+func (f *Firehose) Calls() []*firehose.PutRecordBatchInput {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*firehose.PutRecordBatchInput{}, f.calls...)
+}
+
+// Streams returns the streams records were put to, sorted.
+func (f *Firehose) Streams() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	streams := make([]string, 0, len(f.records))
+	for stream := range f.records {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	return streams
+}
+
+// RawRecords returns the data of the records put to `stream`, in order, as sent.
+func (f *Firehose) RawRecords(stream string) [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte{}, f.records[stream]...)
+}
+
+// Records returns the records put to `stream` decoded from JSON, in order. Gzip compressed
+// records are decompressed. It fails `t` if a record isn't JSON.
+func (f *Firehose) Records(t testing.TB, stream string) []map[string]interface{} {
+	t.Helper()
+	raw := f.RawRecords(stream)
+	records := make([]map[string]interface{}, len(raw))
+	for i, data := range raw {
+		bs, err := decompress(data)
+		if err != nil {
+			t.Fatalf("record %d of %s: %s", i, stream, err)
+		}
+		if err := json.Unmarshal(bs, &records[i]); err != nil {
+			t.Fatalf("record %d of %s isn't JSON: %s", i, stream, err)
+		}
+	}
+	return records
+}
+
+// Reset forgets the calls and records, and the failures still to inject.
+func (f *Firehose) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls, f.records, f.errs, f.failRecords = nil, map[string][][]byte{}, nil, 0
+}
+
+// decompress returns the JSON of the record data `bs`, whether it's gzip compressed or not.
+func decompress(bs []byte) ([]byte, error) {
+	if len(bs) < 2 || bs[0] != 0x1f || bs[1] != 0x8b {
+		return bs, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
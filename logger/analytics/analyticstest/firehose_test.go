@@ -0,0 +1,98 @@
+package analyticstest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+	"github.com/caido/dependency-kayvee-go/v6/logger/analytics"
+)
+
+func newLogger(t *testing.T, f *Firehose, c analytics.Config) *analytics.Logger {
+	c.Environment, c.DBName = "testenv", "testdb"
+	c.FirehoseAPI = f
+	c.ErrLogger = logger.NewMockCountLogger("errors")
+	c.RetryBaseDelay = time.Millisecond
+	al, err := analytics.New(c)
+	require.NoError(t, err)
+	return al
+}
+
+func TestFirehose(t *testing.T) {
+	f := NewFirehose()
+	al := newLogger(t, f, analytics.Config{})
+	al.InfoD("first", logger.M{"n": 1})
+	al.InfoD("second", logger.M{"n": 2})
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, []string{"testenv--testdb"}, f.Streams())
+	records := f.Records(t, "testenv--testdb")
+	require.Len(t, records, 2)
+	assert.Equal(t, float64(1), records[0]["n"])
+	assert.Equal(t, float64(2), records[1]["n"])
+	assert.Len(t, f.Calls(), 1)
+
+	f.Reset()
+	assert.Empty(t, f.Streams())
+	assert.Empty(t, f.Calls())
+}
+
+func TestFirehoseGzip(t *testing.T) {
+	f := NewFirehose()
+	al := newLogger(t, f, analytics.Config{Compression: analytics.CompressionGzip})
+	al.InfoD("compressed", logger.M{"n": 1})
+	require.NoError(t, al.Close())
+
+	raw := f.RawRecords("testenv--testdb")
+	require.Len(t, raw, 1)
+	assert.Equal(t, byte(0x1f), raw[0][0])
+	records := f.Records(t, "testenv--testdb")
+	require.Len(t, records, 1)
+	assert.Equal(t, float64(1), records[0]["n"])
+}
+
+func TestFirehoseFailures(t *testing.T) {
+	f := NewFirehose()
+	f.ThrottleNext(1)
+	f.FailRecords(1, "InternalFailure")
+	al := newLogger(t, f, analytics.Config{})
+	al.InfoD("first", logger.M{"n": 1})
+	al.InfoD("second", logger.M{"n": 2})
+	require.NoError(t, al.Close())
+
+	// the throttled call, the call failing the first record, and its retry
+	calls := f.Calls()
+	require.Len(t, calls, 3)
+	assert.Len(t, calls[1].Records, 2)
+	assert.Len(t, calls[2].Records, 1)
+	records := f.Records(t, "testenv--testdb")
+	require.Len(t, records, 2)
+	assert.Equal(t, float64(2), records[0]["n"])
+	assert.Equal(t, float64(1), records[1]["n"])
+}
+
+func TestFirehoseFailNext(t *testing.T) {
+	f := NewFirehose()
+	f.FailNext(errors.New("boom"))
+	_, err := f.PutRecordBatch(&firehose.PutRecordBatchInput{
+		DeliveryStreamName: aws.String("stream"),
+		Records:            []*firehose.Record{{Data: []byte(`{}`)}},
+	})
+	assert.EqualError(t, err, "boom")
+	assert.Empty(t, f.RawRecords("stream"))
+
+	out, err := f.PutRecordBatch(&firehose.PutRecordBatchInput{
+		DeliveryStreamName: aws.String("stream"),
+		Records:            []*firehose.Record{{Data: []byte(`{}`)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), aws.Int64Value(out.FailedPutCount))
+	assert.Equal(t, "record-1", aws.StringValue(out.RequestResponses[0].RecordId))
+	assert.Equal(t, [][]byte{[]byte(`{}`)}, f.RawRecords("stream"))
+}
@@ -0,0 +1,216 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/firehose"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// DropPolicy is what happens to records written while MaxBufferedBytes are buffered.
+type DropPolicy string
+
+const (
+	// DropNewest rejects the records written with ErrBufferFull. It's the default.
+	DropNewest DropPolicy = "drop-newest"
+	// DropOldest drops the batches waiting for a send worker, then the records buffered
+	// first (unless BatchByEventType is set), to make room. Only records of the level of the
+	// record written or lower are dropped, lowest level first, and Error and Critical ones
+	// never are: batches waiting for a send worker that hold others stay queued, in order, and
+	// Error and Critical records wait for room like with BlockWhenFull. Batches being sent
+	// aren't dropped: if they hold the buffer, the record written is rejected.
+	DropOldest DropPolicy = "drop-oldest"
+	// BlockWhenFull blocks writing until batches are sent, or until the context of
+	// WriteContext is done, in which case the record is rejected.
+	BlockWhenFull DropPolicy = "block"
+)
+
+// ErrBufferFull is the error of records dropped because MaxBufferedBytes were buffered.
+var ErrBufferFull = errors.New("analytics buffer is full")
+
+// bufferCap bounds the bytes of the records buffered, queued or being sent.
+type bufferCap struct {
+	max    int
+	policy DropPolicy
+
+	mu   sync.Mutex
+	used int
+	// freed is closed when bytes are released, and replaced
+	freed chan struct{}
+
+	dropped uint64
+}
+
+func newBufferCap(max int, policy DropPolicy) (*bufferCap, error) {
+	switch policy {
+	case "":
+		policy = DropNewest
+	case DropNewest, DropOldest, BlockWhenFull:
+	default:
+		return nil, fmt.Errorf("unknown drop policy %q", policy)
+	}
+	return &bufferCap{max: max, policy: policy, freed: make(chan struct{})}, nil
+}
+
+// tryReserve reserves `n` bytes if they fit, or returns a channel closed once bytes are
+// released. A record larger than the cap fits in an empty buffer, so that it isn't stuck.
+func (b *bufferCap) tryReserve(n int) (bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n <= b.max || b.used == 0 {
+		b.used += n
+		return true, nil
+	}
+	return false, b.freed
+}
+
+func (b *bufferCap) release(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+}
+
+// buffered returns the number of bytes buffered.
+func (b *bufferCap) buffered() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// reserve makes room for a record of `n` bytes at `level` according to the drop policy, or
+// returns ErrBufferFull if the record must be dropped.
+func (al *Logger) reserve(ctx context.Context, n int, level logger.LogLevel) error {
+	b := al.bufferCap
+	if b == nil {
+		return nil
+	}
+	for {
+		ok, freed := b.tryReserve(n)
+		if ok {
+			return nil
+		}
+		switch b.policy {
+		case BlockWhenFull:
+			select {
+			case <-freed:
+				continue
+			case <-ctx.Done():
+			}
+		case DropOldest:
+			if al.dropOldest(level) {
+				continue
+			}
+			if level >= logger.Error {
+				select {
+				case <-freed:
+					continue
+				case <-ctx.Done():
+				}
+			}
+		}
+		atomic.AddUint64(&b.dropped, 1)
+		return ErrBufferFull
+	}
+}
+
+// unbuffer releases the bytes of `records`, which left the buffer.
+func (al *Logger) unbuffer(records []*firehose.Record) {
+	if al.bufferCap == nil {
+		return
+	}
+	n := 0
+	for _, r := range records {
+		n += len(r.Data)
+	}
+	al.bufferCap.release(n)
+}
+
+// dropOldest makes room for a record at `level` by dropping the batch queued first that can be
+// dropped, or else the record buffered first of the lowest level, and returns false if there's
+// none, see DropOldest.
+func (al *Logger) dropOldest(level logger.LogLevel) bool {
+	if al.dropQueued(level) {
+		return true
+	}
+	al.mu.Lock()
+	i := -1
+	for j, l := range al.batchLevels {
+		if droppable(l, level) && (i < 0 || l < al.batchLevels[i]) {
+			i = j
+		}
+	}
+	if i < 0 {
+		al.mu.Unlock()
+		return false
+	}
+	record := al.batch[i]
+	al.batch = append(al.batch[:i:i], al.batch[i+1:]...)
+	al.batchLevels = append(al.batchLevels[:i:i], al.batchLevels[i+1:]...)
+	al.batchBytes -= len(record.Data)
+	atomic.AddUint64(&al.bufferCap.dropped, 1)
+	al.unbuffer([]*firehose.Record{record})
+	al.mu.Unlock()
+	al.sendFailed(newBatchID(), &batchSend{records: []*firehose.Record{record}}, ErrBufferFull)
+	return true
+}
+
+// dropQueued drops the batch queued first that can be dropped to make room for a record at
+// `level`, and returns false if there's none, or if batches are being queued.
+func (al *Logger) dropQueued(level logger.LogLevel) bool {
+	p := al.pool
+	select {
+	case p.submitting <- struct{}{}:
+	default:
+		return false
+	}
+	defer al.endSubmitting()
+	if p.closed {
+		return false
+	}
+	// take the queued batches out, and queue the ones kept again in order, which can't block
+	// since only the holder of p.submitting queues batches
+	var jobs []sendJob
+	for n := len(p.queue); n > 0; n-- {
+		select {
+		case job := <-p.queue:
+			jobs = append(jobs, job)
+		default:
+		}
+	}
+	dropped := false
+	for _, job := range jobs {
+		if !dropped && droppable(job.level, level) {
+			dropped = true
+			atomic.AddUint64(&al.bufferCap.dropped, uint64(len(job.batch)))
+			al.unbuffer(job.batch)
+			p.dropped = append(p.dropped, droppedJob{job, ErrBufferFull})
+			continue
+		}
+		p.queue <- job
+	}
+	return dropped
+}
+
+// droppable returns whether a record at `level` can be dropped to make room for one at
+// `written`.
+func droppable(level, written logger.LogLevel) bool {
+	return level <= written && level < logger.Error
+}
+
+// maxLevel returns the highest of `levels`.
+func maxLevel(levels []logger.LogLevel) logger.LogLevel {
+	highest := logger.Trace
+	for _, l := range levels {
+		if l > highest {
+			highest = l
+		}
+	}
+	return highest
+}
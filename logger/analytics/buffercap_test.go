@@ -0,0 +1,211 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// cappedLogger returns a logger buffering up to 2 records of `{"n":N}` before it's flushed.
+func cappedLogger(t *testing.T, c *gomock.Controller, policy DropPolicy) (*Logger, *[]map[string]interface{}) {
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment:      "testenv",
+		DBName:           "testdb",
+		FirehoseAPI:      mf,
+		ErrLogger:        logger.NewMockCountLogger("errors"),
+		FlushInterval:    time.Hour,
+		MaxBufferedBytes: 20, // each record is 8 bytes
+		DropPolicy:       policy,
+	})
+	require.NoError(t, err)
+	return al, records
+}
+
+func TestBufferCapDropNewest(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	al, records := cappedLogger(t, c, "")
+
+	_, err := al.Write([]byte(`{"n":1}`))
+	assert.NoError(t, err)
+	_, err = al.Write([]byte(`{"n":2}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 16, al.Stats().BufferedBytes)
+	var acked error
+	_, err = al.WriteAck([]byte(`{"n":3}`), func(err error) { acked = err })
+	assert.Equal(t, ErrBufferFull, err)
+	assert.Equal(t, ErrBufferFull, acked)
+	assert.Equal(t, uint64(1), al.Stats().DroppedRecords)
+
+	require.NoError(t, al.Close())
+	assert.Equal(t, []map[string]interface{}{{"n": 1.0}, {"n": 2.0}}, *records)
+	assert.Equal(t, 0, al.Stats().BufferedBytes)
+}
+
+func TestBufferCapDropOldest(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	al, records := cappedLogger(t, c, DropOldest)
+
+	acks := make(chan error, 1)
+	_, err := al.WriteAck([]byte(`{"n":1}`), func(err error) { acks <- err })
+	assert.NoError(t, err)
+	for _, line := range []string{`{"n":2}`, `{"n":3}`, `{"n":4}`} {
+		_, err = al.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, ErrBufferFull, <-acks)
+	assert.Equal(t, uint64(2), al.Stats().DroppedRecords)
+
+	require.NoError(t, al.Close())
+	assert.Equal(t, []map[string]interface{}{{"n": 3.0}, {"n": 4.0}}, *records)
+}
+
+func TestBufferCapDropOldestLevels(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	al, records := cappedLogger(t, c, DropOldest)
+
+	for _, w := range []struct {
+		line string
+		err  error
+	}{
+		{`{"level":"debug","n":1}`, nil},
+		{`{"level":"info","n":2}`, nil},
+		// the lowest level is dropped first
+		{`{"level":"info","n":3}`, nil},
+		// higher levels aren't dropped for lower ones
+		{`{"level":"debug","n":4}`, ErrBufferFull},
+		{`{"level":"error","n":5}`, nil},
+		{`{"level":"critical","n":6}`, nil},
+	} {
+		_, err := al.Write([]byte(w.line))
+		assert.Equal(t, w.err, err, w.line)
+	}
+	// errors aren't dropped either, so it waits for room
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := al.WriteContext(ctx, []byte(`{"level":"error","n":7}`))
+	assert.Equal(t, ErrBufferFull, err)
+	assert.Equal(t, uint64(5), al.Stats().DroppedRecords)
+
+	require.NoError(t, al.Close())
+	assert.Equal(t, []map[string]interface{}{{"n": 5.0}, {"n": 6.0}}, *records)
+}
+
+func TestBufferCapDropOldestQueuedErrors(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var sent []string
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		started <- struct{}{}
+		<-release
+		sent = append(sent, string(input.Records[0].Data))
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).Times(2)
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		ErrLogger:                        logger.NewMockCountLogger("errors"),
+		FirehosePutRecordBatchMaxRecords: 1,
+		MaxBufferedBytes:                 20,
+		DropPolicy:                       DropOldest,
+		SendPool:                         &SendPoolConfig{Workers: 1, QueueSize: 1},
+	})
+	require.NoError(t, err)
+
+	// the worker is busy with the first batch, and the second one is queued
+	al.Write([]byte(`{"level":"info","n":1}`))
+	<-started
+	al.Write([]byte(`{"level":"error","n":2}`))
+	_, err = al.Write([]byte(`{"level":"info","n":3}`))
+	assert.Equal(t, ErrBufferFull, err, "the queued error isn't dropped")
+	close(release)
+	require.NoError(t, al.Close())
+	assert.Equal(t, []string{`{"n":1}` + "\n", `{"n":2}` + "\n"}, sent)
+}
+
+func TestBufferCapDropOldestQueuedOrder(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	var sent []string
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		started <- struct{}{}
+		<-release
+		sent = append(sent, string(input.Records[0].Data))
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).Times(3)
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		ErrLogger:                        logger.NewMockCountLogger("errors"),
+		FirehosePutRecordBatchMaxRecords: 1,
+		MaxBufferedBytes:                 28,
+		DropPolicy:                       DropOldest,
+		SendPool:                         &SendPoolConfig{Workers: 1, QueueSize: 2},
+	})
+	require.NoError(t, err)
+
+	// the worker is busy with the first batch, and the next two are queued
+	al.Write([]byte(`{"level":"info","n":1}`))
+	<-started
+	al.Write([]byte(`{"level":"error","n":2}`))
+	al.Write([]byte(`{"level":"info","n":3}`))
+	_, err = al.Write([]byte(`{"level":"info","n":4}`))
+	assert.NoError(t, err, "the queued info batch behind the error is dropped")
+	close(release)
+	require.NoError(t, al.Close())
+	assert.Equal(t, []string{`{"n":1}` + "\n", `{"n":2}` + "\n", `{"n":4}` + "\n"}, sent)
+	assert.Equal(t, uint64(1), al.Stats().DroppedRecords)
+}
+
+func TestBufferCapBlock(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	al, records := cappedLogger(t, c, BlockWhenFull)
+
+	al.Write([]byte(`{"n":1}`))
+	al.Write([]byte(`{"n":2}`))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := al.WriteContext(ctx, []byte(`{"n":3}`))
+	assert.Equal(t, ErrBufferFull, err, "gives up once the context is done")
+
+	written := make(chan error)
+	go func() {
+		_, err := al.Write([]byte(`{"n":4}`))
+		written <- err
+	}()
+	select {
+	case <-written:
+		t.Fatal("wrote to a full buffer")
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.NoError(t, al.Flush())
+	assert.NoError(t, <-written, "unblocks once the buffer is sent")
+
+	require.NoError(t, al.Close())
+	assert.Equal(t, []map[string]interface{}{{"n": 1.0}, {"n": 2.0}, {"n": 4.0}}, *records)
+}
+
+func TestBufferCapConfig(t *testing.T) {
+	_, err := New(Config{Environment: "testenv", DBName: "testdb", Region: "us-west-1", MaxBufferedBytes: 1, DropPolicy: "lifo"})
+	assert.EqualError(t, err, `unknown drop policy "lifo"`)
+}
@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/eapache/go-resiliency/breaker"
+)
+
+// Circuit breaker defaults, see CircuitBreakerConfig.
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerSuccessThreshold = 1
+	defaultBreakerOpenTimeout      = 30 * time.Second
+)
+
+// CircuitBreakerConfig configures a circuit breaker around batch delivery. Once
+// FailureThreshold batches fail without a quiet period of OpenTimeout in between, the
+// breaker opens and batches are dropped immediately, instead of tying up goroutines retrying
+// against a dead stream. After OpenTimeout the next batch is sent as a probe;
+// SuccessThreshold successful probes close the breaker again, a failed one reopens it.
+type CircuitBreakerConfig struct {
+	// FailureThreshold defaults to 5.
+	FailureThreshold int
+	// SuccessThreshold defaults to 1.
+	SuccessThreshold int
+	// OpenTimeout defaults to 30 seconds.
+	OpenTimeout time.Duration
+}
+
+func newBreaker(c CircuitBreakerConfig) *breaker.Breaker {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if c.SuccessThreshold <= 0 {
+		c.SuccessThreshold = defaultBreakerSuccessThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	return breaker.New(c.FailureThreshold, c.SuccessThreshold, c.OpenTimeout)
+}
+
+// deliver runs `send` through the circuit breaker, if there is one.
+func (al *Logger) deliver(send func() error) error {
+	if al.breaker == nil {
+		return send()
+	}
+	return al.breaker.Run(send)
+}
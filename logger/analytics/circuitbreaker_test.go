@@ -0,0 +1,51 @@
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil,
+		awserr.New(firehose.ErrCodeResourceNotFoundException, "no such stream", nil)).Times(2)
+
+	errBuf := &bytes.Buffer{}
+	errLogger := logger.New("errors")
+	errLogger.SetOutput(errBuf)
+	al, err := New(Config{
+		Environment:    "testenv",
+		DBName:         "testdb",
+		FirehoseAPI:    mf,
+		ErrLogger:      errLogger,
+		CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour},
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	for i := 0; i < 3; i++ {
+		al.InfoD("test-title", logger.M{"i": i})
+		al.flush()
+		al.sendBatchWG.Wait()
+	}
+	assert.True(t, al.Stats().CircuitBreakerOpen)
+
+	lines := strings.Split(strings.TrimSpace(errBuf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"title":"send-batch-error"`)
+	assert.Contains(t, lines[1], `"title":"send-batch-error"`)
+	assert.Contains(t, lines[2], `"title":"send-batch-dropped"`)
+	assert.Contains(t, lines[2], `"records":1`)
+}
@@ -0,0 +1,78 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+
+	firehosev2 "github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/firehose/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+	"github.com/aws/smithy-go"
+)
+
+// FirehoseClient is the part of the aws-sdk-go-v2 Firehose client used by the logger, e.g.
+// *firehose.Client from github.com/aws/aws-sdk-go-v2/service/firehose.
+type FirehoseClient interface {
+	PutRecordBatch(ctx context.Context, params *firehosev2.PutRecordBatchInput, optFns ...func(*firehosev2.Options)) (*firehosev2.PutRecordBatchOutput, error)
+}
+
+// clientV2 sends with an aws-sdk-go-v2 client. It only implements PutRecordBatch: the logger
+// doesn't call the rest of the FirehoseAPI with it.
+type clientV2 struct {
+	firehoseiface.FirehoseAPI
+	client  FirehoseClient
+	options []func(*firehosev2.Options)
+}
+
+// PutRecordBatch sends `input` without a deadline, see putRecordBatch.
+func (c *clientV2) PutRecordBatch(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	return c.putRecordBatch(context.Background(), input)
+}
+
+func (c *clientV2) putRecordBatch(ctx context.Context, input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	records := make([]types.Record, len(input.Records))
+	for i, r := range input.Records {
+		records[i] = types.Record{Data: r.Data}
+	}
+	out, err := c.client.PutRecordBatch(ctx, &firehosev2.PutRecordBatchInput{
+		DeliveryStreamName: input.DeliveryStreamName,
+		Records:            records,
+	}, c.options...)
+	if err != nil {
+		return nil, toAWSError(err)
+	}
+	result := &firehose.PutRecordBatchOutput{
+		FailedPutCount:   aws.Int64(int64(aws.Int32Value(out.FailedPutCount))),
+		RequestResponses: make([]*firehose.PutRecordBatchResponseEntry, len(out.RequestResponses)),
+	}
+	for i, res := range out.RequestResponses {
+		result.RequestResponses[i] = &firehose.PutRecordBatchResponseEntry{
+			ErrorCode:    res.ErrorCode,
+			ErrorMessage: res.ErrorMessage,
+			RecordId:     res.RecordId,
+		}
+	}
+	return result, nil
+}
+
+// toAWSError converts errors of aws-sdk-go-v2 to the errors of aws-sdk-go, so they're
+// classified the same way. Errors other than API errors are request errors, e.g. timeouts.
+func toAWSError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return awserr.New(apiErr.ErrorCode(), apiErr.ErrorMessage(), err)
+	}
+	return awserr.New("RequestError", err.Error(), err)
+}
+
+// putRecordBatch calls PutRecordBatch on `api`, with `ctx` for aws-sdk-go-v2 clients.
+// FirehoseAPI implementations may only implement PutRecordBatch, so they're called without it.
+func putRecordBatch(ctx context.Context, api firehoseiface.FirehoseAPI, input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	if c, ok := api.(*clientV2); ok {
+		return c.putRecordBatch(ctx, input)
+	}
+	return api.PutRecordBatch(input)
+}
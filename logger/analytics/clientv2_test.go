@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	firehosev2 "github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/firehose/types"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// fakeClientV2 is a FirehoseClient returning `errs` in turn, and then delivering records.
+type fakeClientV2 struct {
+	errs      []error
+	calls     int
+	records   []string
+	deadlines bool
+}
+
+func (f *fakeClientV2) PutRecordBatch(ctx context.Context, params *firehosev2.PutRecordBatchInput, optFns ...func(*firehosev2.Options)) (*firehosev2.PutRecordBatchOutput, error) {
+	f.calls++
+	_, f.deadlines = ctx.Deadline()
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	out := &firehosev2.PutRecordBatchOutput{FailedPutCount: aws.Int32(0)}
+	for _, r := range params.Records {
+		f.records = append(f.records, string(r.Data))
+		out.RequestResponses = append(out.RequestResponses, types.PutRecordBatchResponseEntry{RecordId: aws.String("rec")})
+	}
+	return out, nil
+}
+
+func TestFirehoseClient(t *testing.T) {
+	client := &fakeClientV2{}
+	al, err := New(Config{
+		Environment:    "testenv",
+		DBName:         "testdb",
+		FirehoseClient: client,
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Close())
+	assert.Equal(t, []string{`{"foo":"bar"}` + "\n"}, client.records)
+	assert.True(t, client.deadlines)
+
+	// requests are retried by the client, not by the logger
+	client = &fakeClientV2{errs: []error{&smithy.GenericAPIError{Code: "ServiceUnavailableException", Message: "slow down"}}}
+	sendErrors := []SendError{}
+	al, err = New(Config{
+		Environment:    "testenv",
+		DBName:         "testdb",
+		FirehoseClient: client,
+		ErrLogger:      logger.NewMockCountLogger("errors"),
+		OnSendError:    func(e SendError) { sendErrors = append(sendErrors, e) },
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Close())
+	assert.Equal(t, 1, client.calls)
+	require.Len(t, sendErrors, 1)
+	assert.EqualError(t, sendErrors[0].Err, "ServiceUnavailableException: slow down\ncaused by: api error ServiceUnavailableException: slow down")
+}
+
+func TestFirehoseClientConfig(t *testing.T) {
+	_, err := New(Config{
+		Environment:    "testenv",
+		DBName:         "testdb",
+		FirehoseClient: &fakeClientV2{},
+		AutoProvision:  &AutoProvisionConfig{},
+	})
+	assert.EqualError(t, err, "cannot use AutoProvision with FirehoseClient")
+}
+
+func TestToAWSError(t *testing.T) {
+	err := toAWSError(&smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "no such stream"})
+	assert.Equal(t, "ResourceNotFoundException", err.(awserr.Error).Code())
+	err = toAWSError(context.DeadlineExceeded)
+	assert.Equal(t, "RequestError", err.(awserr.Error).Code())
+}
@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// Compression is how record data is compressed before it's sent: the name of a codec of the
+// kayvee logger package, see logger.CodecByName.
+type Compression string
+
+const (
+	// CompressionNone sends records as JSON.
+	CompressionNone Compression = ""
+	// CompressionGzip sends every record as a gzip member. Firehose concatenates records, so
+	// the delivered objects are gzip files of the JSON records.
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd sends every record as a zstd frame, which are concatenated like gzip
+	// members.
+	CompressionZstd Compression = "zstd"
+)
+
+// compressionCodec returns the codec of `c`, or nil for CompressionNone.
+func compressionCodec(c Compression) (logger.Codec, error) {
+	if c == CompressionNone {
+		return nil, nil
+	}
+	return logger.CodecByName(string(c))
+}
+
+// compress compresses the record data `bs` with `codec`, if it's set.
+func compress(codec logger.Codec, bs []byte) ([]byte, error) {
+	if codec == nil {
+		return bs, nil
+	}
+	return logger.Compress(codec, bs)
+}
+
+// decompress returns the JSON of the record data `bs` compressed with `codec`, if it's set.
+func decompress(codec logger.Codec, bs []byte) ([]byte, error) {
+	if codec == nil {
+		return bs, nil
+	}
+	r, err := codec.NewReader(bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestCompression(t *testing.T) {
+	for _, compression := range []Compression{CompressionGzip, CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			mf := NewMockFirehoseAPI(c)
+			var delivered []byte
+			mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+				for _, r := range input.Records {
+					delivered = append(delivered, r.Data...)
+				}
+				return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+			})
+
+			al, err := New(Config{
+				Environment:                    "testenv",
+				DBName:                         "testdb",
+				FirehoseAPI:                    mf,
+				FirehosePutRecordBatchMaxBytes: 100,
+				Compression:                    compression,
+			})
+			require.NoError(t, err)
+
+			// the records only fit in a batch compressed
+			payload := strings.Repeat("x", 1000)
+			al.InfoD("test-title", logger.M{"i": 1, "payload": payload})
+			al.InfoD("test-title", logger.M{"i": 2, "payload": payload})
+			require.NoError(t, al.Close())
+
+			// the concatenated records are a compressed file of the JSON records
+			codec, err := logger.CodecByName(string(compression))
+			require.NoError(t, err)
+			r, err := codec.NewReader(bytes.NewReader(delivered))
+			require.NoError(t, err)
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, `{"i":1,"payload":"`+payload+`"}`+"\n"+`{"i":2,"payload":"`+payload+`"}`+"\n", string(data))
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	codec, err := compressionCodec(CompressionZstd)
+	require.NoError(t, err)
+	compressed, err := compress(codec, []byte(`{"foo":"bar"}`))
+	require.NoError(t, err)
+	data, err := decompress(codec, compressed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"foo":"bar"}`, string(data))
+
+	codec, err = compressionCodec(CompressionNone)
+	require.NoError(t, err)
+	data, err = decompress(codec, []byte(`{"foo":"bar"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"foo":"bar"}`, string(data))
+
+	_, err = New(Config{Environment: "testenv", DBName: "testdb", Region: "us-west-1", Compression: "brotli"})
+	assert.EqualError(t, err, `unknown codec "brotli", expected one of deflate, gzip, identity, lz4, snappy, zstd`)
+}
@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// DefaultContextFields are the context fields of the kayvee logger of a context, set with
+// logger.NewContext and AddContext, that are added to records by default.
+var DefaultContextFields = []string{"trace_id", "span_id", "request_id"}
+
+// defaultContextFields returns the DefaultContextFields of the kayvee logger of `ctx`.
+func defaultContextFields(ctx context.Context) map[string]interface{} {
+	kv := logger.FromContext(ctx)
+	fields := map[string]interface{}{}
+	for _, f := range DefaultContextFields {
+		if v, ok := kv.GetContext(f); ok {
+			fields[f] = v
+		}
+	}
+	return fields
+}
+
+// WriteContext writes a record like Write, adding the context fields of `ctx` to it. Writes
+// that send a batch don't block past `ctx` when the send queue is full: the batch is queued by
+// a later flush instead. It returns the error of `ctx` if it's already done.
+func (al *Logger) WriteContext(ctx context.Context, bs []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return 0, err
+	}
+	al.addContextFields(ctx, m)
+	return al.write(ctx, m, al.takeAck(m))
+}
+
+// InfoDContext logs like InfoD, adding the context fields of `ctx` to the record.
+func (al *Logger) InfoDContext(ctx context.Context, title string, data logger.M) {
+	withContext := make(logger.M, len(data))
+	for k, v := range data {
+		withContext[k] = v
+	}
+	al.addContextFields(ctx, withContext)
+	al.KayveeLogger.InfoD(title, withContext)
+}
+
+// addContextFields adds the context fields of `ctx` that `m` doesn't have.
+func (al *Logger) addContextFields(ctx context.Context, m map[string]interface{}) {
+	for k, v := range al.contextFields(ctx) {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+}
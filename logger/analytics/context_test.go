@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestWriteContext(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+	})
+	require.NoError(t, err)
+
+	kv := logger.New("test")
+	kv.AddContext("trace_id", "abc")
+	kv.AddContext("request_id", "req-1")
+	ctx := logger.NewContext(context.Background(), kv)
+	_, err = al.WriteContext(ctx, []byte(`{"title":"test-title","foo":"bar","request_id":"req-2"}`))
+	require.NoError(t, err)
+	al.InfoDContext(ctx, "test-title", logger.M{"foo": "baz"})
+	_, err = al.WriteContext(context.Background(), []byte(`{"title":"test-title","foo":"qux"}`))
+	require.NoError(t, err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = al.WriteContext(canceled, []byte(`{"title":"test-title","foo":"quux"}`))
+	assert.Equal(t, context.Canceled, err)
+
+	require.NoError(t, al.Close())
+	assert.Equal(t, []map[string]interface{}{
+		{"foo": "bar", "trace_id": "abc", "request_id": "req-2"},
+		{"foo": "baz", "trace_id": "abc", "request_id": "req-1"},
+		{"foo": "qux"},
+	}, *records)
+}
+
+type tenantKey struct{}
+
+func TestContextFields(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		ContextFields: func(ctx context.Context) map[string]interface{} {
+			return map[string]interface{}{"tenant": ctx.Value(tenantKey{})}
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	_, err = al.WriteContext(ctx, []byte(`{"title":"test-title","foo":"bar"}`))
+	require.NoError(t, err)
+	require.NoError(t, al.Close())
+	assert.Equal(t, []map[string]interface{}{{"foo": "bar", "tenant": "acme"}}, *records)
+}
+
+func TestWriteContextQueueFull(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	release := make(chan struct{})
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		<-release
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).Times(3)
+
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		FirehosePutRecordBatchMaxRecords: 1,
+		SendPool:                         &SendPoolConfig{Workers: 1, QueueSize: 1},
+	})
+	require.NoError(t, err)
+
+	// the worker is busy with the first batch, the second one is queued, and the third one
+	// would block
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	written := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			al.WriteContext(ctx, []byte(`{"title":"test-title","i":1}`))
+		}
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("writing blocked past the deadline")
+	}
+	// the batch is still sent once there's room in the queue
+	close(release)
+	require.NoError(t, al.Close())
+}
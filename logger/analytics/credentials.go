@@ -0,0 +1,105 @@
+package analytics
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// newFirehoseClient returns a client for `region`, using the credentials of `o` if they're set
+// and the default credential chain otherwise.
+func newFirehoseClient(region string, o clientOptions) (firehoseiface.FirehoseAPI, error) {
+	sess, err := session.NewSession(o.awsConfig(region).WithEndpointResolver(EndpointResolver))
+	if err != nil {
+		return nil, fmt.Errorf("error creating firehose client: %v", err)
+	}
+	o.addHandlers(&sess.Handlers)
+	return firehose.New(sess), nil
+}
+
+// assumeRoleCredentials returns the credentials of the role c.RoleARN, assumed with the
+// credentials of c.CredentialsProvider or of the default credential chain.
+func assumeRoleCredentials(c Config) (*credentials.Credentials, error) {
+	o := newClientOptions(c)
+	o.credentials = nil
+	if c.CredentialsProvider != nil {
+		o.credentials = credentials.NewCredentials(c.CredentialsProvider)
+	}
+	sess, err := session.NewSession(o.awsConfig(c.Region))
+	if err != nil {
+		return nil, fmt.Errorf("error creating sts client: %v", err)
+	}
+	o.addHandlers(&sess.Handlers)
+	return stscreds.NewCredentials(sess, c.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		if c.ExternalID != "" {
+			p.ExternalID = aws.String(c.ExternalID)
+		}
+		if c.RoleSessionName != "" {
+			p.RoleSessionName = c.RoleSessionName
+		}
+	}), nil
+}
+
+// isExpiredTokenError returns true if `err` is AWS rejecting expired credentials, typically
+// temporary STS credentials that rotated.
+func isExpiredTokenError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case "ExpiredTokenException", "ExpiredToken", "RequestExpired", "InvalidClientTokenId":
+		return true
+	}
+	return false
+}
+
+// client returns the client of the primary stream.
+func (al *Logger) client() firehoseiface.FirehoseAPI {
+	al.clientMu.RLock()
+	defer al.clientMu.RUnlock()
+	return al.fhAPI
+}
+
+// apiFor returns the client to send to `dest` with.
+func (al *Logger) apiFor(dest destination) firehoseiface.FirehoseAPI {
+	if dest.api != nil {
+		return dest.api
+	}
+	return al.client()
+}
+
+// renewCredentials recovers from `api` being rejected for expired credentials: the
+// credentials are expired so they're fetched again, and the client of the primary stream, if
+// it's `api` and the logger created it, is rebuilt from a new session. It returns the client
+// to retry with.
+func (al *Logger) renewCredentials(dest destination, api firehoseiface.FirehoseAPI) firehoseiface.FirehoseAPI {
+	if al.credentials != nil {
+		al.credentials.Expire()
+	}
+	if dest.api != nil || al.newClient == nil {
+		return api
+	}
+	al.clientMu.Lock()
+	defer al.clientMu.Unlock()
+	if al.fhAPI != api {
+		// another sender already rebuilt it
+		return al.fhAPI
+	}
+	renewed, err := al.newClient()
+	if err != nil {
+		al.errLogger.ErrorD("renew-credentials-error", logger.M{"stream": al.fhStream, "error": err.Error()})
+		return api
+	}
+	al.fhAPI = renewed
+	al.errLogger.InfoD("renew-credentials", logger.M{"stream": al.fhStream})
+	return renewed
+}
@@ -0,0 +1,130 @@
+package analytics
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+	"github.com/eapache/go-resiliency/retrier"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// countingProvider is a credentials.Provider counting how many times credentials were fetched.
+type countingProvider struct {
+	retrieved int
+}
+
+func (p *countingProvider) Retrieve() (credentials.Value, error) {
+	p.retrieved++
+	return credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret"}, nil
+}
+
+func (p *countingProvider) IsExpired() bool {
+	return false
+}
+
+func TestRenewCredentials(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	expired := NewMockFirehoseAPI(c)
+	renewed := NewMockFirehoseAPI(c)
+	expired.EXPECT().PutRecordBatch(gomock.Any()).Return(nil,
+		awserr.New("ExpiredTokenException", "The security token included in the request is expired", nil))
+	renewed.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil)
+
+	provider := &countingProvider{}
+	creds := credentials.NewCredentials(provider)
+	_, err := creds.Get()
+	require.NoError(t, err)
+
+	errBuf := &bytes.Buffer{}
+	errLogger := logger.New("errors")
+	errLogger.SetOutput(errBuf)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		Region:      "us-west-1",
+		Credentials: creds,
+		ErrLogger:   errLogger,
+	})
+	require.NoError(t, err)
+	defer al.Close()
+	// stand in for the clients built from sessions
+	al.fhAPI = expired
+	al.newClient = func() (firehoseiface.FirehoseAPI, error) { return renewed, nil }
+
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Flush())
+	assert.Equal(t, renewed, al.client())
+
+	// the credentials were expired, so they're fetched again when next used
+	_, err = creds.Get()
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.retrieved)
+
+	lines := strings.Split(strings.TrimSpace(errBuf.String()), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"title":"renew-credentials"`)
+}
+
+func TestRequestErrorClassifier(t *testing.T) {
+	classifier := RequestErrorClassifier{}
+	assert.Equal(t, retrier.Succeed, classifier.Classify(nil))
+	assert.Equal(t, retrier.Retry, classifier.Classify(awserr.New("RequestError", "connection reset by peer", nil)))
+	assert.Equal(t, retrier.Retry, classifier.Classify(awserr.New("ExpiredTokenException", "expired", nil)))
+	assert.Equal(t, retrier.Fail, classifier.Classify(awserr.New(firehose.ErrCodeResourceNotFoundException, "no such stream", nil)))
+	assert.Equal(t, retrier.Fail, classifier.Classify(errors.New("boom")))
+}
+
+func TestRoleARN(t *testing.T) {
+	var assumeRole url.Values
+	var firehoseAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded; charset=utf-8" {
+			require.NoError(t, r.ParseForm())
+			assumeRole = r.PostForm
+			assert.Contains(t, r.Header.Get("Authorization"), "Credential=base-id/")
+			w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>
+				<AccessKeyId>role-id</AccessKeyId><SecretAccessKey>role-secret</SecretAccessKey>
+				<SessionToken>role-token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration>
+				</Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+			return
+		}
+		firehoseAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"FailedPutCount":0,"RequestResponses":[{"RecordId":"rec-1"}]}`))
+	}))
+	defer srv.Close()
+
+	al, err := New(Config{
+		Environment:         "testenv",
+		DBName:              "testdb",
+		Endpoint:            srv.URL,
+		CredentialsProvider: &credentials.StaticProvider{Value: credentials.Value{AccessKeyID: "base-id", SecretAccessKey: "base-secret"}},
+		RoleARN:             "arn:aws:iam::123456789012:role/analytics-writer",
+		ExternalID:          "ext-1",
+		RoleSessionName:     "my-service",
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, "AssumeRole", assumeRole.Get("Action"))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/analytics-writer", assumeRole.Get("RoleArn"))
+	assert.Equal(t, "ext-1", assumeRole.Get("ExternalId"))
+	assert.Equal(t, "my-service", assumeRole.Get("RoleSessionName"))
+	assert.Contains(t, firehoseAuth, "Credential=role-id/", "firehose is called with the credentials of the role")
+}
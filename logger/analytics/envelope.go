@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+)
+
+// The fields of enveloped records, see EnvelopeConfig.
+const (
+	EnvelopeTimeField    = "ts"
+	EnvelopeSchemaField  = "schema"
+	EnvelopeSourceField  = "source"
+	EnvelopePayloadField = "payload"
+)
+
+// EnvelopeConfig wraps every record in an envelope with the same top-level fields, so that
+// the tables of every stream have consistent columns:
+//
+//	{"ts": "2024-05-01T12:00:00.123Z", "schema": "1", "source": "my-service", "payload": {...}}
+//
+// ts is the time the record was written, in UTC. The partition keys of records are copied to
+// the envelope, so dynamic partitioning queries don't change.
+type EnvelopeConfig struct {
+	// Schema is the schema version of the records, e.g. "1".
+	Schema string
+	// Schemas overrides Schema for the records of some titles.
+	Schemas map[string]string
+	// Source defaults to the source of the entry, i.e. of the kayvee logger writing it.
+	Source string
+	// Fields are static fields added to every envelope, e.g. the team owning the service.
+	// They can't be named like the fields of the envelope.
+	Fields map[string]interface{}
+}
+
+func validateEnvelope(c EnvelopeConfig) error {
+	for k := range c.Fields {
+		switch k {
+		case EnvelopeTimeField, EnvelopeSchemaField, EnvelopeSourceField, EnvelopePayloadField:
+			return errors.New("envelope Fields cannot override " + k)
+		}
+	}
+	return nil
+}
+
+// wrap returns the envelope of the record `m` of the entry titled `title` from `source`.
+func (c *EnvelopeConfig) wrap(title, source string, m map[string]interface{}, partitionKeys []PartitionKey) map[string]interface{} {
+	schema, ok := c.Schemas[title]
+	if !ok {
+		schema = c.Schema
+	}
+	if c.Source != "" {
+		source = c.Source
+	}
+	e := make(map[string]interface{}, len(c.Fields)+len(partitionKeys)+4)
+	for k, v := range c.Fields {
+		e[k] = v
+	}
+	for _, k := range partitionKeys {
+		e[k.Field] = m[k.Field]
+	}
+	e[EnvelopeTimeField] = time.Now().UTC().Format(time.RFC3339Nano)
+	e[EnvelopeSchemaField] = schema
+	e[EnvelopeSourceField] = source
+	e[EnvelopePayloadField] = m
+	return e
+}
+
+// payload returns the record wrapped in `m` if records are enveloped, or `m` otherwise.
+func (al *Logger) payload(m map[string]interface{}) map[string]interface{} {
+	if al.envelope != nil {
+		if p, ok := m[EnvelopePayloadField].(map[string]interface{}); ok {
+			return p
+		}
+	}
+	return m
+}
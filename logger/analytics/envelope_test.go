@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestEnvelope(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment:   "testenv",
+		DBName:        "testdb",
+		FirehoseAPI:   mf,
+		PartitionKeys: []PartitionKey{{Field: "district_id"}},
+		Envelope: &EnvelopeConfig{
+			Schema:  "1",
+			Schemas: map[string]string{"login": "2"},
+			Fields:  map[string]interface{}{"team": "eng-infra"},
+		},
+	})
+	require.NoError(t, err)
+
+	before := time.Now().UTC()
+	al.InfoD("signup", logger.M{"district_id": "d1", "user": "u1"})
+	src := logger.New("my-service")
+	src.SetOutput(al)
+	src.InfoD("login", logger.M{"district_id": "d2"})
+	require.NoError(t, al.Close())
+
+	require.Len(t, *records, 2)
+	r := (*records)[0]
+	assert.Equal(t, "1", r["schema"])
+	assert.Equal(t, "testdb", r["source"])
+	assert.Equal(t, "eng-infra", r["team"])
+	assert.Equal(t, "d1", r["district_id"], "partition keys are copied to the envelope")
+	assert.Equal(t, map[string]interface{}{"district_id": "d1", "user": "u1"}, r["payload"])
+	ts, err := time.Parse(time.RFC3339Nano, r["ts"].(string))
+	require.NoError(t, err)
+	assert.False(t, ts.Before(before.Truncate(time.Second)))
+
+	r = (*records)[1]
+	assert.Equal(t, "2", r["schema"])
+	assert.Equal(t, "my-service", r["source"])
+}
+
+func TestEnvelopeSource(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		Envelope:    &EnvelopeConfig{Source: "billing"},
+		Oversize:    &OversizeConfig{MaxRecordBytes: 200, Action: OversizeTruncate, TruncateFields: []string{"body"}},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("email", logger.M{"body": string(make([]byte, 500))})
+	require.NoError(t, al.Close())
+
+	require.Len(t, *records, 1)
+	r := (*records)[0]
+	assert.Equal(t, "billing", r["source"])
+	payload := r["payload"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"body"}, payload["_truncated"], "enveloped records are truncated")
+}
+
+func TestEnvelopeValidation(t *testing.T) {
+	_, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: NewMockFirehoseAPI(gomock.NewController(t)),
+		Envelope:    &EnvelopeConfig{Fields: map[string]interface{}{"payload": 1}},
+	})
+	assert.EqualError(t, err, "envelope Fields cannot override payload")
+}
@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"github.com/aws/aws-sdk-go/service/firehose"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// eventBatch is the records buffered for an event type.
+type eventBatch struct {
+	records []*firehose.Record
+	bytes   int
+	// level is the highest level of the records.
+	level logger.LogLevel
+}
+
+// EventTypeStats describes the records of an event type, see Config.BatchByEventType.
+type EventTypeStats struct {
+	// Buffered is the number of records waiting to be sent.
+	Buffered int
+	// Written is the number of records written since the logger was created.
+	Written uint64
+}
+
+// bufferEvent adds `r`, at `level`, to the batch of `eventType`, and cuts the batches that
+// reached a threshold for flushPending. al.mu must be held.
+func (al *Logger) bufferEvent(eventType string, level logger.LogLevel, r *firehose.Record) {
+	b, ok := al.eventBatches[eventType]
+	if !ok {
+		b = &eventBatch{}
+		al.eventBatches[eventType] = b
+	}
+	b.records = append(b.records, r)
+	b.bytes += len(r.Data)
+	if level > b.level {
+		b.level = level
+	}
+	al.batchBytes += len(r.Data)
+	al.eventCounts[eventType]++
+	if len(b.records) >= al.flushRecords {
+		al.sendEventBatch(eventType)
+	}
+	// the byte budget is shared, so the largest batch is sent to make room rather than all of
+	// them: a burst of one event type doesn't send the others early
+	for al.batchBytes > int(0.9*float64(al.maxBatchBytes)) {
+		al.sendEventBatch(al.largestEventBatch())
+	}
+}
+
+// sendEventBatch cuts the batch of `eventType` for flushPending. al.mu must be held.
+func (al *Logger) sendEventBatch(eventType string) {
+	b, ok := al.eventBatches[eventType]
+	if !ok {
+		return
+	}
+	delete(al.eventBatches, eventType)
+	al.batchBytes -= b.bytes
+	al.cutBatch(b.records, b.level)
+}
+
+// largestEventBatch returns the event type with the most buffered bytes. al.mu must be held.
+func (al *Logger) largestEventBatch() string {
+	largest, bytes := "", -1
+	for eventType, b := range al.eventBatches {
+		if b.bytes > bytes {
+			largest, bytes = eventType, b.bytes
+		}
+	}
+	return largest
+}
+
+// eventTypeStats returns the stats of every event type written. al.mu must be held.
+func (al *Logger) eventTypeStats() map[string]EventTypeStats {
+	if al.eventBatches == nil {
+		return nil
+	}
+	stats := make(map[string]EventTypeStats, len(al.eventCounts))
+	for eventType, written := range al.eventCounts {
+		s := EventTypeStats{Written: written}
+		if b, ok := al.eventBatches[eventType]; ok {
+			s.Buffered = len(b.records)
+		}
+		stats[eventType] = s
+	}
+	return stats
+}
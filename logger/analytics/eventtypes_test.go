@@ -0,0 +1,71 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestBatchByEventType(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	sent := make(chan []string, 10)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		records := []string{}
+		for _, r := range input.Records {
+			records = append(records, strings.TrimSpace(string(r.Data)))
+		}
+		sent <- records
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).AnyTimes()
+	nextBatch := func() []string {
+		select {
+		case records := <-sent:
+			return records
+		case <-time.After(time.Second):
+			t.Fatal("batch wasn't sent")
+			return nil
+		}
+	}
+
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		FirehosePutRecordBatchMaxRecords: 3,
+		FirehosePutRecordBatchMaxBytes:   100,
+		BatchByEventType:                 true,
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	// a burst of one type is sent without the others
+	al.InfoD("quiet", logger.M{"q": 1})
+	for i := 0; i < 3; i++ {
+		al.InfoD("burst", logger.M{"b": i})
+	}
+	assert.Equal(t, []string{`{"b":0}`, `{"b":1}`, `{"b":2}`}, nextBatch())
+	assert.Equal(t, map[string]EventTypeStats{
+		"quiet": {Buffered: 1, Written: 1},
+		"burst": {Buffered: 0, Written: 3},
+	}, al.Stats().EventTypes)
+
+	// the largest batch is sent when the shared byte budget is reached
+	al.InfoD("big", logger.M{"data": strings.Repeat("x", 40)})
+	al.InfoD("big", logger.M{"data": strings.Repeat("y", 40)})
+	assert.Len(t, nextBatch(), 2)
+	assert.Equal(t, 1, al.Stats().EventTypes["quiet"].Buffered)
+
+	require.NoError(t, al.Flush())
+	assert.Equal(t, []string{`{"q":1}`}, nextBatch())
+	assert.Equal(t, EventTypeStats{Buffered: 0, Written: 1}, al.Stats().EventTypes["quiet"])
+}
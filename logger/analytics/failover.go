@@ -0,0 +1,213 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// Failover defaults, see FailoverConfig.
+const (
+	defaultFailoverFailureThreshold = 3
+	defaultFailoverProbeInterval    = time.Minute
+)
+
+// FailoverConfig configures failing over to secondary Firehose streams. Once
+// FailureThreshold batches in a row fail to be delivered to the stream in use, batches are
+// sent to the next stream of Secondaries instead, starting with the batch that failed last.
+// While a secondary is in use, the first batch after every ProbeInterval is sent to the
+// primary stream as a probe: if it's delivered, the logger fails back to the primary stream,
+// otherwise the batch is sent to the secondary as usual.
+//
+// Transitions are logged to ErrLogger as "firehose-failover" and "firehose-failback" entries.
+type FailoverConfig struct {
+	// Secondaries are the streams to fail over to, in order of priority.
+	Secondaries []FailoverStream
+	// FailureThreshold defaults to 3.
+	FailureThreshold int
+	// ProbeInterval defaults to 1 minute.
+	ProbeInterval time.Duration
+}
+
+// FailoverStream is a secondary Firehose stream.
+type FailoverStream struct {
+	// StreamName defaults to the name of the primary stream, for streams replicated in
+	// several regions.
+	StreamName string
+	// Region is the region of the stream.
+	Region string
+	// FirehoseAPI defaults to an API object configured with Region, but can be overriden here.
+	FirehoseAPI firehoseiface.FirehoseAPI
+}
+
+// destination is a stream batches can be sent to.
+type destination struct {
+	stream string
+	region string
+	api    firehoseiface.FirehoseAPI
+}
+
+// failover tracks which of its destinations batches are sent to. destinations[0] is the
+// primary stream.
+type failover struct {
+	destinations     []destination
+	failureThreshold int
+	probeInterval    time.Duration
+
+	mu        sync.Mutex
+	active    int
+	failures  int
+	lastProbe time.Time
+}
+
+// newFailover returns the failover from `primary`, whose api is the logger's client, to the
+// secondaries of `c`.
+func newFailover(primary destination, c FailoverConfig, o clientOptions) (*failover, error) {
+	if len(c.Secondaries) == 0 {
+		return nil, errors.New("failover requires at least one secondary stream")
+	}
+	f := &failover{
+		destinations:     []destination{primary},
+		failureThreshold: c.FailureThreshold,
+		probeInterval:    c.ProbeInterval,
+	}
+	if f.failureThreshold <= 0 {
+		f.failureThreshold = defaultFailoverFailureThreshold
+	}
+	if f.probeInterval <= 0 {
+		f.probeInterval = defaultFailoverProbeInterval
+	}
+	for _, s := range c.Secondaries {
+		dest := destination{stream: s.StreamName, region: s.Region, api: s.FirehoseAPI}
+		if dest.stream == "" {
+			dest.stream = primary.stream
+		}
+		if dest.api == nil {
+			if dest.region == "" {
+				return nil, errors.New("secondary streams must provide FirehoseAPI or Region")
+			}
+			api, err := newFirehoseClient(dest.region, o)
+			if err != nil {
+				return nil, err
+			}
+			dest.api = api
+		}
+		f.destinations = append(f.destinations, dest)
+	}
+	return f, nil
+}
+
+// pick returns the index of the destination in use, and whether the primary destination is
+// due for a probe.
+func (f *failover) pick(now time.Time) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active > 0 && now.Sub(f.lastProbe) >= f.probeInterval {
+		f.lastProbe = now
+		return f.active, true
+	}
+	return f.active, false
+}
+
+// succeeded records that a batch was delivered to destination `i`, and returns true if the
+// logger failed back to it.
+func (f *failover) succeeded(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i == f.active {
+		f.failures = 0
+		return false
+	}
+	if i < f.active {
+		f.active = i
+		f.failures = 0
+		return true
+	}
+	return false
+}
+
+// failed records that a batch couldn't be delivered to destination `i`. It returns the
+// destination to send the batch to instead, whether to send it there because the logger
+// failed over, and whether it's this call that failed over.
+func (f *failover) failed(i int, now time.Time) (int, bool, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i < f.active {
+		// another sender already failed over
+		return f.active, true, false
+	}
+	if i > f.active {
+		// a probe of a stream the logger isn't using anymore
+		return f.active, false, false
+	}
+	f.failures++
+	if f.failures < f.failureThreshold || f.active == len(f.destinations)-1 {
+		return f.active, false, false
+	}
+	f.active++
+	f.failures = 0
+	f.lastProbe = now
+	return f.active, true, true
+}
+
+// sendBatchWithFailover sends the records of `s` to the destination in use, failing over and
+// back as described in FailoverConfig.
+func (al *Logger) sendBatchWithFailover(ctx context.Context, s *batchSend) error {
+	f := al.failover
+	active, probe := f.pick(time.Now())
+	if probe {
+		if err := al.putBatch(ctx, f.destinations[0], s); err == nil {
+			if f.succeeded(0) {
+				al.logTransition("firehose-failback", f.destinations[active], f.destinations[0], nil)
+			}
+			return nil
+		}
+	}
+	for {
+		err := al.putBatch(ctx, f.destinations[active], s)
+		if err == nil {
+			f.succeeded(active)
+			return nil
+		}
+		next, retry, transitioned := f.failed(active, time.Now())
+		if transitioned {
+			al.logTransition("firehose-failover", f.destinations[active], f.destinations[next], err)
+		}
+		if !retry {
+			return err
+		}
+		active = next
+	}
+}
+
+// logTransition logs the logger switching from destination `from` to `to`.
+func (al *Logger) logTransition(title string, from, to destination, err error) {
+	data := logger.M{
+		"from_stream": from.stream,
+		"from_region": from.region,
+		"to_stream":   to.stream,
+		"to_region":   to.region,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		al.errLogger.WarnD(title, data)
+		return
+	}
+	al.errLogger.InfoD(title, data)
+}
+
+// ActiveStream returns the stream batches are sent to, a secondary one after failing over.
+func (al *Logger) ActiveStream() string {
+	if al.failover == nil {
+		return al.fhStream
+	}
+	f := al.failover
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.destinations[f.active].stream
+}
@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// streamMatcher matches PutRecordBatch inputs for the stream it names.
+type streamMatcher string
+
+func (m streamMatcher) Matches(x interface{}) bool {
+	input, ok := x.(*firehose.PutRecordBatchInput)
+	return ok && aws.StringValue(input.DeliveryStreamName) == string(m)
+}
+
+func (m streamMatcher) String() string {
+	return "is a batch for stream " + string(m)
+}
+
+func TestFailover(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	primary := NewMockFirehoseAPI(c)
+	secondary := NewMockFirehoseAPI(c)
+	down := awserr.New(firehose.ErrCodeResourceNotFoundException, "region down", nil)
+	ok := &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}
+	gomock.InOrder(
+		// the first batch fails, the second one fails over
+		primary.EXPECT().PutRecordBatch(streamMatcher("testenv--testdb")).Return(nil, down).Times(2),
+		secondary.EXPECT().PutRecordBatch(streamMatcher("testenv--testdb-west")).Return(ok, nil),
+		// the third batch is sent to the secondary while the primary is still down
+		secondary.EXPECT().PutRecordBatch(gomock.Any()).Return(ok, nil),
+		// the fourth batch probes the primary, which is down
+		primary.EXPECT().PutRecordBatch(gomock.Any()).Return(nil, down),
+		secondary.EXPECT().PutRecordBatch(gomock.Any()).Return(ok, nil),
+		// the fifth batch probes the primary, which recovered
+		primary.EXPECT().PutRecordBatch(gomock.Any()).Return(ok, nil),
+		// the sixth batch is sent to the primary
+		primary.EXPECT().PutRecordBatch(gomock.Any()).Return(ok, nil),
+	)
+
+	errBuf := &bytes.Buffer{}
+	errLogger := logger.New("errors")
+	errLogger.SetOutput(errBuf)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: primary,
+		ErrLogger:   errLogger,
+		Failover: &FailoverConfig{
+			Secondaries:      []FailoverStream{{StreamName: "testenv--testdb-west", Region: "us-west-2", FirehoseAPI: secondary}},
+			FailureThreshold: 2,
+			ProbeInterval:    time.Hour,
+		},
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	send := func() {
+		al.InfoD("test-title", logger.M{"foo": "bar"})
+		al.flush()
+		al.sendBatchWG.Wait()
+	}
+	send()
+	assert.Equal(t, "testenv--testdb", al.ActiveStream())
+	send()
+	assert.Equal(t, "testenv--testdb-west", al.ActiveStream())
+	send()
+
+	al.failover.probeInterval = 0
+	send()
+	assert.Equal(t, "testenv--testdb-west", al.ActiveStream())
+	send()
+	assert.Equal(t, "testenv--testdb", al.ActiveStream())
+	al.failover.probeInterval = time.Hour
+	send()
+
+	lines := strings.Split(strings.TrimSpace(errBuf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"title":"send-batch-error"`)
+	assert.Contains(t, lines[1], `"title":"firehose-failover"`)
+	assert.Contains(t, lines[1], `"to_stream":"testenv--testdb-west"`)
+	assert.Contains(t, lines[1], `"to_region":"us-west-2"`)
+	assert.Contains(t, lines[2], `"title":"firehose-failback"`)
+	assert.Contains(t, lines[2], `"to_stream":"testenv--testdb"`)
+}
+
+func TestFailoverConfigErrors(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	for _, failover := range []FailoverConfig{
+		{},
+		{Secondaries: []FailoverStream{{StreamName: "other"}}},
+	} {
+		_, err := New(Config{
+			Environment: "testenv",
+			DBName:      "testdb",
+			FirehoseAPI: NewMockFirehoseAPI(c),
+			Failover:    &failover,
+		})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,144 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// StreamTarget is an additional stream records are fanned out to, see Config.Targets.
+type StreamTarget struct {
+	// StreamName is the name of the Firehose to send to.
+	StreamName string
+	// SampleRate is the fraction of the records sent to the stream, between 0 and 1, e.g. 0.01
+	// for a sampled debugging stream. Defaults to 1, i.e. every record. Records the stream gets
+	// have their SampledCountField multiplied by the inverse of the rate.
+	SampleRate float64
+	// Disabled turns the target off, i.e. no records are sent to the stream.
+	Disabled bool
+	// FirehosePutRecordBatchMaxRecords, FirehosePutRecordBatchMaxBytes and FlushInterval
+	// override those of the Config for the batches of the stream.
+	FirehosePutRecordBatchMaxRecords int
+	FirehosePutRecordBatchMaxBytes   int
+	FlushInterval                    time.Duration
+}
+
+// fanoutTarget is a stream records are fanned out to, with a logger of its own.
+type fanoutTarget struct {
+	*Logger
+	sampleRate float64
+}
+
+// startTargets creates the loggers of the targets of `c`, which send like the logger
+// configured by `c` unless the target overrides it. Targets don't spool or fail over.
+func (al *Logger) startTargets(c Config) error {
+	for _, t := range c.Targets {
+		if t.Disabled {
+			continue
+		}
+		if t.StreamName == "" {
+			return errors.New("stream targets require a StreamName")
+		}
+		if t.SampleRate < 0 || t.SampleRate > 1 {
+			return errors.New("stream target sample rates must be between 0 and 1")
+		}
+		tc := streamConfig(c, t.StreamName)
+		if t.FirehosePutRecordBatchMaxRecords != 0 {
+			tc.FirehosePutRecordBatchMaxRecords = t.FirehosePutRecordBatchMaxRecords
+		}
+		if t.FirehosePutRecordBatchMaxBytes != 0 {
+			tc.FirehosePutRecordBatchMaxBytes = t.FirehosePutRecordBatchMaxBytes
+		}
+		if t.FlushInterval != 0 {
+			tc.FlushInterval = t.FlushInterval
+		}
+		tl, err := New(tc)
+		if err != nil {
+			al.closeTargets()
+			return err
+		}
+		rate := t.SampleRate
+		if rate == 0 {
+			rate = 1
+		}
+		al.targets = append(al.targets, fanoutTarget{Logger: tl, sampleRate: rate})
+	}
+	return nil
+}
+
+// streamConfig returns the Config of a logger sending to `stream` like the logger configured
+// by `c`, without spooling, failing over, validating or sampling records.
+func streamConfig(c Config, stream string) Config {
+	c.DBName, c.StreamName = "", stream
+	c.Targets, c.StreamSelector, c.Schema = nil, nil, nil
+	c.SampleRates, c.DefaultSampleRate = nil, 0
+	c.Spool, c.Failover = nil, nil
+	return c
+}
+
+// fanOut writes a deep copy of the record `m`, before it's processed for the stream, to the
+// targets sampling it. Their errors are logged by their loggers, and don't fail the write.
+func (al *Logger) fanOut(ctx context.Context, m map[string]interface{}) {
+	for _, t := range al.targets {
+		if t.sampleRate < 1 && rand.Float64() >= t.sampleRate {
+			continue
+		}
+		record := copyRecord(m)
+		if t.sampleRate < 1 {
+			weigh(record, t.sampleRate)
+		}
+		t.write(ctx, record, nil)
+	}
+}
+
+// copyRecord returns a deep copy of the record `m`, so that targets processing their copy in
+// place, e.g. to redact it, don't change the nested objects and arrays of `m`.
+func copyRecord(m map[string]interface{}) map[string]interface{} {
+	record := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		record[k] = copyValue(v)
+	}
+	return record
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyRecord(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = copyValue(v[i])
+		}
+		return values
+	}
+	return v
+}
+
+// eachTarget calls `f` with the logger of every target, selected stream and quarantine
+// stream, and returns the first error.
+func (al *Logger) eachTarget(f func(t *Logger) error) error {
+	var firstErr error
+	for _, t := range al.targets {
+		if err := f(t.Logger); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, sl := range al.selectedStreams() {
+		if err := f(sl); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if al.schemas != nil && al.schemas.quarantine != nil {
+		if err := f(al.schemas.quarantine); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// closeTargets closes the targets, and returns the first error.
+func (al *Logger) closeTargets() error {
+	return al.eachTarget((*Logger).Close)
+}
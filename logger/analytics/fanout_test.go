@@ -0,0 +1,82 @@
+package analytics
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// recordsByStream returns a mock Firehose API that accepts every batch, and the records it
+// received by stream, to read once the logger is closed.
+func recordsByStream(t *testing.T, c *gomock.Controller) (*MockFirehoseAPI, map[string][]map[string]interface{}) {
+	mf := NewMockFirehoseAPI(c)
+	var mu sync.Mutex
+	records := map[string][]map[string]interface{}{}
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range input.Records {
+			m := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(r.Data, &m))
+			records[*input.DeliveryStreamName] = append(records[*input.DeliveryStreamName], m)
+		}
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).AnyTimes()
+	return mf, records
+}
+
+func TestTargets(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := recordsByStream(t, c)
+
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		Targets: []StreamTarget{
+			{StreamName: "debug", FirehosePutRecordBatchMaxRecords: 1},
+			{StreamName: "sampled", SampleRate: 1e-9},
+			{StreamName: "disabled", Disabled: true},
+		},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("test-title", logger.M{"i": 1})
+	_, err = al.Write([]byte(`{"title":"test-title","i":2}`))
+	require.NoError(t, err)
+	require.NoError(t, al.Close())
+
+	expected := []map[string]interface{}{{"i": 1.0}, {"i": 2.0}}
+	assert.Equal(t, map[string][]map[string]interface{}{
+		"testenv--testdb": expected,
+		"debug":           expected,
+	}, records)
+}
+
+func TestTargetsConfig(t *testing.T) {
+	for _, test := range []struct {
+		target StreamTarget
+		err    string
+	}{
+		{StreamTarget{}, "stream targets require a StreamName"},
+		{StreamTarget{StreamName: "debug", SampleRate: 2}, "stream target sample rates must be between 0 and 1"},
+	} {
+		_, err := New(Config{
+			Environment: "testenv",
+			DBName:      "testdb",
+			Region:      "us-west-1",
+			Targets:     []StreamTarget{test.target},
+		})
+		assert.EqualError(t, err, test.err)
+	}
+}
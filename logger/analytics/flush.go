@@ -0,0 +1,112 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrFlushTimeout is returned by Flush and Close when batches are still being sent after
+// FlushTimeout.
+var ErrFlushTimeout = errors.New("timed out waiting for batches to be sent")
+
+// batchGroup counts the batches being sent, like a sync.WaitGroup that can be waited on with
+// a deadline, and concurrently with new batches being started.
+type batchGroup struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{}
+}
+
+// Add adds `delta` to the number of batches being sent.
+func (g *batchGroup) Add(delta int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.n == 0 && delta > 0 {
+		g.idle = make(chan struct{})
+	}
+	g.n += delta
+	if g.n < 0 {
+		panic("analytics: negative batchGroup counter")
+	}
+	if g.n == 0 && g.idle != nil {
+		close(g.idle)
+	}
+}
+
+// Done marks a batch as sent.
+func (g *batchGroup) Done() {
+	g.Add(-1)
+}
+
+// Wait blocks until no batches are being sent.
+func (g *batchGroup) Wait() {
+	g.WaitTimeout(0)
+}
+
+// WaitTimeout blocks until no batches are being sent, or at most `timeout` if it's positive.
+// It returns false if batches are still being sent.
+func (g *batchGroup) WaitTimeout(timeout time.Duration) bool {
+	if timeout <= 0 {
+		return g.WaitContext(context.Background())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return g.WaitContext(ctx)
+}
+
+// WaitContext blocks until no batches are being sent, or until `ctx` is done. It returns false
+// if batches are still being sent.
+func (g *batchGroup) WaitContext(ctx context.Context) bool {
+	g.mu.Lock()
+	if g.n == 0 {
+		g.mu.Unlock()
+		return true
+	}
+	idle := g.idle
+	g.mu.Unlock()
+	select {
+	case <-idle:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Flush sends the buffered records, and blocks until every batch being sent, including ones
+// sent before, has been delivered or has failed, or until FlushTimeout. Use it before the
+// process exits, or at points where events must not be lost. With Synchronous, it returns the
+// errors of the batches it sends.
+func (al *Logger) Flush() error {
+	err := al.flushBatches(context.Background(), nil)
+	if werr := al.waitForBatches(); err == nil {
+		err = werr
+	}
+	if terr := al.eachTarget((*Logger).Flush); err == nil {
+		err = terr
+	}
+	return err
+}
+
+// FlushContext is like Flush, but waits until `ctx` is done instead of FlushTimeout, and
+// returns its error if batches are still being sent. Queueing the batches doesn't block past
+// `ctx` either when the send queue is full.
+func (al *Logger) FlushContext(ctx context.Context) error {
+	err := al.flushBatches(ctx, nil)
+	if !al.sendBatchWG.WaitContext(ctx) {
+		return ctx.Err()
+	}
+	if terr := al.eachTarget(func(t *Logger) error { return t.FlushContext(ctx) }); err == nil {
+		err = terr
+	}
+	return err
+}
+
+// waitForBatches waits for the batches being sent, for at most FlushTimeout.
+func (al *Logger) waitForBatches() error {
+	if !al.sendBatchWG.WaitTimeout(al.flushTimeout) {
+		return ErrFlushTimeout
+	}
+	return nil
+}
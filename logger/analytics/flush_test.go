@@ -0,0 +1,210 @@
+package analytics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestFlush(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	release := make(chan struct{})
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		<-release
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).Times(2)
+
+	al, err := New(Config{
+		Environment:  "testenv",
+		DBName:       "testdb",
+		FirehoseAPI:  mf,
+		FlushTimeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// nothing to send
+	assert.NoError(t, al.Flush())
+
+	al.InfoD("test-title", logger.M{"i": 1})
+	assert.Equal(t, ErrFlushTimeout, al.Flush())
+
+	// the batch still being sent is waited for along with the new one
+	al.InfoD("test-title", logger.M{"i": 2})
+	close(release)
+	assert.NoError(t, al.Flush())
+	assert.NoError(t, al.Close())
+}
+
+func TestBatchGroup(t *testing.T) {
+	g := &batchGroup{}
+	assert.True(t, g.WaitTimeout(time.Millisecond))
+
+	g.Add(2)
+	assert.False(t, g.WaitTimeout(time.Millisecond))
+	g.Done()
+	go g.Done()
+	g.Wait()
+
+	// the group can be reused
+	g.Add(1)
+	assert.False(t, g.WaitTimeout(time.Millisecond))
+	g.Done()
+	assert.True(t, g.WaitTimeout(time.Millisecond))
+	assert.Panics(t, g.Done)
+}
+
+func TestFlushInterval(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	sent := make(chan int, 1)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		sent <- len(input.Records)
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	})
+
+	al, err := New(Config{
+		Environment:   "testenv",
+		DBName:        "testdb",
+		FirehoseAPI:   mf,
+		FlushInterval: 10 * time.Millisecond,
+		// the interval takes precedence
+		FirehosePutRecordBatchMaxTime: time.Hour,
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	// a partial batch is sent without waiting for more records
+	al.InfoD("test-title", logger.M{"i": 1})
+	select {
+	case n := <-sent:
+		assert.Equal(t, 1, n)
+	case <-time.After(time.Second):
+		t.Fatal("batch wasn't sent")
+	}
+}
+
+func TestFlushContext(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	// requests keep failing, so the batch is retried until it's canceled
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil,
+		awserr.New("RequestError", "connection reset by peer", nil)).AnyTimes()
+
+	sendErrors := make(chan SendError, 1)
+	al, err := New(Config{
+		Environment:  "testenv",
+		DBName:       "testdb",
+		FirehoseAPI:  mf,
+		ErrLogger:    logger.NewMockCountLogger("errors"),
+		FlushTimeout: 20 * time.Millisecond,
+		OnSendError:  func(e SendError) { sendErrors <- e },
+	})
+	require.NoError(t, err)
+
+	al.InfoD("test-title", logger.M{"i": 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, al.FlushContext(ctx))
+
+	assert.Equal(t, ErrFlushTimeout, al.Close())
+	select {
+	case e := <-sendErrors:
+		assert.Error(t, e.Err)
+	case <-time.After(time.Second):
+		t.Fatal("closing didn't cancel the batch")
+	}
+}
+
+func TestSynchronous(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	sending := false
+	calls := 0
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		assert.True(t, sending, "batches are sent by the writer")
+		calls++
+		if calls == 2 {
+			return nil, awserr.New("ValidationException", "bad", nil)
+		}
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).Times(2)
+
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		ErrLogger:                        logger.NewMockCountLogger("errors"),
+		FirehosePutRecordBatchMaxRecords: 2,
+		FlushInterval:                    time.Millisecond,
+		RetryAttempts:                    1,
+		Synchronous:                      true,
+	})
+	require.NoError(t, err)
+
+	_, err = al.Write([]byte(`{"n":1}`))
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond) // the interval doesn't flush
+	sending = true
+	_, err = al.Write([]byte(`{"n":2}`))
+	assert.NoError(t, err, "the batch is sent once it's full")
+	sending = false
+
+	_, err = al.Write([]byte(`{"n":3}`))
+	assert.NoError(t, err)
+	sending = true
+	err = al.Flush()
+	var sendErr SendError
+	require.ErrorAs(t, err, &sendErr)
+	assert.Equal(t, [][]byte{[]byte("{\"n\":3}\n")}, sendErr.Records)
+	assert.Equal(t, "ValidationException", sendErr.Err.(awserr.Error).Code())
+	assert.NoError(t, al.Close())
+}
+
+func TestSynchronousErrorOfRecord(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil, awserr.New("ValidationException", "bad", nil))
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+		FailedPutCount:   aws.Int64(0),
+		RequestResponses: []*firehose.PutRecordBatchResponseEntry{{RecordId: aws.String("rec-1")}},
+	}, nil)
+
+	al, err := New(Config{
+		Environment:                    "testenv",
+		DBName:                         "testdb",
+		FirehoseAPI:                    mf,
+		ErrLogger:                      logger.NewMockCountLogger("errors"),
+		FirehosePutRecordBatchMaxBytes: 60,
+		BatchByEventType:               true,
+		RetryAttempts:                  1,
+		Synchronous:                    true,
+	})
+	require.NoError(t, err)
+
+	r := &ackRecorder{results: map[string]error{}}
+	_, err = al.WriteAck([]byte(`{"title":"a","pad":"`+strings.Repeat("x", 40)+`"}`), r.ack("a"))
+	assert.NoError(t, err)
+	// the batch of "a" is sent to make room, but "b" is still buffered
+	_, err = al.WriteAck([]byte(`{"title":"b","n":1}`), r.ack("b"))
+	assert.NoError(t, err)
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, "ValidationException", r.results["a"].(awserr.Error).Code())
+	assert.NoError(t, r.results["b"])
+}
@@ -0,0 +1,67 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ResetAfterFork restarts the goroutines of the logger in the child of a fork, see the
+// ForkResetter of the kayvee logger package: the send workers, and the ones flushing, adapting
+// batches and replaying the spool. The records buffered, queued or being sent in the parent
+// are dropped without being acknowledged, since the parent sends them. The loggers of Targets
+// and selected streams are registered themselves, so the ResetAfterFork of the kayvee logger
+// package resets them too.
+func (al *Logger) ResetAfterFork() error {
+	select {
+	case <-al.done:
+		// closed
+		return nil
+	default:
+	}
+	// stop what's left of the goroutines of the parent
+	close(al.done)
+	al.sendingTicker.Stop()
+	if !al.pool.closed {
+		close(al.pool.queue)
+	}
+
+	al.mu, al.clientMu, al.streamsMu = sync.Mutex{}, sync.RWMutex{}, sync.Mutex{}
+	al.batch, al.batchLevels, al.batchBytes, al.pending = nil, nil, 0, nil
+	if al.eventBatches != nil {
+		al.eventBatches = map[string]*eventBatch{}
+	}
+	al.sendBatchWG = batchGroup{}
+	al.pendingAcks, al.recordAcks = sync.Map{}, sync.Map{}
+	if al.bufferCap != nil {
+		b, err := newBufferCap(al.bufferCap.max, al.bufferCap.policy)
+		if err != nil {
+			return err
+		}
+		al.bufferCap = b
+	}
+	al.sendCtx, al.cancelSends = context.WithCancel(context.Background())
+	if err := al.startSendPool(SendPoolConfig{
+		Workers:         al.pool.workers,
+		QueueSize:       cap(al.pool.queue),
+		QueueFullPolicy: al.pool.policy,
+	}); err != nil {
+		return err
+	}
+
+	al.done = make(chan struct{})
+	al.sendingTicker = time.NewTicker(al.flushInterval)
+	if al.adaptive != nil {
+		al.backoffSteps = 0
+		al.startAdaptiveBatching(*al.adaptive)
+	}
+	if al.spool != nil {
+		if err := al.startSpool(al.spool.config); err != nil {
+			return err
+		}
+	}
+	if !al.synchronous {
+		al.startFlushing()
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package analytics
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestResetAfterFork(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment:      "testenv",
+		DBName:           "testdb",
+		FirehoseAPI:      mf,
+		MaxBufferedBytes: 1024,
+	})
+	require.NoError(t, err)
+
+	var acks []error
+	al.InfoDAck("parent", logger.M{"n": 1}, func(err error) { acks = append(acks, err) })
+	require.NoError(t, logger.ResetAfterFork())
+	assert.Equal(t, 0, al.Stats().BufferedBytes, "the records of the parent are dropped")
+	al.InfoD("child", logger.M{"n": 2})
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, []map[string]interface{}{{"n": 2.0}}, *records)
+	assert.Empty(t, acks)
+}
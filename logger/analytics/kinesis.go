@@ -0,0 +1,140 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// Backend is the kind of stream records are sent to.
+type Backend string
+
+const (
+	// BackendFirehose sends records to a Firehose delivery stream with PutRecordBatch.
+	BackendFirehose Backend = "firehose"
+	// BackendKinesis sends records to a Kinesis data stream with PutRecords.
+	BackendKinesis Backend = "kinesis"
+)
+
+// partitionKeyField is the field DefaultPartitionKey reads.
+const partitionKeyField = "partition_key"
+
+// DefaultPartitionKey is the PartitionKeyFunc used by default: the "partition_key" field if
+// the record has one, a random key spreading records across shards otherwise.
+func DefaultPartitionKey(m logger.M) string {
+	if key, ok := m[partitionKeyField].(string); ok && key != "" {
+		return key
+	}
+	return fmt.Sprintf("%d", rand.Int())
+}
+
+// newKinesisClient returns a client for `region`, using the credentials of `o` if they're set
+// and the default credential chain otherwise.
+func newKinesisClient(region string, o clientOptions) (kinesisiface.KinesisAPI, error) {
+	sess, err := session.NewSession(o.awsConfig(region))
+	if err != nil {
+		return nil, fmt.Errorf("error creating kinesis client: %v", err)
+	}
+	o.addHandlers(&sess.Handlers)
+	return kinesis.New(sess), nil
+}
+
+// kinesisBackend sends batches to a Kinesis data stream. It only implements PutRecordBatch:
+// the logger doesn't call the rest of the FirehoseAPI with it.
+type kinesisBackend struct {
+	firehoseiface.FirehoseAPI
+	api          kinesisiface.KinesisAPI
+	partitionKey func(logger.M) string
+	// codec is the Compression of the records, which are decompressed to get their partition key.
+	codec logger.Codec
+}
+
+// PutRecordBatch sends the records of `input` with PutRecords, and reports the sequence
+// numbers of the delivered records as their RecordId.
+func (k *kinesisBackend) PutRecordBatch(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	entries := make([]*kinesis.PutRecordsRequestEntry, len(input.Records))
+	for i, r := range input.Records {
+		data, err := decompress(k.codec, r.Data)
+		if err != nil {
+			return nil, err
+		}
+		var m logger.M
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		entries[i] = &kinesis.PutRecordsRequestEntry{
+			Data:         r.Data,
+			PartitionKey: aws.String(k.partitionKey(m)),
+		}
+	}
+	out, err := k.api.PutRecords(&kinesis.PutRecordsInput{
+		StreamName: input.DeliveryStreamName,
+		Records:    entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &firehose.PutRecordBatchOutput{
+		FailedPutCount:   out.FailedRecordCount,
+		RequestResponses: make([]*firehose.PutRecordBatchResponseEntry, len(out.Records)),
+	}
+	for i, res := range out.Records {
+		result.RequestResponses[i] = &firehose.PutRecordBatchResponseEntry{
+			ErrorCode:    res.ErrorCode,
+			ErrorMessage: res.ErrorMessage,
+			RecordId:     res.SequenceNumber,
+		}
+	}
+	return result, nil
+}
+
+// useKinesis configures the logger to send to a Kinesis data stream.
+func (al *Logger) useKinesis(c Config) error {
+	if c.FirehoseAPI != nil || c.FirehoseClient != nil {
+		return errors.New("cannot use FirehoseAPI or FirehoseClient with the kinesis backend")
+	}
+	if c.AutoProvision != nil || c.Failover != nil {
+		return errors.New("cannot use AutoProvision or Failover with the kinesis backend")
+	}
+	partitionKey := c.PartitionKeyFunc
+	if partitionKey == nil {
+		partitionKey = DefaultPartitionKey
+	}
+	codec, err := compressionCodec(c.Compression)
+	if err != nil {
+		return err
+	}
+	if c.KinesisAPI != nil {
+		if k, ok := c.KinesisAPI.(*kinesis.Kinesis); ok {
+			newClientOptions(c).addHandlers(&k.Handlers)
+		}
+		al.fhAPI = &kinesisBackend{api: c.KinesisAPI, partitionKey: partitionKey, codec: codec}
+		return nil
+	}
+	if c.Region == "" {
+		return errors.New("must provide KinesisAPI or Region")
+	}
+	al.newClient = func() (firehoseiface.FirehoseAPI, error) {
+		api, err := newKinesisClient(c.Region, newClientOptions(c))
+		if err != nil {
+			return nil, err
+		}
+		return &kinesisBackend{api: api, partitionKey: partitionKey, codec: codec}, nil
+	}
+	api, err := al.newClient()
+	if err != nil {
+		return err
+	}
+	al.fhAPI = api
+	return nil
+}
@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// fakeKinesis records PutRecords calls, failing the first record of the first call.
+type fakeKinesis struct {
+	kinesisiface.KinesisAPI
+	inputs []*kinesis.PutRecordsInput
+}
+
+func (f *fakeKinesis) PutRecords(input *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+	f.inputs = append(f.inputs, input)
+	out := &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int64(0)}
+	for i := range input.Records {
+		if len(f.inputs) == 1 && i == 0 {
+			out.FailedRecordCount = aws.Int64(1)
+			out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+				ErrorCode: aws.String("ProvisionedThroughputExceededException"),
+			})
+			continue
+		}
+		out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{SequenceNumber: aws.String("seq")})
+	}
+	return out, nil
+}
+
+func TestKinesisBackend(t *testing.T) {
+	api := &fakeKinesis{}
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		Backend:     BackendKinesis,
+		KinesisAPI:  api,
+		PartitionKeyFunc: func(m logger.M) string {
+			return m["district"].(string)
+		},
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"district": "d1"})
+	al.InfoD("test-title", logger.M{"district": "d2"})
+	require.NoError(t, al.Close())
+
+	// the failed record is sent again
+	require.Len(t, api.inputs, 2)
+	assert.Equal(t, "testenv--testdb", aws.StringValue(api.inputs[0].StreamName))
+	keys := []string{}
+	for _, input := range api.inputs {
+		for _, r := range input.Records {
+			keys = append(keys, aws.StringValue(r.PartitionKey))
+		}
+	}
+	assert.Equal(t, []string{"d1", "d2", "d1"}, keys)
+	assert.Equal(t, `{"district":"d1"}`+"\n", string(api.inputs[1].Records[0].Data))
+}
+
+func TestKinesisBackendConfig(t *testing.T) {
+	_, err := New(Config{Environment: "testenv", DBName: "testdb", Backend: BackendKinesis})
+	assert.EqualError(t, err, "must provide KinesisAPI or Region")
+	_, err = New(Config{Environment: "testenv", DBName: "testdb", Backend: BackendKinesis, KinesisAPI: &fakeKinesis{},
+		Failover: &FailoverConfig{}})
+	assert.EqualError(t, err, "cannot use AutoProvision or Failover with the kinesis backend")
+	_, err = New(Config{Environment: "testenv", DBName: "testdb", Backend: "kafka", Region: "us-west-1"})
+	assert.EqualError(t, err, `unknown backend "kafka"`)
+}
+
+func TestDefaultPartitionKey(t *testing.T) {
+	assert.Equal(t, "k1", DefaultPartitionKey(logger.M{"partition_key": "k1"}))
+	assert.NotEmpty(t, DefaultPartitionKey(logger.M{}))
+}
@@ -0,0 +1,181 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// Outbox defaults, see OutboxConfig.
+const (
+	defaultOutboxTable        = "analytics_outbox"
+	defaultOutboxBatchSize    = 500
+	defaultOutboxPollInterval = 5 * time.Second
+)
+
+// OutboxConfig configures an Outbox.
+type OutboxConfig struct {
+	// Table is the outbox table. Defaults to "analytics_outbox". It needs an auto-incrementing
+	// integer "id" primary key and a text "record" column, e.g. in PostgreSQL:
+	//
+	//	CREATE TABLE analytics_outbox (id BIGSERIAL PRIMARY KEY, record TEXT NOT NULL);
+	Table string
+	// NumberedPlaceholders uses $1, $2... placeholders, as PostgreSQL does, instead of ?.
+	NumberedPlaceholders bool
+	// BatchSize is the number of events relayed at a time. Defaults to 500.
+	BatchSize int
+	// PollInterval is how often Relay checks for new events. Defaults to 5 seconds.
+	PollInterval time.Duration
+}
+
+// Outbox stores analytics events in the transactions of the application, and relays them to a
+// Logger once committed: events of rolled back transactions are never sent. Events are
+// delivered at least once, and deleted from the outbox once delivered. A single relay should
+// run per outbox table.
+type Outbox struct {
+	db     *sql.DB
+	config OutboxConfig
+}
+
+// NewOutbox returns an outbox stored in `db`.
+func NewOutbox(db *sql.DB, c OutboxConfig) *Outbox {
+	if c.Table == "" {
+		c.Table = defaultOutboxTable
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultOutboxBatchSize
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultOutboxPollInterval
+	}
+	return &Outbox{db: db, config: c}
+}
+
+// Add stores the event `title` with `data` in `tx`. It's relayed once `tx` is committed.
+func (o *Outbox) Add(ctx context.Context, tx *sql.Tx, title string, data logger.M) error {
+	record := make(logger.M, len(data)+1)
+	for k, v := range data {
+		record[k] = v
+	}
+	record["title"] = title
+	bs, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (record) VALUES (%s)",
+		o.config.Table, o.placeholders(1)), string(bs))
+	return err
+}
+
+// Relay sends the committed events to `al` until `ctx` is done.
+func (o *Outbox) Relay(ctx context.Context, al *Logger) error {
+	ticker := time.NewTicker(o.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		n, err := o.RelayOnce(ctx, al)
+		if err != nil && ctx.Err() == nil {
+			al.errLogger.ErrorD("outbox-relay-error", logger.M{"stream": al.fhStream, "error": err.Error()})
+		}
+		if n == o.config.BatchSize {
+			// there's likely more to send
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce sends a batch of committed events to `al`, waits for them to be delivered, and
+// deletes the delivered ones from the outbox. Events that couldn't be delivered are sent again
+// next time, and events rejected by `al` are deleted. It returns the number of events deleted.
+func (o *Outbox) RelayOnce(ctx context.Context, al *Logger) (int, error) {
+	rows, err := o.db.QueryContext(ctx, fmt.Sprintf("SELECT id, record FROM %s ORDER BY id LIMIT %d",
+		o.config.Table, o.config.BatchSize))
+	if err != nil {
+		return 0, err
+	}
+	ids, records := []int64{}, []string{}
+	for rows.Next() {
+		var id int64
+		var record string
+		if err := rows.Scan(&id, &record); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		records = append(records, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	type result struct {
+		id  int64
+		err error
+	}
+	results := make(chan result, len(ids))
+	done := []int64{}
+	pending := 0
+	for i, record := range records {
+		id := ids[i]
+		if _, err := al.WriteAck([]byte(record), func(err error) { results <- result{id, err} }); err != nil {
+			// the ack was called too, but rejected events would never be sent
+			<-results
+			al.errLogger.ErrorD("outbox-record-rejected", logger.M{"stream": al.fhStream, "id": id, "error": err.Error()})
+			done = append(done, id)
+			continue
+		}
+		pending++
+	}
+	al.flush()
+	for ; pending > 0; pending-- {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case r := <-results:
+			if r.err == nil {
+				done = append(done, r.id)
+			}
+		}
+	}
+	if err := o.delete(ctx, done); err != nil {
+		return 0, err
+	}
+	return len(done), nil
+}
+
+// delete removes the events `ids` from the outbox.
+func (o *Outbox) delete(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	_, err := o.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)",
+		o.config.Table, o.placeholders(len(ids))), args...)
+	return err
+}
+
+// placeholders returns `n` placeholders separated by commas.
+func (o *Outbox) placeholders(n int) string {
+	ps := make([]string, n)
+	for i := range ps {
+		if o.config.NumberedPlaceholders {
+			ps[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			ps[i] = "?"
+		}
+	}
+	return strings.Join(ps, ", ")
+}
@@ -0,0 +1,87 @@
+package analytics
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestOutboxAdd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	outbox := NewOutbox(db, OutboxConfig{Table: "events", NumberedPlaceholders: true})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO events (record) VALUES ($1)")).
+		WithArgs(`{"foo":"bar","title":"signup"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, outbox.Add(context.Background(), tx, "signup", logger.M{"foo": "bar"}))
+	require.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxRelay(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		require.Len(t, input.Records, 2)
+		assert.Equal(t, `{"i":1}`+"\n", string(input.Records[0].Data))
+		return &firehose.PutRecordBatchOutput{
+			FailedPutCount: aws.Int64(1),
+			RequestResponses: []*firehose.PutRecordBatchResponseEntry{
+				{RecordId: aws.String("rec-1")},
+				{ErrorCode: aws.String("InternalFailure")},
+			},
+		}, nil
+	})
+	// the failed record is sent again, and is delivered this time
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+		FailedPutCount:   aws.Int64(0),
+		RequestResponses: []*firehose.PutRecordBatchResponseEntry{{RecordId: aws.String("rec-3")}},
+	}, nil)
+
+	al, err := New(Config{
+		Environment:   "testenv",
+		DBName:        "testdb",
+		FirehoseAPI:   mf,
+		ErrLogger:     logger.NewMockCountLogger("errors"),
+		PartitionKeys: []PartitionKey{{Field: "i"}},
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	outbox := NewOutbox(db, OutboxConfig{})
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, record FROM analytics_outbox ORDER BY id LIMIT 500")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "record"}).
+			AddRow(1, `{"i":1,"title":"signup"}`).
+			AddRow(2, `{"title":"signup"}`).
+			AddRow(3, `{"i":3,"title":"signup"}`))
+	// the rejected event is deleted with the delivered ones
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM analytics_outbox WHERE id IN (?, ?, ?)")).
+		WithArgs(2, 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := outbox.RelayOnce(context.Background(), al)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
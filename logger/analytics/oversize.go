@@ -0,0 +1,252 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// firehoseMaxRecordBytes is the AWS limit on the size of a record.
+// https://docs.aws.amazon.com/firehose/latest/dev/limits.html
+const firehoseMaxRecordBytes = 1000 * 1024
+
+// OversizeAction is what Write does with records over the per-record size limit.
+type OversizeAction int
+
+const (
+	// OversizeReject rejects the record with an *OversizeRecordError. It's the default.
+	OversizeReject OversizeAction = iota
+	// OversizeTruncate shortens the string values of OversizeConfig.TruncateFields, in order,
+	// until the record fits. The truncated fields are listed in its _truncated field. Records
+	// that still don't fit are rejected.
+	OversizeTruncate
+	// OversizeSplit splits the JSON of the record into continuation records, which carry the
+	// partition keys of the record along with:
+	//
+	//   - _split_id, shared by the continuation records of a record.
+	//   - _split_index, from 0, and _split_count.
+	//   - _split_data, a part of the JSON. Concatenating them in order gives the record back.
+	//
+	// The Ack of the record is called once all of them are delivered or one is dropped.
+	OversizeSplit
+	// OversizeHandle passes the record to OversizeConfig.Handler instead of sending it, e.g.
+	// to upload it to S3 and log a reference to it. Its Ack is called with ErrNotLogged.
+	OversizeHandle
+)
+
+// OversizeHandler receives the records over the size limit with OversizeHandle, along with
+// the title of their entry and their size.
+type OversizeHandler func(title string, record map[string]interface{}, size int)
+
+// OversizeConfig configures what Write does with records over the size limit. Records fail
+// every retry otherwise, along with the batch they're sent in.
+type OversizeConfig struct {
+	// Action defaults to OversizeReject.
+	Action OversizeAction
+	// TruncateFields are the fields shortened with OversizeTruncate.
+	TruncateFields []string
+	// Handler receives the records with OversizeHandle.
+	Handler OversizeHandler
+	// MaxRecordBytes is the size limit, after compression. Defaults to the Firehose limit of
+	// 1,000 KiB.
+	MaxRecordBytes int
+}
+
+// OversizeRecordError is returned by Write for records over the size limit that can't be
+// sent.
+type OversizeRecordError struct {
+	Size, Limit int
+}
+
+func (e *OversizeRecordError) Error() string {
+	return fmt.Sprintf("record of %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
+}
+
+func validateOversize(c OversizeConfig) error {
+	switch c.Action {
+	case OversizeReject, OversizeSplit:
+	case OversizeTruncate:
+		if len(c.TruncateFields) == 0 {
+			return errors.New("OversizeTruncate requires TruncateFields")
+		}
+	case OversizeHandle:
+		if c.Handler == nil {
+			return errors.New("OversizeHandle requires a Handler")
+		}
+	default:
+		return fmt.Errorf("unknown oversize action %d", c.Action)
+	}
+	return nil
+}
+
+// writeOversize handles the record `m` of the entry titled `eventType` at `level`, whose data
+// `bs` is over the size limit, according to the OversizeConfig.
+func (al *Logger) writeOversize(ctx context.Context, eventType string, level logger.LogLevel, m map[string]interface{}, bs []byte, ack Ack) (int, error) {
+	switch al.oversize.Action {
+	case OversizeTruncate:
+		bs, err := al.truncateRecord(m, bs)
+		if err != nil {
+			return rejectRecord(ack, al.rejectOversize(eventType, err))
+		}
+		if err := al.buffer(ctx, eventType, level, bs, ack); err != nil {
+			return 0, err
+		}
+		return len(bs), nil
+	case OversizeSplit:
+		records, err := al.splitRecord(m)
+		if err != nil {
+			return rejectRecord(ack, al.rejectOversize(eventType, err))
+		}
+		if ack != nil {
+			ack = splitAck(ack, len(records))
+		}
+		n := 0
+		for _, r := range records {
+			if err := al.buffer(ctx, eventType, level, r, ack); err != nil {
+				return n, err
+			}
+			n += len(r)
+		}
+		return n, nil
+	case OversizeHandle:
+		al.oversize.Handler(eventType, m, len(bs))
+		if ack != nil {
+			ack(ErrNotLogged)
+		}
+		return 0, nil
+	}
+	return rejectRecord(ack, al.rejectOversize(eventType, &OversizeRecordError{Size: len(bs), Limit: al.oversize.MaxRecordBytes}))
+}
+
+// rejectOversize logs that a record of the entry titled `eventType` was rejected because of
+// `err`, and returns `err`.
+func (al *Logger) rejectOversize(eventType string, err error) error {
+	al.errLogger.ErrorD("oversize-record", logger.M{
+		"stream":     al.fhStream,
+		"event_type": eventType,
+		"error":      err.Error(),
+	})
+	return err
+}
+
+// truncateRecord shortens the TruncateFields of `m` until its data fits, and returns the
+// data.
+func (al *Logger) truncateRecord(m map[string]interface{}, bs []byte) ([]byte, error) {
+	limit := al.oversize.MaxRecordBytes
+	truncated := []string{}
+	payload := al.payload(m)
+	for _, field := range al.oversize.TruncateFields {
+		s, ok := payload[field].(string)
+		for ok && len(bs) > limit && s != "" {
+			// cut a little more than the excess, since escaping makes values longer in JSON
+			n := len(s) - (len(bs) - limit) - 64
+			if n < 0 {
+				n = 0
+			}
+			for n > 0 && !utf8.RuneStart(s[n]) {
+				n--
+			}
+			s = s[:n]
+			payload[field] = s
+			if len(truncated) == 0 || truncated[len(truncated)-1] != field {
+				truncated = append(truncated, field)
+				payload["_truncated"] = truncated
+			}
+			var err error
+			if bs, err = al.marshalRecord(m); err != nil {
+				return nil, err
+			}
+		}
+		if len(bs) <= limit {
+			return bs, nil
+		}
+	}
+	return nil, &OversizeRecordError{Size: len(bs), Limit: limit}
+}
+
+// splitRecord returns the data of the continuation records of `m`.
+func (al *Logger) splitRecord(m map[string]interface{}) ([][]byte, error) {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	id := newBatchID()
+	continuation := func(index, count int, part []byte) map[string]interface{} {
+		r := map[string]interface{}{
+			"_split_id":    id,
+			"_split_index": index,
+			"_split_count": count,
+			"_split_data":  string(part),
+		}
+		for _, k := range al.partitionKeys {
+			r[k.Field] = m[k.Field]
+		}
+		return r
+	}
+
+	// find the parts with an upper bound of the count, so that the records can only get
+	// smaller once it's known
+	limit := al.oversize.MaxRecordBytes
+	parts := [][]byte{}
+	for rest := line; len(rest) > 0; {
+		n := len(rest)
+		for {
+			for n < len(rest) && n > 0 && !utf8.RuneStart(rest[n]) {
+				n--
+			}
+			if n == 0 {
+				return nil, &OversizeRecordError{Size: len(line), Limit: limit}
+			}
+			bs, err := al.marshalRecord(continuation(len(parts), len(line), rest[:n]))
+			if err != nil {
+				return nil, err
+			}
+			if len(bs) <= limit {
+				break
+			}
+			// cut a little more than the excess, since escaping makes the part longer in JSON
+			n -= len(bs) - limit + 64
+			if n < 0 {
+				n = 0
+			}
+		}
+		parts = append(parts, rest[:n])
+		rest = rest[n:]
+	}
+
+	records := make([][]byte, 0, len(parts))
+	for i, part := range parts {
+		bs, err := al.marshalRecord(continuation(i, len(parts), part))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, bs)
+	}
+	return records, nil
+}
+
+// splitAck returns an Ack calling `ack` once it's called `n` times, or as soon as it's called
+// with an error.
+func splitAck(ack Ack, n int) Ack {
+	var (
+		mu   sync.Mutex
+		done bool
+	)
+	return func(err error) {
+		mu.Lock()
+		n--
+		call := !done && (err != nil || n == 0)
+		if call {
+			done = true
+		}
+		mu.Unlock()
+		if call {
+			ack(err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// deliveredRecords returns a mock FirehoseAPI accepting every batch, and the decoded records
+// it received.
+func deliveredRecords(t *testing.T, c *gomock.Controller) (*MockFirehoseAPI, *[]map[string]interface{}) {
+	mf := NewMockFirehoseAPI(c)
+	records := []map[string]interface{}{}
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		for _, r := range input.Records {
+			m := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(r.Data, &m))
+			records = append(records, m)
+		}
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).AnyTimes()
+	return mf, &records
+}
+
+func TestOversizeReject(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		Oversize:    &OversizeConfig{MaxRecordBytes: 100},
+	})
+	require.NoError(t, err)
+
+	_, err = al.Write([]byte(`{"title":"big","payload":"` + strings.Repeat("x", 200) + `"}`))
+	assert.Equal(t, &OversizeRecordError{Size: 215, Limit: 100}, err)
+	_, err = al.Write([]byte(`{"title":"small","payload":"x"}`))
+	assert.NoError(t, err)
+	require.NoError(t, al.Close())
+	assert.Equal(t, []map[string]interface{}{{"payload": "x"}}, *records)
+}
+
+func TestOversizeTruncate(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		Oversize: &OversizeConfig{
+			Action:         OversizeTruncate,
+			TruncateFields: []string{"missing", "body", "stack"},
+			MaxRecordBytes: 200,
+		},
+	})
+	require.NoError(t, err)
+
+	// escaped characters take more room in JSON
+	_, err = al.Write([]byte(`{"title":"big","id":1,"body":"` + strings.Repeat(`é\"`, 100) + `"}`))
+	assert.NoError(t, err)
+	_, err = al.Write([]byte(`{"title":"big","id":2,"body":"a","stack":"` + strings.Repeat("x", 1000) + `"}`))
+	assert.NoError(t, err)
+	// records that can't be truncated enough are rejected
+	_, err = al.Write([]byte(`{"title":"big","id":3,"other":"` + strings.Repeat("x", 1000) + `"}`))
+	assert.IsType(t, &OversizeRecordError{}, err)
+	require.NoError(t, al.Close())
+
+	require.Len(t, *records, 2)
+	body := (*records)[0]["body"].(string)
+	assert.True(t, strings.HasPrefix(strings.Repeat(`é"`, 100), body))
+	assert.NotEmpty(t, body)
+	assert.Equal(t, []interface{}{"body"}, (*records)[0]["_truncated"])
+	// fields are truncated in order
+	assert.Equal(t, "", (*records)[1]["body"])
+	assert.NotEmpty(t, (*records)[1]["stack"])
+	assert.Equal(t, []interface{}{"body", "stack"}, (*records)[1]["_truncated"])
+	for _, r := range *records {
+		bs, _ := json.Marshal(r)
+		assert.True(t, len(bs) < 200)
+	}
+}
+
+func TestOversizeSplit(t *testing.T) {
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			mf := NewMockFirehoseAPI(c)
+			data := [][]byte{}
+			mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+				out := &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}
+				for _, r := range input.Records {
+					assert.True(t, len(r.Data) <= 300)
+					data = append(data, r.Data)
+					out.RequestResponses = append(out.RequestResponses, &firehose.PutRecordBatchResponseEntry{RecordId: aws.String("id")})
+				}
+				return out, nil
+			}).AnyTimes()
+			al, err := New(Config{
+				Environment:   "testenv",
+				DBName:        "testdb",
+				FirehoseAPI:   mf,
+				ErrLogger:     logger.NewMockCountLogger("errors"),
+				Compression:   compression,
+				PartitionKeys: []PartitionKey{{Field: "team"}},
+				Oversize:      &OversizeConfig{Action: OversizeSplit, MaxRecordBytes: 300},
+			})
+			require.NoError(t, err)
+
+			// random enough not to fit compressed
+			payload := strings.Repeat(`<"ü">`, 10)
+			for i := 0; i < 80; i++ {
+				payload += string(rune('a' + i*7%26))
+				payload += newBatchID()[:i%8]
+			}
+			acks := []error{}
+			al.InfoDAck("big", logger.M{"team": "eng", "payload": payload}, func(err error) { acks = append(acks, err) })
+			require.NoError(t, al.Close())
+			assert.Equal(t, []error{nil}, acks)
+
+			require.True(t, len(data) > 1)
+			joined := ""
+			for i, d := range data {
+				d, err := decompress(al.codec, d)
+				require.NoError(t, err)
+				r := map[string]interface{}{}
+				require.NoError(t, json.Unmarshal(d, &r))
+				assert.Equal(t, "eng", r["team"])
+				assert.Equal(t, float64(i), r["_split_index"])
+				assert.Equal(t, float64(len(data)), r["_split_count"])
+				joined += r["_split_data"].(string)
+			}
+			record := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal([]byte(joined), &record))
+			assert.Equal(t, map[string]interface{}{"team": "eng", "payload": payload}, record)
+		})
+	}
+}
+
+func TestOversizeHandle(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	handled := []string{}
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		Oversize: &OversizeConfig{
+			Action:         OversizeHandle,
+			MaxRecordBytes: 100,
+			Handler: func(title string, record map[string]interface{}, size int) {
+				assert.Equal(t, 214, size)
+				handled = append(handled, title+":"+record["id"].(string))
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var acked error
+	al.InfoDAck("big", logger.M{"id": "a", "payload": strings.Repeat("x", 190)}, func(err error) { acked = err })
+	require.NoError(t, al.Close())
+	assert.Equal(t, []string{"big:a"}, handled)
+	assert.Equal(t, ErrNotLogged, acked)
+	assert.Empty(t, *records)
+}
+
+func TestSplitAck(t *testing.T) {
+	calls := []error{}
+	ack := splitAck(func(err error) { calls = append(calls, err) }, 3)
+	ack(nil)
+	ack(nil)
+	assert.Empty(t, calls)
+	ack(nil)
+	assert.Equal(t, []error{nil}, calls)
+
+	calls = nil
+	boom := errors.New("boom")
+	ack = splitAck(func(err error) { calls = append(calls, err) }, 3)
+	ack(nil)
+	ack(boom)
+	ack(nil)
+	assert.Equal(t, []error{boom}, calls)
+}
+
+func TestOversizeConfig(t *testing.T) {
+	for _, test := range []struct {
+		c   OversizeConfig
+		err string
+	}{
+		{OversizeConfig{Action: OversizeTruncate}, "OversizeTruncate requires TruncateFields"},
+		{OversizeConfig{Action: OversizeHandle}, "OversizeHandle requires a Handler"},
+		{OversizeConfig{Action: 7}, "unknown oversize action 7"},
+	} {
+		_, err := New(Config{Environment: "testenv", DBName: "testdb", Region: "us-west-1", Oversize: &test.c})
+		assert.EqualError(t, err, test.err)
+	}
+}
@@ -0,0 +1,108 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// PartitionKey is a field Firehose dynamic partitioning reads from every record, e.g. with a
+// `.event_date` JQ query.
+type PartitionKey struct {
+	// Field is the name of the field.
+	Field string
+	// Derive, when set, computes the field for records that don't have it. Records without
+	// the field are rejected otherwise.
+	Derive PartitionDeriver
+}
+
+// PartitionDeriver computes a partition key for `record`, and returns false if it can't.
+type PartitionDeriver func(record map[string]interface{}) (interface{}, bool)
+
+// DeriveCurrentDate derives partition keys from the current UTC time formatted with
+// `layout`, e.g. "2006-01-02".
+func DeriveCurrentDate(layout string) PartitionDeriver {
+	return func(map[string]interface{}) (interface{}, bool) {
+		return time.Now().UTC().Format(layout), true
+	}
+}
+
+// DeriveDateFrom derives partition keys from the RFC 3339 time in the field `timeField`,
+// formatted in UTC with `layout`.
+func DeriveDateFrom(timeField, layout string) PartitionDeriver {
+	return func(record map[string]interface{}) (interface{}, bool) {
+		s, ok := record[timeField].(string)
+		if !ok {
+			return nil, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, false
+		}
+		return t.UTC().Format(layout), true
+	}
+}
+
+// DeriveConstant derives the partition key `v` for every record, e.g. the team owning the
+// service.
+func DeriveConstant(v interface{}) PartitionDeriver {
+	return func(map[string]interface{}) (interface{}, bool) {
+		return v, true
+	}
+}
+
+// MissingPartitionKeyError is returned by Write for records missing a partition key that
+// can't be derived, or whose value isn't a string, number or boolean. Such records would be
+// delivered to the error output of the stream.
+type MissingPartitionKeyError struct {
+	Field string
+}
+
+func (e *MissingPartitionKeyError) Error() string {
+	return fmt.Sprintf("record has no valid partition key %q", e.Field)
+}
+
+func validatePartitionKeys(keys []PartitionKey) error {
+	for _, k := range keys {
+		if k.Field == "" {
+			return errors.New("partition keys must have a Field")
+		}
+	}
+	return nil
+}
+
+// ensurePartitionKeys derives the missing partition keys of `record`, and returns an error if
+// one is missing or invalid.
+func (al *Logger) ensurePartitionKeys(record map[string]interface{}) error {
+	for _, k := range al.partitionKeys {
+		v, ok := record[k.Field]
+		if !ok && k.Derive != nil {
+			if v, ok = k.Derive(record); ok {
+				record[k.Field] = v
+			}
+		}
+		if !ok || !isPartitionValue(v) {
+			err := &MissingPartitionKeyError{Field: k.Field}
+			al.errLogger.ErrorD("missing-partition-key", logger.M{
+				"stream": al.fhStream,
+				"field":  k.Field,
+				"error":  err.Error(),
+			})
+			return err
+		}
+	}
+	return nil
+}
+
+// isPartitionValue returns true if `v` can be a partition key.
+func isPartitionValue(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return v != ""
+	case bool, float64, float32, int, int64, int32, uint, uint64, uint32:
+		return true
+	}
+	return false
+}
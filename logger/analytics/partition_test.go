@@ -0,0 +1,74 @@
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestPartitionKeys(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	var sent [][]byte
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		for _, r := range input.Records {
+			sent = append(sent, r.Data)
+		}
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	})
+
+	errBuf := &bytes.Buffer{}
+	errLogger := logger.New("errors")
+	errLogger.SetOutput(errBuf)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   errLogger,
+		PartitionKeys: []PartitionKey{
+			{Field: "event_date", Derive: DeriveDateFrom("time", "2006-01-02")},
+			{Field: "team", Derive: DeriveConstant("eng")},
+			{Field: "user"},
+		},
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	_, err = al.Write([]byte(`{"time":"2020-03-04T23:30:00-02:00","user":"u1"}`))
+	require.NoError(t, err)
+	_, err = al.Write([]byte(`{"event_date":"2020-01-01","team":"data","user":"u2"}`))
+	require.NoError(t, err)
+
+	_, err = al.Write([]byte(`{"time":"2020-03-04T23:30:00Z"}`))
+	assert.Equal(t, &MissingPartitionKeyError{Field: "user"}, err)
+	_, err = al.Write([]byte(`{"user":"u3"}`))
+	assert.Equal(t, &MissingPartitionKeyError{Field: "event_date"}, err)
+	_, err = al.Write([]byte(`{"time":"2020-03-04T23:30:00Z","user":{"id":"u4"}}`))
+	assert.Equal(t, &MissingPartitionKeyError{Field: "user"}, err)
+	assert.Equal(t, 3, strings.Count(errBuf.String(), `"title":"missing-partition-key"`))
+
+	require.NoError(t, al.Flush())
+	assert.Equal(t, [][]byte{
+		[]byte(`{"event_date":"2020-03-05","team":"eng","time":"2020-03-04T23:30:00-02:00","user":"u1"}` + "\n"),
+		[]byte(`{"event_date":"2020-01-01","team":"data","user":"u2"}` + "\n"),
+	}, sent)
+}
+
+func TestPartitionKeysConfig(t *testing.T) {
+	_, err := New(Config{
+		Environment:   "testenv",
+		DBName:        "testdb",
+		Region:        "us-west-1",
+		PartitionKeys: []PartitionKey{{Derive: DeriveCurrentDate("2006-01-02")}},
+	})
+	assert.EqualError(t, err, "partition keys must have a Field")
+}
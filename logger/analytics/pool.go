@@ -0,0 +1,257 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/firehose"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// Send pool defaults, see SendPoolConfig.
+const (
+	defaultSendWorkers   = 4
+	defaultSendQueueSize = 16
+)
+
+// QueueFullPolicy is what happens to a batch when the send queue is full.
+type QueueFullPolicy string
+
+const (
+	// QueueFullBlock blocks writing until there's room in the queue.
+	QueueFullBlock QueueFullPolicy = "block"
+	// QueueFullDropOldest drops the batch queued first to make room.
+	QueueFullDropOldest QueueFullPolicy = "drop-oldest"
+	// QueueFullDropNewest drops the batch being queued.
+	QueueFullDropNewest QueueFullPolicy = "drop-newest"
+)
+
+// ErrSendQueueFull is the error of batches dropped because the send queue was full.
+var ErrSendQueueFull = errors.New("send queue is full")
+
+// SendPoolConfig configures the workers sending batches, which bound the memory held by
+// batches and the concurrent requests made when writing faster than the stream accepts.
+type SendPoolConfig struct {
+	// Workers is the number of batches sent concurrently. Defaults to 4.
+	Workers int
+	// QueueSize is the number of batches waiting for a worker. Defaults to 16.
+	QueueSize int
+	// QueueFullPolicy defaults to QueueFullBlock.
+	QueueFullPolicy QueueFullPolicy
+}
+
+// sendJob is a batch waiting for a worker.
+type sendJob struct {
+	batchID string
+	batch   []*firehose.Record
+	// level is the highest level of the records of the batch, see DropOldest.
+	level logger.LogLevel
+}
+
+// droppedJob is a batch dropped because of err.
+type droppedJob struct {
+	job sendJob
+	err error
+}
+
+// sendPool is the queue of the workers sending batches.
+type sendPool struct {
+	queue   chan sendJob
+	policy  QueueFullPolicy
+	workers int
+	// submitting is held while batches are queued, so that they're queued in order without
+	// al.mu held. It's taken before al.mu.
+	submitting chan struct{}
+	// closed is set when the logger is closed, with submitting and al.mu held.
+	closed bool
+	// dropped are the batches dropped while submitting is held, and unqueued the ones cut
+	// once closed, see endSubmitting.
+	dropped  []droppedJob
+	unqueued []sendJob
+
+	// limit is the number of workers allowed to send at once, lowered while Firehose throttles
+	mu     sync.Mutex
+	cond   *sync.Cond
+	active int
+	limit  int
+}
+
+// startSendPool starts the workers configured by `c`.
+func (al *Logger) startSendPool(c SendPoolConfig) error {
+	workers, queueSize, policy := c.Workers, c.QueueSize, c.QueueFullPolicy
+	if workers <= 0 {
+		workers = defaultSendWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultSendQueueSize
+	}
+	switch policy {
+	case "":
+		policy = QueueFullBlock
+	case QueueFullBlock, QueueFullDropOldest, QueueFullDropNewest:
+	default:
+		return fmt.Errorf("unknown queue full policy %q", policy)
+	}
+	p := &sendPool{
+		queue:      make(chan sendJob, queueSize),
+		policy:     policy,
+		workers:    workers,
+		submitting: make(chan struct{}, 1),
+		limit:      workers,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	al.pool = p
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range p.queue {
+				al.work(job)
+			}
+		}()
+	}
+	return nil
+}
+
+// work sends the batch of `job` once the workers are allowed to.
+func (al *Logger) work(job sendJob) {
+	p := al.pool
+	p.acquire()
+	defer p.release()
+	al.send(job)
+}
+
+// acquire blocks until the worker is allowed to send.
+func (p *sendPool) acquire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.active >= p.limit {
+		p.cond.Wait()
+	}
+	p.active++
+}
+
+func (p *sendPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+	p.cond.Signal()
+}
+
+// setLimit sets the number of workers allowed to send at once.
+func (p *sendPool) setLimit(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = n
+	p.cond.Broadcast()
+}
+
+// submitPending queues the batches of al.pending for the workers, in order. When the queue is
+// full and writes block, it returns once `ctx` is done, leaving the batches not queued yet to a
+// later flush.
+func (al *Logger) submitPending(ctx context.Context) {
+	p := al.pool
+	select {
+	case p.submitting <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer al.endSubmitting()
+	al.mu.Lock()
+	jobs := al.pending
+	al.pending = nil
+	al.mu.Unlock()
+	for i, job := range jobs {
+		if !al.submit(ctx, job) {
+			al.mu.Lock()
+			al.pending = append(jobs[i:len(jobs):len(jobs)], al.pending...)
+			al.mu.Unlock()
+			return
+		}
+	}
+}
+
+// submit queues `job` for the workers, as the queue full policy says, and returns false if
+// `ctx` is done before there's room in the queue. p.submitting must be held.
+func (al *Logger) submit(ctx context.Context, job sendJob) bool {
+	p := al.pool
+	if p.closed {
+		// the workers are gone, but writing after closing used to be fine, so the batch is
+		// sent by the writing goroutine
+		p.unqueued = append(p.unqueued, job)
+		return true
+	}
+	switch p.policy {
+	case QueueFullDropNewest:
+		select {
+		case p.queue <- job:
+		default:
+			al.unbuffer(job.batch)
+			p.dropped = append(p.dropped, droppedJob{job, ErrSendQueueFull})
+		}
+	case QueueFullDropOldest:
+		for {
+			select {
+			case p.queue <- job:
+				return true
+			default:
+			}
+			select {
+			case oldest := <-p.queue:
+				al.unbuffer(oldest.batch)
+				p.dropped = append(p.dropped, droppedJob{oldest, ErrSendQueueFull})
+			default:
+			}
+		}
+	default:
+		select {
+		case p.queue <- job:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// endSubmitting releases p.submitting, then drops the batches dropped while it was held and
+// sends the ones cut once closed from the calling goroutine, so that OnSendError and acks can
+// write.
+func (al *Logger) endSubmitting() {
+	p := al.pool
+	dropped, unqueued := p.dropped, p.unqueued
+	p.dropped, p.unqueued = nil, nil
+	<-p.submitting
+	for _, d := range dropped {
+		al.dropJob(d.job, d.err)
+	}
+	for _, job := range unqueued {
+		al.send(job)
+	}
+}
+
+// dropJob drops the batch of `job` because of `err`, e.g. since the queue is full. It must be
+// called without al.mu or p.submitting held, so that OnSendError and acks can write.
+func (al *Logger) dropJob(job sendJob, err error) {
+	defer al.sendBatchWG.Done()
+	al.sendFailed(job.batchID, &batchSend{records: job.batch}, err)
+	al.errLogger.ErrorD("send-batch-dropped", logger.M{
+		"stream":   al.fhStream,
+		"batch_id": job.batchID,
+		"records":  len(job.batch),
+		"error":    err.Error(),
+	})
+}
+
+// stopSendPool stops the workers once the queued batches are sent.
+func (al *Logger) stopSendPool() {
+	p := al.pool
+	p.submitting <- struct{}{}
+	defer func() { <-p.submitting }()
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+}
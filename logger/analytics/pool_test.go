@@ -0,0 +1,196 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestSendPool(t *testing.T) {
+	for _, test := range []struct {
+		policy  QueueFullPolicy
+		dropped string
+	}{
+		{QueueFullDropNewest, `{"i":3}` + "\n"},
+		{QueueFullDropOldest, `{"i":2}` + "\n"},
+	} {
+		t.Run(string(test.policy), func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			mf := NewMockFirehoseAPI(c)
+			started := make(chan struct{}, 3)
+			release := make(chan struct{})
+			mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+				started <- struct{}{}
+				<-release
+				return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+			}).Times(2)
+
+			var mu sync.Mutex
+			sendErrors := []SendError{}
+			al, err := New(Config{
+				Environment:                      "testenv",
+				DBName:                           "testdb",
+				FirehoseAPI:                      mf,
+				FirehosePutRecordBatchMaxRecords: 1,
+				ErrLogger:                        logger.NewMockCountLogger("errors"),
+				SendPool:                         &SendPoolConfig{Workers: 1, QueueSize: 1, QueueFullPolicy: test.policy},
+				OnSendError: func(e SendError) {
+					mu.Lock()
+					defer mu.Unlock()
+					sendErrors = append(sendErrors, e)
+				},
+			})
+			require.NoError(t, err)
+
+			// the worker is busy with the first batch, and the second one is queued
+			al.InfoD("test-title", logger.M{"i": 1})
+			<-started
+			al.InfoD("test-title", logger.M{"i": 2})
+			al.InfoD("test-title", logger.M{"i": 3})
+			mu.Lock()
+			assert.Len(t, sendErrors, 1, "the writing goroutine reports the dropped batch")
+			mu.Unlock()
+			close(release)
+			require.NoError(t, al.Close())
+
+			require.Len(t, sendErrors, 1)
+			assert.Equal(t, ErrSendQueueFull, sendErrors[0].Err)
+			assert.Equal(t, [][]byte{[]byte(test.dropped)}, sendErrors[0].Records)
+		})
+	}
+}
+
+func TestSendPoolBlock(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	release := make(chan struct{})
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		<-release
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).Times(3)
+
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		FirehosePutRecordBatchMaxRecords: 1,
+		SendPool:                         &SendPoolConfig{Workers: 1, QueueSize: 1},
+	})
+	require.NoError(t, err)
+
+	written := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			al.InfoD("test-title", logger.M{"i": i})
+		}
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("writing didn't block on the full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-written
+	require.NoError(t, al.Close())
+}
+
+func TestSendPoolBlockContext(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	sent := []string{}
+	mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, string(input.Records[0].Data))
+		return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}, nil
+	}).Times(4)
+
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		FirehosePutRecordBatchMaxRecords: 1,
+		SendPool:                         &SendPoolConfig{Workers: 1, QueueSize: 1},
+	})
+	require.NoError(t, err)
+
+	// the worker is busy with the first batch, the second one is queued, and the third one
+	// blocks its writer
+	al.InfoD("test-title", logger.M{"i": 1})
+	al.InfoD("test-title", logger.M{"i": 2})
+	written := make(chan struct{})
+	go func() {
+		al.InfoD("test-title", logger.M{"i": 3})
+		close(written)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// the blocked writer doesn't hold the logger
+	stats := make(chan Stats)
+	go func() { stats <- al.Stats() }()
+	select {
+	case <-stats:
+	case <-time.After(time.Second):
+		t.Fatal("the blocked writer holds the logger")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = al.WriteContext(ctx, []byte(`{"title":"test-title","i":4}`))
+	assert.NoError(t, err)
+	assert.Equal(t, context.DeadlineExceeded, al.FlushContext(ctx))
+
+	close(release)
+	<-written
+	require.NoError(t, al.Close())
+	assert.Equal(t, []string{
+		`{"i":1}` + "\n",
+		`{"i":2}` + "\n",
+		`{"i":3}` + "\n",
+		`{"i":4}` + "\n",
+	}, sent)
+}
+
+func TestSendPoolAfterClose(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment:                      "testenv",
+		DBName:                           "testdb",
+		FirehoseAPI:                      mf,
+		FirehosePutRecordBatchMaxRecords: 1,
+		ErrLogger:                        logger.NewMockCountLogger("errors"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, al.Close())
+
+	// the batch is sent by the writing goroutine
+	al.InfoD("test-title", logger.M{"i": 1})
+	assert.Equal(t, []map[string]interface{}{{"i": 1.0}}, *records)
+}
+
+func TestSendPoolConfig(t *testing.T) {
+	_, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		Region:      "us-west-1",
+		SendPool:    &SendPoolConfig{QueueFullPolicy: "drop-random"},
+	})
+	assert.EqualError(t, err, `unknown queue full policy "drop-random"`)
+}
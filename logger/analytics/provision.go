@@ -0,0 +1,212 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+)
+
+// Auto-provisioning defaults, see AutoProvisionConfig and ParquetConfig.
+const (
+	defaultProvisionActiveTimeout = 5 * time.Minute
+	defaultParquetBufferSize      = 128
+	minParquetBufferSize          = 64
+)
+
+var defaultProvisionEnvironments = []string{"dev", "development", "test", "testing", "local"}
+
+// provisionPollInterval is how often the status of a created stream is checked.
+var provisionPollInterval = 5 * time.Second
+
+// AutoProvisionConfig configures creating the delivery stream when it doesn't exist, so that
+// ephemeral environments don't need a Firehose set up by hand. Streams are only created in
+// Environments; elsewhere a missing stream is left to fail as usual.
+//
+// Created streams deliver to S3 through BucketARN and RoleARN, and are tagged with Tags along
+// with "created-by: kayvee-go" and the "environment". New blocks until the stream is active.
+type AutoProvisionConfig struct {
+	// Environments defaults to dev, development, test, testing and local.
+	Environments []string
+	// BucketARN is the S3 bucket records are delivered to. Required.
+	BucketARN string
+	// RoleARN is the IAM role Firehose assumes to write to the bucket. Required.
+	RoleARN string
+	// Prefix is the S3 prefix of the delivered objects. Defaults to "<stream name>/".
+	Prefix string
+	// Tags are added to the created stream.
+	Tags map[string]string
+	// ActiveTimeout bounds how long New waits for a created stream to become active. Defaults
+	// to 5 minutes.
+	ActiveTimeout time.Duration
+	// Parquet, when set, makes the created stream deliver Parquet files instead of ndjson.
+	Parquet *ParquetConfig
+}
+
+// ParquetConfig makes Firehose buffer records and convert them to Parquet files before
+// delivering them to S3, so that no downstream job has to convert the ndjson objects. The
+// schema is declared by a Glue table: Firehose can't infer it from the records, and drops
+// the fields the table doesn't have. Records must be sent uncompressed, i.e. with
+// CompressionNone.
+type ParquetConfig struct {
+	// DatabaseName and TableName are the Glue table declaring the schema. Required.
+	DatabaseName string
+	TableName    string
+	// Region is the region of the Glue catalog. Defaults to the region of the stream.
+	Region string
+	// RoleARN is the IAM role Firehose assumes to read the table. Defaults to the RoleARN of
+	// the AutoProvisionConfig.
+	RoleARN string
+	// BufferSize is the size in MiB records are buffered up to before a file is written, at
+	// least 64. Defaults to 128.
+	BufferSize int
+	// BufferInterval is how long records are buffered at most before a file is written, between
+	// 1 and 15 minutes. Defaults to the Firehose default of 5 minutes.
+	BufferInterval time.Duration
+}
+
+// destination returns the S3 destination delivering Parquet files to the bucket and prefix
+// of `s3`.
+func (c ParquetConfig) destination(s3 *firehose.ExtendedS3DestinationConfiguration) (*firehose.ExtendedS3DestinationConfiguration, error) {
+	if c.DatabaseName == "" || c.TableName == "" {
+		return nil, errors.New("Parquet requires DatabaseName and TableName")
+	}
+	size := c.BufferSize
+	if size == 0 {
+		size = defaultParquetBufferSize
+	}
+	if size < minParquetBufferSize {
+		return nil, fmt.Errorf("Parquet BufferSize must be at least %d MiB", minParquetBufferSize)
+	}
+	role := c.RoleARN
+	if role == "" {
+		role = aws.StringValue(s3.RoleARN)
+	}
+	schema := &firehose.SchemaConfiguration{
+		DatabaseName: aws.String(c.DatabaseName),
+		TableName:    aws.String(c.TableName),
+		RoleARN:      aws.String(role),
+	}
+	if c.Region != "" {
+		schema.Region = aws.String(c.Region)
+	}
+	s3.BufferingHints = &firehose.BufferingHints{SizeInMBs: aws.Int64(int64(size))}
+	if c.BufferInterval != 0 {
+		s3.BufferingHints.IntervalInSeconds = aws.Int64(int64(c.BufferInterval / time.Second))
+	}
+	// Parquet files are compressed by the serializer, and can't be compressed again
+	s3.CompressionFormat = aws.String(firehose.CompressionFormatUncompressed)
+	s3.DataFormatConversionConfiguration = &firehose.DataFormatConversionConfiguration{
+		Enabled: aws.Bool(true),
+		InputFormatConfiguration: &firehose.InputFormatConfiguration{
+			Deserializer: &firehose.Deserializer{OpenXJsonSerDe: &firehose.OpenXJsonSerDe{}},
+		},
+		OutputFormatConfiguration: &firehose.OutputFormatConfiguration{
+			Serializer: &firehose.Serializer{ParquetSerDe: &firehose.ParquetSerDe{
+				Compression: aws.String(firehose.ParquetCompressionSnappy),
+			}},
+		},
+		SchemaConfiguration: schema,
+	}
+	return s3, nil
+}
+
+// provisionStream creates `stream` as configured by `c` if it doesn't exist and `env` is one
+// of the configured environments, and waits for it to be active.
+func provisionStream(api firehoseiface.FirehoseAPI, stream, env string, c AutoProvisionConfig) error {
+	environments := c.Environments
+	if len(environments) == 0 {
+		environments = defaultProvisionEnvironments
+	}
+	allowed := false
+	for _, e := range environments {
+		if e == env {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil
+	}
+	if c.BucketARN == "" || c.RoleARN == "" {
+		return errors.New("auto-provisioning requires BucketARN and RoleARN")
+	}
+
+	_, err := api.DescribeDeliveryStream(&firehose.DescribeDeliveryStreamInput{
+		DeliveryStreamName: aws.String(stream),
+	})
+	if err == nil {
+		return nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != firehose.ErrCodeResourceNotFoundException {
+		return fmt.Errorf("error describing stream %s: %v", stream, err)
+	}
+
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = stream + "/"
+	}
+	tags := map[string]string{"created-by": "kayvee-go", "environment": env}
+	for k, v := range c.Tags {
+		tags[k] = v
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s3 := &firehose.ExtendedS3DestinationConfiguration{
+		BucketARN:         aws.String(c.BucketARN),
+		RoleARN:           aws.String(c.RoleARN),
+		Prefix:            aws.String(prefix),
+		CompressionFormat: aws.String(firehose.CompressionFormatGzip),
+	}
+	if c.Parquet != nil {
+		if s3, err = c.Parquet.destination(s3); err != nil {
+			return err
+		}
+	}
+	input := &firehose.CreateDeliveryStreamInput{
+		DeliveryStreamName:                 aws.String(stream),
+		DeliveryStreamType:                 aws.String(firehose.DeliveryStreamTypeDirectPut),
+		ExtendedS3DestinationConfiguration: s3,
+	}
+	for _, k := range keys {
+		input.Tags = append(input.Tags, &firehose.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	if _, err := api.CreateDeliveryStream(input); err != nil {
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != firehose.ErrCodeResourceInUseException {
+			return fmt.Errorf("error creating stream %s: %v", stream, err)
+		}
+		// another process is creating it
+	}
+
+	timeout := c.ActiveTimeout
+	if timeout <= 0 {
+		timeout = defaultProvisionActiveTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := api.DescribeDeliveryStream(&firehose.DescribeDeliveryStreamInput{
+			DeliveryStreamName: aws.String(stream),
+		})
+		if err == nil {
+			switch status := aws.StringValue(out.DeliveryStreamDescription.DeliveryStreamStatus); status {
+			case firehose.DeliveryStreamStatusActive:
+				return nil
+			case firehose.DeliveryStreamStatusCreating:
+			default:
+				return fmt.Errorf("stream %s is %s", stream, status)
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for stream %s to become active", stream)
+		}
+		time.Sleep(provisionPollInterval)
+	}
+}
@@ -0,0 +1,129 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func describeOutput(status string) *firehose.DescribeDeliveryStreamOutput {
+	return &firehose.DescribeDeliveryStreamOutput{
+		DeliveryStreamDescription: &firehose.DeliveryStreamDescription{
+			DeliveryStreamStatus: aws.String(status),
+		},
+	}
+}
+
+func TestAutoProvision(t *testing.T) {
+	defer func(interval time.Duration) { provisionPollInterval = interval }(provisionPollInterval)
+	provisionPollInterval = time.Millisecond
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	gomock.InOrder(
+		mf.EXPECT().DescribeDeliveryStream(gomock.Any()).Return(nil,
+			awserr.New(firehose.ErrCodeResourceNotFoundException, "not found", nil)),
+		mf.EXPECT().CreateDeliveryStream(gomock.Any()).DoAndReturn(func(input *firehose.CreateDeliveryStreamInput) (*firehose.CreateDeliveryStreamOutput, error) {
+			assert.Equal(t, "test--testdb", aws.StringValue(input.DeliveryStreamName))
+			s3 := input.ExtendedS3DestinationConfiguration
+			assert.Equal(t, "arn:aws:s3:::bucket", aws.StringValue(s3.BucketARN))
+			assert.Equal(t, "arn:aws:iam::123:role/firehose", aws.StringValue(s3.RoleARN))
+			assert.Equal(t, "test--testdb/", aws.StringValue(s3.Prefix))
+			tags := map[string]string{}
+			for _, tag := range input.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			assert.Equal(t, map[string]string{"created-by": "kayvee-go", "environment": "test", "team": "eng"}, tags)
+			return &firehose.CreateDeliveryStreamOutput{}, nil
+		}),
+		mf.EXPECT().DescribeDeliveryStream(gomock.Any()).Return(describeOutput(firehose.DeliveryStreamStatusCreating), nil),
+		mf.EXPECT().DescribeDeliveryStream(gomock.Any()).Return(describeOutput(firehose.DeliveryStreamStatusActive), nil),
+	)
+
+	al, err := New(Config{
+		Environment: "test",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		AutoProvision: &AutoProvisionConfig{
+			BucketARN: "arn:aws:s3:::bucket",
+			RoleARN:   "arn:aws:iam::123:role/firehose",
+			Tags:      map[string]string{"team": "eng"},
+		},
+	})
+	require.NoError(t, err)
+	al.Close()
+}
+
+func TestAutoProvisionSkipped(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	config := &AutoProvisionConfig{BucketARN: "arn:aws:s3:::bucket", RoleARN: "arn:aws:iam::123:role/firehose"}
+
+	// streams aren't created outside of the configured environments
+	al, err := New(Config{Environment: "production", DBName: "testdb", FirehoseAPI: mf, AutoProvision: config})
+	require.NoError(t, err)
+	al.Close()
+
+	// existing streams are left alone
+	mf.EXPECT().DescribeDeliveryStream(gomock.Any()).Return(describeOutput(firehose.DeliveryStreamStatusActive), nil)
+	al, err = New(Config{Environment: "dev", DBName: "testdb", FirehoseAPI: mf, AutoProvision: config})
+	require.NoError(t, err)
+	al.Close()
+
+	_, err = New(Config{Environment: "dev", DBName: "testdb", FirehoseAPI: mf, AutoProvision: &AutoProvisionConfig{}})
+	assert.EqualError(t, err, "auto-provisioning requires BucketARN and RoleARN")
+}
+
+func TestAutoProvisionParquet(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	notFound := awserr.New(firehose.ErrCodeResourceNotFoundException, "not found", nil)
+	gomock.InOrder(
+		mf.EXPECT().DescribeDeliveryStream(gomock.Any()).Return(nil, notFound),
+		mf.EXPECT().CreateDeliveryStream(gomock.Any()).DoAndReturn(func(input *firehose.CreateDeliveryStreamInput) (*firehose.CreateDeliveryStreamOutput, error) {
+			s3 := input.ExtendedS3DestinationConfiguration
+			assert.Equal(t, firehose.CompressionFormatUncompressed, aws.StringValue(s3.CompressionFormat))
+			assert.Equal(t, int64(128), aws.Int64Value(s3.BufferingHints.SizeInMBs))
+			assert.Equal(t, int64(600), aws.Int64Value(s3.BufferingHints.IntervalInSeconds))
+			conversion := s3.DataFormatConversionConfiguration
+			assert.True(t, aws.BoolValue(conversion.Enabled))
+			assert.NotNil(t, conversion.InputFormatConfiguration.Deserializer.OpenXJsonSerDe)
+			assert.NotNil(t, conversion.OutputFormatConfiguration.Serializer.ParquetSerDe)
+			assert.Equal(t, &firehose.SchemaConfiguration{
+				DatabaseName: aws.String("analytics"),
+				TableName:    aws.String("events"),
+				RoleARN:      aws.String("arn:aws:iam::123:role/firehose"),
+			}, conversion.SchemaConfiguration)
+			return &firehose.CreateDeliveryStreamOutput{}, nil
+		}),
+		mf.EXPECT().DescribeDeliveryStream(gomock.Any()).Return(describeOutput(firehose.DeliveryStreamStatusActive), nil),
+	)
+	config := &AutoProvisionConfig{
+		BucketARN: "arn:aws:s3:::bucket",
+		RoleARN:   "arn:aws:iam::123:role/firehose",
+		Parquet:   &ParquetConfig{DatabaseName: "analytics", TableName: "events", BufferInterval: 10 * time.Minute},
+	}
+	al, err := New(Config{Environment: "test", DBName: "testdb", FirehoseAPI: mf, AutoProvision: config})
+	require.NoError(t, err)
+	al.Close()
+
+	_, err = New(Config{Environment: "test", DBName: "testdb", FirehoseAPI: mf, AutoProvision: config, Compression: CompressionGzip})
+	assert.EqualError(t, err, "cannot use Compression with Parquet")
+
+	mf.EXPECT().DescribeDeliveryStream(gomock.Any()).Return(nil, notFound).Times(2)
+	config.Parquet = &ParquetConfig{TableName: "events"}
+	_, err = New(Config{Environment: "test", DBName: "testdb", FirehoseAPI: mf, AutoProvision: config})
+	assert.EqualError(t, err, "Parquet requires DatabaseName and TableName")
+	config.Parquet = &ParquetConfig{DatabaseName: "analytics", TableName: "events", BufferSize: 32}
+	_, err = New(Config{Environment: "test", DBName: "testdb", FirehoseAPI: mf, AutoProvision: config})
+	assert.EqualError(t, err, "Parquet BufferSize must be at least 64 MiB")
+}
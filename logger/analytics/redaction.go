@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// RedactionAction is what is done to redacted values.
+type RedactionAction string
+
+const (
+	// RedactMask replaces redacted values with RedactionConfig.Replacement.
+	RedactMask RedactionAction = "mask"
+	// RedactHash replaces redacted values with their hex HMAC-SHA256, keyed with
+	// RedactionConfig.HashKey, so that records can still be joined or counted on them.
+	RedactHash RedactionAction = "hash"
+)
+
+// Patterns of common PII, for RedactionConfig.Patterns.
+const (
+	EmailPattern = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+	SSNPattern   = `\b\d{3}-\d{2}-\d{4}\b`
+)
+
+const defaultRedactionReplacement = "[REDACTED]"
+
+// RedactionConfig configures the values redacted from records before they leave the process.
+// Stats reports the number of values redacted.
+type RedactionConfig struct {
+	// Fields are the names of the fields whose values are redacted, in nested objects too.
+	Fields []string
+	// Patterns are regular expressions whose matches are redacted in string values, e.g.
+	// EmailPattern.
+	Patterns []string
+	// Action defaults to RedactMask.
+	Action RedactionAction
+	// Replacement replaces values with RedactMask. Defaults to "[REDACTED]".
+	Replacement string
+	// HashKey keys the hashes of RedactHash. Without it, values that are easy to guess, e.g.
+	// phone numbers, can be recovered from their hash.
+	HashKey []byte
+}
+
+// redactor redacts the values covered by a RedactionConfig.
+type redactor struct {
+	fields      map[string]bool
+	patterns    []*regexp.Regexp
+	action      RedactionAction
+	replacement string
+	hashKey     []byte
+
+	redactions uint64
+}
+
+func newRedactor(c RedactionConfig) (*redactor, error) {
+	r := &redactor{fields: map[string]bool{}, action: c.Action, replacement: c.Replacement, hashKey: c.HashKey}
+	switch r.action {
+	case "":
+		r.action = RedactMask
+	case RedactMask, RedactHash:
+	default:
+		return nil, fmt.Errorf("unknown redaction action %q", r.action)
+	}
+	if r.replacement == "" {
+		r.replacement = defaultRedactionReplacement
+	}
+	for _, f := range c.Fields {
+		r.fields[f] = true
+	}
+	for _, pattern := range c.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern '%s': %s", pattern, err.Error())
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// redact redacts the values of `m` covered by the policy, in place.
+func (r *redactor) redact(m map[string]interface{}) {
+	for k, v := range m {
+		if r.fields[k] {
+			m[k] = r.replace(v)
+			continue
+		}
+		m[k] = r.redactValue(v)
+	}
+}
+
+func (r *redactor) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		for _, re := range r.patterns {
+			v = re.ReplaceAllStringFunc(v, func(match string) string {
+				return r.replace(match).(string)
+			})
+		}
+		return v
+	case map[string]interface{}:
+		r.redact(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = r.redactValue(v[i])
+		}
+	}
+	return v
+}
+
+// replace returns the redacted value of `v`, and counts it.
+func (r *redactor) replace(v interface{}) interface{} {
+	atomic.AddUint64(&r.redactions, 1)
+	if r.action == RedactMask {
+		return r.replacement
+	}
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+	h := hmac.New(sha256.New, r.hashKey)
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestRedactionMask(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		Redaction: &RedactionConfig{
+			Fields:   []string{"name", "phone"},
+			Patterns: []string{EmailPattern, SSNPattern},
+		},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("signup", logger.M{
+		"name":    "Ada Lovelace",
+		"note":    "contact ada@example.com or bob@example.org, ssn 123-45-6789",
+		"user_id": 42,
+		"guardian": map[string]interface{}{
+			"phone":  5551234,
+			"emails": []interface{}{"grace@example.com", "none"},
+		},
+	})
+	require.NoError(t, al.Close())
+
+	require.Len(t, *records, 1)
+	assert.Equal(t, map[string]interface{}{
+		"name":    "[REDACTED]",
+		"note":    "contact [REDACTED] or [REDACTED], ssn [REDACTED]",
+		"user_id": 42.0,
+		"guardian": map[string]interface{}{
+			"phone":  "[REDACTED]",
+			"emails": []interface{}{"[REDACTED]", "none"},
+		},
+	}, (*records)[0])
+	assert.Equal(t, uint64(6), al.Stats().Redactions)
+}
+
+func TestRedactionHash(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		Redaction: &RedactionConfig{
+			Fields:  []string{"email"},
+			Action:  RedactHash,
+			HashKey: []byte("secret"),
+		},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("login", logger.M{"email": "ada@example.com"})
+	al.InfoD("login", logger.M{"email": "ada@example.com"})
+	require.NoError(t, al.Close())
+
+	h := hmac.New(sha256.New, []byte("secret"))
+	h.Write([]byte("ada@example.com"))
+	require.Len(t, *records, 2)
+	assert.Equal(t, hex.EncodeToString(h.Sum(nil)), (*records)[0]["email"])
+	assert.Equal(t, (*records)[0], (*records)[1], "hashes can be joined on")
+}
+
+func TestRedactionTargets(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := recordsByStream(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		Targets:     []StreamTarget{{StreamName: "debug"}},
+		Redaction: &RedactionConfig{
+			Fields:  []string{"email"},
+			Action:  RedactHash,
+			HashKey: []byte("secret"),
+		},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("login", logger.M{"user": map[string]interface{}{"email": "ada@example.com"}})
+	stats := al.Stats()
+	require.NoError(t, al.Close())
+
+	// every stream gets the record hashed once
+	h := hmac.New(sha256.New, []byte("secret"))
+	h.Write([]byte("ada@example.com"))
+	expected := []map[string]interface{}{{"user": map[string]interface{}{"email": hex.EncodeToString(h.Sum(nil))}}}
+	assert.Equal(t, map[string][]map[string]interface{}{
+		"testenv--testdb": expected,
+		"debug":           expected,
+	}, records)
+	assert.Equal(t, uint64(1), stats.Redactions)
+}
+
+func TestRedactionValidation(t *testing.T) {
+	for _, rc := range []RedactionConfig{
+		{Action: "encrypt"},
+		{Patterns: []string{"("}},
+	} {
+		_, err := New(Config{
+			Environment: "testenv",
+			DBName:      "testdb",
+			FirehoseAPI: NewMockFirehoseAPI(gomock.NewController(t)),
+			Redaction:   &rc,
+		})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger/filter"
+)
+
+// defaultReplayTimeField is the field Replay reads entry times from.
+const defaultReplayTimeField = "timestamp"
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	// Titles restricts the replay to entries with one of these titles. All entries are
+	// replayed when empty.
+	Titles []string
+	// TimeField is the field holding the time of an entry, either as an RFC 3339 string
+	// or as seconds since the epoch. Defaults to "timestamp".
+	TimeField string
+	// Since and Until restrict the replay to entries in [Since, Until). A zero value leaves
+	// that side unbounded. Entries without a parseable time are skipped when either is set.
+	Since time.Time
+	Until time.Time
+	// Filter restricts the replay to entries matching the expression. All entries are
+	// replayed when nil.
+	Filter *filter.Expr
+	// RatePerSecond limits how many entries are replayed per second. Unlimited when 0.
+	RatePerSecond float64
+}
+
+// ReplayStats describes the outcome of a Replay.
+type ReplayStats struct {
+	// Read is the number of lines read.
+	Read int
+	// Replayed is the number of entries written to the destination.
+	Replayed int
+	// Skipped is the number of entries that didn't match the filters.
+	Skipped int
+	// Invalid is the number of lines that weren't kayvee JSON.
+	Invalid int
+}
+
+// Replay reads newline-delimited kayvee JSON from `r` and writes the entries matching
+// `opts` to `w`, usually a *Logger, so that they go through the regular batching and are
+// delivered to its Firehose stream. It's meant for backfilling an ark db after an outage.
+// Replay stops early if `ctx` is canceled. The caller is responsible for closing `w` to
+// flush the last batch.
+func Replay(ctx context.Context, r io.Reader, w io.Writer, opts ReplayOptions) (ReplayStats, error) {
+	stats := ReplayStats{}
+	timeField := opts.TimeField
+	if timeField == "" {
+		timeField = defaultReplayTimeField
+	}
+	titles := map[string]bool{}
+	for _, t := range opts.Titles {
+		titles[t] = true
+	}
+	var throttle <-chan time.Time
+	if opts.RatePerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.RatePerSecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	scanner := bufio.NewScanner(r)
+	// kayvee lines can be much longer than bufio's default 64KB limit
+	scanner.Buffer(make([]byte, 0, 64*1024), firehosePutRecordBatchMaxBytes)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		stats.Read++
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			stats.Invalid++
+			continue
+		}
+		if len(titles) > 0 {
+			if title, _ := entry["title"].(string); !titles[title] {
+				stats.Skipped++
+				continue
+			}
+		}
+		if opts.Filter != nil && !opts.Filter.MatchMap(entry) {
+			stats.Skipped++
+			continue
+		}
+		if !opts.Since.IsZero() || !opts.Until.IsZero() {
+			t, ok := replayEntryTime(entry[timeField])
+			if !ok || (!opts.Since.IsZero() && t.Before(opts.Since)) ||
+				(!opts.Until.IsZero() && !t.Before(opts.Until)) {
+				stats.Skipped++
+				continue
+			}
+		}
+
+		if throttle != nil {
+			select {
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			case <-throttle:
+			}
+		}
+		if _, err := w.Write(line); err != nil {
+			return stats, fmt.Errorf("error replaying line %d: %v", stats.Read, err)
+		}
+		stats.Replayed++
+	}
+	return stats, scanner.Err()
+}
+
+func replayEntryTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		return parsed, err == nil
+	case float64:
+		sec := int64(t)
+		return time.Unix(sec, int64((t-float64(sec))*float64(time.Second))), true
+	}
+	return time.Time{}, false
+}
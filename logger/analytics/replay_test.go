@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger/filter"
+)
+
+const replayInput = `{"title":"signup","timestamp":"2024-01-01T10:00:00Z","user":"a"}
+{"title":"login","timestamp":"2024-01-01T11:00:00Z","user":"b"}
+not json
+
+{"title":"signup","timestamp":1704108600,"user":"c"}
+{"title":"signup","user":"d"}
+`
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          ReplayOptions
+		expectedUsers []string
+		expectedStats ReplayStats
+	}{
+		{
+			name:          "replays everything",
+			opts:          ReplayOptions{},
+			expectedUsers: []string{"a", "b", "c", "d"},
+			expectedStats: ReplayStats{Read: 5, Replayed: 4, Invalid: 1},
+		},
+		{
+			name:          "filters by title",
+			opts:          ReplayOptions{Titles: []string{"signup"}},
+			expectedUsers: []string{"a", "c", "d"},
+			expectedStats: ReplayStats{Read: 5, Replayed: 3, Skipped: 1, Invalid: 1},
+		},
+		{
+			name: "filters by time range",
+			opts: ReplayOptions{
+				Since: time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+				Until: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			},
+			expectedUsers: []string{"b", "c"},
+			expectedStats: ReplayStats{Read: 5, Replayed: 2, Skipped: 2, Invalid: 1},
+		},
+		{
+			name:          "filters by expression",
+			opts:          ReplayOptions{Filter: filter.MustParse(`title==signup && user!~"[ab]"`)},
+			expectedUsers: []string{"c", "d"},
+			expectedStats: ReplayStats{Read: 5, Replayed: 2, Skipped: 2, Invalid: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			stats, err := Replay(context.Background(), strings.NewReader(replayInput), lineWriter{out}, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStats, stats)
+			users := []string{}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				i := strings.Index(line, `"user":"`)
+				require.True(t, i >= 0, line)
+				users = append(users, line[i+8:i+9])
+			}
+			assert.Equal(t, tt.expectedUsers, users)
+		})
+	}
+}
+
+func TestReplayRateLimitAndCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Replay(ctx, strings.NewReader(replayInput), lineWriter{&bytes.Buffer{}}, ReplayOptions{RatePerSecond: 1})
+	assert.Equal(t, context.Canceled, err)
+
+	start := time.Now()
+	stats, err := Replay(context.Background(), strings.NewReader(replayInput), lineWriter{&bytes.Buffer{}}, ReplayOptions{RatePerSecond: 100})
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Replayed)
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+}
+
+// lineWriter separates written lines with newlines, like a Logger's batches do.
+type lineWriter struct {
+	buf *bytes.Buffer
+}
+
+func (w lineWriter) Write(bs []byte) (int, error) {
+	w.buf.Write(bs)
+	w.buf.WriteByte('\n')
+	return len(bs), nil
+}
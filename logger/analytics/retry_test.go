@@ -0,0 +1,95 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/eapache/go-resiliency/retrier"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// retryAll retries every error.
+type retryAll struct{}
+
+func (retryAll) Classify(err error) retrier.Action {
+	if err == nil {
+		return retrier.Succeed
+	}
+	return retrier.Retry
+}
+
+func TestRetryPolicy(t *testing.T) {
+	resetErr := awserr.New("RequestError", "connection reset by peer", nil)
+	noStream := awserr.New(firehose.ErrCodeResourceNotFoundException, "no such stream", nil)
+	for _, test := range []struct {
+		desc  string
+		err   error
+		calls int
+		c     Config
+	}{
+		{"retried as configured", resetErr, 3, Config{RetryAttempts: 2}},
+		{"retries disabled", resetErr, 1, Config{RetryAttempts: -1}},
+		{"not retried by default", noStream, 1, Config{}},
+		{"retried by the classifier", noStream, 6, Config{RetryClassifier: retryAll{}}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+			mf := NewMockFirehoseAPI(c)
+			mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil, test.err).Times(test.calls)
+
+			config := test.c
+			config.Environment, config.DBName, config.FirehoseAPI = "testenv", "testdb", mf
+			config.ErrLogger = logger.NewMockCountLogger("errors")
+			config.RetryBaseDelay = time.Millisecond
+			sendErrors := []SendError{}
+			config.OnSendError = func(e SendError) { sendErrors = append(sendErrors, e) }
+			al, err := New(config)
+			require.NoError(t, err)
+
+			al.InfoD("test-title", logger.M{"foo": "bar"})
+			require.NoError(t, al.Close())
+			require.Len(t, sendErrors, 1)
+			assert.Equal(t, test.err, sendErrors[0].Err)
+			assert.Equal(t, test.calls, sendErrors[0].Attempts)
+		})
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	// the record keeps failing within the batch
+	mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+		FailedPutCount:   aws.Int64(1),
+		RequestResponses: []*firehose.PutRecordBatchResponseEntry{{ErrorCode: aws.String("InternalFailure")}},
+	}, nil).MinTimes(1)
+
+	sendErrors := []SendError{}
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		SendTimeout: 20 * time.Millisecond,
+		// so the deadline is reached while sending, not while throttled
+		FirehoseMaxRequestsPerSecond: 1e9,
+		OnSendError:                  func(e SendError) { sendErrors = append(sendErrors, e) },
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Close())
+	assert.True(t, time.Since(start) < time.Second)
+	require.Len(t, sendErrors, 1)
+	assert.EqualError(t, sendErrors[0].Err, "timed out sending events: 1 remaining")
+}
@@ -0,0 +1,67 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// SampledCountField is the field of sampled records holding the number of entries each one
+// stands for, i.e. the inverse of its sample rate, so that downstream aggregations can
+// re-weight them, e.g. SUM(_sampled_count) instead of COUNT(*). Records sampled by both the
+// logger and one of its Config.Targets stand for the product of the inverses of both rates.
+const SampledCountField = "_sampled_count"
+
+// sampler decides which records are sent, by title.
+type sampler struct {
+	rates       map[string]float64
+	defaultRate float64
+}
+
+// newSampler returns the sampler of the rates of `c`, or nil if every record is sent.
+func newSampler(c Config) (*sampler, error) {
+	s := &sampler{rates: c.SampleRates, defaultRate: c.DefaultSampleRate}
+	if s.defaultRate == 0 {
+		s.defaultRate = 1
+	}
+	if s.defaultRate < 0 || s.defaultRate > 1 {
+		return nil, errors.New("DefaultSampleRate must be between 0 and 1")
+	}
+	for title, rate := range s.rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("the sample rate of %q must be between 0 and 1", title)
+		}
+	}
+	if len(s.rates) == 0 && s.defaultRate == 1 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// sample returns false if the record `m` of the entry titled `title` is sampled out. Records
+// sampled in get the SampledCountField.
+func (s *sampler) sample(title string, m map[string]interface{}) bool {
+	rate, ok := s.rates[title]
+	if !ok {
+		rate = s.defaultRate
+	}
+	if rate >= 1 {
+		return true
+	}
+	if rate == 0 || rand.Float64() >= rate {
+		return false
+	}
+	weigh(m, rate)
+	return true
+}
+
+// weigh multiplies the SampledCountField of the record `m`, sampled in at `rate`, by the
+// inverse of the rate, so that records sampled again, e.g. by the targets of Config.Targets,
+// stand for every entry dropped along the way.
+func weigh(m map[string]interface{}, rate float64) {
+	count, ok := m[SampledCountField].(float64)
+	if !ok {
+		count = 1
+	}
+	m[SampledCountField] = count / rate
+}
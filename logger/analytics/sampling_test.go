@@ -0,0 +1,92 @@
+package analytics
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestSampling(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := deliveredRecords(t, c)
+	al, err := New(Config{
+		Environment:       "testenv",
+		DBName:            "testdb",
+		FirehoseAPI:       mf,
+		ErrLogger:         logger.NewMockCountLogger("errors"),
+		SampleRates:       map[string]float64{"telemetry": 0.25, "never": 0, "always": 1},
+		DefaultSampleRate: 0.5,
+	})
+	require.NoError(t, err)
+
+	acked := 0
+	for i := 0; i < 1000; i++ {
+		al.InfoD("telemetry", logger.M{})
+		al.InfoD("other", logger.M{})
+		al.InfoDAck("never", logger.M{}, func(err error) {
+			assert.Equal(t, ErrNotLogged, err)
+			acked++
+		})
+	}
+	al.InfoD("always", logger.M{})
+	require.NoError(t, al.Close())
+	assert.Equal(t, 1000, acked)
+
+	counts := map[float64]int{}
+	for _, r := range *records {
+		count, _ := r[SampledCountField].(float64)
+		counts[count]++
+	}
+	assert.Equal(t, 1, counts[0], "records sent at a rate of 1 don't have the field")
+	assert.InDelta(t, 250, counts[4], 75)
+	assert.InDelta(t, 500, counts[2], 100)
+	assert.Len(t, counts, 3)
+}
+
+func TestSamplingTargets(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := recordsByStream(t, c)
+	al, err := New(Config{
+		Environment:       "testenv",
+		DBName:            "testdb",
+		FirehoseAPI:       mf,
+		ErrLogger:         logger.NewMockCountLogger("errors"),
+		DefaultSampleRate: 0.5,
+		Targets: []StreamTarget{
+			{StreamName: "all"},
+			{StreamName: "sampled", SampleRate: 0.1},
+		},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 4000; i++ {
+		al.InfoD("telemetry", logger.M{})
+	}
+	require.NoError(t, al.Close())
+
+	for stream, count := range map[string]float64{"testenv--testdb": 2, "all": 2, "sampled": 20} {
+		sum := 0.0
+		for _, r := range records[stream] {
+			assert.Equal(t, count, r[SampledCountField], stream)
+			sum += r[SampledCountField].(float64)
+		}
+		assert.InDelta(t, 4000, sum, 1200, stream)
+	}
+}
+
+func TestSamplingConfig(t *testing.T) {
+	s, err := newSampler(Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = newSampler(Config{DefaultSampleRate: 1.5})
+	assert.EqualError(t, err, "DefaultSampleRate must be between 0 and 1")
+	_, err = newSampler(Config{SampleRates: map[string]float64{"t": -1}})
+	assert.EqualError(t, err, `the sample rate of "t" must be between 0 and 1`)
+}
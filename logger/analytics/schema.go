@@ -0,0 +1,138 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// SchemaAction is what Write does with records that don't match the schema of their title.
+type SchemaAction int
+
+const (
+	// SchemaReject rejects the record with a *SchemaValidationError. It's the default.
+	SchemaReject SchemaAction = iota
+	// SchemaQuarantine sends the record to SchemaConfig.QuarantineStream instead, with the
+	// _schema_title and _schema_errors fields, so that it can be fixed and replayed without
+	// breaking the loads of the stream.
+	SchemaQuarantine
+)
+
+// SchemaConfig configures validating records against JSON Schemas before they're buffered.
+type SchemaConfig struct {
+	// Schemas are the JSON Schemas of the records, by the title of their entry. Records of other
+	// titles aren't validated. They describe the record as sent, without the ignored fields.
+	Schemas map[string]string
+	// Action defaults to SchemaReject.
+	Action SchemaAction
+	// QuarantineStream is the Firehose stream of the invalid records with SchemaQuarantine.
+	QuarantineStream string
+	// OnFailure, when set, is called with every record that doesn't match its schema, from the
+	// goroutine writing it.
+	OnFailure func(*SchemaValidationError)
+}
+
+// SchemaValidationError describes a record that doesn't match the schema of its title.
+type SchemaValidationError struct {
+	Title  string
+	Record map[string]interface{}
+	// Errors describe how the record doesn't match, e.g. "count: Invalid type. Expected:
+	// integer, given: string".
+	Errors []string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("record of %q doesn't match its schema: %s", e.Title, strings.Join(e.Errors, "; "))
+}
+
+// schemaValidator validates records against the schemas of their title.
+type schemaValidator struct {
+	schemas    map[string]*gojsonschema.Schema
+	action     SchemaAction
+	quarantine *Logger
+	onFailure  func(*SchemaValidationError)
+}
+
+// newSchemaValidator compiles the schemas of `c`, and creates the logger of the quarantine
+// stream from the Config `lc`.
+func newSchemaValidator(c SchemaConfig, lc Config) (*schemaValidator, error) {
+	v := &schemaValidator{
+		schemas:   map[string]*gojsonschema.Schema{},
+		action:    c.Action,
+		onFailure: c.OnFailure,
+	}
+	for title, schema := range c.Schemas {
+		s, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema for %q: %s", title, err)
+		}
+		v.schemas[title] = s
+	}
+	switch c.Action {
+	case SchemaReject:
+	case SchemaQuarantine:
+		if c.QuarantineStream == "" {
+			return nil, errors.New("SchemaQuarantine requires a QuarantineStream")
+		}
+		q, err := New(streamConfig(lc, c.QuarantineStream))
+		if err != nil {
+			return nil, err
+		}
+		v.quarantine = q
+	default:
+		return nil, fmt.Errorf("unknown schema action %d", c.Action)
+	}
+	return v, nil
+}
+
+// validate returns the error of the record `m` of the entry titled `title` if it doesn't
+// match its schema. The ignored fields of `m` aren't validated.
+func (al *Logger) validate(title string, m map[string]interface{}) *SchemaValidationError {
+	schema, ok := al.schemas.schemas[title]
+	if !ok {
+		return nil
+	}
+	record := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		record[k] = v
+	}
+	for _, f := range al.ignoredFields {
+		delete(record, f)
+	}
+	result, err := schema.Validate(gojsonschema.NewGoLoader(record))
+	if err != nil {
+		return &SchemaValidationError{Title: title, Record: record, Errors: []string{err.Error()}}
+	}
+	if result.Valid() {
+		return nil
+	}
+	errs := make([]string, len(result.Errors()))
+	for i, e := range result.Errors() {
+		errs[i] = e.String()
+	}
+	return &SchemaValidationError{Title: title, Record: record, Errors: errs}
+}
+
+// writeInvalid handles the record `m` that doesn't match its schema as configured.
+func (al *Logger) writeInvalid(ctx context.Context, m map[string]interface{}, invalid *SchemaValidationError, ack Ack) (int, error) {
+	if al.schemas.onFailure != nil {
+		al.schemas.onFailure(invalid)
+	}
+	al.errLogger.ErrorD("schema-validation-failed", logger.M{
+		"stream":     al.fhStream,
+		"event_type": invalid.Title,
+		"errors":     invalid.Errors,
+		"quarantine": al.schemas.quarantine != nil,
+	})
+	if al.schemas.quarantine == nil {
+		return rejectRecord(ack, invalid)
+	}
+	m["_schema_title"] = invalid.Title
+	m["_schema_errors"] = invalid.Errors
+	return al.schemas.quarantine.write(ctx, m, ack)
+}
@@ -0,0 +1,104 @@
+package analytics
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+const signupSchema = `{
+	"type": "object",
+	"properties": {"user_id": {"type": "string"}, "count": {"type": "integer"}},
+	"required": ["user_id"]
+}`
+
+func TestSchemaReject(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := recordsByStream(t, c)
+	failures := []*SchemaValidationError{}
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		Schema: &SchemaConfig{
+			Schemas:   map[string]string{"signup": signupSchema},
+			OnFailure: func(e *SchemaValidationError) { failures = append(failures, e) },
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = al.Write([]byte(`{"title":"signup","level":"info","user_id":"u1","count":1}`))
+	assert.NoError(t, err)
+	_, err = al.Write([]byte(`{"title":"signup","level":"info","count":"many"}`))
+	var invalid *SchemaValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "signup", invalid.Title)
+	assert.Len(t, invalid.Errors, 2)
+	_, err = al.Write([]byte(`{"title":"login","count":"many"}`))
+	assert.NoError(t, err)
+	require.NoError(t, al.Close())
+
+	require.Len(t, failures, 1)
+	assert.Equal(t, map[string]interface{}{"count": "many"}, failures[0].Record)
+	assert.Equal(t, map[string][]map[string]interface{}{
+		"testenv--testdb": {{"user_id": "u1", "count": 1.0}, {"count": "many"}},
+	}, records)
+}
+
+func TestSchemaQuarantine(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := recordsByStream(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		Schema: &SchemaConfig{
+			Schemas:          map[string]string{"signup": signupSchema},
+			Action:           SchemaQuarantine,
+			QuarantineStream: "testenv--quarantine",
+		},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("signup", logger.M{"user_id": "u1"})
+	al.InfoD("signup", logger.M{"user_id": 1})
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, map[string][]map[string]interface{}{
+		"testenv--testdb": {{"user_id": "u1"}},
+		"testenv--quarantine": {{
+			"user_id":        1.0,
+			"_schema_title":  "signup",
+			"_schema_errors": []interface{}{"user_id: Invalid type. Expected: string, given: integer"},
+		}},
+	}, records)
+}
+
+func TestSchemaConfig(t *testing.T) {
+	for _, test := range []struct {
+		c   SchemaConfig
+		err string
+	}{
+		{SchemaConfig{Schemas: map[string]string{"signup": `{"type": 1}`}}, `invalid schema for "signup"`},
+		{SchemaConfig{Action: SchemaQuarantine}, "SchemaQuarantine requires a QuarantineStream"},
+		{SchemaConfig{Action: 5}, "unknown schema action 5"},
+	} {
+		c := test.c
+		_, err := New(Config{
+			Environment: "testenv",
+			DBName:      "testdb",
+			Region:      "us-west-1",
+			Schema:      &c,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), test.err)
+	}
+}
@@ -0,0 +1,55 @@
+package analytics
+
+import "github.com/aws/aws-sdk-go/service/firehose"
+
+// SendError describes a batch that couldn't be delivered, see Config.OnSendError.
+type SendError struct {
+	// BatchID identifies the batch in the logger's diagnostics.
+	BatchID string
+	// Stream is the stream the batch was sent to.
+	Stream string
+	// Records are the undelivered records, each one a line of JSON. Records of the batch that
+	// were delivered before the error aren't included.
+	Records [][]byte
+	// Attempts is the number of PutRecordBatch calls made for the batch, 0 if it was dropped by
+	// the circuit breaker.
+	Attempts int
+	// Err is the last error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e SendError) Error() string {
+	return "sending batch " + e.BatchID + " to " + e.Stream + ": " + e.Err.Error()
+}
+
+// Unwrap returns the last error.
+func (e SendError) Unwrap() error {
+	return e.Err
+}
+
+// sendFailed hands the undelivered records of `s` to their Ack and OnSendError.
+func (al *Logger) sendFailed(batchID string, s *batchSend, err error) {
+	for _, r := range s.records {
+		al.acked(r, err)
+	}
+	if al.onSendError == nil {
+		return
+	}
+	al.onSendError(SendError{
+		BatchID:  batchID,
+		Stream:   al.fhStream,
+		Records:  recordData(s.records),
+		Attempts: s.attempts,
+		Err:      err,
+	})
+}
+
+// recordData returns the data of `records`.
+func recordData(records []*firehose.Record) [][]byte {
+	data := make([][]byte, len(records))
+	for i, r := range records {
+		data[i] = r.Data
+	}
+	return data
+}
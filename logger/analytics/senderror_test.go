@@ -0,0 +1,70 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/eapache/go-resiliency/breaker"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestOnSendError(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	noStream := awserr.New(firehose.ErrCodeResourceNotFoundException, "no such stream", nil)
+	gomock.InOrder(
+		// the first record is delivered, the second one fails and is sent again
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+			FailedPutCount: aws.Int64(1),
+			RequestResponses: []*firehose.PutRecordBatchResponseEntry{
+				{RecordId: aws.String("rec-1")},
+				{ErrorCode: aws.String("InternalFailure")},
+			},
+		}, nil),
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil, noStream).Times(2),
+	)
+
+	sendErrors := []SendError{}
+	al, err := New(Config{
+		Environment:    "testenv",
+		DBName:         "testdb",
+		FirehoseAPI:    mf,
+		ErrLogger:      logger.NewMockCountLogger("errors"),
+		CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour},
+		OnSendError:    func(e SendError) { sendErrors = append(sendErrors, e) },
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	al.InfoD("test-title", logger.M{"i": 1})
+	al.InfoD("test-title", logger.M{"i": 2})
+	require.NoError(t, al.Flush())
+	al.InfoD("test-title", logger.M{"i": 3})
+	require.NoError(t, al.Flush())
+	// the circuit breaker is open
+	al.InfoD("test-title", logger.M{"i": 4})
+	require.NoError(t, al.Flush())
+
+	require.Len(t, sendErrors, 3)
+	assert.Equal(t, "testenv--testdb", sendErrors[0].Stream)
+	assert.NotEmpty(t, sendErrors[0].BatchID)
+	assert.Equal(t, [][]byte{[]byte(`{"i":2}` + "\n")}, sendErrors[0].Records)
+	assert.Equal(t, 2, sendErrors[0].Attempts)
+	assert.Equal(t, noStream, sendErrors[0].Err)
+	assert.ErrorIs(t, sendErrors[0], noStream)
+
+	assert.Equal(t, [][]byte{[]byte(`{"i":3}` + "\n")}, sendErrors[1].Records)
+	assert.Equal(t, 1, sendErrors[1].Attempts)
+
+	assert.Equal(t, [][]byte{[]byte(`{"i":4}` + "\n")}, sendErrors[2].Records)
+	assert.Equal(t, 0, sendErrors[2].Attempts)
+	assert.Equal(t, breaker.ErrBreakerOpen, sendErrors[2].Err)
+}
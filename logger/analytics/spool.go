@@ -0,0 +1,239 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/firehose"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// defaultSpoolReplayInterval is how often spooled batches are sent again by default.
+const defaultSpoolReplayInterval = 30 * time.Second
+
+// spoolExt is the extension of spool files. Files are written under another name and renamed,
+// so partially written files are never replayed.
+const spoolExt = ".spool"
+
+// ErrSpooled is passed to the Ack of records that couldn't be sent and were spooled to disk.
+// They're sent again when the stream recovers, but aren't acknowledged then.
+var ErrSpooled = errors.New("record was spooled to disk")
+
+// SpoolConfig configures saving batches that couldn't be sent to disk, and sending them again
+// once the stream recovers, so outages don't lose events. Spooled batches aren't passed to
+// OnSendError.
+type SpoolConfig struct {
+	// Dir is the directory batches are saved in. It's created if it doesn't exist. Required.
+	Dir string
+	// ReplayInterval is how often spooled batches are sent again. Defaults to 30 seconds.
+	ReplayInterval time.Duration
+	// MaxBytes bounds the size of the spooled batches. Batches that don't fit fail as usual.
+	// Unbounded by default.
+	MaxBytes int64
+}
+
+// spool is the directory of batches that couldn't be sent.
+type spool struct {
+	config SpoolConfig
+	// mu serializes saving batches with replaying them.
+	mu sync.Mutex
+}
+
+// startSpool sets up the spool configured by `c`, and starts replaying it.
+func (al *Logger) startSpool(c SpoolConfig) error {
+	if c.Dir == "" {
+		return errors.New("spool requires a Dir")
+	}
+	if c.ReplayInterval <= 0 {
+		c.ReplayInterval = defaultSpoolReplayInterval
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating spool dir: %v", err)
+	}
+	al.spool = &spool{config: c}
+
+	ticker := time.NewTicker(c.ReplayInterval)
+	done := al.done
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				al.replaySpool()
+			}
+		}
+	}()
+	return nil
+}
+
+// spoolBatch saves the undelivered records of `s` to disk, and returns false if it couldn't.
+func (al *Logger) spoolBatch(batchID string, s *batchSend, sendErr error) bool {
+	if err := al.spool.save(batchID, s.records); err != nil {
+		al.errLogger.ErrorD("spool-error", logger.M{"stream": al.fhStream, "batch_id": batchID, "error": err.Error()})
+		return false
+	}
+	for _, r := range s.records {
+		al.acked(r, ErrSpooled)
+	}
+	al.errLogger.WarnD("send-batch-spooled", logger.M{
+		"stream":   al.fhStream,
+		"batch_id": batchID,
+		"records":  len(s.records),
+		"attempts": s.attempts,
+		"error":    sendErr.Error(),
+	})
+	return true
+}
+
+// replaySpool sends the spooled batches, oldest first, until one fails.
+func (al *Logger) replaySpool() {
+	al.spool.mu.Lock()
+	defer al.spool.mu.Unlock()
+	files, err := al.spool.files()
+	if err != nil {
+		al.errLogger.ErrorD("spool-error", logger.M{"stream": al.fhStream, "error": err.Error()})
+		return
+	}
+	for _, file := range files {
+		records, err := readSpoolFile(file)
+		if err != nil {
+			// it would never be sent
+			al.errLogger.ErrorD("spool-error", logger.M{"stream": al.fhStream, "file": file, "error": err.Error()})
+			os.Remove(file)
+			continue
+		}
+		send := &batchSend{records: records}
+		err = al.deliver(func() error {
+			ctx, cancel := context.WithTimeout(al.sendCtx, al.sendTimeout)
+			defer cancel()
+			return al.sendBatch(ctx, send)
+		})
+		if err == nil {
+			os.Remove(file)
+			al.errLogger.InfoD("spool-replayed", logger.M{"stream": al.fhStream, "file": file, "records": len(records)})
+			continue
+		}
+		if len(send.records) < len(records) {
+			// keep the records that weren't delivered
+			if werr := writeSpoolFile(file, send.records); werr != nil {
+				al.errLogger.ErrorD("spool-error", logger.M{"stream": al.fhStream, "file": file, "error": werr.Error()})
+			}
+		}
+		return
+	}
+}
+
+// save writes `records` to a new spool file.
+func (sp *spool) save(batchID string, records []*firehose.Record) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.config.MaxBytes > 0 {
+		size, err := sp.size()
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			size += int64(len(r.Data)) + 4
+		}
+		if size > sp.config.MaxBytes {
+			return fmt.Errorf("spool is full: %d bytes", sp.config.MaxBytes)
+		}
+	}
+	name := fmt.Sprintf("%020d-%s%s", time.Now().UnixNano(), batchID, spoolExt)
+	return writeSpoolFile(filepath.Join(sp.config.Dir, name), records)
+}
+
+// files returns the spool files, oldest first.
+func (sp *spool) files() ([]string, error) {
+	entries, err := os.ReadDir(sp.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), spoolExt) {
+			files = append(files, filepath.Join(sp.config.Dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// size returns the size of the spool files.
+func (sp *spool) size() (int64, error) {
+	files, err := sp.files()
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// writeSpoolFile writes `records` to `file`, each prefixed by its length, replacing it
+// atomically.
+func writeSpoolFile(file string, records []*firehose.Record) error {
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		binary.Write(w, binary.BigEndian, uint32(len(r.Data)))
+		w.Write(r.Data)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// readSpoolFile reads the records written by writeSpoolFile.
+func readSpoolFile(file string) ([]*firehose.Record, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	records := []*firehose.Record{}
+	for {
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		records = append(records, &firehose.Record{Data: data})
+	}
+}
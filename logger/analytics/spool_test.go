@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestSpool(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	noStream := awserr.New(firehose.ErrCodeResourceNotFoundException, "no such stream", nil)
+	replayed := make(chan []*firehose.Record, 1)
+	gomock.InOrder(
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil, noStream),
+		// the stream is still down when replaying the first time
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil, noStream),
+		mf.EXPECT().PutRecordBatch(gomock.Any()).DoAndReturn(func(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+			replayed <- input.Records
+			return &firehose.PutRecordBatchOutput{
+				FailedPutCount:   aws.Int64(0),
+				RequestResponses: []*firehose.PutRecordBatchResponseEntry{{RecordId: aws.String("rec-1")}},
+			}, nil
+		}),
+	)
+
+	dir := t.TempDir()
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		Spool:       &SpoolConfig{Dir: filepath.Join(dir, "spool"), ReplayInterval: 10 * time.Millisecond},
+		OnSendError: func(SendError) { t.Error("spooled batches aren't send errors") },
+	})
+	require.NoError(t, err)
+	defer al.Close()
+
+	var ackErr error
+	al.InfoDAck("test-title", logger.M{"foo": "bar"}, func(err error) { ackErr = err })
+	require.NoError(t, al.Flush())
+	assert.Equal(t, ErrSpooled, ackErr)
+
+	select {
+	case records := <-replayed:
+		require.Len(t, records, 1)
+		assert.Equal(t, `{"foo":"bar"}`+"\n", string(records[0].Data))
+	case <-time.After(time.Second):
+		t.Fatal("spooled batch wasn't replayed")
+	}
+	// the file is removed once replayed
+	assert.Eventually(t, func() bool {
+		files, err := al.spool.files()
+		return err == nil && len(files) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSpoolFiles(t *testing.T) {
+	sp := &spool{config: SpoolConfig{Dir: t.TempDir(), MaxBytes: 30}}
+	records := []*firehose.Record{{Data: []byte(`{"i":1}`)}, {Data: []byte{0x1f, 0x8b, 0}}}
+	require.NoError(t, sp.save("batch-1", records))
+
+	files, err := sp.files()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	read, err := readSpoolFile(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, records, read)
+
+	assert.EqualError(t, sp.save("batch-2", records), "spool is full: 30 bytes")
+
+	// partially written files aren't replayed
+	require.NoError(t, os.WriteFile(files[0]+".tmp", []byte("partial"), 0o644))
+	files, err = sp.files()
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,55 @@
+package analytics
+
+import (
+	"sync/atomic"
+
+	"github.com/eapache/go-resiliency/breaker"
+)
+
+// Stats describes the current state of a Logger.
+type Stats struct {
+	// FlushRecordsThreshold is the number of buffered records that triggers a flush.
+	FlushRecordsThreshold int
+	// RecordsPerSecond is the smoothed write throughput. Only measured with adaptive batching.
+	RecordsPerSecond float64
+	// ThrottleBackoff is the number of times adaptive batching has doubled the flush interval
+	// and halved the send concurrency because Firehose throttles, or 0 once it's recovered.
+	ThrottleBackoff int
+	// BufferedBytes is the size of the records buffered, queued or being sent. Only measured
+	// with MaxBufferedBytes.
+	BufferedBytes int
+	// DroppedRecords is the number of records dropped because MaxBufferedBytes were buffered.
+	DroppedRecords uint64
+	// Redactions is the number of values redacted. Only counted with Redaction.
+	Redactions uint64
+	// CircuitBreakerOpen is true while the circuit breaker is dropping batches.
+	CircuitBreakerOpen bool
+	// EventTypes describes the records of every event type. Only set with BatchByEventType.
+	EventTypes map[string]EventTypeStats
+}
+
+// Stats returns the current state of the logger.
+func (al *Logger) Stats() Stats {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	var buffered int
+	var dropped uint64
+	if al.bufferCap != nil {
+		buffered = al.bufferCap.buffered()
+		dropped = atomic.LoadUint64(&al.bufferCap.dropped)
+	}
+	var redactions uint64
+	if al.redactor != nil {
+		redactions = atomic.LoadUint64(&al.redactor.redactions)
+	}
+	return Stats{
+		FlushRecordsThreshold: al.flushRecords,
+		RecordsPerSecond:      al.recordsPerSecond,
+		ThrottleBackoff:       al.backoffSteps,
+		BufferedBytes:         buffered,
+		DroppedRecords:        dropped,
+		Redactions:            redactions,
+		CircuitBreakerOpen:    al.breaker != nil && al.breaker.GetState() == breaker.Open,
+		EventTypes:            al.eventTypeStats(),
+	}
+}
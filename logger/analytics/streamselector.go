@@ -0,0 +1,47 @@
+package analytics
+
+import "sort"
+
+// StreamSelector returns the name of the Firehose stream a record goes to, from its fields, or
+// "" for the stream of the Config. It's given the record before the ignored fields are
+// stripped, so it can use the title of the entry.
+type StreamSelector func(record map[string]interface{}) string
+
+// selectedStream returns the logger sending to the stream StreamSelector selects for `m`, or
+// nil if it's the stream of `al`.
+func (al *Logger) selectedStream(m map[string]interface{}) (*Logger, error) {
+	if al.streamSelector == nil {
+		return nil, nil
+	}
+	stream := al.streamSelector(m)
+	if stream == "" || stream == al.fhStream {
+		return nil, nil
+	}
+	al.streamsMu.Lock()
+	defer al.streamsMu.Unlock()
+	if sl, ok := al.streams[stream]; ok {
+		return sl, nil
+	}
+	sl, err := New(streamConfig(al.config, stream))
+	if err != nil {
+		return nil, err
+	}
+	al.streams[stream] = sl
+	return sl, nil
+}
+
+// selectedStreams returns the loggers of the streams selected so far, ordered by stream.
+func (al *Logger) selectedStreams() []*Logger {
+	al.streamsMu.Lock()
+	defer al.streamsMu.Unlock()
+	names := make([]string, 0, len(al.streams))
+	for name := range al.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	loggers := make([]*Logger, len(names))
+	for i, name := range names {
+		loggers[i] = al.streams[name]
+	}
+	return loggers
+}
@@ -0,0 +1,43 @@
+package analytics
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestStreamSelector(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf, records := recordsByStream(t, c)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		FirehoseAPI: mf,
+		ErrLogger:   logger.NewMockCountLogger("errors"),
+		StreamSelector: func(record map[string]interface{}) string {
+			if district, ok := record["district_id"].(string); ok {
+				return "testenv--districts-" + district
+			}
+			return ""
+		},
+	})
+	require.NoError(t, err)
+
+	al.InfoD("test-title", logger.M{"district_id": "a", "i": 1})
+	al.InfoD("test-title", logger.M{"district_id": "b", "i": 2})
+	al.InfoD("test-title", logger.M{"i": 3})
+	al.InfoD("test-title", logger.M{"district_id": "a", "i": 4})
+	require.NoError(t, al.Flush())
+	require.NoError(t, al.Close())
+
+	assert.Equal(t, map[string][]map[string]interface{}{
+		"testenv--districts-a": {{"district_id": "a", "i": 1.0}, {"district_id": "a", "i": 4.0}},
+		"testenv--districts-b": {{"district_id": "b", "i": 2.0}},
+		"testenv--testdb":      {{"i": 3.0}},
+	}, records)
+}
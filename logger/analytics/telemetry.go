@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	firehosev2 "github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Names of the request handlers added to aws-sdk-go clients.
+const (
+	userAgentHandlerName = "kayvee.analytics.UserAgent"
+	headersHandlerName   = "kayvee.analytics.RequestHeaders"
+)
+
+// clientOptions configures the AWS clients used by the logger.
+type clientOptions struct {
+	credentials *credentials.Credentials
+	userAgent   string
+	headers     map[string]string
+	endpoint    string
+	disableSSL  bool
+}
+
+func newClientOptions(c Config) clientOptions {
+	return clientOptions{
+		credentials: c.Credentials,
+		userAgent:   c.UserAgent,
+		headers:     c.RequestHeaders,
+		endpoint:    c.Endpoint,
+		disableSSL:  c.DisableSSL,
+	}
+}
+
+// awsConfig returns the config of an aws-sdk-go client for `region`.
+func (o clientOptions) awsConfig(region string) *aws.Config {
+	config := aws.NewConfig().WithRegion(region)
+	if o.credentials != nil {
+		config = config.WithCredentials(o.credentials)
+	}
+	if o.endpoint != "" {
+		config = config.WithEndpoint(o.endpoint)
+	}
+	if o.disableSSL {
+		config = config.WithDisableSSL(true)
+	}
+	return config
+}
+
+// addHandlers adds the user agent and headers to the requests of an aws-sdk-go client. It can
+// be called more than once on the same handlers.
+func (o clientOptions) addHandlers(h *request.Handlers) {
+	if o.userAgent != "" {
+		h.Build.RemoveByName(userAgentHandlerName)
+		h.Build.PushBackNamed(request.NamedHandler{
+			Name: userAgentHandlerName,
+			Fn:   request.MakeAddToUserAgentFreeFormHandler(o.userAgent),
+		})
+	}
+	if len(o.headers) > 0 {
+		h.Build.RemoveByName(headersHandlerName)
+		h.Build.PushBackNamed(request.NamedHandler{
+			Name: headersHandlerName,
+			Fn: func(r *request.Request) {
+				for k, v := range o.headers {
+					r.HTTPRequest.Header.Set(k, v)
+				}
+			},
+		})
+	}
+}
+
+// v2Options returns the options adding the user agent and headers to the requests of an
+// aws-sdk-go-v2 client.
+func (o clientOptions) v2Options() []func(*firehosev2.Options) {
+	if o.userAgent == "" && len(o.headers) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(o.headers))
+	for k := range o.headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return []func(*firehosev2.Options){func(opts *firehosev2.Options) {
+		if name, version, ok := strings.Cut(o.userAgent, "/"); ok {
+			opts.APIOptions = append(opts.APIOptions, awsmiddleware.AddUserAgentKeyValue(name, version))
+		} else if o.userAgent != "" {
+			opts.APIOptions = append(opts.APIOptions, awsmiddleware.AddUserAgentKey(o.userAgent))
+		}
+		for _, k := range keys {
+			opts.APIOptions = append(opts.APIOptions, smithyhttp.SetHeaderValue(k, o.headers[k]))
+		}
+	}}
+}
@@ -0,0 +1,102 @@
+package analytics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	awsv2 "github.com/aws/aws-sdk-go-v2/aws"
+	firehosev2 "github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// telemetryServer answers PutRecordBatch, and records the headers of the last request.
+func telemetryServer(t *testing.T) (*httptest.Server, *http.Header) {
+	headers := &http.Header{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*headers = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"FailedPutCount":0,"RequestResponses":[{"RecordId":"rec-1"}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, headers
+}
+
+func TestTelemetry(t *testing.T) {
+	srv, headers := telemetryServer(t)
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(srv.URL).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	require.NoError(t, err)
+	api := firehose.New(sess)
+
+	al, err := New(Config{
+		Environment:    "testenv",
+		DBName:         "testdb",
+		FirehoseAPI:    api,
+		UserAgent:      "my-service/1.2",
+		RequestHeaders: map[string]string{"X-Team": "eng"},
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Close())
+
+	assert.Contains(t, headers.Get("User-Agent"), "my-service/1.2")
+	assert.Equal(t, "eng", headers.Get("X-Team"))
+
+	// adding the handlers again doesn't duplicate them
+	n := api.Handlers.Build.Len()
+	newClientOptions(Config{UserAgent: "my-service/1.2"}).addHandlers(&api.Handlers)
+	assert.Equal(t, n, api.Handlers.Build.Len())
+}
+
+func TestTelemetryV2(t *testing.T) {
+	srv, headers := telemetryServer(t)
+	client := firehosev2.New(firehosev2.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: awsv2.String(srv.URL),
+		Credentials:  awsv2.AnonymousCredentials{},
+	})
+	api := &clientV2{
+		client:  client,
+		options: newClientOptions(Config{UserAgent: "my-service/1.2", RequestHeaders: map[string]string{"X-Team": "eng"}}).v2Options(),
+	}
+	_, err := api.putRecordBatch(context.Background(), &firehose.PutRecordBatchInput{
+		DeliveryStreamName: aws.String("stream"),
+		Records:            []*firehose.Record{{Data: []byte(`{"foo":"bar"}`)}},
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, headers.Get("User-Agent"), "my-service/1.2")
+	assert.Equal(t, "eng", headers.Get("X-Team"))
+}
+
+func TestEndpoint(t *testing.T) {
+	srv, headers := telemetryServer(t)
+	al, err := New(Config{
+		Environment: "testenv",
+		DBName:      "testdb",
+		Endpoint:    strings.TrimPrefix(srv.URL, "http://"),
+		DisableSSL:  true,
+		CredentialsProvider: &credentials.StaticProvider{Value: credentials.Value{
+			AccessKeyID:     "localstack",
+			SecretAccessKey: "localstack",
+		}},
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	require.NoError(t, al.Close())
+
+	assert.Contains(t, headers.Get("Authorization"), "Credential=localstack/")
+	assert.Contains(t, headers.Get("Authorization"), "/us-east-1/firehose/")
+}
@@ -0,0 +1,122 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+)
+
+// firehoseMaxRequestsPerSecond is the default cap on PutRecordBatch calls per second, in line
+// with the default Firehose quota.
+// https://docs.aws.amazon.com/firehose/latest/dev/limits.html
+const firehoseMaxRequestsPerSecond = 2000
+
+// throttleMinRequestsPerSecond is the lowest rate the throttle backs off to.
+const throttleMinRequestsPerSecond = 1
+
+// throttleRecovery is the fraction of the current rate regained after each successful call.
+const throttleRecovery = 0.1
+
+// throttle is a token bucket shared by all the sendBatch goroutines of a Logger. When Firehose
+// throttles, the rate is halved and every sender waits for the bucket to refill, instead of each
+// goroutine retrying on its own and amplifying the throttling.
+type throttle struct {
+	mu      sync.Mutex
+	rate    float64
+	maxRate float64
+	tokens  float64
+	last    time.Time
+	// signals is the number of times Firehose throttled since takeSignals was last called
+	signals int
+}
+
+func newThrottle(maxRate float64) *throttle {
+	return &throttle{
+		rate:    maxRate,
+		maxRate: maxRate,
+		tokens:  maxRate,
+		last:    time.Now(),
+	}
+}
+
+// wait blocks until a request can be made, or returns an error if that would be after the
+// deadline of `ctx` or it's canceled.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	t.tokens = math.Min(t.rate, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+	t.tokens--
+	var delay time.Duration
+	if t.tokens < 0 {
+		delay = time.Duration(-t.tokens / t.rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return fmt.Errorf("throttled: next request allowed in %s", delay)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttled halves the request rate and drains the bucket, so that all senders pause.
+func (t *throttle) throttled() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rate = math.Max(throttleMinRequestsPerSecond, t.rate/2)
+	t.tokens = math.Min(t.tokens, 0)
+	t.signals++
+}
+
+// takeSignals returns the number of times Firehose throttled since the last call.
+func (t *throttle) takeSignals() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := t.signals
+	t.signals = 0
+	return n
+}
+
+// succeeded gradually restores the request rate.
+func (t *throttle) succeeded() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rate = math.Min(t.maxRate, t.rate*(1+throttleRecovery)+1)
+}
+
+// currentRate returns the allowed requests per second.
+func (t *throttle) currentRate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rate
+}
+
+// isThrottlingError returns true if `err` is Firehose telling us to slow down.
+func isThrottlingError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && isThrottlingErrorCode(aerr.Code())
+}
+
+func isThrottlingErrorCode(code string) bool {
+	switch code {
+	case firehose.ErrCodeServiceUnavailableException, firehose.ErrCodeLimitExceededException,
+		"ThrottlingException", "ProvisionedThroughputExceededException":
+		return true
+	}
+	return false
+}
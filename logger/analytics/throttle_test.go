@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestThrottle(t *testing.T) {
+	thr := newThrottle(100)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, thr.wait(ctx), "the bucket starts full")
+	}
+
+	thr.throttled()
+	assert.Equal(t, 50.0, thr.currentRate())
+	start := time.Now()
+	require.NoError(t, thr.wait(ctx))
+	assert.True(t, time.Since(start) >= 15*time.Millisecond, "senders wait for the bucket to refill")
+	expired, cancelExpired := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelExpired()
+	assert.Error(t, thr.wait(expired), "waiting past the deadline fails")
+	canceled, cancelWait := context.WithCancel(context.Background())
+	cancelWait()
+	assert.Equal(t, context.Canceled, thr.wait(canceled), "canceling stops waiting")
+
+	for i := 0; i < 20; i++ {
+		thr.throttled()
+	}
+	assert.Equal(t, 1.0, thr.currentRate())
+	for i := 0; i < 100; i++ {
+		thr.succeeded()
+	}
+	assert.Equal(t, 100.0, thr.currentRate())
+}
+
+func TestSendBatchBacksOffWhenThrottled(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	mf := NewMockFirehoseAPI(c)
+	gomock.InOrder(
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(nil,
+			awserr.New(firehose.ErrCodeServiceUnavailableException, "slow down", nil)),
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+			FailedPutCount: aws.Int64(1),
+			RequestResponses: []*firehose.PutRecordBatchResponseEntry{
+				{ErrorCode: aws.String(firehose.ErrCodeServiceUnavailableException)},
+			},
+		}, nil),
+		mf.EXPECT().PutRecordBatch(gomock.Any()).Return(&firehose.PutRecordBatchOutput{
+			FailedPutCount: aws.Int64(0),
+			RequestResponses: []*firehose.PutRecordBatchResponseEntry{
+				{RecordId: aws.String("rec-1")},
+			},
+		}, nil),
+	)
+
+	al, err := New(Config{
+		Environment:                  "testenv",
+		DBName:                       "testdb",
+		FirehoseAPI:                  mf,
+		FirehoseMaxRequestsPerSecond: 40,
+	})
+	require.NoError(t, err)
+	al.InfoD("test-title", logger.M{"foo": "bar"})
+	al.Close()
+	// halved twice, then one success: 10 * 1.1 + 1
+	assert.Equal(t, 12.0, al.throttle.currentRate())
+}
@@ -0,0 +1,257 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrAsyncWriterClosed is returned by AsyncWriter.Write after Close.
+var ErrAsyncWriterClosed = errors.New("async writer is closed")
+
+// numLogLevels is the number of LogLevels, Trace through Critical.
+const numLogLevels = int(Critical) + 1
+
+// AsyncWriter decouples logging from a slow output. Write queues the line and returns
+// immediately, and a background goroutine writes queued lines to the underlying writer.
+//
+// Queued lines are kept in one lane per level and written highest level first. When the
+// queue is full, the oldest line of the lowest non-empty lane below the incoming line's level
+// is dropped to make room; if there is none the incoming line is dropped instead. Error and
+// Critical lines are never dropped: writing them blocks until there is room.
+type AsyncWriter struct {
+	out       io.Writer
+	levelFunc func([]byte) LogLevel
+	maxQueued int
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	lanes    [numLogLevels][]asyncEntry
+	ring     *ringFile
+	queued   int
+	closed   bool
+	done     chan struct{}
+	dropped  [numLogLevels]uint64
+
+	unregister func()
+}
+
+// asyncEntry is a queued line, along with its position in the ring file if it was persisted.
+type asyncEntry struct {
+	line      []byte
+	pos       uint64
+	persisted bool
+}
+
+// NewAsyncWriter returns an AsyncWriter queueing at most `maxQueued` lines for `out`.
+func NewAsyncWriter(out io.Writer, maxQueued int) *AsyncWriter {
+	if maxQueued <= 0 {
+		maxQueued = 1
+	}
+	w := &AsyncWriter{
+		out:       out,
+		levelFunc: levelOfLine,
+		maxQueued: maxQueued,
+		done:      make(chan struct{}),
+	}
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+	w.unregister = RegisterForkResetter(w)
+	go w.run(w.done)
+	return w
+}
+
+// NewPersistentAsyncWriter is like NewAsyncWriter, but also keeps queued lines in a
+// memory-mapped ring file at `path` of `size` bytes, so that they survive a crash. Lines left
+// in the file by a previous process are written to `out` before returning. Lines that don't
+// fit in the ring file are still queued, just not persisted.
+func NewPersistentAsyncWriter(out io.Writer, maxQueued int, path string, size int) (*AsyncWriter, error) {
+	ring, err := openRingFile(path, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range ring.pending() {
+		if _, err := out.Write(line); err != nil {
+			ring.close()
+			return nil, err
+		}
+	}
+	ring.reset()
+
+	w := NewAsyncWriter(out, maxQueued)
+	w.ring = ring
+	return w, nil
+}
+
+// SetLevelFunc overrides how the level of a line is determined. By default the "level" field
+// of kayvee JSON lines is used, and lines without one are treated as Info. Levels `f` returns
+// outside Trace..Critical are treated as Info too.
+func (w *AsyncWriter) SetLevelFunc(f func(line []byte) LogLevel) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.levelFunc = f
+}
+
+// Write queues a copy of `p`.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	line := append([]byte(nil), p...)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+	lvl := w.levelFunc(line)
+	if lvl < Trace || lvl > Critical {
+		lvl = Info
+	}
+	for w.queued >= w.maxQueued {
+		if w.evictBelow(lvl) {
+			break
+		}
+		if lvl < Error {
+			w.dropped[lvl]++
+			return len(p), nil
+		}
+		w.notFull.Wait()
+		if w.closed {
+			return 0, ErrAsyncWriterClosed
+		}
+	}
+	entry := asyncEntry{line: line}
+	if w.ring != nil {
+		entry.pos, entry.persisted = w.ring.append(line)
+	}
+	w.lanes[lvl] = append(w.lanes[lvl], entry)
+	w.queued++
+	w.notEmpty.Signal()
+	return len(p), nil
+}
+
+// evictBelow drops the oldest line of the lowest non-empty lane below `lvl`, and returns
+// false if there is none. Must be called with w.mu held.
+func (w *AsyncWriter) evictBelow(lvl LogLevel) bool {
+	for l := Trace; l < lvl && l < Error; l++ {
+		if len(w.lanes[l]) > 0 {
+			w.release(w.lanes[l][0])
+			w.lanes[l] = w.lanes[l][1:]
+			w.queued--
+			w.dropped[l]++
+			return true
+		}
+	}
+	return false
+}
+
+// release frees the ring file space of an entry that was written or dropped. Must be called
+// with w.mu held.
+func (w *AsyncWriter) release(e asyncEntry) {
+	if e.persisted {
+		w.ring.markDone(e.pos)
+	}
+}
+
+// Dropped returns the number of lines dropped so far, per level.
+func (w *AsyncWriter) Dropped() map[LogLevel]uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := map[LogLevel]uint64{}
+	for l, n := range w.dropped {
+		if n > 0 {
+			out[LogLevel(l)] = n
+		}
+	}
+	return out
+}
+
+// Queued returns the number of lines waiting to be written.
+func (w *AsyncWriter) Queued() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.queued
+}
+
+// Close writes all queued lines, stops the background goroutine and closes the ring file.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notEmpty.Broadcast()
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+	w.unregister()
+	<-w.done
+	if w.ring != nil {
+		return w.ring.close()
+	}
+	return nil
+}
+
+// ResetAfterFork restarts the background goroutine in the child of a fork, see ForkResetter.
+// Lines aren't persisted in the child, since the ring file is shared with the parent.
+func (w *AsyncWriter) ResetAfterFork() error {
+	w.mu = sync.Mutex{}
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+	w.lanes = [numLogLevels][]asyncEntry{}
+	w.queued = 0
+	w.ring = nil
+	if w.closed {
+		return nil
+	}
+	w.done = make(chan struct{})
+	go w.run(w.done)
+	return nil
+}
+
+// run writes queued lines until the writer is closed, or reset by ResetAfterFork.
+func (w *AsyncWriter) run(done chan struct{}) {
+	defer close(done)
+	for {
+		w.mu.Lock()
+		for w.queued == 0 && !w.closed && w.done == done {
+			w.notEmpty.Wait()
+		}
+		if w.queued == 0 || w.done != done {
+			w.mu.Unlock()
+			return
+		}
+		var entry asyncEntry
+		for l := numLogLevels - 1; l >= 0; l-- {
+			if len(w.lanes[l]) > 0 {
+				entry = w.lanes[l][0]
+				w.lanes[l] = w.lanes[l][1:]
+				break
+			}
+		}
+		w.queued--
+		w.notFull.Signal()
+		w.mu.Unlock()
+
+		w.out.Write(entry.line)
+
+		w.mu.Lock()
+		w.release(entry)
+		w.mu.Unlock()
+	}
+}
+
+var levelFieldPrefix = []byte(`"level":"`)
+
+// levelOfLine extracts the level of a kayvee JSON line without decoding all of it.
+func levelOfLine(line []byte) LogLevel {
+	i := bytes.Index(line, levelFieldPrefix)
+	if i < 0 {
+		return Info
+	}
+	rest := line[i+len(levelFieldPrefix):]
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return Info
+	}
+	return levelFromName(string(rest[:end]), Info)
+}
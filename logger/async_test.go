@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedWriter blocks every write until the gate is opened.
+type gatedWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.Write(p)
+}
+
+func (g *gatedWriter) lines() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return strings.Split(strings.TrimSpace(g.buf.String()), "\n")
+}
+
+func asyncLine(level, title string) []byte {
+	return []byte(`{"level":"` + level + `","title":"` + title + `"}` + "\n")
+}
+
+func TestAsyncWriterWritesEverythingWhenNotFull(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	w := NewAsyncWriter(out, 10)
+	for _, title := range []string{"a", "b", "c"} {
+		_, err := w.Write(asyncLine("info", title))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	assert.Equal(t, []string{
+		`{"level":"info","title":"a"}`,
+		`{"level":"info","title":"b"}`,
+		`{"level":"info","title":"c"}`,
+	}, out.lines())
+	assert.Empty(t, w.Dropped())
+
+	_, err := w.Write(asyncLine("info", "d"))
+	assert.Equal(t, ErrAsyncWriterClosed, err)
+}
+
+func TestAsyncWriterShedsLowestPriorityFirst(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	w := NewAsyncWriter(out, 3)
+
+	// the first line is picked up by the background goroutine and blocks on the gate
+	w.Write(asyncLine("info", "in-flight"))
+	require.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.queued == 0
+	}, time.Second, time.Millisecond)
+
+	w.Write(asyncLine("debug", "debug-1"))
+	w.Write(asyncLine("info", "info-1"))
+	w.Write(asyncLine("debug", "debug-2"))
+	// full: evicts debug-1
+	w.Write(asyncLine("warning", "warning-1"))
+	// full: evicts debug-2
+	w.Write(asyncLine("error", "error-1"))
+	// full, nothing below debug: the incoming line is dropped
+	w.Write(asyncLine("debug", "debug-3"))
+
+	close(out.gate)
+	require.NoError(t, w.Close())
+	assert.Equal(t, []string{
+		`{"level":"info","title":"in-flight"}`,
+		`{"level":"error","title":"error-1"}`,
+		`{"level":"warning","title":"warning-1"}`,
+		`{"level":"info","title":"info-1"}`,
+	}, out.lines())
+	assert.Equal(t, map[LogLevel]uint64{Debug: 3}, w.Dropped())
+}
+
+func TestAsyncWriterNeverDropsErrors(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	w := NewAsyncWriter(out, 1)
+	w.Write(asyncLine("error", "error-1"))
+	w.Write(asyncLine("error", "error-2"))
+
+	written := make(chan struct{})
+	go func() {
+		w.Write(asyncLine("critical", "critical-1"))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("expected write to block while the queue is full of errors")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(out.gate)
+	<-written
+	require.NoError(t, w.Close())
+	assert.Len(t, out.lines(), 3)
+	assert.Empty(t, w.Dropped())
+}
+
+func TestAsyncWriterBogusLevel(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	w := NewAsyncWriter(out, 10)
+	w.SetLevelFunc(func([]byte) LogLevel { return LogLevel(42) })
+	_, err := w.Write(asyncLine("info", "a"))
+	require.NoError(t, err)
+	w.SetLevelFunc(func([]byte) LogLevel { return LogLevel(-1) })
+	_, err = w.Write(asyncLine("info", "b"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Len(t, out.lines(), 2)
+}
+
+func TestLevelOfLine(t *testing.T) {
+	assert.Equal(t, Warning, levelOfLine(asyncLine("warning", "x")))
+	assert.Equal(t, Info, levelOfLine([]byte("plain text")))
+	assert.Equal(t, Info, levelOfLine([]byte(`{"level":"bogus"}`)))
+}
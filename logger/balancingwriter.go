@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoHealthyEndpoints is returned by BalancingWriter.Write when every endpoint is down.
+var ErrNoHealthyEndpoints = errors.New("no healthy endpoints")
+
+// ErrBalancingWriterClosed is returned by BalancingWriter.Write after Close.
+var ErrBalancingWriterClosed = errors.New("balancing writer is closed")
+
+// BalancingStrategy selects the endpoint a BalancingWriter sends an entry to.
+type BalancingStrategy int
+
+const (
+	// RoundRobin cycles through the healthy endpoints.
+	RoundRobin BalancingStrategy = iota
+	// LeastPending picks the healthy endpoint with the fewest writes in progress.
+	LeastPending
+)
+
+// BalancingWriterConfig configures a BalancingWriter.
+type BalancingWriterConfig struct {
+	// Addresses are the collector endpoints, e.g. "collector-1:5170".
+	Addresses []string
+	// Strategy selects how entries are spread across endpoints. Defaults to RoundRobin.
+	Strategy BalancingStrategy
+	// HealthCheckInterval is how often endpoints marked as down are redialed. Defaults to 5s.
+	HealthCheckInterval time.Duration
+	// Dial connects to an endpoint. Defaults to a TCP connection with a 5s timeout.
+	Dial func(addr string) (io.WriteCloser, error)
+}
+
+// BalancingWriter spreads entries across several collector endpoints, for shipping directly to
+// a horizontally scaled ingestion tier. Each Write is sent whole to a single endpoint. An
+// endpoint whose write fails is marked as down, and a background health check redials it until
+// it's back. The entry is retried on another endpoint if none of it was sent; once part of it
+// was, its connection is closed and the error is returned instead, so that it's neither
+// duplicated nor followed by another entry on the same line.
+type BalancingWriter struct {
+	config    BalancingWriterConfig
+	endpoints []*balancedEndpoint
+	next      uint64
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type balancedEndpoint struct {
+	addr    string
+	healthy atomic.Bool
+	pending int64
+
+	mu   sync.Mutex
+	conn io.WriteCloser
+}
+
+// NewBalancingWriter returns a BalancingWriter for `config`. Connections are opened lazily.
+func NewBalancingWriter(config BalancingWriterConfig) (*BalancingWriter, error) {
+	if len(config.Addresses) == 0 {
+		return nil, errors.New("at least one address is required")
+	}
+	if config.HealthCheckInterval == 0 {
+		config.HealthCheckInterval = 5 * time.Second
+	}
+	if config.Dial == nil {
+		config.Dial = func(addr string) (io.WriteCloser, error) {
+			return net.DialTimeout("tcp", addr, 5*time.Second)
+		}
+	}
+	b := &BalancingWriter{config: config, done: make(chan struct{})}
+	for _, addr := range config.Addresses {
+		e := &balancedEndpoint{addr: addr}
+		e.healthy.Store(true)
+		b.endpoints = append(b.endpoints, e)
+	}
+	go b.healthCheck()
+	return b, nil
+}
+
+// Write implements io.Writer.
+func (b *BalancingWriter) Write(p []byte) (int, error) {
+	tried := make([]bool, len(b.endpoints))
+	for {
+		e := b.pick(tried)
+		if e == nil {
+			return 0, ErrNoHealthyEndpoints
+		}
+		if n, err := e.write(b, p); err == nil || n > 0 || err == ErrBalancingWriterClosed {
+			return n, err
+		}
+	}
+}
+
+// pick returns a healthy endpoint that hasn't been tried yet, or nil if there is none.
+func (b *BalancingWriter) pick(tried []bool) *balancedEndpoint {
+	var best *balancedEndpoint
+	start := int(atomic.AddUint64(&b.next, 1) - 1)
+	for i := range b.endpoints {
+		idx := (start + i) % len(b.endpoints)
+		e := b.endpoints[idx]
+		if tried[idx] || !e.healthy.Load() {
+			continue
+		}
+		if b.config.Strategy == RoundRobin {
+			tried[idx] = true
+			return e
+		}
+		if best == nil || atomic.LoadInt64(&e.pending) < atomic.LoadInt64(&best.pending) {
+			best = e
+		}
+	}
+	for idx, e := range b.endpoints {
+		if e == best {
+			tried[idx] = true
+		}
+	}
+	return best
+}
+
+// Healthy returns the addresses of the endpoints currently considered up.
+func (b *BalancingWriter) Healthy() []string {
+	out := []string{}
+	for _, e := range b.endpoints {
+		if e.healthy.Load() {
+			out = append(out, e.addr)
+		}
+	}
+	return out
+}
+
+// Close stops health checks and closes all connections.
+func (b *BalancingWriter) Close() error {
+	b.closeOnce.Do(func() { close(b.done) })
+	for _, e := range b.endpoints {
+		e.mu.Lock()
+		if e.conn != nil {
+			e.conn.Close()
+			e.conn = nil
+		}
+		e.mu.Unlock()
+	}
+	return nil
+}
+
+// closed returns whether Close was called. Close closes b.done before taking the locks of the
+// endpoints, so connections aren't dialed after Close by callers holding them.
+func (b *BalancingWriter) closed() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *BalancingWriter) healthCheck() {
+	ticker := time.NewTicker(b.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+		for _, e := range b.endpoints {
+			if e.healthy.Load() {
+				continue
+			}
+			e.mu.Lock()
+			if b.closed() {
+				e.mu.Unlock()
+				return
+			}
+			if conn, err := b.config.Dial(e.addr); err == nil {
+				e.conn = conn
+				e.healthy.Store(true)
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+func (e *balancedEndpoint) write(b *BalancingWriter, p []byte) (int, error) {
+	atomic.AddInt64(&e.pending, 1)
+	defer atomic.AddInt64(&e.pending, -1)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if b.closed() {
+		return 0, ErrBalancingWriterClosed
+	}
+	if e.conn == nil {
+		conn, err := b.config.Dial(e.addr)
+		if err != nil {
+			e.healthy.Store(false)
+			return 0, err
+		}
+		e.conn = conn
+	}
+	n, err := e.conn.Write(p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		e.conn.Close()
+		e.conn = nil
+		e.healthy.Store(false)
+	}
+	return n, err
+}
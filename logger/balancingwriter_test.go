@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCollectors hands out in-memory connections, and can take endpoints down.
+type fakeCollectors struct {
+	mu       sync.Mutex
+	received map[string][]string
+	down     map[string]bool
+	// short endpoints fail after writing the first byte
+	short map[string]bool
+}
+
+type fakeConn struct {
+	c    *fakeCollectors
+	addr string
+}
+
+func (f *fakeCollectors) dial(addr string) (io.WriteCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down[addr] {
+		return nil, errors.New("connection refused")
+	}
+	return fakeConn{f, addr}, nil
+}
+
+func (f *fakeCollectors) setDown(addr string, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down[addr] = down
+}
+
+func (c fakeConn) Write(p []byte) (int, error) {
+	c.c.mu.Lock()
+	defer c.c.mu.Unlock()
+	if c.c.down[c.addr] {
+		return 0, errors.New("broken pipe")
+	}
+	if c.c.short[c.addr] {
+		c.c.received[c.addr] = append(c.c.received[c.addr], string(p[:1]))
+		return 1, errors.New("connection reset")
+	}
+	c.c.received[c.addr] = append(c.c.received[c.addr], string(p))
+	return len(p), nil
+}
+
+func (c fakeConn) Close() error { return nil }
+
+func TestBalancingWriterRoundRobin(t *testing.T) {
+	collectors := &fakeCollectors{received: map[string][]string{}, down: map[string]bool{}}
+	b, err := NewBalancingWriter(BalancingWriterConfig{
+		Addresses:           []string{"a", "b", "c"},
+		Dial:                collectors.dial,
+		HealthCheckInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer b.Close()
+
+	for _, line := range []string{"1", "2", "3", "4"} {
+		_, err := b.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, map[string][]string{"a": {"1", "4"}, "b": {"2"}, "c": {"3"}}, collectors.received)
+
+	// writes to an endpoint that goes down are retried elsewhere
+	collectors.setDown("b", true)
+	for _, line := range []string{"5", "6", "7"} {
+		_, err := b.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"a", "c"}, b.Healthy())
+	assert.Equal(t, []string{"2"}, collectors.received["b"])
+	assert.Len(t, collectors.received["a"], 3)
+	assert.Len(t, collectors.received["c"], 3)
+
+	// the health check brings it back
+	collectors.setDown("b", false)
+	assert.Eventually(t, func() bool { return len(b.Healthy()) == 3 }, time.Second, time.Millisecond)
+}
+
+func TestBalancingWriterLeastPendingAndAllDown(t *testing.T) {
+	collectors := &fakeCollectors{received: map[string][]string{}, down: map[string]bool{"a": true}}
+	b, err := NewBalancingWriter(BalancingWriterConfig{
+		Addresses: []string{"a", "b"},
+		Strategy:  LeastPending,
+		Dial:      collectors.dial,
+	})
+	require.NoError(t, err)
+	defer b.Close()
+
+	_, err = b.Write([]byte("1"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"b": {"1"}}, collectors.received)
+
+	collectors.setDown("b", true)
+	_, err = b.Write([]byte("2"))
+	assert.Equal(t, ErrNoHealthyEndpoints, err)
+
+	_, err = NewBalancingWriter(BalancingWriterConfig{})
+	assert.Error(t, err)
+}
+
+func TestBalancingWriterPartialWriteAndClose(t *testing.T) {
+	collectors := &fakeCollectors{
+		received: map[string][]string{},
+		down:     map[string]bool{},
+		short:    map[string]bool{"a": true},
+	}
+	b, err := NewBalancingWriter(BalancingWriterConfig{Addresses: []string{"a", "b"}, Dial: collectors.dial})
+	require.NoError(t, err)
+
+	// an entry partly sent isn't retried on another endpoint
+	n, err := b.Write([]byte("12"))
+	assert.Equal(t, 1, n)
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, map[string][]string{"a": {"1"}}, collectors.received)
+	assert.Equal(t, []string{"b"}, b.Healthy())
+
+	require.NoError(t, b.Close())
+	_, err = b.Write([]byte("3"))
+	assert.Equal(t, ErrBalancingWriterClosed, err)
+	assert.Equal(t, map[string][]string{"a": {"1"}}, collectors.received)
+}
@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BatchSummary accumulates the outcomes of the items of a batch job, and logs them as a single
+// "batch-summary" entry instead of an entry per item. It's safe for concurrent use.
+//
+//	s := logger.NewBatchSummary("sync-rosters")
+//	for _, r := range rosters {
+//		if err := sync(r); err != nil {
+//			s.Fail("district-api", r.ID, err)
+//			continue
+//		}
+//		s.OK()
+//	}
+//	s.Log(lggr)
+type BatchSummary struct {
+	// MaxExamples is the number of examples kept for every error category. Defaults to 3.
+	MaxExamples int
+	// TopCategories is the number of categories, by count, whose examples are logged. Defaults
+	// to 5.
+	TopCategories int
+
+	name       string
+	start      time.Time
+	mu         sync.Mutex
+	ok         int
+	skipped    map[string]int
+	failed     map[string]int
+	examples   map[string][]string
+	numSkipped int
+	numFailed  int
+}
+
+// NewBatchSummary returns an empty BatchSummary of the batch job `name`.
+func NewBatchSummary(name string) *BatchSummary {
+	return &BatchSummary{
+		name:     name,
+		start:    clock(),
+		skipped:  map[string]int{},
+		failed:   map[string]int{},
+		examples: map[string][]string{},
+	}
+}
+
+// OK counts an item that succeeded.
+func (s *BatchSummary) OK() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ok++
+}
+
+// Skip counts an item skipped for `reason`.
+func (s *BatchSummary) Skip(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.numSkipped++
+	s.skipped[reason]++
+}
+
+// Fail counts an item that failed with an error of `category`, e.g. "timeout" or
+// "validation". `item` identifies the item in the examples of the category, and may be empty.
+func (s *BatchSummary) Fail(category, item string, err error) {
+	example := item
+	if err != nil {
+		if example != "" {
+			example += ": "
+		}
+		example += err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.numFailed++
+	s.failed[category]++
+	s.addExample(category, example)
+}
+
+// AddExample attaches an example to the error category `category`, e.g. the payload of a
+// failed item, without counting an item. Only the first MaxExamples are kept.
+func (s *BatchSummary) AddExample(category, example string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addExample(category, example)
+}
+
+func (s *BatchSummary) addExample(category, example string) {
+	limit := s.MaxExamples
+	if limit <= 0 {
+		limit = 3
+	}
+	if example != "" && len(s.examples[category]) < limit {
+		s.examples[category] = append(s.examples[category], example)
+	}
+}
+
+// Fields returns the fields of the summary: the name of the job, the counts of items ok,
+// failed, skipped and in total, the duration_s of the job, the counts of every error category
+// and skip reason, and the examples of the top error categories.
+func (s *BatchSummary) Fields() M {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	categories := make([]string, 0, len(s.failed))
+	for c := range s.failed {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if s.failed[categories[i]] != s.failed[categories[j]] {
+			return s.failed[categories[i]] > s.failed[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	top := s.TopCategories
+	if top <= 0 {
+		top = 5
+	}
+	examples := map[string][]string{}
+	for _, c := range categories[:min(top, len(categories))] {
+		if len(s.examples[c]) > 0 {
+			examples[c] = append([]string(nil), s.examples[c]...)
+		}
+	}
+	errorCategories := make(map[string]int, len(s.failed))
+	for c, n := range s.failed {
+		errorCategories[c] = n
+	}
+	skipReasons := make(map[string]int, len(s.skipped))
+	for r, n := range s.skipped {
+		skipReasons[r] = n
+	}
+	return M{
+		"name":             s.name,
+		"ok":               s.ok,
+		"failed":           s.numFailed,
+		"skipped":          s.numSkipped,
+		"total":            s.ok + s.numFailed + s.numSkipped,
+		"duration_s":       clock().Sub(s.start).Seconds(),
+		"error_categories": errorCategories,
+		"skip_reasons":     skipReasons,
+		"error_examples":   examples,
+	}
+}
+
+// Log logs the "batch-summary" entry to `l`, at the warning level if an item failed.
+func (s *BatchSummary) Log(l Leveled) {
+	data := s.Fields()
+	if data["failed"].(int) > 0 {
+		l.WarnD("batch-summary", data)
+	} else {
+		l.InfoD("batch-summary", data)
+	}
+}
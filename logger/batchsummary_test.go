@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestBatchSummary(t *testing.T) {
+	now := time.Unix(0, 0)
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+
+	s := NewBatchSummary("sync-rosters")
+	s.MaxExamples = 2
+	s.TopCategories = 1
+	for i := 0; i < 5; i++ {
+		s.OK()
+	}
+	s.Skip("inactive")
+	for i := 0; i < 3; i++ {
+		s.Fail("timeout", fmt.Sprintf("roster-%d", i), errors.New("deadline exceeded"))
+	}
+	s.Fail("validation", "roster-9", errors.New("missing teacher"))
+	s.AddExample("validation", `{"id":"roster-9"}`)
+	now = now.Add(1500 * time.Millisecond)
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	s.Log(l)
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 1)
+	e := lines[0]
+	assert.Equal(t, "batch-summary", e["title"])
+	assert.Equal(t, "warning", e["level"])
+	assert.Equal(t, "sync-rosters", e["name"])
+	assert.Equal(t, 5.0, e["ok"])
+	assert.Equal(t, 4.0, e["failed"])
+	assert.Equal(t, 1.0, e["skipped"])
+	assert.Equal(t, 10.0, e["total"])
+	assert.Equal(t, 1.5, e["duration_s"])
+	assert.Equal(t, map[string]interface{}{"timeout": 3.0, "validation": 1.0}, e["error_categories"])
+	assert.Equal(t, map[string]interface{}{"inactive": 1.0}, e["skip_reasons"])
+	assert.Equal(t, map[string]interface{}{
+		"timeout": []interface{}{"roster-0: deadline exceeded", "roster-1: deadline exceeded"},
+	}, e["error_examples"], "only the examples of the top categories are logged")
+}
+
+func TestBatchSummaryOK(t *testing.T) {
+	s := NewBatchSummary("sync-rosters")
+	s.OK()
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	s.Log(l)
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "info", lines[0]["level"])
+	assert.Equal(t, map[string]interface{}{}, lines[0]["error_examples"])
+}
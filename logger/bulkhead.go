@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// IsolatedSink runs a Sink in its own goroutine behind a bounded queue, so that a slow or
+// stalled destination, like a Firehose sink, doesn't hold up the goroutine logging, and with
+// it the output and the other sinks. Register one per destination:
+//
+//	l.AddSink(logger.NewIsolatedSink(firehoseSink, 1000))
+//	l.AddSink(logger.NewIsolatedSink(metricsSink, 100))
+//
+// When the queue is full, incoming entries are dropped and counted rather than waiting for
+// room. Outputs are isolated the same way by wrapping them in an AsyncWriter.
+type IsolatedSink struct {
+	sink    Sink
+	queue   chan Entry
+	done    chan struct{}
+	dropped uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewIsolatedSink returns an IsolatedSink queueing at most `maxQueued` entries for `s`.
+func NewIsolatedSink(s Sink, maxQueued int) *IsolatedSink {
+	if maxQueued <= 0 {
+		maxQueued = 1
+	}
+	is := &IsolatedSink{
+		sink:  s,
+		queue: make(chan Entry, maxQueued),
+		done:  make(chan struct{}),
+	}
+	go is.run()
+	return is
+}
+
+// WriteEntry implements the Sink interface. It queues `e` without blocking, and drops it if
+// the queue is full or the sink is closed.
+func (is *IsolatedSink) WriteEntry(e Entry) {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	if is.closed {
+		atomic.AddUint64(&is.dropped, 1)
+		return
+	}
+	select {
+	case is.queue <- e:
+	default:
+		atomic.AddUint64(&is.dropped, 1)
+	}
+}
+
+// Queued returns the number of entries waiting to be handed to the sink.
+func (is *IsolatedSink) Queued() int {
+	return len(is.queue)
+}
+
+// Dropped returns the number of entries dropped so far.
+func (is *IsolatedSink) Dropped() uint64 {
+	return atomic.LoadUint64(&is.dropped)
+}
+
+// Close hands the queued entries to the sink and stops the goroutine. Entries written after
+// Close are dropped.
+func (is *IsolatedSink) Close() {
+	is.mu.Lock()
+	if !is.closed {
+		is.closed = true
+		close(is.queue)
+	}
+	is.mu.Unlock()
+	<-is.done
+}
+
+func (is *IsolatedSink) run() {
+	defer close(is.done)
+	for e := range is.queue {
+		is.sink.WriteEntry(e)
+	}
+}
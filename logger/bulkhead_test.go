@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestIsolatedSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+
+	// a stalled sink
+	unblock := make(chan struct{})
+	stalled := NewIsolatedSink(SinkFunc(func(e Entry) { <-unblock }), 2)
+	l.AddSink(stalled)
+
+	var mu sync.Mutex
+	titles := []string{}
+	healthy := NewIsolatedSink(SinkFunc(func(e Entry) {
+		mu.Lock()
+		defer mu.Unlock()
+		titles = append(titles, e.Title)
+	}), 10)
+	l.AddSink(healthy)
+
+	// the stalled sink holds one entry and queues two, the fourth and fifth are dropped
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		l.Info(title)
+	}
+	healthy.Close()
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, titles)
+	assert.Len(t, decodeLines(t, buf), 5)
+	assert.Equal(t, uint64(0), healthy.Dropped())
+	assert.True(t, stalled.Dropped() >= 2)
+
+	close(unblock)
+	stalled.Close()
+	assert.Equal(t, 0, stalled.Queued())
+
+	// entries written after Close are dropped
+	dropped := stalled.Dropped()
+	l.Info("f")
+	assert.Equal(t, dropped+1, stalled.Dropped())
+	require.NotPanics(t, stalled.Close)
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"math/rand"
+	"time"
+)
+
+// cacheMissErrors are the messages of the errors cache clients return for missing keys:
+// redis.Nil of go-redis, and memcache.ErrCacheMiss of gomemcache.
+var cacheMissErrors = map[string]bool{
+	"redis: nil":           true,
+	"memcache: cache miss": true,
+}
+
+// IsCacheMiss returns true if `err` is the error of a go-redis or gomemcache client for a
+// missing key.
+func IsCacheMiss(err error) bool {
+	return err != nil && cacheMissErrors[err.Error()]
+}
+
+// CacheInstrumentation instruments the commands of a cache client, e.g. go-redis or
+// gomemcache, without depending on it:
+//   - a "cache-command" entry with the latency of a sample of the commands
+//   - a "cache-command-error" entry for every failed command
+//   - "cache-hit" and "cache-miss" counters for reads
+//
+// Entries have db_system and command fields. Wrap calls with Do, or hook it into the
+// client, e.g. with a go-redis ProcessHook:
+//
+//	func (h hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+//		return func(ctx context.Context, cmd redis.Cmder) error {
+//			return h.ci.Do(cmd.Name(), isRead(cmd.Name()), func() error { return next(ctx, cmd) })
+//		}
+//	}
+type CacheInstrumentation struct {
+	// Logger defaults to a logger with the source "cache".
+	Logger KayveeLogger
+	// System is the db_system of entries, e.g. "redis" or "memcache".
+	System string
+	// SampleRate is the fraction of the commands whose latency is logged, between 0 and 1.
+	// Defaults to 1, i.e. every command.
+	SampleRate float64
+	// IsMiss returns true if the error of a read means the key is missing. Defaults to
+	// IsCacheMiss.
+	IsMiss func(err error) bool
+}
+
+// Do runs `command` with `f`, and instruments it. `read` commands, e.g. GET, are counted as
+// hits or misses. Misses aren't errors: Do returns the error of `f` as is, but doesn't log it.
+func (c *CacheInstrumentation) Do(command string, read bool, f func() error) error {
+	start := clock()
+	err := f()
+	c.Observe(command, read, clock().Sub(start), err)
+	return err
+}
+
+// Observe instruments `command`, which took `latency` and failed with `err` if it isn't nil.
+func (c *CacheInstrumentation) Observe(command string, read bool, latency time.Duration, err error) {
+	lggr := c.Logger
+	if lggr == nil {
+		lggr = New("cache")
+	}
+	isMiss := c.IsMiss
+	if isMiss == nil {
+		isMiss = IsCacheMiss
+	}
+	data := M{"db_system": c.System, "command": command}
+	miss := err != nil && isMiss(err)
+	if read {
+		if miss {
+			lggr.CounterD("cache-miss", 1, data)
+		} else if err == nil {
+			lggr.CounterD("cache-hit", 1, data)
+		}
+	}
+
+	rate := c.SampleRate
+	if rate == 0 {
+		rate = 1
+	}
+	failed := err != nil && !miss
+	if !failed && (rate < 1 && rand.Float64() >= rate) {
+		return
+	}
+	entry := M{"db_system": c.System, "command": command, "latency_ms": float64(latency) / float64(time.Millisecond)}
+	if failed {
+		entry["error"] = err.Error()
+		lggr.ErrorD("cache-command-error", entry)
+		return
+	}
+	if rate < 1 {
+		entry["sample_rate"] = rate
+	}
+	lggr.InfoD("cache-command", entry)
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestCacheInstrumentation(t *testing.T) {
+	now := time.Unix(0, 0)
+	SetClock(func() time.Time {
+		now = now.Add(2 * time.Millisecond)
+		return now
+	})
+	defer SetClock(nil)
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	ci := &CacheInstrumentation{Logger: l, System: "redis"}
+
+	assert.NoError(t, ci.Do("get", true, func() error { return nil }))
+	miss := errors.New("redis: nil")
+	assert.Equal(t, miss, ci.Do("get", true, func() error { return miss }))
+	assert.EqualError(t, ci.Do("set", false, func() error { return errors.New("connection refused") }), "connection refused")
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 5)
+	assert.Equal(t, "cache-hit", lines[0]["title"])
+	assert.Equal(t, "counter", lines[0]["type"])
+	assert.Equal(t, "redis", lines[0]["db_system"])
+	assert.Equal(t, "cache-command", lines[1]["title"])
+	assert.Equal(t, "get", lines[1]["command"])
+	assert.Equal(t, 2.0, lines[1]["latency_ms"])
+	assert.Equal(t, "cache-miss", lines[2]["title"])
+	assert.Equal(t, "cache-command", lines[3]["title"], "misses aren't errors")
+	assert.Equal(t, "cache-command-error", lines[4]["title"])
+	assert.Equal(t, "error", lines[4]["level"])
+	assert.Equal(t, "connection refused", lines[4]["error"])
+	assert.Equal(t, "set", lines[4]["command"])
+}
+
+func TestCacheInstrumentationSampling(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	ci := &CacheInstrumentation{Logger: l, System: "memcache", SampleRate: 1e-9}
+
+	for i := 0; i < 100; i++ {
+		ci.Observe("get", false, time.Millisecond, nil)
+	}
+	ci.Observe("get", false, time.Millisecond, errors.New("timeout"))
+	ci.Observe("get", true, time.Millisecond, errors.New("memcache: cache miss"))
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 2, "errors are always logged")
+	assert.Equal(t, "cache-command-error", lines[0]["title"])
+	assert.Equal(t, "cache-miss", lines[1]["title"])
+}
+
+func TestIsCacheMiss(t *testing.T) {
+	assert.True(t, IsCacheMiss(errors.New("redis: nil")))
+	assert.True(t, IsCacheMiss(errors.New("memcache: cache miss")))
+	assert.False(t, IsCacheMiss(errors.New("redis: connection pool timeout")))
+	assert.False(t, IsCacheMiss(nil))
+}
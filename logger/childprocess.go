@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ForwardCmdOutput attaches to the stdout and stderr of `cmd`, which must not have been
+// started yet, and forwards its output through `l`. Lines that are kayvee JSON are passed
+// through with their own title, level and fields; other lines are wrapped like a
+// PlainTextWriter would. Every entry gets `child_process` (the command name),
+// `child_stream` ("stdout" or "stderr") and, once started, `child_pid` fields.
+//
+// The returned flush func logs any trailing output without a newline; call it after
+// cmd.Wait returns.
+func ForwardCmdOutput(cmd *exec.Cmd, l KayveeLogger) (flush func()) {
+	stdout := newChildProcessWriter(cmd, "stdout", l)
+	stderr := newChildProcessWriter(cmd, "stderr", l)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return func() {
+		stdout.flush()
+		stderr.flush()
+	}
+}
+
+// childProcessWriter forwards the output of one stream of a child process.
+type childProcessWriter struct {
+	cmd    *exec.Cmd
+	stream string
+	text   *PlainTextWriter
+	lines  lineSplitter
+}
+
+func newChildProcessWriter(cmd *exec.Cmd, stream string, l KayveeLogger) *childProcessWriter {
+	return &childProcessWriter{
+		cmd:    cmd,
+		stream: stream,
+		text: &PlainTextWriter{
+			Logger:        l,
+			Title:         "child-process-output",
+			DefaultLevel:  Info,
+			LevelPatterns: DefaultLevelPatterns,
+		},
+	}
+}
+
+func (w *childProcessWriter) Write(p []byte) (int, error) {
+	for _, line := range w.lines.split(p) {
+		w.forward(line)
+	}
+	return len(p), nil
+}
+
+func (w *childProcessWriter) flush() {
+	w.forward(w.lines.rest())
+}
+
+func (w *childProcessWriter) fields() M {
+	fields := M{
+		"child_process": filepath.Base(w.cmd.Path),
+		"child_stream":  w.stream,
+	}
+	if w.cmd.Process != nil {
+		fields["child_pid"] = w.cmd.Process.Pid
+	}
+	return fields
+}
+
+// forward passes kayvee JSON lines through and wraps anything else as plain text.
+func (w *childProcessWriter) forward(line string) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "{") {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &data); err == nil {
+			if title, ok := data["title"].(string); ok {
+				logLvl := w.text.DefaultLevel
+				if name, ok := data["level"].(string); ok {
+					logLvl = levelFromName(name, logLvl)
+				}
+				// the parent re-routes the entry, so the child's routing metadata is dropped
+				for _, k := range []string{"title", "level", "_kvmeta"} {
+					delete(data, k)
+				}
+				for k, v := range w.fields() {
+					data[k] = v
+				}
+				logAtLevel(w.text.Logger, logLvl, title, data)
+				return
+			}
+		}
+	}
+	w.text.logLine(line, w.fields())
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardCmdOutput(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	buf := &bytes.Buffer{}
+	lg := New("parent")
+	lg.SetOutput(buf)
+
+	cmd := exec.Command(sh, "-c", `echo '{"title":"child-started","level":"warning","source":"child","n":1}'; echo "ERROR: plain failure" 1>&2`)
+	flush := ForwardCmdOutput(cmd, lg)
+	require.NoError(t, cmd.Run())
+	flush()
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 2)
+	byTitle := map[string]map[string]interface{}{}
+	for _, l := range lines {
+		byTitle[l["title"].(string)] = l
+	}
+
+	started := byTitle["child-started"]
+	require.NotNil(t, started)
+	assert.Equal(t, "warning", started["level"])
+	assert.Equal(t, "child", started["source"], "the child's source is kept")
+	assert.Equal(t, 1.0, started["n"])
+	assert.Equal(t, "sh", started["child_process"])
+	assert.Equal(t, "stdout", started["child_stream"])
+	assert.NotNil(t, started["child_pid"])
+
+	plain := byTitle["child-process-output"]
+	require.NotNil(t, plain)
+	assert.Equal(t, "error", plain["level"])
+	assert.Equal(t, "parent", plain["source"])
+	assert.Equal(t, "ERROR: plain failure", plain["msg"])
+	assert.Equal(t, "stderr", plain["child_stream"])
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// clockFunc holds the time source of the logger package, see SetClock.
+var clockFunc atomic.Pointer[func() time.Time]
+
+// clock returns the current time of the logger package's time source.
+func clock() time.Time {
+	if f := clockFunc.Load(); f != nil {
+		return (*f)()
+	}
+	return time.Now()
+}
+
+// entropy is the source of randomness of generated ids, see SetEntropy.
+var entropy io.Reader = rand.Reader
+
+// SetClock replaces the clock used for the times written in entries (e.g. by the ECS, OTel,
+// pino and protobuf outputs, Sinks and heartbeats), so that snapshot tests and simulations
+// can produce byte-identical output. The middleware package times requests with it too. nil
+// restores time.Now. It's safe to call while entries are being logged.
+func SetClock(f func() time.Time) {
+	if f == nil {
+		clockFunc.Store(nil)
+		return
+	}
+	clockFunc.Store(&f)
+}
+
+// Now returns the current time of the clock set with SetClock.
+func Now() time.Time {
+	return clock()
+}
+
+// SetEntropy replaces the source of randomness used for generated ids, like the stream id
+// of WithSequence. nil restores crypto/rand.
+func SetEntropy(r io.Reader) {
+	if r == nil {
+		r = rand.Reader
+	}
+	entropy = r
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestSetClockAndEntropyMakeOutputDeterministic(t *testing.T) {
+	SetClock(func() time.Time { return time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC) })
+	defer SetClock(nil)
+	defer SetEntropy(nil)
+
+	run := func() string {
+		SetEntropy(strings.NewReader(strings.Repeat("x", 64)))
+		buf := &bytes.Buffer{}
+		l := New("my-app")
+		l.SetConfig("my-app", Info, WithSequence(ECSFormatter), buf)
+		l.InfoD("hello", M{"a": 1})
+		return buf.String()
+	}
+	first := run()
+	assert.Equal(t, first, run())
+	assert.Contains(t, first, `"@timestamp":"2024-01-01T10:00:00Z"`)
+	assert.Contains(t, first, `"_seq_stream":"7878787878787878"`)
+
+	// the default JSON output has no time or random fields
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	l.Info("hello")
+	assert.NotContains(t, buf.String(), "2024")
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"title":"request-finished","level":"info","status-code":200}`+"\n"), 100)
+	for _, name := range []string{"identity", "gzip", "deflate", "zstd", "snappy", "lz4", "ZSTD"} {
+		t.Run(name, func(t *testing.T) {
+			c, err := CodecByName(name)
+			require.NoError(t, err)
+
+			compressed, err := Compress(c, payload)
+			require.NoError(t, err)
+			if name != "identity" {
+				assert.True(t, len(compressed) < len(payload)/4, "%d bytes", len(compressed))
+			}
+
+			r, err := c.NewReader(bytes.NewReader(compressed))
+			require.NoError(t, err)
+			defer r.Close()
+			decompressed, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, payload, decompressed)
+		})
+	}
+}
+
+type upperCodec struct{ identityCodec }
+
+func (upperCodec) Name() string { return "upper" }
+
+func TestRegisterCodec(t *testing.T) {
+	_, err := CodecByName("upper")
+	assert.EqualError(t, err, `unknown codec "upper", expected one of deflate, gzip, identity, lz4, snappy, zstd`)
+
+	RegisterCodec(upperCodec{})
+	defer func() {
+		codecsMu.Lock()
+		delete(codecs, "upper")
+		codecsMu.Unlock()
+	}()
+	c, err := CodecByName("upper")
+	require.NoError(t, err)
+	assert.Equal(t, "upper", c.Name())
+}
@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CostGuardAction is what a CostGuard does with entries over budget.
+type CostGuardAction int
+
+const (
+	// CostGuardSample keeps 1 in CostGuardConfig.SampleRate entries over budget, stamped with
+	// a _sample_rate field so that counts can be extrapolated. It's the default.
+	CostGuardSample CostGuardAction = iota
+	// CostGuardDrop drops every entry over budget.
+	CostGuardDrop
+)
+
+func (a CostGuardAction) String() string {
+	if a == CostGuardDrop {
+		return "drop"
+	}
+	return "sample"
+}
+
+// ByteBudget limits the bytes written per hour and per day, in UTC. 0 is unlimited.
+type ByteBudget struct {
+	Hourly int64
+	Daily  int64
+}
+
+// CostGuardConfig configures a CostGuard.
+type CostGuardConfig struct {
+	// ByteBudget limits the bytes of all the entries.
+	ByteBudget
+	// Titles limits the bytes of the entries with a title, in addition to ByteBudget.
+	Titles map[string]ByteBudget
+	// Action is what is done with entries over budget.
+	Action CostGuardAction
+	// SampleRate is 1 in how many entries over budget are kept with CostGuardSample. Defaults
+	// to 100.
+	SampleRate int
+	// Diagnostics receives the diagnostic entries, as kayvee JSON lines. Defaults to os.Stderr.
+	Diagnostics io.Writer
+}
+
+// CostGuardStats counts the entries over budget.
+type CostGuardStats struct {
+	// Exceeded is the number of times a budget was exceeded, i.e. of diagnostic entries.
+	Exceeded uint64
+	// Sampled is the number of entries over budget that were kept by sampling.
+	Sampled uint64
+	// Dropped is the number of entries over budget that were dropped.
+	Dropped uint64
+}
+
+// CostGuard enforces byte budgets on the entries of the loggers whose formatter it wraps, so
+// that a runaway loop can't run up the log ingestion bill. Once an entry would exceed a
+// budget, entries are sampled or dropped until the budget's window ends, and a
+// kayvee-budget-exceeded diagnostic entry is written once per window.
+//
+// Set it per logger with SetFormatter, e.g. l.SetFormatter(guard.Wrap(kv.Format)). Loggers
+// sharing a CostGuard share its budgets.
+type CostGuard struct {
+	c CostGuardConfig
+
+	mu       sync.Mutex
+	total    budgetUsage
+	titles   map[string]*budgetUsage
+	overSeen uint64
+	stats    CostGuardStats
+}
+
+// budgetUsage counts the bytes written in the current windows of a budget.
+type budgetUsage struct {
+	hour, day               time.Time
+	hourBytes, dayBytes     int64
+	hourAlerted, dayAlerted bool
+}
+
+// NewCostGuard returns a CostGuard enforcing the budgets of `c`.
+func NewCostGuard(c CostGuardConfig) *CostGuard {
+	if c.SampleRate <= 0 {
+		c.SampleRate = 100
+	}
+	if c.Diagnostics == nil {
+		c.Diagnostics = os.Stderr
+	}
+	return &CostGuard{c: c, titles: map[string]*budgetUsage{}}
+}
+
+// Wrap returns a Formatter formatting entries with `formatter`, and enforcing the budgets on
+// the lines it returns.
+func (g *CostGuard) Wrap(formatter Formatter) Formatter {
+	return func(data map[string]interface{}) string {
+		line := formatter(data)
+		if line == "" {
+			return line
+		}
+		title, _ := data["title"].(string)
+		keep, sampled := g.admit(title, int64(len(line)+1))
+		if !keep {
+			return ""
+		}
+		if sampled && len(line) >= 2 && line[0] == '{' && line[len(line)-1] == '}' {
+			line = line[:len(line)-1] + `,"_sample_rate":` + strconv.Itoa(g.c.SampleRate) + "}"
+		}
+		return line
+	}
+}
+
+// Stats returns the number of entries over budget so far.
+func (g *CostGuard) Stats() CostGuardStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
+
+// admit checks an entry of `size` bytes titled `title` against the budgets, counting it if
+// it's kept. It returns whether to keep the entry, and whether it was kept by sampling.
+func (g *CostGuard) admit(title string, size int64) (keep, sampled bool) {
+	now := clock().UTC()
+	var diagnostics []map[string]interface{}
+	defer func() {
+		for _, d := range diagnostics {
+			io.WriteString(g.c.Diagnostics, formatJSON(d)+"\n")
+		}
+	}()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	usages := []*budgetUsage{&g.total}
+	budgets := []ByteBudget{g.c.ByteBudget}
+	names := []string{""}
+	if b, ok := g.c.Titles[title]; ok {
+		u := g.titles[title]
+		if u == nil {
+			u = &budgetUsage{}
+			g.titles[title] = u
+		}
+		usages, budgets, names = append(usages, u), append(budgets, b), append(names, title)
+	}
+
+	over := false
+	for i, u := range usages {
+		u.roll(now)
+		if u.over(budgets[i], size) {
+			over = true
+		}
+		for _, window := range u.exceeded(budgets[i], size) {
+			g.stats.Exceeded++
+			d := map[string]interface{}{
+				"title":       "kayvee-budget-exceeded",
+				"level":       Critical.String(),
+				"source":      "kayvee-cost-guard",
+				"window":      window,
+				"action":      g.c.Action.String(),
+				"entry_title": title,
+			}
+			if names[i] != "" {
+				d["budget_title"] = names[i]
+			}
+			if window == "hourly" {
+				d["limit_bytes"], d["used_bytes"] = budgets[i].Hourly, u.hourBytes
+			} else {
+				d["limit_bytes"], d["used_bytes"] = budgets[i].Daily, u.dayBytes
+			}
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	if over {
+		g.overSeen++
+		if g.c.Action == CostGuardDrop || (g.overSeen-1)%uint64(g.c.SampleRate) != 0 {
+			g.stats.Dropped++
+			return false, false
+		}
+		g.stats.Sampled++
+		sampled = true
+	}
+	for _, u := range usages {
+		u.hourBytes += size
+		u.dayBytes += size
+	}
+	return true, sampled
+}
+
+// roll starts new windows if `now` is past the current ones.
+func (u *budgetUsage) roll(now time.Time) {
+	if hour := now.Truncate(time.Hour); !hour.Equal(u.hour) {
+		u.hour, u.hourBytes, u.hourAlerted = hour, 0, false
+	}
+	if day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); !day.Equal(u.day) {
+		u.day, u.dayBytes, u.dayAlerted = day, 0, false
+	}
+}
+
+// over returns true if `size` more bytes exceed budget `b`.
+func (u *budgetUsage) over(b ByteBudget, size int64) bool {
+	return (b.Hourly > 0 && u.hourBytes+size > b.Hourly) || (b.Daily > 0 && u.dayBytes+size > b.Daily)
+}
+
+// exceeded returns the windows of budget `b` that `size` more bytes exceed for the first time
+// in the window.
+func (u *budgetUsage) exceeded(b ByteBudget, size int64) []string {
+	windows := []string{}
+	if b.Hourly > 0 && u.hourBytes+size > b.Hourly && !u.hourAlerted {
+		u.hourAlerted = true
+		windows = append(windows, "hourly")
+	}
+	if b.Daily > 0 && u.dayBytes+size > b.Daily && !u.dayAlerted {
+		u.dayAlerted = true
+		windows = append(windows, "daily")
+	}
+	return windows
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineSize returns the size of the line of an info entry logged by a "my-app" logger.
+func lineSize(title string, data M) int64 {
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, JSONFormatter, out)
+	l.InfoD(title, data)
+	return int64(out.Len())
+}
+
+func TestCostGuardSamplesOverBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+
+	diagnostics := &bytes.Buffer{}
+	guard := NewCostGuard(CostGuardConfig{
+		ByteBudget:  ByteBudget{Hourly: 4 * lineSize("loop", M{"i": 0})},
+		SampleRate:  3,
+		Diagnostics: diagnostics,
+	})
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, guard.Wrap(JSONFormatter), out)
+
+	for i := 0; i < 10; i++ {
+		l.InfoD("loop", M{"i": i})
+	}
+	entries := decodeLines(t, out)
+	// 4 entries fit in the budget, then 1 in 3 is kept
+	require.Len(t, entries, 6)
+	assert.Nil(t, entries[3]["_sample_rate"])
+	assert.Equal(t, float64(4), entries[4]["i"])
+	assert.Equal(t, float64(3), entries[4]["_sample_rate"])
+	assert.Equal(t, float64(7), entries[5]["i"])
+	assert.Equal(t, CostGuardStats{Exceeded: 1, Sampled: 2, Dropped: 4}, guard.Stats())
+
+	diags := decodeLines(t, diagnostics)
+	require.Len(t, diags, 1)
+	assert.Equal(t, "kayvee-budget-exceeded", diags[0]["title"])
+	assert.Equal(t, "critical", diags[0]["level"])
+	assert.Equal(t, "hourly", diags[0]["window"])
+	assert.Equal(t, "sample", diags[0]["action"])
+	assert.Equal(t, "loop", diags[0]["entry_title"])
+	assert.Equal(t, float64(4*lineSize("loop", M{"i": 0})), diags[0]["limit_bytes"])
+	assert.Nil(t, diags[0]["budget_title"])
+
+	// the budget renews with the next hour
+	now = now.Add(time.Hour)
+	out.Reset()
+	l.InfoD("loop", M{"i": 10})
+	require.Len(t, decodeLines(t, out), 1)
+	assert.Nil(t, decodeLines(t, out)[0]["_sample_rate"])
+}
+
+func TestCostGuardTitleBudgets(t *testing.T) {
+	diagnostics := &bytes.Buffer{}
+	guard := NewCostGuard(CostGuardConfig{
+		Titles:      map[string]ByteBudget{"noisy": {Daily: 2 * lineSize("noisy", M{})}},
+		Action:      CostGuardDrop,
+		Diagnostics: diagnostics,
+	})
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, guard.Wrap(JSONFormatter), out)
+
+	for i := 0; i < 5; i++ {
+		l.Info("noisy")
+		l.Info("quiet")
+	}
+	titles := []interface{}{}
+	for _, e := range decodeLines(t, out) {
+		titles = append(titles, e["title"])
+	}
+	assert.Equal(t, []interface{}{"noisy", "quiet", "noisy", "quiet", "quiet", "quiet", "quiet"}, titles)
+	assert.Equal(t, CostGuardStats{Exceeded: 1, Dropped: 3}, guard.Stats())
+
+	diags := decodeLines(t, diagnostics)
+	require.Len(t, diags, 1)
+	assert.Equal(t, "daily", diags[0]["window"])
+	assert.Equal(t, "drop", diags[0]["action"])
+	assert.Equal(t, "noisy", diags[0]["budget_title"])
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Schedule returns when a job runs next after `t`. It's the Schedule interface of
+// github.com/robfig/cron, so parsed cron specs can be used as is.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Every returns the Schedule of a job running every `d`.
+func Every(d time.Duration) Schedule {
+	return everySchedule(d)
+}
+
+// CronConfig configures a CronReporter.
+type CronConfig struct {
+	// Job names the job in the entries.
+	Job string
+	// HeartbeatInterval is the time between the heartbeats of a run. Defaults to a minute, and
+	// a negative interval disables heartbeats.
+	HeartbeatInterval time.Duration
+	// Schedule is the schedule of the job, for WatchSchedule.
+	Schedule Schedule
+	// Grace is how late a run can start before it's reported missed. It must be shorter than
+	// the time between runs. Defaults to a minute.
+	Grace time.Duration
+}
+
+// CronReporter reports the runs of a cron-style job: a "cron-run-started" entry when a run
+// starts, "cron-run-heartbeat" entries while it runs, and a "cron-run-finished" entry with its
+// duration and exit status. With WatchSchedule, runs that don't start on schedule are reported
+// with "cron-run-missed" entries. Entries have the job and the run_id of the run.
+type CronReporter struct {
+	l      Leveled
+	c      CronConfig
+	starts int64
+}
+
+// NewCronReporter returns a CronReporter logging to `l`.
+func NewCronReporter(l Leveled, c CronConfig) *CronReporter {
+	if c.HeartbeatInterval == 0 {
+		c.HeartbeatInterval = time.Minute
+	}
+	if c.Grace <= 0 {
+		c.Grace = time.Minute
+	}
+	return &CronReporter{l: l, c: c}
+}
+
+// CronRun is a run of a job, see CronReporter.Start.
+type CronRun struct {
+	r     *CronReporter
+	id    string
+	start time.Time
+	stop  context.CancelFunc
+	once  sync.Once
+}
+
+// Start reports that a run starts, and sends its heartbeats until it finishes or `ctx` is
+// done.
+func (r *CronReporter) Start(ctx context.Context) *CronRun {
+	atomic.AddInt64(&r.starts, 1)
+	b := make([]byte, 8)
+	io.ReadFull(entropy, b)
+	ctx, stop := context.WithCancel(ctx)
+	run := &CronRun{r: r, id: hex.EncodeToString(b), start: clock(), stop: stop}
+	r.l.InfoD("cron-run-started", run.fields())
+	if r.c.HeartbeatInterval > 0 {
+		StartHeartbeat(ctx, r.l, HeartbeatConfig{
+			Interval: r.c.HeartbeatInterval,
+			Title:    "cron-run-heartbeat",
+			Stats:    run.fields,
+		})
+	}
+	return run
+}
+
+// Run runs `f` as a run of the job, and returns its error.
+func (r *CronReporter) Run(ctx context.Context, f func(ctx context.Context) error) error {
+	run := r.Start(ctx)
+	err := f(ctx)
+	run.Finish(err)
+	return err
+}
+
+func (run *CronRun) fields() map[string]interface{} {
+	return M{"job": run.r.c.Job, "run_id": run.id}
+}
+
+// Finish reports that the run succeeded, or failed with `err`. Only the first call to Finish
+// or FinishExitCode is reported.
+func (run *CronRun) Finish(err error) {
+	data := M{}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	run.finish(err == nil, data)
+}
+
+// FinishExitCode reports that the run exited with `code`, e.g. the one of a child process.
+func (run *CronRun) FinishExitCode(code int) {
+	run.finish(code == 0, M{"exit_code": code})
+}
+
+func (run *CronRun) finish(success bool, data M) {
+	run.once.Do(func() {
+		run.stop()
+		for k, v := range run.fields() {
+			data[k] = v
+		}
+		data["duration_s"] = clock().Sub(run.start).Seconds()
+		if success {
+			data["status"] = "success"
+			run.r.l.InfoD("cron-run-finished", data)
+		} else {
+			data["status"] = "failure"
+			run.r.l.ErrorD("cron-run-finished", data)
+		}
+	})
+}
+
+// WatchSchedule reports the runs that don't start within Grace of the times of the Schedule,
+// with "cron-run-missed" entries holding the expected_at time, until `ctx` is done. It blocks,
+// so run it in its own goroutine:
+//
+//	go reporter.WatchSchedule(ctx)
+//
+// It returns right away without a Schedule.
+func (r *CronReporter) WatchSchedule(ctx context.Context) {
+	if r.c.Schedule == nil {
+		return
+	}
+	atomic.StoreInt64(&r.starts, 0)
+	for next := r.c.Schedule.Next(clock()); ; next = r.c.Schedule.Next(next) {
+		timer := time.NewTimer(next.Add(r.c.Grace).Sub(clock()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if atomic.SwapInt64(&r.starts, 0) == 0 {
+			r.l.ErrorD("cron-run-missed", M{
+				"job":         r.c.Job,
+				"expected_at": next.UTC().Format(time.RFC3339),
+				"grace_s":     r.c.Grace.Seconds(),
+			})
+		}
+	}
+}
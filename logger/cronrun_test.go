@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func newCronOutput() (*gatedWriter, KayveeLogger) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	l := New("my-worker")
+	l.SetConfig("my-worker", Info, kv.Format, out)
+	return out, l
+}
+
+func cronEntries(t *testing.T, out *gatedWriter) []map[string]interface{} {
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	return decodeLines(t, &out.buf)
+}
+
+func TestCronReporter(t *testing.T) {
+	now := time.Unix(0, 0)
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+
+	out, l := newCronOutput()
+	r := NewCronReporter(l, CronConfig{Job: "nightly-sync", HeartbeatInterval: -1})
+
+	assert.NoError(t, r.Run(context.Background(), func(ctx context.Context) error {
+		now = now.Add(90 * time.Second)
+		return nil
+	}))
+	err := errors.New("database unavailable")
+	assert.Equal(t, err, r.Run(context.Background(), func(ctx context.Context) error { return err }))
+	run := r.Start(context.Background())
+	run.FinishExitCode(2)
+	run.Finish(nil)
+
+	entries := cronEntries(t, out)
+	require.Len(t, entries, 6)
+	assert.Equal(t, "cron-run-started", entries[0]["title"])
+	assert.Equal(t, "nightly-sync", entries[0]["job"])
+	require.NotEmpty(t, entries[0]["run_id"])
+	assert.Equal(t, "cron-run-finished", entries[1]["title"])
+	assert.Equal(t, entries[0]["run_id"], entries[1]["run_id"])
+	assert.Equal(t, "success", entries[1]["status"])
+	assert.Equal(t, "info", entries[1]["level"])
+	assert.Equal(t, 90.0, entries[1]["duration_s"])
+
+	assert.NotEqual(t, entries[0]["run_id"], entries[2]["run_id"])
+	assert.Equal(t, "failure", entries[3]["status"])
+	assert.Equal(t, "error", entries[3]["level"])
+	assert.Equal(t, "database unavailable", entries[3]["error"])
+
+	assert.Equal(t, "failure", entries[5]["status"])
+	assert.Equal(t, 2.0, entries[5]["exit_code"], "only the first finish is reported")
+}
+
+func TestCronReporterHeartbeat(t *testing.T) {
+	out, l := newCronOutput()
+	r := NewCronReporter(l, CronConfig{Job: "nightly-sync", HeartbeatInterval: 5 * time.Millisecond})
+
+	run := r.Start(context.Background())
+	require.Eventually(t, func() bool { return len(out.lines()) >= 2 }, time.Second, time.Millisecond)
+	run.Finish(nil)
+
+	entries := cronEntries(t, out)
+	assert.Equal(t, "cron-run-heartbeat", entries[1]["title"])
+	assert.Equal(t, "nightly-sync", entries[1]["job"])
+	assert.Equal(t, entries[0]["run_id"], entries[1]["run_id"])
+}
+
+func TestCronReporterMissedRuns(t *testing.T) {
+	out, l := newCronOutput()
+	r := NewCronReporter(l, CronConfig{
+		Job:               "nightly-sync",
+		HeartbeatInterval: -1,
+		Schedule:          Every(40 * time.Millisecond),
+		Grace:             10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.WatchSchedule(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool { return len(cronEntries(t, out)) >= 1 }, time.Second, time.Millisecond)
+	entries := cronEntries(t, out)
+	assert.Equal(t, "cron-run-missed", entries[0]["title"])
+	assert.Equal(t, "error", entries[0]["level"])
+	assert.Equal(t, "nightly-sync", entries[0]["job"])
+	assert.Equal(t, 0.01, entries[0]["grace_s"])
+	assert.NotEmpty(t, entries[0]["expected_at"])
+}
+
+func TestCronReporterOnSchedule(t *testing.T) {
+	out, l := newCronOutput()
+	r := NewCronReporter(l, CronConfig{
+		Job:               "nightly-sync",
+		HeartbeatInterval: -1,
+		Schedule:          Every(time.Hour),
+		Grace:             time.Hour,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.WatchSchedule(ctx)
+		close(done)
+	}()
+	r.Start(ctx).Finish(nil)
+	cancel()
+	<-done
+
+	entries := cronEntries(t, out)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "cron-run-finished", entries[1]["title"])
+}
@@ -0,0 +1,26 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, NewCSVFormatter("title", "user", "amount", "tags"), buf)
+	l.InfoD("purchase", M{"user": "Doe, Jane", "amount": 12.5, "tags": []string{"a"}})
+	l.InfoD("purchase", M{"user": `say "hi"`})
+
+	assert.Equal(t, "title,user,amount,tags\n"+
+		`purchase,"Doe, Jane",12.5,"[""a""]"`+"\n"+
+		`purchase,"say ""hi""",,`+"\n", buf.String())
+}
+
+func TestTSVFormatter(t *testing.T) {
+	f := NewTSVFormatter("title", "level", "count")
+	assert.Equal(t, "title\tlevel\tcount\nsignup\tinfo\t3", f(M{"title": "signup", "level": "info", "count": 3}))
+	assert.Equal(t, "login\t\t", f(M{"title": "login"}))
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriteTimeout is returned by DeadlineWriter when the underlying writer doesn't finish a
+// write in time.
+var ErrWriteTimeout = errors.New("write timed out")
+
+// DeadlineWriterStats counts the writes a DeadlineWriter gave up on.
+type DeadlineWriterStats struct {
+	// TimedOut is the number of writes that were started but didn't finish before their
+	// deadline. They are still completed whenever the underlying writer unblocks.
+	TimedOut uint64
+	// Dropped is the number of writes that couldn't be started before their deadline because
+	// the underlying writer was still blocked on an earlier one.
+	Dropped uint64
+	// FellBack is the number of dropped writes that were written to the fallback instead.
+	FellBack uint64
+}
+
+// DeadlineWriter wraps a writer that may block, like a network socket or a full pipe, so
+// that a stuck log destination can never stall the code that is logging. Each Write waits at
+// most the configured timeout for the underlying writer.
+type DeadlineWriter struct {
+	w        io.Writer
+	fallback io.Writer
+	timeout  time.Duration
+
+	writes    chan deadlineWrite
+	closeOnce sync.Once
+
+	timedOut uint64
+	dropped  uint64
+	fellBack uint64
+}
+
+type deadlineWrite struct {
+	p      []byte
+	result chan deadlineResult
+}
+
+type deadlineResult struct {
+	n   int
+	err error
+}
+
+// NewDeadlineWriter returns a DeadlineWriter writing to `w`, giving up on writes that take
+// longer than `timeout`. If the underlying writer is still blocked on an earlier write when
+// the timeout expires, the write is sent to `fallback` instead (e.g. os.Stderr), or dropped if
+// `fallback` is nil.
+func NewDeadlineWriter(w io.Writer, timeout time.Duration, fallback io.Writer) *DeadlineWriter {
+	dw := &DeadlineWriter{
+		w:        w,
+		fallback: fallback,
+		timeout:  timeout,
+		writes:   make(chan deadlineWrite),
+	}
+	go dw.run()
+	return dw
+}
+
+func (dw *DeadlineWriter) run() {
+	for write := range dw.writes {
+		n, err := dw.w.Write(write.p)
+		write.result <- deadlineResult{n, err}
+	}
+}
+
+// Write implements io.Writer.
+func (dw *DeadlineWriter) Write(p []byte) (int, error) {
+	timer := time.NewTimer(dw.timeout)
+	defer timer.Stop()
+
+	write := deadlineWrite{
+		p:      append([]byte(nil), p...),
+		result: make(chan deadlineResult, 1),
+	}
+	select {
+	case dw.writes <- write:
+	case <-timer.C:
+		atomic.AddUint64(&dw.dropped, 1)
+		if dw.fallback != nil {
+			atomic.AddUint64(&dw.fellBack, 1)
+			return dw.fallback.Write(p)
+		}
+		return 0, ErrWriteTimeout
+	}
+
+	select {
+	case res := <-write.result:
+		return res.n, res.err
+	case <-timer.C:
+		atomic.AddUint64(&dw.timedOut, 1)
+		return 0, ErrWriteTimeout
+	}
+}
+
+// Stats returns the number of writes given up on so far.
+func (dw *DeadlineWriter) Stats() DeadlineWriterStats {
+	return DeadlineWriterStats{
+		TimedOut: atomic.LoadUint64(&dw.timedOut),
+		Dropped:  atomic.LoadUint64(&dw.dropped),
+		FellBack: atomic.LoadUint64(&dw.fellBack),
+	}
+}
+
+// Close stops the background goroutine once the write in progress, if any, finishes. The
+// DeadlineWriter must not be written to after Close.
+func (dw *DeadlineWriter) Close() error {
+	dw.closeOnce.Do(func() { close(dw.writes) })
+	return nil
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestDeadlineWriterPassesThrough(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	dw := NewDeadlineWriter(out, time.Second, nil)
+	defer dw.Close()
+
+	n, err := dw.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, []string{"hello"}, out.lines())
+
+	boom := errors.New("boom")
+	dw = NewDeadlineWriter(errWriter{boom}, time.Second, nil)
+	defer dw.Close()
+	_, err = dw.Write([]byte("hello\n"))
+	assert.Equal(t, boom, err)
+}
+
+func TestDeadlineWriterGivesUpOnBlockedWriter(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	fallback := &bytes.Buffer{}
+	dw := NewDeadlineWriter(out, 10*time.Millisecond, fallback)
+	defer dw.Close()
+
+	// started but blocked: times out, and is completed once the writer unblocks
+	start := time.Now()
+	_, err := dw.Write([]byte("first\n"))
+	assert.Equal(t, ErrWriteTimeout, err)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// can't be started: goes to the fallback
+	n, err := dw.Write([]byte("second\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	assert.Equal(t, "second\n", fallback.String())
+	assert.Equal(t, DeadlineWriterStats{TimedOut: 1, Dropped: 1, FellBack: 1}, dw.Stats())
+
+	close(out.gate)
+	_, err = dw.Write([]byte("third\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "third"}, out.lines())
+}
+
+func TestDeadlineWriterDropsWithoutFallback(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	defer close(out.gate)
+	dw := NewDeadlineWriter(out, 5*time.Millisecond, nil)
+	defer dw.Close()
+
+	dw.Write([]byte("first\n"))
+	n, err := dw.Write([]byte("second\n"))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, ErrWriteTimeout, err)
+	assert.Equal(t, DeadlineWriterStats{TimedOut: 1, Dropped: 1}, dw.Stats())
+}
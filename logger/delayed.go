@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// delayedWheel schedules the entries of LogAfter.
+var delayedWheel = newTimerWheel(100*time.Millisecond, 512)
+
+// DelayedEntry is an entry scheduled with LogAfter.
+type DelayedEntry struct {
+	l      Leveled
+	logLvl LogLevel
+	title  string
+	data   map[string]interface{}
+
+	rounds int
+	// state is delayedPending until the entry is either canceled or logged.
+	state int32
+}
+
+const (
+	delayedPending int32 = iota
+	delayedCanceled
+	delayedLogged
+)
+
+// LogAfter logs an entry through `l` once `delay` has passed, unless it's canceled first. It's
+// meant for entries that are only interesting when something takes too long, e.g. a warning
+// that an operation is still running, canceled when the operation completes:
+//
+//	slow := logger.LogAfter(lg, 30*time.Second, logger.Warning, "still-running", logger.M{"job": id})
+//	defer slow.Cancel()
+//
+// Delays are rounded up to the next 100ms. `data` must not be modified after the call.
+func LogAfter(l Leveled, delay time.Duration, logLvl LogLevel, title string, data map[string]interface{}) *DelayedEntry {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	d := &DelayedEntry{l: l, logLvl: logLvl, title: title, data: data}
+	delayedWheel.schedule(d, delay)
+	return d
+}
+
+// Cancel prevents the entry from being logged. It returns false if the entry was already
+// logged or canceled.
+func (d *DelayedEntry) Cancel() bool {
+	return atomic.CompareAndSwapInt32(&d.state, delayedPending, delayedCanceled)
+}
+
+// fire logs the entry unless it was canceled.
+func (d *DelayedEntry) fire() {
+	if atomic.CompareAndSwapInt32(&d.state, delayedPending, delayedLogged) {
+		logAtLevel(d.l, d.logLvl, d.title, d.data)
+	}
+}
+
+// timerWheel is a hashed timing wheel: entries are put in the slot the wheel will point to when
+// they are due, along with the number of full turns to wait for. Scheduling and canceling are
+// O(1) no matter how many entries are pending, unlike one time.Timer per entry. The wheel only
+// runs a goroutine while entries are pending.
+type timerWheel struct {
+	tick time.Duration
+
+	mu      sync.Mutex
+	slots   [][]*DelayedEntry
+	pos     int
+	pending int
+	running bool
+}
+
+func newTimerWheel(tick time.Duration, size int) *timerWheel {
+	return &timerWheel{tick: tick, slots: make([][]*DelayedEntry, size)}
+}
+
+func (w *timerWheel) schedule(d *DelayedEntry, delay time.Duration) {
+	ticks := int((delay + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slot := (w.pos + ticks) % len(w.slots)
+	d.rounds = (ticks - 1) / len(w.slots)
+	w.slots[slot] = append(w.slots[slot], d)
+	w.pending++
+	if !w.running {
+		w.running = true
+		go w.run()
+	}
+}
+
+func (w *timerWheel) run() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !w.advance() {
+			return
+		}
+	}
+}
+
+// advance moves the wheel by one tick and logs the entries that are due. It returns false,
+// and marks the wheel as stopped, once no entries are pending.
+func (w *timerWheel) advance() bool {
+	w.mu.Lock()
+	w.pos = (w.pos + 1) % len(w.slots)
+	var due []*DelayedEntry
+	kept := w.slots[w.pos][:0]
+	for _, d := range w.slots[w.pos] {
+		switch {
+		case atomic.LoadInt32(&d.state) != delayedPending:
+			w.pending--
+		case d.rounds > 0:
+			d.rounds--
+			kept = append(kept, d)
+		default:
+			due = append(due, d)
+			w.pending--
+		}
+	}
+	for i := len(kept); i < len(w.slots[w.pos]); i++ {
+		w.slots[w.pos][i] = nil
+	}
+	w.slots[w.pos] = kept
+	running := w.pending > 0
+	w.running = running
+	w.mu.Unlock()
+
+	for _, d := range due {
+		d.fire()
+	}
+	return running
+}
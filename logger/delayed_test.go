@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestTimerWheel(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Trace, kv.Format, out)
+
+	// a long tick so that only the test advances the wheel
+	w := newTimerWheel(time.Hour, 4)
+	schedule := func(delay time.Duration, title string) *DelayedEntry {
+		d := &DelayedEntry{l: l, logLvl: Warning, title: title, data: M{}}
+		w.schedule(d, delay)
+		return d
+	}
+	schedule(0, "t1")
+	schedule(2*time.Hour, "t2")
+	schedule(4*time.Hour, "t4")
+	schedule(6*time.Hour, "t6")
+	canceled := schedule(time.Hour, "canceled")
+	assert.True(t, canceled.Cancel())
+	assert.False(t, canceled.Cancel())
+
+	titlesAt := map[int][]string{}
+	for tick := 1; tick <= 7; tick++ {
+		running := w.advance()
+		lines := decodeLines(t, out)
+		out.Reset()
+		titlesAt[tick] = titlesOf(lines)
+		assert.Equal(t, tick < 6, running, "tick %d", tick)
+	}
+	assert.Equal(t, map[int][]string{
+		1: {"t1"}, 2: {"t2"}, 3: {}, 4: {"t4"}, 5: {}, 6: {"t6"}, 7: {},
+	}, titlesAt)
+}
+
+func TestLogAfter(t *testing.T) {
+	out := &lockedBuffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Trace, kv.Format, out)
+
+	d := LogAfter(l, 50*time.Millisecond, Warning, "still-running", M{"job": "j1"})
+	canceled := LogAfter(l, 50*time.Millisecond, Warning, "canceled", nil)
+	require.True(t, canceled.Cancel())
+
+	require.Eventually(t, func() bool { return out.String() != "" }, 2*time.Second, 10*time.Millisecond)
+	assert.False(t, d.Cancel())
+	assert.Contains(t, out.String(), `"title":"still-running"`)
+	assert.Contains(t, out.String(), `"job":"j1"`)
+	assert.NotContains(t, out.String(), "canceled")
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// devStackLines is the number of lines of a stack trace shown by DevFormatter before the
+// rest is folded.
+const devStackLines = 5
+
+// devStackKeys are the fields DevFormatter treats as stack traces.
+var devStackKeys = map[string]bool{
+	"stack":       true,
+	"stacktrace":  true,
+	"stack_trace": true,
+}
+
+// devHiddenKeys are the fields DevFormatter leaves out: they are either rendered in the
+// header line or are routing metadata that isn't useful locally.
+var devHiddenKeys = map[string]bool{
+	"title":   true,
+	"level":   true,
+	"source":  true,
+	"_kvmeta": true,
+}
+
+var devLevelColors = map[string]string{
+	"trace":    "\x1b[90m",
+	"debug":    "\x1b[36m",
+	"info":     "\x1b[32m",
+	"warning":  "\x1b[33m",
+	"error":    "\x1b[31m",
+	"critical": "\x1b[1;31m",
+}
+
+const devColorReset = "\x1b[0m"
+
+// devColors controls whether DevFormatter colors its output. Follows https://no-color.org.
+var devColors = os.Getenv("NO_COLOR") == ""
+
+// DevFormatter is a Formatter for local development. It prints a level-colored header
+// line followed by one aligned `key = value` line per field, renders nested objects
+// indented below their key, and folds long stack traces. It isn't meant to be machine
+// parseable; set KAYVEE_FORMAT=pretty to enable it for loggers created with New.
+func DevFormatter(data map[string]interface{}) string {
+	b := &strings.Builder{}
+
+	level, _ := data["level"].(string)
+	if devColors {
+		b.WriteString(devLevelColors[level])
+	}
+	fmt.Fprintf(b, "%-8s", strings.ToUpper(level))
+	if devColors {
+		b.WriteString(devColorReset)
+	}
+	fmt.Fprintf(b, " %v", data["title"])
+	if source, ok := data["source"].(string); ok && source != "" {
+		fmt.Fprintf(b, " (%s)", source)
+	}
+
+	writeDevFields(b, data, "    ", true)
+	return b.String()
+}
+
+func writeDevFields(b *strings.Builder, data map[string]interface{}, indent string, top bool) {
+	keys := make([]string, 0, len(data))
+	width := 0
+	for k := range data {
+		if top && devHiddenKeys[k] {
+			continue
+		}
+		keys = append(keys, k)
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b.WriteString("\n")
+		b.WriteString(indent)
+		switch v := data[k].(type) {
+		case map[string]interface{}:
+			b.WriteString(k)
+			b.WriteString(":")
+			writeDevFields(b, v, indent+"  ", false)
+		case M:
+			b.WriteString(k)
+			b.WriteString(":")
+			writeDevFields(b, v, indent+"  ", false)
+		case string:
+			fmt.Fprintf(b, "%-*s = ", width, k)
+			if devStackKeys[strings.ToLower(k)] {
+				writeDevStack(b, v, indent+strings.Repeat(" ", width+3))
+			} else {
+				b.WriteString(v)
+			}
+		default:
+			fmt.Fprintf(b, "%-*s = %s", width, k, devValue(v))
+		}
+	}
+}
+
+// writeDevStack writes the first lines of a stack trace, aligned under its key, and
+// replaces the rest with a count of the folded lines.
+func writeDevStack(b *strings.Builder, stack, indent string) {
+	lines := strings.Split(strings.TrimRight(stack, "\n"), "\n")
+	for i, line := range lines {
+		if i == devStackLines {
+			fmt.Fprintf(b, "\n%s... %d more lines", indent, len(lines)-devStackLines)
+			return
+		}
+		if i > 0 {
+			b.WriteString("\n")
+			b.WriteString(indent)
+		}
+		b.WriteString(strings.TrimSpace(line))
+	}
+}
+
+func devValue(v interface{}) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(bs)
+}
@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevFormatter(t *testing.T) {
+	devColors = false
+	defer func() { devColors = os.Getenv("NO_COLOR") == "" }()
+
+	out := DevFormatter(map[string]interface{}{
+		"title":   "request-finished",
+		"level":   "info",
+		"source":  "my-app",
+		"_kvmeta": map[string]interface{}{"routes": []interface{}{}},
+		"status":  200,
+		"op":      "getUser",
+		"user": map[string]interface{}{
+			"id":   "u1",
+			"name": "Ada",
+		},
+	})
+	expected := strings.Join([]string{
+		"INFO     request-finished (my-app)",
+		"    op     = getUser",
+		"    status = 200",
+		"    user:",
+		"      id   = u1",
+		"      name = Ada",
+	}, "\n")
+	assert.Equal(t, expected, out)
+}
+
+func TestDevFormatterFoldsStacks(t *testing.T) {
+	devColors = false
+	defer func() { devColors = os.Getenv("NO_COLOR") == "" }()
+
+	stack := "goroutine 1 [running]:\nmain.a()\n\t/a.go:1\nmain.b()\n\t/b.go:2\nmain.c()\n\t/c.go:3\n"
+	out := DevFormatter(map[string]interface{}{"title": "panic", "level": "error", "stack": stack})
+	expected := strings.Join([]string{
+		"ERROR    panic",
+		"    stack = goroutine 1 [running]:",
+		"            main.a()",
+		"            /a.go:1",
+		"            main.b()",
+		"            /b.go:2",
+		"            ... 2 more lines",
+	}, "\n")
+	assert.Equal(t, expected, out)
+}
+
+func TestDevFormatterColors(t *testing.T) {
+	devColors = true
+	defer func() { devColors = os.Getenv("NO_COLOR") == "" }()
+
+	out := DevFormatter(map[string]interface{}{"title": "oops", "level": "error"})
+	assert.Equal(t, "\x1b[31mERROR   \x1b[0m oops", out)
+}
+
+func TestPrettyFormatFromEnv(t *testing.T) {
+	devColors = false
+	defer func() { devColors = os.Getenv("NO_COLOR") == "" }()
+	os.Setenv("KAYVEE_FORMAT", "pretty")
+	defer os.Unsetenv("KAYVEE_FORMAT")
+
+	buf := &bytes.Buffer{}
+	lg := New("logger-tester")
+	lg.SetOutput(buf)
+	lg.Info("hello")
+	assert.True(t, strings.HasPrefix(buf.String(), "INFO     hello (logger-tester)\n"), buf.String())
+}
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// redactedValue replaces the before/after values of redacted fields in a Diff.
+const redactedValue = "[REDACTED]"
+
+// Diff computes a field-level diff of `old` and `new`, which may be structs or maps, for
+// audit logging of configuration and model changes. Both values are compared after a JSON
+// round trip, so field names follow json tags, and nested objects are flattened into dotted
+// paths. Only changed paths are reported:
+//
+//	log.InfoD("config-changed", logger.Diff(oldCfg, newCfg, "password"))
+//
+// produces `changed_fields` (the sorted list of changed paths) and `changes` (each path
+// mapped to its `before` and `after` values). A path that only exists on one side omits the
+// other value. Values of fields whose name or full path appears in `redact` are replaced by
+// "[REDACTED]" so secrets don't end up in audit trails.
+func Diff(old, new interface{}, redact ...string) M {
+	before := flattenForDiff(old)
+	after := flattenForDiff(new)
+	redacted := map[string]bool{}
+	for _, r := range redact {
+		redacted[r] = true
+	}
+
+	changes := map[string]interface{}{}
+	changed := []string{}
+	record := func(path string, b, a interface{}, hasB, hasA bool) {
+		change := M{}
+		if hasB {
+			change["before"] = redactIfNeeded(path, b, redacted)
+		}
+		if hasA {
+			change["after"] = redactIfNeeded(path, a, redacted)
+		}
+		changes[path] = change
+		changed = append(changed, path)
+	}
+	for path, b := range before {
+		a, ok := after[path]
+		if !ok {
+			record(path, b, nil, true, false)
+		} else if !reflect.DeepEqual(a, b) {
+			record(path, b, a, true, true)
+		}
+	}
+	for path, a := range after {
+		if _, ok := before[path]; !ok {
+			record(path, nil, a, false, true)
+		}
+	}
+	sort.Strings(changed)
+
+	return M{
+		"changed_fields": changed,
+		"changes":        changes,
+	}
+}
+
+func redactIfNeeded(path string, v interface{}, redacted map[string]bool) interface{} {
+	if redacted[path] {
+		return redactedValue
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 && redacted[path[i+1:]] {
+		return redactedValue
+	}
+	return v
+}
+
+// flattenForDiff converts `v` to a map of dotted paths to leaf values. Values that can't be
+// represented as a JSON object are stored under the "value" path.
+func flattenForDiff(v interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	if v == nil {
+		return out
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		out["value"] = v
+		return out
+	}
+	var generic interface{}
+	if err := json.Unmarshal(bs, &generic); err != nil {
+		out["value"] = v
+		return out
+	}
+	obj, ok := generic.(map[string]interface{})
+	if !ok {
+		out["value"] = generic
+		return out
+	}
+	flattenInto(out, "", obj)
+	return out
+}
+
+func flattenInto(out map[string]interface{}, prefix string, obj map[string]interface{}) {
+	for k, v := range obj {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			flattenInto(out, path, sub)
+			continue
+		}
+		out[path] = v
+	}
+}
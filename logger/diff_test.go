@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type diffTestConfig struct {
+	Name     string            `json:"name"`
+	Replicas int               `json:"replicas"`
+	Password string            `json:"password"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+func TestDiffStructs(t *testing.T) {
+	old := diffTestConfig{Name: "svc", Replicas: 2, Password: "hunter2", Labels: map[string]string{"team": "a"}}
+	new := diffTestConfig{Name: "svc", Replicas: 3, Password: "hunter3", Labels: map[string]string{"team": "a", "tier": "web"}}
+
+	d := Diff(old, new, "password")
+	assert.Equal(t, []string{"labels.tier", "password", "replicas"}, d["changed_fields"])
+	assert.Equal(t, map[string]interface{}{
+		"labels.tier": M{"after": "web"},
+		"password":    M{"before": "[REDACTED]", "after": "[REDACTED]"},
+		"replicas":    M{"before": 2.0, "after": 3.0},
+	}, d["changes"])
+}
+
+func TestDiffMaps(t *testing.T) {
+	d := Diff(M{"a": 1, "b": M{"c": "x"}}, M{"a": 1})
+	assert.Equal(t, []string{"b.c"}, d["changed_fields"])
+	assert.Equal(t, map[string]interface{}{"b.c": M{"before": "x"}}, d["changes"])
+
+	d = Diff(M{"a": 1}, M{"a": 1})
+	assert.Equal(t, []string{}, d["changed_fields"])
+}
+
+func TestDiffLogs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := New("logger-tester")
+	lg.SetOutput(buf)
+	lg.InfoD("config-changed", Diff(M{"a": 1}, M{"a": 2}))
+
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, []interface{}{"a"}, out["changed_fields"])
+}
@@ -0,0 +1,122 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DurationFormat is how time.Duration field values are serialized.
+type DurationFormat int32
+
+const (
+	// DurationNanoseconds serializes durations as integer nanoseconds, which is what
+	// encoding/json does. It's the default.
+	DurationNanoseconds DurationFormat = iota
+	// DurationMilliseconds serializes durations as float milliseconds, e.g. 1.5.
+	DurationMilliseconds
+	// DurationISO8601 serializes durations as ISO 8601 strings, e.g. "PT1M30.5S".
+	DurationISO8601
+)
+
+var durationFormat int32
+
+// SetDurationFormat sets how time.Duration field values are serialized by every logger, and
+// by the kayvee middleware for response times, so that all durations of a service have the
+// same unit.
+func SetDurationFormat(f DurationFormat) {
+	atomic.StoreInt32(&durationFormat, int32(f))
+}
+
+// FormatDuration returns `d` serialized according to the DurationFormat.
+func FormatDuration(d time.Duration) interface{} {
+	switch DurationFormat(atomic.LoadInt32(&durationFormat)) {
+	case DurationMilliseconds:
+		return float64(d) / float64(time.Millisecond)
+	case DurationISO8601:
+		return formatISO8601Duration(d)
+	}
+	return int64(d)
+}
+
+// FormatDurations replaces the time.Duration values of `data` according to the
+// DurationFormat, for entries that aren't logged by a logger of this package, e.g. those of the
+// kv middleware.
+func FormatDurations(data map[string]interface{}) {
+	if DurationFormat(atomic.LoadInt32(&durationFormat)) == DurationNanoseconds {
+		return
+	}
+	for k, v := range data {
+		if d, ok := v.(time.Duration); ok {
+			data[k] = FormatDuration(d)
+		}
+	}
+}
+
+// durationNanoseconds returns the nanoseconds of the duration `v`, either a time.Duration or
+// one serialized by FormatDuration, and false if it isn't one.
+func durationNanoseconds(v interface{}) (int64, bool) {
+	format := DurationFormat(atomic.LoadInt32(&durationFormat))
+	switch v := v.(type) {
+	case time.Duration:
+		return int64(v), true
+	case string:
+		// "-PT1M30.5S" is "-1m30.5s" for time.ParseDuration
+		s := strings.Replace(v, "PT", "", 1)
+		if format != DurationISO8601 || s == v {
+			return 0, false
+		}
+		d, err := time.ParseDuration(strings.ToLower(s))
+		return int64(d), err == nil
+	case int64:
+		if format == DurationNanoseconds {
+			return v, true
+		}
+	case int:
+		if format == DurationNanoseconds {
+			return int64(v), true
+		}
+	case float64:
+		if format == DurationMilliseconds {
+			return int64(v * float64(time.Millisecond)), true
+		}
+		if format == DurationNanoseconds {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// formatISO8601Duration formats `d` as an ISO 8601 duration with hours, minutes and seconds.
+func formatISO8601Duration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	b := &strings.Builder{}
+	// math.MinInt64 can't be negated, but its magnitude fits in a uint64
+	u := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		u = -u
+	}
+	b.WriteString("PT")
+	hours := u / uint64(time.Hour)
+	u -= hours * uint64(time.Hour)
+	minutes := u / uint64(time.Minute)
+	u -= minutes * uint64(time.Minute)
+	if hours > 0 {
+		b.WriteString(strconv.FormatUint(hours, 10) + "H")
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.FormatUint(minutes, 10) + "M")
+	}
+	if u > 0 {
+		secs := strconv.FormatUint(u/uint64(time.Second), 10)
+		if frac := u % uint64(time.Second); frac > 0 {
+			secs += strings.TrimRight("."+strconv.FormatUint(frac+uint64(time.Second), 10)[1:], "0")
+		}
+		b.WriteString(secs + "S")
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDuration(t *testing.T) {
+	defer SetDurationFormat(DurationNanoseconds)
+
+	tests := []struct {
+		d       time.Duration
+		ns      interface{}
+		ms      interface{}
+		iso8601 interface{}
+	}{
+		{0, int64(0), 0.0, "PT0S"},
+		{1500 * time.Microsecond, int64(1500000), 1.5, "PT0.0015S"},
+		{90*time.Second + 500*time.Millisecond, int64(90500000000), 90500.0, "PT1M30.5S"},
+		{26*time.Hour + 3*time.Second, int64(93603000000000), 93603000.0, "PT26H3S"},
+		{-2 * time.Minute, int64(-120000000000), -120000.0, "-PT2M"},
+		{time.Duration(math.MinInt64), int64(math.MinInt64), float64(math.MinInt64) / 1e6, "-PT2562047H47M16.854775808S"},
+	}
+	for _, test := range tests {
+		SetDurationFormat(DurationNanoseconds)
+		assert.Equal(t, test.ns, FormatDuration(test.d), test.d.String())
+		SetDurationFormat(DurationMilliseconds)
+		assert.Equal(t, test.ms, FormatDuration(test.d), test.d.String())
+		SetDurationFormat(DurationISO8601)
+		assert.Equal(t, test.iso8601, FormatDuration(test.d), test.d.String())
+	}
+}
+
+func TestDurationFieldsUseDurationFormat(t *testing.T) {
+	defer SetDurationFormat(DurationNanoseconds)
+	SetDurationFormat(DurationMilliseconds)
+
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, JSONFormatter, out)
+	l.InfoD("t", M{"elapsed": 2 * time.Second})
+	l.Log(Info, "typed", KV("elapsed", 250*time.Microsecond))
+
+	lines := decodeLines(t, out)
+	assert.Equal(t, 2000.0, lines[0]["elapsed"])
+	assert.Equal(t, 0.25, lines[1]["elapsed"])
+}
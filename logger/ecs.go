@@ -0,0 +1,50 @@
+package logger
+
+import "time"
+
+// ecsVersion is the Elastic Common Schema version ECSFormatter conforms to.
+const ecsVersion = "1.6.0"
+
+// ecsFields maps kayvee fields, including the ones logged by the kayvee middleware, to their
+// ECS equivalents. When an entry has several fields with the same equivalent, e.g. trace_id
+// and trace-id, the last one in sorted order wins, i.e. trace_id, span_id and stacktrace.
+var ecsFields = map[string]string{
+	"title":         "message",
+	"level":         "log.level",
+	"source":        "service.name",
+	"deploy_env":    "service.environment",
+	"trace_id":      "trace.id",
+	"trace-id":      "trace.id",
+	"span_id":       "span.id",
+	"span-id":       "span.id",
+	"error":         "error.message",
+	"error_type":    "error.type",
+	"stack":         "error.stack_trace",
+	"stacktrace":    "error.stack_trace",
+	"stack_trace":   "error.stack_trace",
+	"method":        "http.request.method",
+	"path":          "url.path",
+	"params":        "url.query",
+	"ip":            "client.ip",
+	"status-code":   "http.response.status_code",
+	"response-size": "http.response.body.bytes",
+	"response-time": "event.duration",
+}
+
+// ECSFormatter is a Formatter emitting entries that conform to the Elastic Common Schema,
+// so that they can be indexed by Elasticsearch or OpenSearch without ingest pipelines. The
+// standard kayvee fields are mapped to their ECS equivalents (e.g. title to message, level
+// to log.level, source to service.name), entries are stamped with @timestamp, and fields
+// without an ECS equivalent are kept as is. event.duration is written as integer nanoseconds,
+// as ECS requires, whatever the DurationFormat. Set KAYVEE_FORMAT=ecs to enable it for loggers
+// created with New.
+func ECSFormatter(data map[string]interface{}) string {
+	out := make(map[string]interface{}, len(data)+2)
+	mapFields(out, data, ecsFields)
+	if d, ok := durationNanoseconds(out["event.duration"]); ok {
+		out["event.duration"] = d
+	}
+	out["@timestamp"] = clock().UTC().Format(time.RFC3339Nano)
+	out["ecs.version"] = ecsVersion
+	return formatJSON(out)
+}
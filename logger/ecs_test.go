@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECSFormatter(t *testing.T) {
+	SetClock(func() time.Time { return time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC) })
+	defer SetClock(nil)
+	os.Setenv("KAYVEE_FORMAT", "ecs")
+	defer os.Unsetenv("KAYVEE_FORMAT")
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetOutput(buf)
+	l.ErrorD("request-failed", M{
+		"trace_id":    "abc",
+		"trace-id":    "def",
+		"error":       errors.New("boom"),
+		"status-code": 500,
+		"user":        "u1",
+	})
+
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "2024-01-01T10:00:00Z", e["@timestamp"])
+	assert.Equal(t, ecsVersion, e["ecs.version"])
+	assert.Equal(t, "request-failed", e["message"])
+	assert.Equal(t, "error", e["log.level"])
+	assert.Equal(t, "my-app", e["service.name"])
+	assert.Equal(t, "abc", e["trace.id"])
+	assert.Equal(t, "boom", e["error.message"])
+	assert.Equal(t, float64(500), e["http.response.status_code"])
+	assert.Equal(t, "u1", e["user"])
+	for _, k := range []string{"title", "level", "source", "trace_id", "trace-id", "error", "status-code"} {
+		assert.NotContains(t, e, k)
+	}
+}
+
+func TestECSFormatterEventDuration(t *testing.T) {
+	defer SetDurationFormat(DurationNanoseconds)
+	for _, f := range []DurationFormat{DurationNanoseconds, DurationMilliseconds, DurationISO8601} {
+		SetDurationFormat(f)
+		out := ECSFormatter(map[string]interface{}{"response-time": FormatDuration(-1500 * time.Millisecond)})
+		assert.Contains(t, out, `"event.duration":-1500000000`, f)
+	}
+}
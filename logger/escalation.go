@@ -0,0 +1,182 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// MaxEscalation is the longest an escalation may last, so that a forgotten one still ends.
+const MaxEscalation = 24 * time.Hour
+
+// Escalation is a temporary lowering of the log level of every logger of the process, e.g. to
+// get debug entries while investigating an incident.
+type Escalation struct {
+	// Level is the level loggers log at, unless theirs is lower already.
+	Level LogLevel
+	// Until is when the escalation ends and loggers go back to their own level.
+	Until time.Time
+	// Reason says why logging was escalated, e.g. an incident id.
+	Reason string
+}
+
+// escalation is the current Escalation, or nil.
+var escalation atomic.Pointer[Escalation]
+
+// Escalate makes every logger log at `level` for `d`, then automatically go back to its own
+// level. It replaces the current escalation, if any. `d` must be positive and at most
+// MaxEscalation.
+func Escalate(level LogLevel, d time.Duration, reason string) error {
+	if d <= 0 || d > MaxEscalation {
+		return fmt.Errorf("escalations must last between 0 and %s", MaxEscalation)
+	}
+	escalation.Store(&Escalation{Level: level, Until: clock().Add(d), Reason: reason})
+	return nil
+}
+
+// EndEscalation makes every logger go back to its own level now.
+func EndEscalation() {
+	escalation.Store(nil)
+}
+
+// CurrentEscalation returns the escalation in effect, and false if there is none.
+func CurrentEscalation() (Escalation, bool) {
+	e := escalation.Load()
+	if e == nil || !clock().Before(e.Until) {
+		return Escalation{}, false
+	}
+	return *e, true
+}
+
+// level returns the level `l` logs at, taking the escalation into account.
+func (l *Logger) level() LogLevel {
+	if e := escalation.Load(); e != nil && e.Level < l.logLvl && clock().Before(e.Until) {
+		return e.Level
+	}
+	return l.logLvl
+}
+
+// EscalationRequest is an escalation requested remotely, see PollEscalation.
+type EscalationRequest struct {
+	Level    LogLevel
+	Duration time.Duration
+	Reason   string
+}
+
+// EscalationPollConfig configures PollEscalation.
+type EscalationPollConfig struct {
+	// Interval is the time between polls. Defaults to 30s.
+	Interval time.Duration
+	// Fetch returns the escalation requested remotely, e.g. by a feature flag, or nil if
+	// there is none.
+	Fetch func(ctx context.Context) (*EscalationRequest, error)
+	// OnError is called with the errors of Fetch and of the escalations it returns. They're
+	// ignored by default, and the current escalation is kept.
+	OnError func(error)
+}
+
+// PollEscalation applies the escalation returned by Fetch every interval until `ctx` is
+// canceled, so that logging can be escalated without a deploy. An escalation is started
+// when Fetch returns a request that differs from the one it last returned, and ended when
+// Fetch stops returning one. A request that is still returned once its escalation ended
+// isn't started again, so a forgotten flag can't keep logging escalated.
+func PollEscalation(ctx context.Context, c EscalationPollConfig) {
+	if c.Interval <= 0 {
+		c.Interval = 30 * time.Second
+	}
+	if c.OnError == nil {
+		c.OnError = func(error) {}
+	}
+	var last *EscalationRequest
+	poll := func() {
+		req, err := c.Fetch(ctx)
+		if err != nil {
+			c.OnError(err)
+			return
+		}
+		if req == nil {
+			if last != nil {
+				EndEscalation()
+				last = nil
+			}
+			return
+		}
+		if last != nil && *last == *req {
+			return
+		}
+		if err := Escalate(req.Level, req.Duration, req.Reason); err != nil {
+			c.OnError(err)
+			return
+		}
+		last = req
+	}
+	go func() {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			poll()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// escalationState is the JSON served by EscalationHandler.
+type escalationState struct {
+	Escalated bool       `json:"escalated"`
+	Level     string     `json:"level,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// EscalationHandler returns an http.Handler for debug endpoints exposing the escalation:
+//
+//   - GET returns the escalation in effect as JSON, with escalated, level, until and reason.
+//   - POST escalates according to the level, duration (e.g. "15m") and reason form values.
+//   - DELETE ends the escalation.
+//
+// POST and DELETE respond like GET.
+func EscalationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if err := escalateFromForm(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			EndEscalation()
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		state := escalationState{}
+		if e, ok := CurrentEscalation(); ok {
+			state = escalationState{Escalated: true, Level: e.Level.String(), Until: &e.Until, Reason: e.Reason}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	})
+}
+
+// escalateFromForm escalates according to the form values of `r`.
+func escalateFromForm(r *http.Request) error {
+	level, err := ParseLevel(r.FormValue("level"))
+	if err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(r.FormValue("duration"))
+	if err != nil {
+		return errors.New("invalid duration")
+	}
+	return Escalate(level, d, r.FormValue("reason"))
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+	defer EndEscalation()
+
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, JSONFormatter, out)
+
+	require.NoError(t, Escalate(Debug, 15*time.Minute, "INC-42"))
+	e, ok := CurrentEscalation()
+	require.True(t, ok)
+	assert.Equal(t, Escalation{Level: Debug, Until: now.Add(15 * time.Minute), Reason: "INC-42"}, e)
+	l.Debug("escalated")
+	l.Trace("still-suppressed")
+	l.Log(Debug, "escalated-fields")
+
+	// the escalation ends by itself
+	now = now.Add(15 * time.Minute)
+	l.Debug("reverted")
+	_, ok = CurrentEscalation()
+	assert.False(t, ok)
+
+	// escalations don't raise the level of loggers
+	require.NoError(t, Escalate(Error, time.Minute, ""))
+	l.Info("kept")
+	EndEscalation()
+
+	titles := []interface{}{}
+	for _, entry := range decodeLines(t, out) {
+		titles = append(titles, entry["title"])
+	}
+	assert.Equal(t, []interface{}{"escalated", "escalated-fields", "kept"}, titles)
+
+	assert.Error(t, Escalate(Debug, 0, ""))
+	assert.Error(t, Escalate(Debug, MaxEscalation+time.Second, ""))
+}
+
+func TestEscalationHandler(t *testing.T) {
+	defer EndEscalation()
+	h := EscalationHandler()
+	state := func(method string, form url.Values) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, "/debug/kayvee/escalation", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		m := map[string]interface{}{}
+		json.Unmarshal(rec.Body.Bytes(), &m)
+		return rec.Code, m
+	}
+
+	code, m := state("GET", nil)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, map[string]interface{}{"escalated": false}, m)
+
+	code, m = state("POST", url.Values{"level": {"debug"}, "duration": {"15m"}, "reason": {"INC-42"}})
+	assert.Equal(t, 200, code)
+	assert.Equal(t, true, m["escalated"])
+	assert.Equal(t, "debug", m["level"])
+	assert.Equal(t, "INC-42", m["reason"])
+	assert.NotEmpty(t, m["until"])
+
+	code, _ = state("POST", url.Values{"level": {"loud"}, "duration": {"15m"}})
+	assert.Equal(t, 400, code)
+	code, _ = state("POST", url.Values{"level": {"debug"}, "duration": {"forever"}})
+	assert.Equal(t, 400, code)
+	code, _ = state("PUT", nil)
+	assert.Equal(t, 405, code)
+
+	code, m = state("DELETE", nil)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, map[string]interface{}{"escalated": false}, m)
+}
+
+func TestPollEscalation(t *testing.T) {
+	defer EndEscalation()
+	var (
+		mu  sync.Mutex
+		req *EscalationRequest
+	)
+	set := func(r *EscalationRequest) {
+		mu.Lock()
+		defer mu.Unlock()
+		req = r
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	PollEscalation(ctx, EscalationPollConfig{
+		Interval: time.Millisecond,
+		Fetch: func(context.Context) (*EscalationRequest, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return req, nil
+		},
+	})
+	escalated := func() bool {
+		_, ok := CurrentEscalation()
+		return ok
+	}
+
+	set(&EscalationRequest{Level: Debug, Duration: time.Hour, Reason: "flag"})
+	require.Eventually(t, escalated, time.Second, time.Millisecond)
+	e, _ := CurrentEscalation()
+	assert.Equal(t, "flag", e.Reason)
+
+	// a request that keeps being returned isn't started again once ended
+	EndEscalation()
+	time.Sleep(10 * time.Millisecond)
+	assert.False(t, escalated())
+
+	set(nil)
+	time.Sleep(10 * time.Millisecond)
+	set(&EscalationRequest{Level: Debug, Duration: time.Hour, Reason: "flag"})
+	require.Eventually(t, escalated, time.Second, time.Millisecond)
+
+	set(nil)
+	require.Eventually(t, func() bool { return !escalated() }, time.Second, time.Millisecond)
+}
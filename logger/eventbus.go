@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SlowSubscriberPolicy is what happens to the entries of a subscriber whose queue is full.
+type SlowSubscriberPolicy int
+
+const (
+	// DropNewest drops the entries published while the queue is full. It's the default.
+	DropNewest SlowSubscriberPolicy = iota
+	// DropOldest drops the entry queued first to make room.
+	DropOldest
+	// Disconnect unsubscribes the subscriber, closing its channel.
+	Disconnect
+)
+
+// EntryFilter selects the entries a subscriber receives. Nil selects every entry.
+type EntryFilter func(e Entry) bool
+
+// SubscribeConfig configures a Subscription.
+type SubscribeConfig struct {
+	// QueueSize is the number of entries queued for the subscriber. Defaults to 100.
+	QueueSize int
+	// Policy defaults to DropNewest.
+	Policy SlowSubscriberPolicy
+}
+
+// Subscription receives the entries logged by every logger of the process that match its
+// filter, see Subscribe.
+type Subscription struct {
+	// C receives the entries. It's closed when the subscription ends. The entries must not be
+	// modified.
+	C <-chan Entry
+
+	c       chan Entry
+	filter  EntryFilter
+	policy  SlowSubscriberPolicy
+	dropped uint64
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// subscriptions are the current Subscriptions, or nil. The slice is replaced, not modified.
+var (
+	subscriptions   atomic.Pointer[[]*Subscription]
+	subscriptionsMu sync.Mutex
+)
+
+// Subscribe returns a Subscription to the entries logged by every logger of the process that
+// match `filter`, e.g. for in-app error counters or admin UIs. Entries are published
+// synchronously by the goroutine logging, without blocking: when the queue of a subscriber is
+// full, the policy of `c` applies. Entries below the level of their logger aren't published.
+func Subscribe(filter EntryFilter, c SubscribeConfig) *Subscription {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	ch := make(chan Entry, c.QueueSize)
+	s := &Subscription{C: ch, c: ch, filter: filter, policy: c.Policy}
+
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	subs := []*Subscription{s}
+	if current := subscriptions.Load(); current != nil {
+		subs = append(subs, *current...)
+	}
+	subscriptions.Store(&subs)
+	return s
+}
+
+// Unsubscribe ends the subscription, and closes C. Entries still queued can be received.
+func (s *Subscription) Unsubscribe() {
+	subscriptionsMu.Lock()
+	if current := subscriptions.Load(); current != nil {
+		subs := make([]*Subscription, 0, len(*current))
+		for _, sub := range *current {
+			if sub != s {
+				subs = append(subs, sub)
+			}
+		}
+		if len(subs) == 0 {
+			subscriptions.Store(nil)
+		} else {
+			subscriptions.Store(&subs)
+		}
+	}
+	subscriptionsMu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.c)
+	}
+}
+
+// Dropped returns the number of entries dropped because the queue was full, including the one
+// that disconnected the subscriber with Disconnect.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// publish hands `e` to the subscriptions it matches.
+func publish(subs []*Subscription, e Entry) {
+	for _, s := range subs {
+		if s.filter == nil || s.filter(e) {
+			if !s.send(e) {
+				s.Unsubscribe()
+			}
+		}
+	}
+}
+
+// send queues `e` according to the policy, and returns false if the subscriber must be
+// disconnected.
+func (s *Subscription) send(e Entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	for {
+		select {
+		case s.c <- e:
+			return true
+		default:
+		}
+		switch s.policy {
+		case DropOldest:
+			select {
+			case <-s.c:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		case Disconnect:
+			atomic.AddUint64(&s.dropped, 1)
+			s.closed = true
+			close(s.c)
+			return false
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+			return true
+		}
+	}
+}
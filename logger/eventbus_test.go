@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+// received returns the titles of the entries queued for `s`.
+func received(s *Subscription) []string {
+	titles := []string{}
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return titles
+			}
+			titles = append(titles, e.Title)
+		default:
+			return titles
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, &bytes.Buffer{})
+
+	all := Subscribe(nil, SubscribeConfig{})
+	defer all.Unsubscribe()
+	errors := Subscribe(func(e Entry) bool { return e.Level >= Error }, SubscribeConfig{})
+	defer errors.Unsubscribe()
+
+	l.Debug("suppressed")
+	l.Info("a")
+	l.ErrorD("b", M{"n": 1})
+	assert.Equal(t, []string{"a", "b"}, received(all))
+	assert.Equal(t, []string{"b"}, received(errors))
+
+	errors.Unsubscribe()
+	l.Error("c")
+	assert.Equal(t, []string{"c"}, received(all))
+	_, ok := <-errors.C
+	assert.False(t, ok)
+}
+
+func TestSlowSubscriberPolicy(t *testing.T) {
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, &bytes.Buffer{})
+
+	for _, test := range []struct {
+		policy   SlowSubscriberPolicy
+		received []string
+	}{
+		{DropNewest, []string{"a", "b"}},
+		{DropOldest, []string{"c", "d"}},
+		{Disconnect, []string{"a", "b"}},
+	} {
+		s := Subscribe(nil, SubscribeConfig{QueueSize: 2, Policy: test.policy})
+		for _, title := range []string{"a", "b", "c", "d"} {
+			l.Info(title)
+		}
+		assert.Equal(t, test.received, received(s))
+		s.Unsubscribe()
+	}
+
+	s := Subscribe(nil, SubscribeConfig{QueueSize: 1, Policy: Disconnect})
+	l.Info("a")
+	l.Info("b")
+	assert.Equal(t, uint64(1), s.Dropped())
+	<-s.C
+	_, ok := <-s.C
+	assert.False(t, ok, "the subscriber is disconnected")
+	assert.Nil(t, subscriptions.Load())
+}
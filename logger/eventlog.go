@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// EventLogConfig configures an EventLogWriter.
+type EventLogConfig struct {
+	// Source is the event source the events are reported by, usually the name of the service.
+	Source string
+	// Install registers Source in the registry if it isn't registered yet, which requires
+	// administrator rights. Installers usually register it instead.
+	Install bool
+	// EventID is the id of the events. Defaults to 1.
+	EventID uint32
+}
+
+// eventSink reports events to the Event Log, like the eventlog.Log of golang.org/x/sys.
+type eventSink interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+// EventLogWriter writes log lines to the Windows Event Log, so that services running on
+// Windows hosts integrate with native log collection. Lines are reported as information,
+// warning or error events according to their level:
+//
+//	w, err := logger.NewEventLogWriter(logger.EventLogConfig{Source: "my-service"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer w.Close()
+//	l.SetOutput(w)
+//
+// It's only supported on Windows.
+type EventLogWriter struct {
+	sink    eventSink
+	eventID uint32
+}
+
+// NewEventLogWriter returns an EventLogWriter reporting events as the source of `c`.
+func NewEventLogWriter(c EventLogConfig) (*EventLogWriter, error) {
+	if c.Source == "" {
+		return nil, errors.New("event log writer requires a Source")
+	}
+	if c.EventID == 0 {
+		c.EventID = 1
+	}
+	sink, err := openEventLog(c)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLogWriter{sink: sink, eventID: c.EventID}, nil
+}
+
+// Write reports every line of `p` as an event.
+func (w *EventLogWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		msg := string(line)
+		var err error
+		switch eventLevel(line) {
+		case Warning:
+			err = w.sink.Warning(w.eventID, msg)
+		case Error, Critical:
+			err = w.sink.Error(w.eventID, msg)
+		default:
+			err = w.sink.Info(w.eventID, msg)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close releases the event log handle.
+func (w *EventLogWriter) Close() error {
+	return w.sink.Close()
+}
+
+// eventLevel returns the level of the kayvee JSON line `line`, or Info if it has none.
+func eventLevel(line []byte) LogLevel {
+	var entry struct {
+		Level string `json:"level"`
+	}
+	if json.Unmarshal(line, &entry) != nil {
+		return Info
+	}
+	return levelFromName(strings.ToLower(entry.Level), Info)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logger
+
+import "errors"
+
+var errEventLogUnsupported = errors.New("the event log is only supported on Windows")
+
+func openEventLog(c EventLogConfig) (eventSink, error) {
+	return nil, errEventLogUnsupported
+}
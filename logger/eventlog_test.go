@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEventSink records the events reported to it.
+type fakeEventSink struct {
+	events []string
+	closed bool
+}
+
+func (s *fakeEventSink) report(kind string, eid uint32, msg string) error {
+	s.events = append(s.events, fmt.Sprintf("%s %d %s", kind, eid, msg))
+	return nil
+}
+
+func (s *fakeEventSink) Info(eid uint32, msg string) error    { return s.report("info", eid, msg) }
+func (s *fakeEventSink) Warning(eid uint32, msg string) error { return s.report("warning", eid, msg) }
+func (s *fakeEventSink) Error(eid uint32, msg string) error   { return s.report("error", eid, msg) }
+func (s *fakeEventSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestEventLogWriter(t *testing.T) {
+	sink := &fakeEventSink{}
+	w := &EventLogWriter{sink: sink, eventID: 7}
+	l := New("my-app")
+	l.SetConfig("my-app", Debug, func(data map[string]interface{}) string {
+		return fmt.Sprintf(`{"title":%q,"level":%q}`, data["title"], data["level"])
+	}, w)
+
+	l.Debug("a")
+	l.Info("b")
+	l.Warn("c")
+	l.Error("d")
+	l.Critical("e")
+	_, err := w.Write([]byte("not json\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{
+		`info 7 {"title":"a","level":"debug"}`,
+		`info 7 {"title":"b","level":"info"}`,
+		`warning 7 {"title":"c","level":"warning"}`,
+		`error 7 {"title":"d","level":"error"}`,
+		`error 7 {"title":"e","level":"critical"}`,
+		`info 7 not json`,
+	}, sink.events)
+	assert.True(t, sink.closed)
+}
+
+func TestNewEventLogWriter(t *testing.T) {
+	_, err := NewEventLogWriter(EventLogConfig{})
+	assert.EqualError(t, err, "event log writer requires a Source")
+	if runtime.GOOS != "windows" {
+		_, err = NewEventLogWriter(EventLogConfig{Source: "my-app"})
+		assert.EqualError(t, err, "the event log is only supported on Windows")
+	}
+}
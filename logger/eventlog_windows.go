@@ -0,0 +1,19 @@
+//go:build windows
+
+package logger
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+func openEventLog(c EventLogConfig) (eventSink, error) {
+	if c.Install {
+		err := eventlog.InstallAsEventCreate(c.Source, eventlog.Error|eventlog.Warning|eventlog.Info)
+		if err != nil && !strings.HasSuffix(err.Error(), "registry key already exists") {
+			return nil, err
+		}
+	}
+	return eventlog.Open(c.Source)
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// FailoverStats describes which destination of a FailoverWriter is in use.
+type FailoverStats struct {
+	// Active is the index of the destination writes currently go to.
+	Active int
+	// Writes and Failures are the number of successful and failed writes per destination.
+	Writes   []uint64
+	Failures []uint64
+}
+
+// FailoverWriter writes to the first healthy destination in a chain, e.g. a Firehose
+// logger, then a local file, then os.Stderr. A destination whose write fails is skipped
+// until `probeInterval` has passed, after which the next write probes it again and switches
+// back to it if it succeeds. The last destination is never skipped.
+type FailoverWriter struct {
+	mu            sync.Mutex
+	destinations  []io.Writer
+	probeInterval time.Duration
+	failedAt      []time.Time
+	writes        []uint64
+	failures      []uint64
+}
+
+// NewFailoverWriter returns a FailoverWriter writing to `destinations`, in order of preference.
+func NewFailoverWriter(probeInterval time.Duration, destinations ...io.Writer) *FailoverWriter {
+	return &FailoverWriter{
+		destinations:  destinations,
+		probeInterval: probeInterval,
+		failedAt:      make([]time.Time, len(destinations)),
+		writes:        make([]uint64, len(destinations)),
+		failures:      make([]uint64, len(destinations)),
+	}
+}
+
+// Write implements io.Writer. It returns the error of the last destination if all of them
+// fail.
+func (fw *FailoverWriter) Write(p []byte) (int, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	err := errors.New("failover writer has no destinations")
+	now := clock()
+	for i, d := range fw.destinations {
+		if i < len(fw.destinations)-1 && !fw.failedAt[i].IsZero() && now.Sub(fw.failedAt[i]) < fw.probeInterval {
+			continue
+		}
+		var n int
+		n, err = d.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err == nil {
+			fw.failedAt[i] = time.Time{}
+			fw.writes[i]++
+			return n, nil
+		}
+		fw.failedAt[i] = now
+		fw.failures[i]++
+	}
+	return 0, err
+}
+
+// Stats returns the active destination and the write counters of each destination.
+func (fw *FailoverWriter) Stats() FailoverStats {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	stats := FailoverStats{
+		Active:   len(fw.destinations) - 1,
+		Writes:   append([]uint64(nil), fw.writes...),
+		Failures: append([]uint64(nil), fw.failures...),
+	}
+	now := clock()
+	for i := range fw.destinations {
+		if fw.failedAt[i].IsZero() || now.Sub(fw.failedAt[i]) >= fw.probeInterval {
+			stats.Active = i
+			break
+		}
+	}
+	return stats
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyWriter fails while `failing` is set.
+type flakyWriter struct {
+	bytes.Buffer
+	failing bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.failing {
+		return 0, errors.New("unavailable")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestFailoverWriter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	primary := &flakyWriter{}
+	secondary := &flakyWriter{}
+	last := &flakyWriter{}
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+	fw := NewFailoverWriter(time.Minute, primary, secondary, last)
+
+	_, err := fw.Write([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, fw.Stats().Active)
+
+	primary.failing = true
+	_, err = fw.Write([]byte("b"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, fw.Stats().Active)
+
+	// the primary isn't probed again before the probe interval
+	primary.failing = false
+	fw.Write([]byte("c"))
+	assert.Equal(t, "a", primary.String())
+	assert.Equal(t, "bc", secondary.String())
+
+	now = now.Add(time.Minute)
+	fw.Write([]byte("d"))
+	assert.Equal(t, "ad", primary.String())
+
+	assert.Equal(t, FailoverStats{
+		Active:   0,
+		Writes:   []uint64{2, 2, 0},
+		Failures: []uint64{1, 0, 0},
+	}, fw.Stats())
+}
+
+func TestFailoverWriterAllFailing(t *testing.T) {
+	primary := &flakyWriter{failing: true}
+	last := &flakyWriter{failing: true}
+	fw := NewFailoverWriter(time.Minute, primary, last)
+
+	_, err := fw.Write([]byte("a"))
+	assert.EqualError(t, err, "unavailable")
+
+	// the last destination is always tried
+	last.failing = false
+	_, err = fw.Write([]byte("b"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", last.String())
+	assert.Equal(t, []uint64{1, 1}, fw.Stats().Failures)
+}
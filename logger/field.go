@@ -0,0 +1,73 @@
+package logger
+
+import "math"
+
+type fieldKind uint8
+
+const (
+	fieldAny fieldKind = iota
+	fieldString
+	fieldInt
+	fieldUint
+	fieldFloat
+	fieldBool
+)
+
+// Field is a typed key-value pair logged with Log. Strings, integers, floats and bools are
+// stored without boxing them in an interface{}, so entries below the log level are dropped
+// before any value is boxed or any map is built; see the benchmarks in field_test.go.
+type Field struct {
+	Key  string
+	kind fieldKind
+	num  uint64
+	str  string
+	any  interface{}
+}
+
+// KV returns a Field for `key` and `value`.
+//
+//	l.Log(logger.Info, "user-created", logger.KV("id", id), logger.KV("admin", false))
+func KV[T any](key string, value T) Field {
+	switch v := any(value).(type) {
+	case string:
+		return Field{Key: key, kind: fieldString, str: v}
+	case int:
+		return Field{Key: key, kind: fieldInt, num: uint64(v)}
+	case int32:
+		return Field{Key: key, kind: fieldInt, num: uint64(v)}
+	case int64:
+		return Field{Key: key, kind: fieldInt, num: uint64(v)}
+	case uint:
+		return Field{Key: key, kind: fieldUint, num: uint64(v)}
+	case uint32:
+		return Field{Key: key, kind: fieldUint, num: uint64(v)}
+	case uint64:
+		return Field{Key: key, kind: fieldUint, num: v}
+	case float64:
+		return Field{Key: key, kind: fieldFloat, num: math.Float64bits(v)}
+	case bool:
+		f := Field{Key: key, kind: fieldBool}
+		if v {
+			f.num = 1
+		}
+		return f
+	}
+	return Field{Key: key, any: value}
+}
+
+// Value returns the value of the field.
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case fieldString:
+		return f.str
+	case fieldInt:
+		return int64(f.num)
+	case fieldUint:
+		return f.num
+	case fieldFloat:
+		return math.Float64frombits(f.num)
+	case fieldBool:
+		return f.num == 1
+	}
+	return f.any
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestKV(t *testing.T) {
+	tests := []struct {
+		field    Field
+		expected interface{}
+	}{
+		{KV("s", "str"), "str"},
+		{KV("i", -3), int64(-3)},
+		{KV("i64", int64(1)<<60), int64(1) << 60},
+		{KV("u", uint64(1)<<63), uint64(1) << 63},
+		{KV("f", 1.5), 1.5},
+		{KV("b", true), true},
+		{KV("d", time.Second), time.Second},
+		{KV("m", M{"a": 1}), M{"a": 1}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.field.Value(), tt.field.Key)
+	}
+}
+
+func TestLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	l.Log(Warning, "disk-full", KV("free_bytes", 0), KV("mount", "/data"), KV("critical", true))
+	l.Log(Debug, "not-logged", KV("a", 1))
+
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "disk-full", entries[0]["title"])
+	assert.Equal(t, "warning", entries[0]["level"])
+	assert.Equal(t, float64(0), entries[0]["free_bytes"])
+	assert.Equal(t, "/data", entries[0]["mount"])
+	assert.Equal(t, true, entries[0]["critical"])
+
+	mock := NewMockCountLogger("my-app")
+	mock.Log(Info, "routed", KV("a", 1))
+}
+
+// benchmarkPath is a variable so that the compiler can't box it statically.
+var benchmarkPath = "/users"
+
+func newBenchmarkLogger() KayveeLogger {
+	l := New("perf")
+	l.SetConfig("perf", Info, func(map[string]interface{}) string { return "" }, io.Discard)
+	return l
+}
+
+func BenchmarkLogFields(b *testing.B) {
+	l := newBenchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Log(Info, "request-finished", KV("status-code", 200+i), KV("path", benchmarkPath), KV("latency", float64(i)))
+	}
+}
+
+func BenchmarkLogM(b *testing.B) {
+	l := newBenchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.InfoD("request-finished", M{"status-code": 200 + i, "path": benchmarkPath, "latency": float64(i)})
+	}
+}
+
+func BenchmarkLogFieldsBelowLevel(b *testing.B) {
+	l := newBenchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Log(Debug, "request-finished", KV("status-code", 200+i), KV("path", benchmarkPath), KV("latency", float64(i)))
+	}
+}
+
+func BenchmarkLogMBelowLevel(b *testing.B) {
+	l := newBenchmarkLogger()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.DebugD("request-finished", M{"status-code": 200 + i, "path": benchmarkPath, "latency": float64(i)})
+	}
+}
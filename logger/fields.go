@@ -0,0 +1,41 @@
+package logger
+
+import "net/url"
+
+// Map converts any map keyed by strings into an M, so typed maps such as map[string]string,
+// map[string]int, url.Values or http.Header can be logged without a manual conversion:
+//
+//	log.InfoD("request-params", logger.Map(req.URL.Query()))
+func Map[T ~map[string]V, V any](m T) M {
+	out := make(M, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// Slice converts a typed slice into a []interface{}.
+func Slice[T any](s []T) []interface{} {
+	if s == nil {
+		return nil
+	}
+	out := make([]interface{}, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+// FromValues converts url.Values into an M. Keys with a single value are logged as a string,
+// keys with several values as a list of strings.
+func FromValues(v url.Values) M {
+	out := make(M, len(v))
+	for k, vals := range v {
+		if len(vals) == 1 {
+			out[k] = vals[0]
+		} else {
+			out[k] = vals
+		}
+	}
+	return out
+}
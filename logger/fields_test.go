@@ -0,0 +1,26 @@
+package logger
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	assert.Equal(t, M{"a": "b"}, Map(map[string]string{"a": "b"}))
+	assert.Equal(t, M{"n": 1}, Map(map[string]int{"n": 1}))
+	assert.Equal(t, M{"q": []string{"1", "2"}}, Map(url.Values{"q": {"1", "2"}}))
+	assert.Equal(t, M{"Accept": []string{"*/*"}}, Map(http.Header{"Accept": {"*/*"}}))
+	assert.Equal(t, M{}, Map(map[string]bool(nil)))
+}
+
+func TestSlice(t *testing.T) {
+	assert.Equal(t, []interface{}{1, 2}, Slice([]int{1, 2}))
+	assert.Nil(t, Slice([]string(nil)))
+}
+
+func TestFromValues(t *testing.T) {
+	assert.Equal(t, M{"a": "1", "b": []string{"2", "3"}}, FromValues(url.Values{"a": {"1"}, "b": {"2", "3"}}))
+}
@@ -0,0 +1,416 @@
+// Package filter implements a small expression language for selecting log entries, so that
+// kayvee tooling (tailers, test harnesses, debugging endpoints) shares one syntax:
+//
+//	level>=error && fields.status-code==500 && title=~"payment.*"
+//
+// An expression compares entry attributes to literals with ==, !=, <, <=, >, >=, =~ (matches a
+// regular expression) and !~, and combines comparisons with &&, ||, ! and parentheses.
+//
+// The attributes are level, title, source, time and fields.<name>, where nested fields are
+// reached with more dots (fields.user.id). Names that aren't one of the other attributes are
+// also looked up in the fields, so fields.status and status are the same.
+//
+// Literals are numbers, double-quoted strings, true, false and null. Bare words like error are
+// strings, which is how levels are usually written. Levels are ordered by severity, times are
+// compared to RFC 3339 strings, numbers are compared numerically and other values as strings.
+// Regular expressions must match the whole value. A comparison with a missing field is false,
+// except `== null`.
+package filter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// SyntaxError is returned by Parse for invalid expressions.
+type SyntaxError struct {
+	// Offset is the byte offset of the error in the expression.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Expr is a parsed filter expression. It is safe for concurrent use.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses a filter expression. An empty expression matches every entry.
+func Parse(src string) (*Expr, error) {
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	if p.tok.kind == tokEOF {
+		return &Expr{src: src, root: always{}}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// MustParse is like Parse but panics if the expression is invalid.
+func MustParse(src string) *Expr {
+	e, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match reports whether `entry` matches the expression.
+func (e *Expr) Match(entry logger.Entry) bool {
+	return e.root.eval(entryAttrs(entry))
+}
+
+// MatchMap reports whether the data of an entry, as passed to a Formatter or a router, matches
+// the expression.
+func (e *Expr) MatchMap(data map[string]interface{}) bool {
+	return e.root.eval(mapAttrs(data))
+}
+
+// attrs looks up the attributes of an entry.
+type attrs interface {
+	lookup(path []string) (interface{}, bool)
+}
+
+type entryAttrs logger.Entry
+
+func (e entryAttrs) lookup(path []string) (interface{}, bool) {
+	if len(path) == 1 {
+		switch path[0] {
+		case "level":
+			return e.Level, true
+		case "title":
+			return e.Title, true
+		case "source":
+			return e.Source, true
+		case "time":
+			return e.Time, !e.Time.IsZero()
+		}
+	}
+	if path[0] == "fields" && len(path) > 1 {
+		path = path[1:]
+	}
+	return lookupPath(e.Fields, path)
+}
+
+type mapAttrs map[string]interface{}
+
+func (m mapAttrs) lookup(path []string) (interface{}, bool) {
+	if len(path) == 1 && path[0] == "level" {
+		name, _ := m["level"].(string)
+		lvl, err := logger.ParseLevel(name)
+		return lvl, err == nil
+	}
+	if path[0] == "fields" && len(path) > 1 {
+		path = path[1:]
+	}
+	return lookupPath(m, path)
+}
+
+func lookupPath(data map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = data
+	for _, key := range path {
+		var ok bool
+		switch obj := v.(type) {
+		case map[string]interface{}:
+			v, ok = obj[key]
+		case logger.M:
+			v, ok = obj[key]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+type node interface {
+	eval(a attrs) bool
+}
+
+type always struct{}
+
+func (always) eval(attrs) bool { return true }
+
+type and struct{ left, right node }
+
+func (n and) eval(a attrs) bool { return n.left.eval(a) && n.right.eval(a) }
+
+type or struct{ left, right node }
+
+func (n or) eval(a attrs) bool { return n.left.eval(a) || n.right.eval(a) }
+
+type not struct{ operand node }
+
+func (n not) eval(a attrs) bool { return !n.operand.eval(a) }
+
+// literal is the right-hand side of a comparison.
+type literal struct {
+	text   string
+	value  interface{} // string, float64, bool or nil
+	re     *regexp.Regexp
+	level  logger.LogLevel
+	levelE error
+	time   time.Time
+	timeE  error
+}
+
+type comparison struct {
+	path []string
+	op   string
+	lit  literal
+}
+
+func (c comparison) eval(a attrs) bool {
+	v, ok := a.lookup(c.path)
+	if c.lit.value == nil && c.lit.re == nil {
+		isNull := !ok || v == nil
+		return (c.op == "==") == isNull
+	}
+	if !ok {
+		return false
+	}
+	if c.lit.re != nil {
+		return c.lit.re.MatchString(fmt.Sprint(v)) == (c.op == "=~")
+	}
+	switch t := v.(type) {
+	case logger.LogLevel:
+		if c.lit.levelE != nil {
+			return false
+		}
+		return compareOrdered(int(t), int(c.lit.level), c.op)
+	case time.Time:
+		if c.lit.timeE != nil {
+			return false
+		}
+		return compareOrdered(t.UnixNano(), c.lit.time.UnixNano(), c.op)
+	case bool:
+		b, isBool := c.lit.value.(bool)
+		if !isBool {
+			return compareOrdered(strconv.FormatBool(t), c.lit.text, c.op)
+		}
+		return compareEqual(t == b, c.op)
+	}
+	if f, isNum := toFloat(v); isNum {
+		if lf, litNum := c.lit.value.(float64); litNum {
+			return compareOrdered(f, lf, c.op)
+		}
+	}
+	return compareOrdered(fmt.Sprint(v), c.lit.text, c.op)
+}
+
+func compareEqual(equal bool, op string) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	}
+	return false
+}
+
+func compareOrdered[T int | int64 | float64 | string](a, b T, op string) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, !math.IsNaN(n)
+	case time.Duration:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() {
+	p.tok = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.tok.kind == tokError {
+		return &SyntaxError{Offset: p.tok.pos, Msg: p.tok.text}
+	}
+	if p.tok.kind == tokEOF {
+		return &SyntaxError{Offset: p.tok.pos, Msg: "unexpected end of expression"}
+	}
+	return &SyntaxError{Offset: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand}, nil
+	case tokLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ) but got %q", p.tok.text)
+		}
+		p.next()
+		return n, nil
+	case tokIdent:
+		return p.parseComparison()
+	}
+	return nil, p.errorf("expected a comparison but got %q", p.tok.text)
+}
+
+func (p *parser) parseComparison() (node, error) {
+	path := strings.Split(p.tok.text, ".")
+	for _, part := range path {
+		if part == "" {
+			return nil, p.errorf("invalid attribute %q", p.tok.text)
+		}
+	}
+	p.next()
+	if p.tok.kind != tokOp {
+		return nil, p.errorf("expected an operator but got %q", p.tok.text)
+	}
+	op := p.tok.text
+	p.next()
+
+	lit := literal{text: p.tok.text}
+	switch p.tok.kind {
+	case tokString:
+		s, err := strconv.Unquote(p.tok.text)
+		if err != nil {
+			return nil, p.errorf("invalid string %s", p.tok.text)
+		}
+		lit.text, lit.value = s, s
+	case tokNumber:
+		// bare words starting with a digit, like unquoted times, are strings
+		if f, err := strconv.ParseFloat(p.tok.text, 64); err == nil {
+			lit.value = f
+		} else {
+			lit.value = p.tok.text
+		}
+	case tokIdent:
+		switch p.tok.text {
+		case "true", "false":
+			lit.value = p.tok.text == "true"
+		case "null":
+			if op != "==" && op != "!=" {
+				return nil, p.errorf("null can only be compared with == or !=")
+			}
+		default:
+			lit.value = p.tok.text
+		}
+	default:
+		return nil, p.errorf("expected a value but got %q", p.tok.text)
+	}
+
+	if op == "=~" || op == "!~" {
+		if lit.value == nil {
+			return nil, p.errorf("expected a regular expression but got null")
+		}
+		if _, err := regexp.Compile(lit.text); err != nil {
+			return nil, p.errorf("invalid regular expression: %v", err)
+		}
+		lit.re = regexp.MustCompile("^(?:" + lit.text + ")$")
+	}
+	lit.level, lit.levelE = logger.ParseLevel(lit.text)
+	lit.time, lit.timeE = time.Parse(time.RFC3339Nano, lit.text)
+	p.next()
+	return comparison{path: path, op: op, lit: lit}, nil
+}
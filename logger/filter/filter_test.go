@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+var testEntry = logger.Entry{
+	Level:  logger.Error,
+	Title:  "payment-failed",
+	Source: "billing",
+	Time:   time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	Fields: logger.M{
+		"status-code": 500,
+		"latency":     0.25,
+		"retried":     true,
+		"user":        map[string]interface{}{"id": "u1", "plan": "pro"},
+		"note":        nil,
+	},
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{``, true},
+		{`level>=error && fields.status-code==500 && title=~"payment.*"`, true},
+		{`level>=critical`, false},
+		{`level<warning`, false},
+		{`level==ERROR`, true},
+		{`title=~"payment"`, false},
+		{`title!~"pay.*"`, false},
+		{`source=="billing"`, true},
+		{`source==billing`, true},
+		{`status-code>=500 && status-code<600`, true},
+		{`fields.status-code=="500"`, true},
+		{`fields.latency<0.5`, true},
+		{`fields.latency>1e-1`, true},
+		{`retried==true`, true},
+		{`retried!=true`, false},
+		{`fields.user.id=="u1"`, true},
+		{`fields.user.plan==free || fields.user.plan==pro`, true},
+		{`!(fields.user.plan==pro)`, false},
+		{`fields.missing==1`, false},
+		{`fields.missing!=1`, false},
+		{`fields.missing==null`, true},
+		{`fields.note==null`, true},
+		{`fields.user!=null`, true},
+		{`time>="2024-01-01T09:00:00Z" && time<2024-01-01T11:00:00Z`, true},
+		{`time>"2024-01-01T10:00:00Z"`, false},
+		{`level>=warning && (title=="a" || status-code==500)`, true},
+		{`level>=warning && title=="a" || status-code==404`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			e, err := Parse(test.expr)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, e.Match(testEntry))
+		})
+	}
+}
+
+func TestMatchMap(t *testing.T) {
+	e := MustParse(`level>=warning && title=="request-finished" && fields.status-code==500`)
+	assert.True(t, e.MatchMap(map[string]interface{}{
+		"title": "request-finished", "level": "error", "status-code": 500,
+	}))
+	assert.False(t, e.MatchMap(map[string]interface{}{
+		"title": "request-finished", "level": "info", "status-code": 500,
+	}))
+	assert.False(t, e.MatchMap(map[string]interface{}{"title": "request-finished"}))
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		offset int
+	}{
+		{`level>=`, 7},
+		{`level`, 5},
+		{`(level==error`, 13},
+		{`level==error &&`, 15},
+		{`level==error)`, 12},
+		{`title=~"["`, 7},
+		{`title=="unterminated`, 7},
+		{`title==#`, 7},
+		{`title>null`, 6},
+		{`a..b==1`, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := Parse(test.expr)
+			var syntaxErr *SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Equal(t, test.offset, syntaxErr.Offset, syntaxErr.Error())
+		})
+	}
+	assert.Panics(t, func() { MustParse("level>=") })
+}
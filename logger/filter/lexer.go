@@ -0,0 +1,107 @@
+package filter
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokError
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lexer splits an expression into tokens.
+type lexer struct {
+	src string
+	pos int
+}
+
+// operators are the comparison operators, longest first so that prefixes don't shadow them.
+var operators = []string{"==", "!=", "<=", ">=", "=~", "!~", "<", ">"}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF, pos: start}
+	}
+	rest := l.src[l.pos:]
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}
+		}
+	}
+	switch {
+	case strings.HasPrefix(rest, "&&"):
+		l.pos += 2
+		return token{kind: tokAnd, text: "&&", pos: start}
+	case strings.HasPrefix(rest, "||"):
+		l.pos += 2
+		return token{kind: tokOr, text: "||", pos: start}
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '!':
+		l.pos++
+		return token{kind: tokNot, text: "!", pos: start}
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}
+	case c == '"':
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			switch l.src[l.pos] {
+			case '\\':
+				l.pos++
+			case '"':
+				l.pos++
+				return token{kind: tokString, text: l.src[start:l.pos], pos: start}
+			}
+		}
+		return token{kind: tokError, text: "unterminated string", pos: start}
+	case isDigit(c) || (c == '-' || c == '.') && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1]):
+		l.pos++
+		for l.pos < len(l.src) && (isIdentChar(l.src[l.pos]) || l.src[l.pos] == '+') {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}
+	case isIdentChar(c):
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}
+	}
+	l.pos++
+	return token{kind: tokError, text: "unexpected character " + string(c), pos: start}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentChar reports whether `c` can be part of an attribute name or a bare word. Dashes are
+// allowed because kayvee fields like status-code use them.
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) ||
+		c == '_' || c == '-' || c == '.' || c == '@' || c == ':'
+}
@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"io"
+	"sync"
+)
+
+// FlightRecorder holds back the low level entries of a single request or operation, and only
+// writes them if it ends in an error. This gives detailed traces of failures without the cost
+// of always writing debug logs:
+//
+//	lg, recorder := logger.NewFlightRecorder("my-app", logger.Info, 500)
+//	ctx = logger.NewContext(ctx, lg)
+//	err := handle(ctx)
+//	recorder.Finish(err)
+//
+// Entries at or above the recorder's threshold are written right away. The first Error or
+// Critical entry, or a call to Flush, writes the entries held so far before it and turns the
+// recorder into a pass-through for the rest of the operation.
+type FlightRecorder struct {
+	threshold  LogLevel
+	maxEntries int
+	out        formatLogger
+
+	mu        sync.Mutex
+	held      []map[string]interface{}
+	dropped   int
+	triggered bool
+}
+
+// NewFlightRecorder returns a logger for a single operation, logging at every level, and the
+// FlightRecorder controlling it. Entries below `threshold` are held back, up to the last
+// `maxEntries` of them.
+func NewFlightRecorder(source string, threshold LogLevel, maxEntries int) (KayveeLogger, *FlightRecorder) {
+	l := New(source)
+	l.SetLogLevel(Trace)
+	r := &FlightRecorder{
+		threshold:  threshold,
+		maxEntries: maxEntries,
+		out:        l.(*Logger).fLogger,
+	}
+	l.setFormatLogger(r)
+	return l, r
+}
+
+// formatAndLog implements the formatLogger interface for *FlightRecorder.
+func (r *FlightRecorder) formatAndLog(data map[string]interface{}) {
+	name, _ := data["level"].(string)
+	lvl := levelFromName(name, Info)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.triggered && lvl < r.threshold {
+		if r.maxEntries <= 0 {
+			r.dropped++
+			return
+		}
+		if len(r.held) >= r.maxEntries {
+			r.held = r.held[1:]
+			r.dropped++
+		}
+		held := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			held[k] = v
+		}
+		r.held = append(r.held, held)
+		return
+	}
+	if lvl >= Error {
+		r.flushLocked()
+	}
+	r.out.formatAndLog(data)
+}
+
+// setFormatter implements the formatLogger interface for *FlightRecorder.
+func (r *FlightRecorder) setFormatter(formatter Formatter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out.setFormatter(formatter)
+}
+
+// setOutput implements the formatLogger interface for *FlightRecorder.
+func (r *FlightRecorder) setOutput(output io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out.setOutput(output)
+}
+
+// Flush writes the entries held so far. Entries logged afterwards are written right away.
+func (r *FlightRecorder) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushLocked()
+}
+
+func (r *FlightRecorder) flushLocked() {
+	r.triggered = true
+	for _, data := range r.held {
+		r.out.formatAndLog(data)
+	}
+	r.held = nil
+}
+
+// Discard drops the entries held so far.
+func (r *FlightRecorder) Discard() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.held = nil
+}
+
+// Finish ends the operation: the held entries are written if `err` isn't nil, and dropped
+// otherwise.
+func (r *FlightRecorder) Finish(err error) {
+	if err != nil {
+		r.Flush()
+	} else {
+		r.Discard()
+	}
+}
+
+// Dropped returns the number of entries dropped because more than maxEntries were held.
+func (r *FlightRecorder) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func titlesOf(lines []map[string]interface{}) []string {
+	titles := []string{}
+	for _, line := range lines {
+		titles = append(titles, line["title"].(string))
+	}
+	return titles
+}
+
+func TestFlightRecorderFinish(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		out := &bytes.Buffer{}
+		lg, recorder := NewFlightRecorder("my-app", Info, 10)
+		lg.SetFormatter(kv.Format)
+		lg.SetOutput(out)
+
+		lg.Debug("cache-miss")
+		lg.Info("request-started")
+		lg.Trace("query")
+		var err error
+		if failed {
+			err = errors.New("failed")
+		}
+		recorder.Finish(err)
+
+		if failed {
+			assert.Equal(t, []string{"request-started", "cache-miss", "query"}, titlesOf(decodeLines(t, out)))
+		} else {
+			assert.Equal(t, []string{"request-started"}, titlesOf(decodeLines(t, out)))
+		}
+	}
+}
+
+func TestFlightRecorderTriggersOnError(t *testing.T) {
+	out := &bytes.Buffer{}
+	lg, recorder := NewFlightRecorder("my-app", Info, 2)
+	lg.SetConfig("my-app", Trace, kv.Format, out)
+
+	lg.Debug("a")
+	lg.Debug("b")
+	lg.DebugD("c", M{"k": "v"})
+	assert.Empty(t, out.String())
+	assert.Equal(t, 1, recorder.Dropped())
+
+	lg.Error("failed")
+	lg.Debug("after")
+	recorder.Finish(nil)
+
+	lines := decodeLines(t, out)
+	assert.Equal(t, []string{"b", "c", "failed", "after"}, titlesOf(lines))
+	require.Len(t, lines, 4)
+	assert.Equal(t, "v", lines[1]["k"])
+	assert.Equal(t, "my-app", lines[1]["source"])
+}
+
+func TestFlightRecorderWithoutRoom(t *testing.T) {
+	out := &bytes.Buffer{}
+	lg, recorder := NewFlightRecorder("my-app", Info, 0)
+	lg.SetConfig("my-app", Trace, kv.Format, out)
+	lg.Debug("a")
+	recorder.Flush()
+	assert.Empty(t, out.String())
+	assert.Equal(t, 1, recorder.Dropped())
+}
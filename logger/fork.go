@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+)
+
+// ForkResetter is implemented by sinks running goroutines, e.g. AsyncWriter and the analytics
+// logger. The child of a fork only has the thread that forked, so their goroutines don't run
+// in it, and what it logs is buffered forever.
+type ForkResetter interface {
+	// ResetAfterFork restarts the goroutines in the child. What was buffered in the parent is
+	// dropped, since the parent writes it.
+	ResetAfterFork() error
+}
+
+var forkResetters = struct {
+	sync.Mutex
+	m map[ForkResetter]struct{}
+}{m: map[ForkResetter]struct{}{}}
+
+// RegisterForkResetter registers `r` to be reset by ResetAfterFork, until the returned function
+// is called. AsyncWriters and analytics loggers register themselves until they're closed.
+func RegisterForkResetter(r ForkResetter) (unregister func()) {
+	forkResetters.Lock()
+	defer forkResetters.Unlock()
+	forkResetters.m[r] = struct{}{}
+	return func() {
+		forkResetters.Lock()
+		defer forkResetters.Unlock()
+		delete(forkResetters.m, r)
+	}
+}
+
+// ResetAfterFork resets every registered ForkResetter, and returns their errors. Call it in
+// the child of a fork, e.g. after daemonizing, before it logs. Processes started with os/exec
+// or syscall.Exec don't need it: they start with no sinks.
+func ResetAfterFork() error {
+	forkResetters.Lock()
+	resetters := make([]ForkResetter, 0, len(forkResetters.m))
+	for r := range forkResetters.m {
+		resetters = append(resetters, r)
+	}
+	forkResetters.Unlock()
+	var errs []error
+	for _, r := range resetters {
+		if err := r.ResetAfterFork(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
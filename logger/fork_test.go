@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type forkResetterFunc func() error
+
+func (f *forkResetterFunc) ResetAfterFork() error { return (*f)() }
+
+func TestResetAfterFork(t *testing.T) {
+	calls := 0
+	ok := forkResetterFunc(func() error { calls++; return nil })
+	failing := forkResetterFunc(func() error { return errors.New("dial tcp: connection refused") })
+	unregisterOK := RegisterForkResetter(&ok)
+	unregisterFailing := RegisterForkResetter(&failing)
+
+	assert.EqualError(t, ResetAfterFork(), "dial tcp: connection refused")
+	assert.Equal(t, 1, calls)
+
+	unregisterOK()
+	unregisterFailing()
+	require.NoError(t, ResetAfterFork())
+	assert.Equal(t, 1, calls)
+}
+
+func TestAsyncWriterResetAfterFork(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	w := NewAsyncWriter(out, 10)
+	_, err := w.Write(asyncLine("info", "parent"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return w.Queued() == 0 }, time.Second, time.Millisecond)
+	_, err = w.Write(asyncLine("info", "queued"))
+	require.NoError(t, err)
+
+	// the goroutine of the parent is stuck on the gate, like it'd be gone in a child
+	require.NoError(t, w.ResetAfterFork())
+	assert.Equal(t, 0, w.Queued())
+	close(out.gate)
+	_, err = w.Write(asyncLine("info", "child"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Contains(t, out.lines(), `{"level":"info","title":"child"}`)
+	assert.NotContains(t, out.lines(), `{"level":"info","title":"queued"}`, "the lines queued in the parent are dropped")
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"context"
+	"time"
+)
+
+// HeartbeatConfig configures StartHeartbeat.
+type HeartbeatConfig struct {
+	// Interval is the time between heartbeats. Defaults to 30s.
+	Interval time.Duration
+	// Title is the title of heartbeat entries. Defaults to "kayvee-heartbeat".
+	Title string
+	// Stats returns extra fields to include in each heartbeat, e.g. the drop counters of the
+	// sink the logger writes to.
+	Stats func() map[string]interface{}
+}
+
+// StartHeartbeat logs a heartbeat entry to `l` every interval until `ctx` is canceled, so that
+// pipeline monitors can tell a service that is silent from one whose logs are being dropped.
+// Heartbeats carry a heartbeat_seq that increases by 1 each time, the uptime of the heartbeat
+// in seconds, the interval in seconds, and the fields returned by Stats.
+func StartHeartbeat(ctx context.Context, l Leveled, c HeartbeatConfig) {
+	if c.Interval <= 0 {
+		c.Interval = 30 * time.Second
+	}
+	if c.Title == "" {
+		c.Title = "kayvee-heartbeat"
+	}
+	start := clock()
+	go func() {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for seq := 1; ; seq++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			data := M{}
+			if c.Stats != nil {
+				for k, v := range c.Stats() {
+					data[k] = v
+				}
+			}
+			data["heartbeat_seq"] = seq
+			data["uptime_s"] = int(clock().Sub(start).Seconds())
+			data["interval_s"] = c.Interval.Seconds()
+			l.InfoD(c.Title, data)
+		}
+	}()
+}
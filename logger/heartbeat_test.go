@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestStartHeartbeat(t *testing.T) {
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	StartHeartbeat(ctx, l, HeartbeatConfig{
+		Interval: 5 * time.Millisecond,
+		Stats:    func() map[string]interface{} { return M{"dropped": 3} },
+	})
+	require.Eventually(t, func() bool { return len(out.lines()) >= 2 }, time.Second, time.Millisecond)
+	cancel()
+
+	out.mu.Lock()
+	entries := decodeLines(t, &out.buf)
+	out.mu.Unlock()
+	assert.Equal(t, "kayvee-heartbeat", entries[0]["title"])
+	assert.Equal(t, float64(1), entries[0]["heartbeat_seq"])
+	assert.Equal(t, float64(2), entries[1]["heartbeat_seq"])
+	assert.Equal(t, 0.005, entries[0]["interval_s"])
+	assert.Equal(t, float64(3), entries[0]["dropped"])
+}
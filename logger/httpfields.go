@@ -0,0 +1,62 @@
+package logger
+
+import "net/http"
+
+// Req returns the fields describing the HTTP request `r`, in the shape the kv middleware logs
+// them with, so that ad-hoc call sites log requests consistently:
+//
+//   - method, path and params, the raw query.
+//   - scheme and host, when they're known.
+//   - ip, see RequestIP.
+//   - content-type and content-length, when the request has a body.
+//
+// Add them to the data of an entry, e.g. l.InfoD("upstream-called", logger.Req(req)).
+func Req(r *http.Request) M {
+	data := M{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"params": r.URL.RawQuery,
+	}
+	if scheme := r.URL.Scheme; scheme != "" {
+		data["scheme"] = scheme
+	} else if r.TLS != nil {
+		data["scheme"] = "https"
+	}
+	if host := r.URL.Host; host != "" {
+		data["host"] = host
+	} else if r.Host != "" {
+		data["host"] = r.Host
+	}
+	data["ip"] = RequestIP(r)
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		data["content-type"] = ct
+	}
+	if r.ContentLength > 0 {
+		data["content-length"] = r.ContentLength
+	}
+	return data
+}
+
+// RequestIP returns the IP the kv middleware logs for `r`: the X-Forwarded-For header, or the
+// remote address of server requests.
+func RequestIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return forwarded
+	}
+	return r.RemoteAddr
+}
+
+// Resp returns the fields describing an HTTP response with status code `status`, headers `hdr`
+// and a body of `size` bytes, in the shape the kv middleware logs them with: status-code,
+// response-size, and response-content-type when it's set. Unlike the request fields, they're
+// prefixed, so that both can be added to the same entry.
+func Resp(status int, hdr http.Header, size int) M {
+	data := M{
+		"status-code":   status,
+		"response-size": size,
+	}
+	if ct := hdr.Get("Content-Type"); ct != "" {
+		data["response-content-type"] = ct
+	}
+	return data
+}
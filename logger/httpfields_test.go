@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReq(t *testing.T) {
+	server := httptest.NewRequest("POST", "https://api.example.com/v1/users?page=2", strings.NewReader(`{"a":1}`))
+	server.Header.Set("Content-Type", "application/json")
+	server.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, M{
+		"method":         "POST",
+		"scheme":         "https",
+		"host":           "api.example.com",
+		"path":           "/v1/users",
+		"params":         "page=2",
+		"ip":             "10.0.0.1:1234",
+		"content-type":   "application/json",
+		"content-length": int64(7),
+	}, Req(server))
+
+	server.Header.Set("X-Forwarded-For", "192.168.0.1")
+	server.URL.Scheme, server.TLS = "", &tls.ConnectionState{}
+	assert.Equal(t, "192.168.0.1", Req(server)["ip"])
+	assert.Equal(t, "https", Req(server)["scheme"])
+
+	client, _ := http.NewRequest("GET", "http://upstream/health", nil)
+	assert.Equal(t, M{
+		"method": "GET",
+		"scheme": "http",
+		"host":   "upstream",
+		"path":   "/health",
+		"params": "",
+		"ip":     "",
+	}, Req(client))
+
+	bare := &http.Request{Method: "GET", URL: &url.URL{Path: "path"}, Header: http.Header{}}
+	assert.Equal(t, M{"method": "GET", "path": "path", "params": "", "ip": ""}, Req(bare))
+}
+
+func TestResp(t *testing.T) {
+	assert.Equal(t, M{
+		"status-code":           201,
+		"response-size":         12,
+		"response-content-type": "application/json",
+	}, Resp(201, http.Header{"Content-Type": {"application/json"}}, 12))
+	assert.Equal(t, M{"status-code": 204, "response-size": 0}, Resp(204, http.Header{}, 0))
+}
@@ -3,7 +3,7 @@ package logger
 import (
 	"io"
 
-	"gopkg.in/Clever/kayvee-go.v6/router"
+	"github.com/caido/dependency-kayvee-go/v6/router"
 )
 
 /////////////////////////////
@@ -13,18 +13,29 @@ import (
 /////////////////////////////
 
 // KayveeLogger is the main logging interface, providing customization of log messages.
+// It's composed of smaller interfaces, so that fakes and adapters can implement only the
+// parts they need.
 type KayveeLogger interface {
+	FieldLogger
+	OutputConfigurable
+	Leveled
+	MetricsLogger
 
-	//
-	// Configuration
-	//
+	// setFormatLogger use for to implemente the mock
+	setFormatLogger(fl formatLogger)
+}
 
+// FieldLogger manages the fields logged with every message.
+type FieldLogger interface {
 	// AddContext adds a new key-val to be logged with all log messages.
 	AddContext(key, val string)
 
 	// GetContext reads a key-val from the global map of data that will be logged with all log messages.
 	GetContext(key string) (interface{}, bool)
+}
 
+// OutputConfigurable configures how and where log messages are written.
+type OutputConfigurable interface {
 	// SetConfig allows configuration changes in one go
 	SetConfig(source string, logLvl LogLevel, formatter Formatter, output io.Writer)
 
@@ -37,23 +48,16 @@ type KayveeLogger interface {
 	// SetOutput changes the output destination of the logger
 	SetOutput(output io.Writer)
 
-	// setFormatLogger use for to implemente the mock
-	setFormatLogger(fl formatLogger)
+	// AddSink registers a Sink that receives every entry logged, in addition to the output.
+	AddSink(s Sink)
 
 	// SetRouter changes the router for this logger instance.  Once set, logs produced by this
 	// logger will not be touched by the global router.  Mostly used for testing and benchmarking.
 	SetRouter(router router.Router)
+}
 
-	//
-	// Logging
-	//
-
-	// Counter takes a string and logs with LogLevel = Info
-	Counter(title string)
-
-	// CounterD takes a string, value, and data map. It logs with LogLevel = Info
-	CounterD(title string, value int, data map[string]interface{})
-
+// Leveled logs messages at a level.
+type Leveled interface {
 	// Critical takes a string and logs with LogLevel = Critical
 	Critical(title string)
 
@@ -78,18 +82,6 @@ type KayveeLogger interface {
 	// ErrorD takes a string and data map. It logs with LogLevel = Error
 	ErrorD(title string, data map[string]interface{})
 
-	// GaugeFloat takes a string and float value. It logs with LogLevel = Info
-	GaugeFloat(title string, value float64)
-
-	// GaugeFloatD takes a string, a float value, and data map. It logs with LogLevel = Info
-	GaugeFloatD(title string, value float64, data map[string]interface{})
-
-	// GaugeInt takes a string and integer value. It logs with LogLevel = Info
-	GaugeInt(title string, value int)
-
-	// GaugeIntD takes a string, an integer value, and data map. It logs with LogLevel = Info
-	GaugeIntD(title string, value int, data map[string]interface{})
-
 	// Info takes a string and logs with LogLevel = Info
 	Info(title string)
 
@@ -101,4 +93,28 @@ type KayveeLogger interface {
 
 	// WarnD takes a string and data map. It logs with LogLevel = Warning
 	WarnD(title string, data map[string]interface{})
+
+	// Log takes a LogLevel, a string and typed fields created with KV. It logs with LogLevel = logLvl
+	Log(logLvl LogLevel, title string, fields ...Field)
+}
+
+// MetricsLogger logs counters and gauges.
+type MetricsLogger interface {
+	// Counter takes a string and logs with LogLevel = Info
+	Counter(title string)
+
+	// CounterD takes a string, value, and data map. It logs with LogLevel = Info
+	CounterD(title string, value int, data map[string]interface{})
+
+	// GaugeFloat takes a string and float value. It logs with LogLevel = Info
+	GaugeFloat(title string, value float64)
+
+	// GaugeFloatD takes a string, a float value, and data map. It logs with LogLevel = Info
+	GaugeFloatD(title string, value float64, data map[string]interface{})
+
+	// GaugeInt takes a string and integer value. It logs with LogLevel = Info
+	GaugeInt(title string, value int)
+
+	// GaugeIntD takes a string, an integer value, and data map. It logs with LogLevel = Info
+	GaugeIntD(title string, value int, data map[string]interface{})
 }
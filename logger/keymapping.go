@@ -0,0 +1,21 @@
+package logger
+
+// RemapKeys returns a Formatter that renames top-level keys before formatting with
+// `formatter`, typically the standard title, level, source and deploy_env fields. `keys` maps
+// kayvee keys to the keys downstream systems expect, e.g. {"title": "message"}. Keys that
+// aren't in `keys` are left as is. Routing happens before formatting, so routing rules keep
+// matching on the kayvee keys.
+//
+//	l.SetFormatter(logger.RemapKeys(kv.Format, map[string]string{"title": "msg"}))
+func RemapKeys(formatter Formatter, keys map[string]string) Formatter {
+	return func(data map[string]interface{}) string {
+		remapped := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			if to, ok := keys[k]; ok && to != "" {
+				k = to
+			}
+			remapped[k] = v
+		}
+		return formatter(remapped)
+	}
+}
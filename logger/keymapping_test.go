@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestRemapKeys(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, RemapKeys(kv.Format, map[string]string{
+		"title":  "message",
+		"level":  "log.level",
+		"source": "",
+	}), buf)
+	l.InfoD("something-happened", M{"user": "abc"})
+
+	entries := decodeLines(t, buf)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "something-happened", entries[0]["message"])
+	assert.Equal(t, "info", entries[0]["log.level"])
+	assert.Equal(t, "my-app", entries[0]["source"])
+	assert.Equal(t, "abc", entries[0]["user"])
+	assert.NotContains(t, entries[0], "title")
+	assert.NotContains(t, entries[0], "level")
+}
@@ -18,7 +18,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/kinesis"
 	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
 	"github.com/eapache/go-resiliency/retrier"
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 //go:generate mockgen -package $GOPACKAGE -destination mock_kinesis.go github.com/aws/aws-sdk-go/service/kinesis/kinesisiface KinesisAPI
@@ -6,7 +6,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/kinesis"
 	gomock "github.com/golang/mock/gomock"
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 func TestLogger(t *testing.T) {
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"io"
+	"os"
+)
+
+// LevelSplitWriter is an output writing the entries at Threshold or above to High, and the
+// others to Low. Set it as the output of a logger:
+//
+//	l.SetOutput(logger.NewStdSplitWriter())
+//
+// The level of an entry is only known when the LevelSplitWriter is the output of the logger
+// itself: wrapped in another writer, e.g. an AsyncWriter, everything is written to Low.
+type LevelSplitWriter struct {
+	Threshold LogLevel
+	Low, High io.Writer
+}
+
+// NewStdSplitWriter returns a LevelSplitWriter writing Warning and above to os.Stderr, and the
+// lower levels to os.Stdout, as several container platforms expect.
+func NewStdSplitWriter() *LevelSplitWriter {
+	return &LevelSplitWriter{Threshold: Warning, Low: os.Stdout, High: os.Stderr}
+}
+
+// Write writes `p` to Low, since its level isn't known.
+func (w *LevelSplitWriter) Write(p []byte) (int, error) {
+	return w.Low.Write(p)
+}
+
+// writeLine writes the line of an entry at `logLvl` to the writer of its level.
+func (w *LevelSplitWriter) writeLine(logLvl LogLevel, line string) {
+	out := w.Low
+	if logLvl >= w.Threshold {
+		out = w.High
+	}
+	io.WriteString(out, line+"\n")
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelSplitWriter(t *testing.T) {
+	low, high := &bytes.Buffer{}, &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Debug, func(data map[string]interface{}) string {
+		return data["title"].(string)
+	}, &LevelSplitWriter{Threshold: Warning, Low: low, High: high})
+
+	l.Debug("a")
+	l.Info("b")
+	l.Warn("c")
+	l.Error("d")
+	l.Critical("e")
+	assert.Equal(t, "a\nb\n", low.String())
+	assert.Equal(t, "c\nd\ne\n", high.String())
+}
+
+func TestNewStdSplitWriter(t *testing.T) {
+	w := NewStdSplitWriter()
+	assert.Equal(t, Warning, w.Threshold)
+	assert.Equal(t, os.Stdout, w.Low)
+	assert.Equal(t, os.Stderr, w.High)
+}
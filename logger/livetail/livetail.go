@@ -0,0 +1,187 @@
+// Package livetail streams the entries of a process's loggers over HTTP, so that engineers
+// can live-tail the structured logs of a single instance while debugging:
+//
+//	tail, err := livetail.New(livetail.Config{Authorize: checkAdminToken})
+//	...
+//	log.AddSink(tail)
+//	http.Handle("/debug/logs", tail)
+//
+// Clients connect with Server-Sent Events, or with a WebSocket when the request asks for an
+// upgrade, and can pass a filter expression (see package filter) in the filter query
+// parameter, e.g. /debug/logs?filter=level>=warning.
+package livetail
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kv "gopkg.in/Clever/kayvee-go.v6"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+	"github.com/caido/dependency-kayvee-go/v6/logger/filter"
+)
+
+// Config configures a Handler.
+type Config struct {
+	// Authorize is called before streaming to a client. Returning an error rejects the request
+	// with a 403. It's required, since entries may contain sensitive data.
+	Authorize func(r *http.Request) error
+	// BufferSize is the number of entries buffered per client. Entries are dropped for clients
+	// that fall further behind, so a slow client never slows down logging. Defaults to 256.
+	BufferSize int
+	// MaxClients is the maximum number of concurrent clients. Further requests are rejected
+	// with a 503. Defaults to 8.
+	MaxClients int
+}
+
+// Handler is an http.Handler streaming the entries it receives as a logger.Sink to its
+// clients.
+type Handler struct {
+	config Config
+
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+	dropped uint64
+}
+
+type client struct {
+	filter  *filter.Expr
+	entries chan logger.Entry
+	dropped uint64
+}
+
+// New returns a Handler for `config`.
+func New(config Config) (*Handler, error) {
+	if config.Authorize == nil {
+		return nil, errors.New("livetail: Authorize is required")
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 256
+	}
+	if config.MaxClients <= 0 {
+		config.MaxClients = 8
+	}
+	return &Handler{config: config, clients: map[*client]struct{}{}}, nil
+}
+
+// WriteEntry implements the logger.Sink interface. It hands `e` to the clients whose filter it
+// matches without blocking.
+func (h *Handler) WriteEntry(e logger.Entry) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if !c.filter.Match(e) {
+			continue
+		}
+		select {
+		case c.entries <- e:
+		default:
+			atomic.AddUint64(&c.dropped, 1)
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	}
+}
+
+// Clients returns the number of connected clients.
+func (h *Handler) Clients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Dropped returns the number of entries dropped because a client fell behind.
+func (h *Handler) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.config.Authorize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	expr, err := filter.Parse(r.URL.Query().Get("filter"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c := &client{filter: expr, entries: make(chan logger.Entry, h.config.BufferSize)}
+	if !h.add(c) {
+		http.Error(w, "too many clients", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.remove(c)
+
+	if isWebSocketUpgrade(r) {
+		serveWebSocket(w, r, c)
+		return
+	}
+	serveSSE(w, r, c)
+}
+
+func (h *Handler) add(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.clients) >= h.config.MaxClients {
+		return false
+	}
+	h.clients[c] = struct{}{}
+	return true
+}
+
+func (h *Handler) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+// sseKeepAlive is how often a comment is sent to idle Server-Sent Events clients, so that
+// proxies don't close the connection.
+var sseKeepAlive = 30 * time.Second
+
+func serveSSE(w http.ResponseWriter, r *http.Request, c *client) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case e := <-c.entries:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", formatEntry(e)); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// formatEntry returns `e` as a single line of kayvee JSON.
+func formatEntry(e logger.Entry) string {
+	data := make(map[string]interface{}, len(e.Fields)+4)
+	for k, v := range e.Fields {
+		data[k] = v
+	}
+	data["title"] = e.Title
+	data["level"] = e.Level.String()
+	data["source"] = e.Source
+	data["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	return kv.Format(data)
+}
@@ -0,0 +1,155 @@
+package livetail
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+	"github.com/caido/dependency-kayvee-go/v6/logger/filter"
+)
+
+func allowAll(*http.Request) error { return nil }
+
+// waitForClients waits until `n` clients are connected to `h`.
+func waitForClients(t *testing.T, h *Handler, n int) {
+	require.Eventually(t, func() bool { return h.Clients() == n }, time.Second, time.Millisecond)
+}
+
+func TestSSE(t *testing.T) {
+	h, err := New(Config{Authorize: allowAll})
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?filter=" + url.QueryEscape(`level>=warning`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	waitForClients(t, h, 1)
+
+	h.WriteEntry(logger.Entry{Level: logger.Info, Title: "skipped"})
+	h.WriteEntry(logger.Entry{Level: logger.Error, Title: "streamed", Source: "app", Fields: logger.M{"a": 1}})
+
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "data: {"), line)
+	assert.Contains(t, line, `"title":"streamed"`)
+	assert.Contains(t, line, `"level":"error"`)
+	assert.Contains(t, line, `"a":1`)
+
+	resp.Body.Close()
+	waitForClients(t, h, 0)
+}
+
+func TestAuthorizeAndLimits(t *testing.T) {
+	_, err := New(Config{})
+	assert.Error(t, err)
+
+	h, err := New(Config{
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "secret" {
+				return errors.New("forbidden")
+			}
+			return nil
+		},
+		MaxClients: 1,
+	})
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	get := func(query string, authorized bool) *http.Response {
+		req, _ := http.NewRequest("GET", srv.URL+query, nil)
+		if authorized {
+			req.Header.Set("Authorization", "secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := get("", false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = get("?filter="+url.QueryEscape("level>="), true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	first := get("", true)
+	defer first.Body.Close()
+	waitForClients(t, h, 1)
+	resp = get("", true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestSlowClientsDropEntries(t *testing.T) {
+	h, err := New(Config{Authorize: allowAll, BufferSize: 2})
+	require.NoError(t, err)
+	c := &client{filter: filter.MustParse(""), entries: make(chan logger.Entry, 2)}
+	require.True(t, h.add(c))
+
+	for i := 0; i < 5; i++ {
+		h.WriteEntry(logger.Entry{Title: "t"})
+	}
+	assert.Len(t, c.entries, 2)
+	assert.Equal(t, uint64(3), h.Dropped())
+}
+
+func TestWebSocket(t *testing.T) {
+	h, err := New(Config{Authorize: allowAll})
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "GET /?filter=title==b HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\n"+
+		"Upgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	// the example handshake from RFC 6455
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	waitForClients(t, h, 1)
+
+	h.WriteEntry(logger.Entry{Title: "a"})
+	h.WriteEntry(logger.Entry{Title: "b"})
+	header := make([]byte, 2)
+	_, err = io.ReadFull(r, header)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x81), header[0])
+	size := int(header[1])
+	if size == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(r, ext)
+		size = int(binary.BigEndian.Uint16(ext))
+	}
+	payload := make([]byte, size)
+	_, err = io.ReadFull(r, payload)
+	require.NoError(t, err)
+	assert.Contains(t, string(payload), `"title":"b"`)
+
+	// a masked close frame from the client ends the stream
+	_, err = conn.Write([]byte{0x88, 0x80, 1, 2, 3, 4})
+	require.NoError(t, err)
+	waitForClients(t, h, 0)
+}
@@ -0,0 +1,164 @@
+package livetail
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the client's key to compute Sec-WebSocket-Accept, see RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// maxWebSocketControlFrame bounds the payload of frames read from clients. Clients have
+// nothing to send but control frames, which are limited to 125 bytes.
+const maxWebSocketControlFrame = 125
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn is the server side of a WebSocket connection, supporting only what streaming
+// entries needs: sending text messages, answering pings and noticing when the client leaves.
+type wsConn struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func serveWebSocket(w http.ResponseWriter, r *http.Request, c *client) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return
+	}
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer netConn.Close()
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	brw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		return
+	}
+
+	ws := &wsConn{w: brw.Writer}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		ws.readLoop(brw.Reader)
+	}()
+	for {
+		select {
+		case <-closed:
+			return
+		case e := <-c.entries:
+			if err := ws.writeFrame(wsText, []byte(formatEntry(e))); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLoop reads frames from the client until it closes the connection, answering pings.
+func (ws *wsConn) readLoop(r *bufio.Reader) {
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		opcode := header[0] & 0xF
+		size := uint64(header[1] & 0x7F)
+		switch size {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return
+			}
+			size = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return
+			}
+			size = binary.BigEndian.Uint64(ext)
+		}
+		if size > maxWebSocketControlFrame {
+			ws.writeFrame(wsClose, []byte{0x03, 0xF1}) // 1009, message too big
+			return
+		}
+		var mask [4]byte
+		if header[1]&0x80 != 0 {
+			if _, err := io.ReadFull(r, mask[:]); err != nil {
+				return
+			}
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+		switch opcode {
+		case wsClose:
+			ws.writeFrame(wsClose, payload)
+			return
+		case wsPing:
+			if err := ws.writeFrame(wsPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeFrame writes a single unfragmented, unmasked frame, as servers do.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	ws.w.Write(header)
+	ws.w.Write(payload)
+	return ws.w.Flush()
+}
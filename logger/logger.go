@@ -1,14 +1,14 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"strings"
 	"sync"
 
-	kv "gopkg.in/Clever/kayvee-go.v6"
-	"gopkg.in/Clever/kayvee-go.v6/router"
+	"github.com/caido/dependency-kayvee-go/v6/router"
 )
 
 /////////////////////
@@ -45,6 +45,16 @@ var logLevelNames = map[LogLevel]string{
 	Critical: "critical",
 }
 
+// formattersByName are the formatters that can be selected with the KAYVEE_FORMAT environment
+// variable for loggers created with New.
+var formattersByName = map[string]Formatter{
+	"json":   JSONFormatter,
+	"pretty": DevFormatter,
+	"ecs":    ECSFormatter,
+	"otel":   OTelFormatter,
+	"pino":   PinoFormatter,
+}
+
 func (l LogLevel) String() string {
 	switch l {
 	case Trace:
@@ -77,6 +87,10 @@ type Logger struct {
 	logLvl    LogLevel
 	fLogger   formatLogger
 	logRouter router.Router
+	sinks     []Sink
+
+	metricsMode MetricsMode
+	metricsSink Sink
 }
 
 var globalRouter router.Router
@@ -142,6 +156,13 @@ func (l *Logger) SetRouter(router router.Router) {
 	l.logRouter = router
 }
 
+// AddSink implements the method for the KayveeLogger interface.
+func (l *Logger) AddSink(s Sink) {
+	l.globalsL.Lock()
+	defer l.globalsL.Unlock()
+	l.sinks = append(l.sinks, s)
+}
+
 // SetLogLevel implements the method for the KayveeLogger interface.
 func (l *Logger) SetLogLevel(logLvl LogLevel) {
 	l.logLvl = logLvl
@@ -245,13 +266,26 @@ func (l *Logger) CriticalD(title string, data map[string]interface{}) {
 	l.logWithLevel(Critical, data)
 }
 
+// Log implements the method for the KayveeLogger interface.
+func (l *Logger) Log(logLvl LogLevel, title string, fields ...Field) {
+	if logLvl < l.level() && !keepsSuppressedEntries() {
+		return
+	}
+	data := make(map[string]interface{}, len(fields)+1)
+	for _, f := range fields {
+		data[f.Key] = f.Value()
+	}
+	data["title"] = title
+	l.logWithLevel(logLvl, data)
+}
+
 // CounterD implements the method for the KayveeLogger interface.
 // Logs with type = gauge, and value = value
 func (l *Logger) CounterD(title string, value int, data map[string]interface{}) {
 	data["title"] = title
 	data["value"] = value
 	data["type"] = "counter"
-	l.logWithLevel(Info, data)
+	l.logMetric(data)
 }
 
 // GaugeIntD implements the method for the KayveeLogger interface.
@@ -270,13 +304,15 @@ func (l *Logger) gauge(title string, value interface{}, data map[string]interfac
 	data["title"] = title
 	data["value"] = value
 	data["type"] = "gauge"
-	l.logWithLevel(Info, data)
+	l.logMetric(data)
 }
 
 // Actual logging. Handles whether to output based on log level and
 // unifies the passed in data with the stored globals
 func (l *Logger) logWithLevel(logLvl LogLevel, data map[string]interface{}) {
-	if logLvl < l.logLvl {
+	recent := recentEntries.Load()
+	suppressed := logLvl < l.level()
+	if suppressed && (recent == nil || !recent.includeSuppressed) {
 		// No log output
 		return
 	}
@@ -290,15 +326,73 @@ func (l *Logger) logWithLevel(logLvl LogLevel, data map[string]interface{}) {
 		}
 		data[key] = value
 	}
+	FormatDurations(data)
+	if suppressed {
+		recent.add(newEntry(logLvl, data, clock()))
+		return
+	}
 	if l.logRouter != nil {
 		data["_kvmeta"] = l.logRouter.Route(data)
 	} else if globalRouter != nil {
 		data["_kvmeta"] = globalRouter.Route(data)
 	}
+	if kvmeta, ok := data["_kvmeta"].(map[string]interface{}); ok {
+		countRuleMatches(kvmeta)
+	}
 
+	subs := subscriptions.Load()
+	if len(l.sinks) > 0 || recent != nil || subs != nil {
+		e := newEntry(logLvl, data, clock())
+		for _, s := range l.sinks {
+			s.WriteEntry(e)
+		}
+		if recent != nil {
+			recent.add(e)
+		}
+		if subs != nil {
+			publish(*subs, e)
+		}
+	}
 	l.fLogger.formatAndLog(data)
 }
 
+// levelFromName returns the LogLevel named `name`, or `fallback` if there is none.
+func levelFromName(name string, fallback LogLevel) LogLevel {
+	if lvl, err := ParseLevel(name); err == nil {
+		return lvl
+	}
+	return fallback
+}
+
+// ParseLevel returns the LogLevel named `name`, as written in the level field of entries.
+// The name is case insensitive.
+func ParseLevel(name string) (LogLevel, error) {
+	for key, val := range logLevelNames {
+		if strings.ToLower(name) == val {
+			return key, nil
+		}
+	}
+	return Info, fmt.Errorf("unknown log level %q", name)
+}
+
+// logAtLevel logs `title` and `data` through the *D method of `l` corresponding to `logLvl`.
+func logAtLevel(l Leveled, logLvl LogLevel, title string, data map[string]interface{}) {
+	switch logLvl {
+	case Trace:
+		l.TraceD(title, data)
+	case Debug:
+		l.DebugD(title, data)
+	case Warning:
+		l.WarnD(title, data)
+	case Error:
+		l.ErrorD(title, data)
+	case Critical:
+		l.CriticalD(title, data)
+	default:
+		l.InfoD(title, data)
+	}
+}
+
 // updateContextMapIfNotReserved updates context[key] to val if key is not in the reserved list.
 func updateContextMapIfNotReserved(context M, key string, val interface{}) {
 	if reservedKeyNames[strings.ToLower(key)] {
@@ -314,6 +408,8 @@ func New(source string) KayveeLogger {
 }
 
 // NewWithContext creates a *logger.Logger. Default values are Debug LogLevel, kayvee Formatter, and std.err output.
+// The formatter can be switched with the KAYVEE_FORMAT environment variable, e.g. KAYVEE_FORMAT=pretty
+// for human readable output during local development.
 func NewWithContext(source string, contextValues map[string]interface{}) KayveeLogger {
 	context := M{}
 	for k, v := range contextValues {
@@ -364,7 +460,12 @@ func NewWithContext(source string, contextValues map[string]interface{}) KayveeL
 		}
 	}
 
-	logObj.SetConfig(source, logLvl, kv.Format, os.Stderr)
+	formatter := Formatter(JSONFormatter)
+	if f, ok := formattersByName[strings.ToLower(os.Getenv("KAYVEE_FORMAT"))]; ok {
+		formatter = f
+	}
+
+	logObj.SetConfig(source, logLvl, formatter, os.Stderr)
 
 	return &logObj
 }
@@ -394,11 +495,23 @@ type formatLogger interface {
 type defaultFormatLogger struct {
 	formatter Formatter
 	logWriter *log.Logger
+	// split is the output if it's a LevelSplitWriter
+	split *LevelSplitWriter
 }
 
 // formatAndLog implements the formatLogger interface for *defaultFormatLogger.
 func (fl *defaultFormatLogger) formatAndLog(data map[string]interface{}) {
 	logString := fl.formatter(data)
+	if logString == "" {
+		// dropped by the formatter, e.g. because of the MarshalFailurePolicy
+		return
+	}
+	countVolume(data["title"], len(logString)+1)
+	if fl.split != nil {
+		level, _ := data["level"].(string)
+		fl.split.writeLine(levelFromName(level, Info), logString)
+		return
+	}
 	fl.logWriter.Println(logString)
 }
 
@@ -410,4 +523,5 @@ func (fl *defaultFormatLogger) setFormatter(formatter Formatter) {
 // setOutput implements the formatLogger interface for *defaultFormatLogger.
 func (fl *defaultFormatLogger) setOutput(output io.Writer) {
 	fl.logWriter = log.New(output, "", 0) // No prefixes
+	fl.split, _ = output.(*LevelSplitWriter)
 }
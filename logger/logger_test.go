@@ -290,6 +290,41 @@ func (m *MockRouter) Route(msg map[string]interface{}) map[string]interface{} {
 	return map[string]interface{}{"routekey": 42}
 }
 
+func TestGlobalRoutingFromBytes(t *testing.T) {
+	defer func() { globalRouter = nil }()
+	require.NoError(t, SetGlobalRoutingFromBytes([]byte(`
+routes:
+  security-events:
+    matchers:
+      title: ["login-failed"]
+    output:
+      type: "siem"
+      format: "cef"
+      vendor: "Clever"
+      product: "my-app"
+`)))
+
+	buf := &bytes.Buffer{}
+	logger := New("logger-tester")
+	logger.SetOutput(buf)
+	logger.InfoD("login-failed", M{"user": "ada"})
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	routes := entry["_kvmeta"].(map[string]interface{})["routes"].([]interface{})
+	require.Len(t, routes, 1)
+	assert.Equal(t, "siem", routes[0].(map[string]interface{})["type"])
+}
+
 func TestLoggerImplementsKayveeLogger(t *testing.T) {
 	assert.Implements(t, (*KayveeLogger)(nil), &Logger{}, "*Logger should implement KayveeLogger")
 }
+
+func TestKayveeLoggerIsComposedOfSmallInterfaces(t *testing.T) {
+	var l KayveeLogger = New("logger-tester")
+	var _ FieldLogger = l
+	var _ OutputConfigurable = l
+	var _ Leveled = l
+	var _ MetricsLogger = l
+	assert.Implements(t, (*Leveled)(nil), NewMockCountLogger("logger-tester"))
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// MarshalFailurePolicy is what the JSON and protobuf formatters do with field values that
+// can't be marshaled, like NaN, channels, cyclic structs or MarshalJSON methods that fail or
+// panic.
+type MarshalFailurePolicy int32
+
+const (
+	// MarshalPlaceholder replaces the value with a string describing the error. It's the
+	// default.
+	MarshalPlaceholder MarshalFailurePolicy = iota
+	// MarshalDropField removes the field from the entry.
+	MarshalDropField
+	// MarshalDropEntry drops the whole entry.
+	MarshalDropEntry
+)
+
+var (
+	marshalFailurePolicy int32
+	marshalFailures      uint64
+)
+
+// SetMarshalFailurePolicy sets what formatters do with field values that can't be marshaled.
+func SetMarshalFailurePolicy(p MarshalFailurePolicy) {
+	atomic.StoreInt32(&marshalFailurePolicy, int32(p))
+}
+
+// MarshalFailures returns the number of field values that couldn't be marshaled so far, for
+// diagnostics.
+func MarshalFailures() uint64 {
+	return atomic.LoadUint64(&marshalFailures)
+}
+
+// marshalJSON marshals `v`, turning panics of MarshalJSON methods into errors.
+func marshalJSON(v interface{}) (bs []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic marshaling value: %v", r)
+		}
+	}()
+	return json.Marshal(v)
+}
+
+// marshalPlaceholder is the value replacing `v`, which couldn't be marshaled because of `err`.
+func marshalPlaceholder(v interface{}, err error) string {
+	return fmt.Sprintf("Error marshaling value in map, err: %s, value: %+v", err.Error(), v)
+}
+
+// marshalFailed records that `v` couldn't be marshaled because of `err`, and returns the
+// policy to apply along with the placeholder for it.
+func marshalFailed(v interface{}, err error) (MarshalFailurePolicy, string) {
+	atomic.AddUint64(&marshalFailures, 1)
+	return MarshalFailurePolicy(atomic.LoadInt32(&marshalFailurePolicy)), marshalPlaceholder(v, err)
+}
+
+// marshalEntry marshals the data of an entry, encoding numbers according to the
+// NumberEncoding and handling the values that can't be marshaled according to the policy. It
+// returns false if the entry must be dropped.
+func marshalEntry(data map[string]interface{}) ([]byte, bool) {
+	encodeNumbers(data)
+	bs, err := marshalJSON(data)
+	if err == nil {
+		return bs, true
+	}
+	for k, v := range data {
+		if _, err := marshalJSON(v); err != nil {
+			switch policy, placeholder := marshalFailed(v, err); policy {
+			case MarshalDropEntry:
+				return nil, false
+			case MarshalDropField:
+				delete(data, k)
+			default:
+				data[k] = placeholder
+			}
+		}
+	}
+	bs, err = marshalJSON(data)
+	return bs, err == nil
+}
+
+// kayveeEnvFields are the environment fields kv.Format adds to every entry.
+var kayveeEnvFields = map[string]string{}
+
+func init() {
+	for field, env := range map[string][]string{
+		"deploy_env":    {"_DEPLOY_ENV", "DEPLOY_ENV"},
+		"wf_id":         {"_EXECUTION_NAME"},
+		"pod-id":        {"_POD_ID"},
+		"pod-shortname": {"_POD_SHORTNAME"},
+		"pod-region":    {"_POD_REGION"},
+		"pod-account":   {"_POD_ACCOUNT"},
+	} {
+		for _, name := range env {
+			if v := os.Getenv(name); v != "" {
+				kayveeEnvFields[field] = v
+				break
+			}
+		}
+	}
+}
+
+// JSONFormatter formats entries as kayvee JSON like kv.Format does, handling values that
+// can't be marshaled according to the MarshalFailurePolicy. It's the default formatter.
+func JSONFormatter(data map[string]interface{}) string {
+	for k, v := range kayveeEnvFields {
+		data[k] = v
+	}
+	return formatJSON(data)
+}
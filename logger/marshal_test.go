@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalJSON() ([]byte, error) {
+	panic("boom")
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("nope")
+}
+
+func TestMarshalFailurePolicy(t *testing.T) {
+	defer SetMarshalFailurePolicy(MarshalPlaceholder)
+
+	tests := []struct {
+		policy   MarshalFailurePolicy
+		expected []map[string]interface{}
+	}{
+		{
+			policy: MarshalPlaceholder,
+			expected: []map[string]interface{}{{
+				"title": "t", "level": "info", "source": "my-app", "ok": "fine",
+				"nan":   "Error marshaling value in map, err: json: unsupported value: NaN, value: NaN",
+				"panic": "Error marshaling value in map, err: panic marshaling value: boom, value: {}",
+				"fails": "Error marshaling value in map, err: json: error calling MarshalJSON for type *logger.failingMarshaler: nope, value: {}",
+			}},
+		},
+		{
+			policy: MarshalDropField,
+			expected: []map[string]interface{}{{
+				"title": "t", "level": "info", "source": "my-app", "ok": "fine",
+			}},
+		},
+		{
+			policy:   MarshalDropEntry,
+			expected: []map[string]interface{}{},
+		},
+	}
+	for _, test := range tests {
+		SetMarshalFailurePolicy(test.policy)
+		before := MarshalFailures()
+		out := &bytes.Buffer{}
+		l := New("my-app")
+		l.SetConfig("my-app", Info, JSONFormatter, out)
+		l.InfoD("t", M{"ok": "fine", "nan": math.NaN(), "panic": panickingMarshaler{}, "fails": failingMarshaler{}})
+
+		lines := decodeLines(t, out)
+		for _, line := range lines {
+			delete(line, "deploy_env")
+			delete(line, "wf_id")
+		}
+		assert.Equal(t, test.expected, lines, "policy %d", test.policy)
+		if test.policy == MarshalDropEntry {
+			assert.Empty(t, out.String())
+			assert.Equal(t, before+1, MarshalFailures())
+		} else {
+			assert.Equal(t, before+3, MarshalFailures())
+		}
+	}
+}
+
+func TestMarshalFailurePolicyProtobuf(t *testing.T) {
+	defer SetMarshalFailurePolicy(MarshalPlaceholder)
+
+	out := &bytes.Buffer{}
+	l := NewProtobufLogger("my-app", out)
+	l.InfoD("placeholder", M{"c": make(chan int)})
+	SetMarshalFailurePolicy(MarshalDropField)
+	l.InfoD("dropped-field", M{"c": make(chan int), "ok": 1})
+	SetMarshalFailurePolicy(MarshalDropEntry)
+	l.InfoD("dropped-entry", M{"c": make(chan int)})
+
+	r := bufio.NewReader(out)
+	entry, _, err := ReadProtobufEntry(r)
+	require.NoError(t, err)
+	assert.Contains(t, entry["c"], "Error marshaling value in map")
+	entry, _, err = ReadProtobufEntry(r)
+	require.NoError(t, err)
+	assert.Equal(t, "dropped-field", entry["title"])
+	assert.NotContains(t, entry, "c")
+	assert.Equal(t, int64(1), entry["ok"])
+	_, _, err = ReadProtobufEntry(r)
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package logger
+
+// MetricsMode is what the counter and gauge methods of a logger do, see SetMetricsMode.
+type MetricsMode int
+
+const (
+	// MetricsLog logs metric entries like the others. It's the default.
+	MetricsLog MetricsMode = iota
+	// MetricsDrop makes the counter and gauge methods no-ops, e.g. when metrics are reported
+	// by a separate agent.
+	MetricsDrop
+	// MetricsRedirect hands metric entries to a Sink instead of logging them.
+	MetricsRedirect
+)
+
+// SetMetricsMode sets what the counter and gauge methods of `l` do, without touching their
+// call sites. With MetricsRedirect, `redirect` receives the metric entries, with the globals of
+// `l`, and they aren't routed, formatted or handed to the sinks of `l`.
+func (l *Logger) SetMetricsMode(mode MetricsMode, redirect Sink) {
+	if mode == MetricsRedirect && redirect == nil {
+		mode = MetricsDrop
+	}
+	l.globalsL.Lock()
+	defer l.globalsL.Unlock()
+	l.metricsMode = mode
+	l.metricsSink = redirect
+}
+
+// logMetric logs the metric entry `data` according to the MetricsMode of `l`.
+func (l *Logger) logMetric(data map[string]interface{}) {
+	l.globalsL.RLock()
+	mode, redirect := l.metricsMode, l.metricsSink
+	if mode == MetricsRedirect {
+		for key, value := range l.globals {
+			if _, ok := data[key]; !ok {
+				data[key] = value
+			}
+		}
+	}
+	l.globalsL.RUnlock()
+
+	switch mode {
+	case MetricsDrop:
+	case MetricsRedirect:
+		redirect.WriteEntry(newEntry(Info, data, clock()))
+	default:
+		l.logWithLevel(Info, data)
+	}
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMetricsMode(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := New("my-app").(*Logger)
+	l.SetConfig("my-app", Info, func(data map[string]interface{}) string {
+		return data["title"].(string)
+	}, out)
+
+	l.Counter("a")
+	l.GaugeInt("b", 1)
+	assert.Equal(t, "a\nb\n", out.String())
+
+	out.Reset()
+	l.SetMetricsMode(MetricsDrop, nil)
+	l.Counter("a")
+	l.GaugeFloat("b", 1.5)
+	l.Info("c")
+	assert.Equal(t, "c\n", out.String())
+
+	out.Reset()
+	entries := []Entry{}
+	l.SetMetricsMode(MetricsRedirect, SinkFunc(func(e Entry) { entries = append(entries, e) }))
+	l.CounterD("a", 2, M{"k": "v"})
+	l.GaugeInt("b", 1)
+	assert.Empty(t, out.String())
+	require.Len(t, entries, 2)
+	assert.Equal(t, "a", entries[0].Title)
+	assert.Equal(t, "my-app", entries[0].Source)
+	assert.Equal(t, Info, entries[0].Level)
+	assert.Equal(t, 2, entries[0].Fields["value"])
+	assert.Equal(t, "counter", entries[0].Fields["type"])
+	assert.Equal(t, "v", entries[0].Fields["k"])
+	assert.Equal(t, "gauge", entries[1].Fields["type"])
+
+	l.SetMetricsMode(MetricsLog, nil)
+	l.Counter("a")
+	assert.Equal(t, "a\n", out.String())
+}
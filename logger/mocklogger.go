@@ -4,7 +4,7 @@ import (
 	"io"
 	"sync"
 
-	"gopkg.in/Clever/kayvee-go.v6/router"
+	"github.com/caido/dependency-kayvee-go/v6/router"
 )
 
 // MockRouteCountLogger is a mock implementation of KayveeLogger that counts the router rules
@@ -135,6 +135,11 @@ func (ml *MockRouteCountLogger) setFormatLogger(output formatLogger) {
 	return // Mocks need a custom format logger
 }
 
+// AddSink implements the method for the KayveeLogger interface.
+func (ml *MockRouteCountLogger) AddSink(s Sink) {
+	ml.logger.AddSink(s)
+}
+
 // SetRouter implements the method for the KayveeLogger interface.
 func (ml *MockRouteCountLogger) SetRouter(router router.Router) {
 	ml.logger.SetRouter(router)
@@ -215,6 +220,11 @@ func (ml *MockRouteCountLogger) CriticalD(title string, data map[string]interfac
 	ml.logger.CriticalD(title, data)
 }
 
+// Log implements the method for the KayveeLogger interface.
+func (ml *MockRouteCountLogger) Log(logLvl LogLevel, title string, fields ...Field) {
+	ml.logger.Log(logLvl, title, fields...)
+}
+
 // CounterD implements the method for the KayveeLogger interface.
 func (ml *MockRouteCountLogger) CounterD(title string, value int, data map[string]interface{}) {
 	ml.logger.CounterD(title, value, data)
@@ -4,7 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	router "gopkg.in/Clever/kayvee-go.v6/router"
+
+	"github.com/caido/dependency-kayvee-go/v6/router"
 )
 
 func TestMockLoggerImplementsKayveeLogger(t *testing.T) {
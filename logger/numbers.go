@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+)
+
+// NonFiniteEncoding is how NaN and infinite floats are encoded in JSON, which has no
+// representation for them.
+type NonFiniteEncoding int
+
+const (
+	// NonFiniteUnsupported leaves them to the MarshalFailurePolicy. It's the default.
+	NonFiniteUnsupported NonFiniteEncoding = iota
+	// NonFiniteString encodes them as the strings "NaN", "+Inf" and "-Inf".
+	NonFiniteString
+	// NonFiniteNull encodes them as null.
+	NonFiniteNull
+)
+
+// maxSafeInteger is the largest integer a float64, and so JavaScript, represents exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// NumberEncoding configures how the JSON formatters encode numbers that downstream parsers
+// can't handle.
+type NumberEncoding struct {
+	// NonFinite is how NaN and infinite floats are encoded.
+	NonFinite NonFiniteEncoding
+	// BigIntsAsStrings encodes integers beyond ±(2^53-1) as strings, since JSON parsers using
+	// float64 numbers, like JavaScript's, silently round them, which corrupts IDs.
+	BigIntsAsStrings bool
+}
+
+var numberEncoding atomic.Pointer[NumberEncoding]
+
+// SetNumberEncoding sets how the JSON formatters encode NaN, infinite floats and big integers.
+// It applies to top-level field values, and to values nested in maps and slices.
+func SetNumberEncoding(e NumberEncoding) {
+	if e == (NumberEncoding{}) {
+		numberEncoding.Store(nil)
+		return
+	}
+	numberEncoding.Store(&e)
+}
+
+// encodeNumbers rewrites the numbers of `data` according to the NumberEncoding.
+func encodeNumbers(data map[string]interface{}) {
+	enc := numberEncoding.Load()
+	if enc == nil {
+		return
+	}
+	for k, v := range data {
+		if encoded, changed := encodeNumber(enc, v); changed {
+			data[k] = encoded
+		}
+	}
+}
+
+// encodeNumber returns `v` encoded according to `enc`, and whether it changed. Maps and slices
+// holding numbers that change are copied rather than modified.
+func encodeNumber(enc *NumberEncoding, v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case float64:
+		return encodeFloat(enc, n)
+	case float32:
+		return encodeFloat(enc, float64(n))
+	case int:
+		return encodeInt(enc, int64(n))
+	case int64:
+		return encodeInt(enc, n)
+	case uint:
+		return encodeUint(enc, uint64(n))
+	case uint64:
+		return encodeUint(enc, n)
+	case map[string]interface{}:
+		return encodeMap(enc, n)
+	case M:
+		return encodeMap(enc, n)
+	case []interface{}:
+		var out []interface{}
+		for i, elem := range n {
+			if encoded, changed := encodeNumber(enc, elem); changed {
+				if out == nil {
+					out = append([]interface{}(nil), n...)
+				}
+				out[i] = encoded
+			}
+		}
+		if out != nil {
+			return out, true
+		}
+	case []float64:
+		var out []interface{}
+		for i, elem := range n {
+			if encoded, changed := encodeFloat(enc, elem); changed {
+				if out == nil {
+					out = make([]interface{}, len(n))
+					for j, f := range n {
+						out[j] = f
+					}
+				}
+				out[i] = encoded
+			}
+		}
+		if out != nil {
+			return out, true
+		}
+	}
+	return v, false
+}
+
+func encodeMap(enc *NumberEncoding, m map[string]interface{}) (interface{}, bool) {
+	var out map[string]interface{}
+	for k, elem := range m {
+		if encoded, changed := encodeNumber(enc, elem); changed {
+			if out == nil {
+				out = make(map[string]interface{}, len(m))
+				for k2, v2 := range m {
+					out[k2] = v2
+				}
+			}
+			out[k] = encoded
+		}
+	}
+	if out != nil {
+		return out, true
+	}
+	return m, false
+}
+
+func encodeFloat(enc *NumberEncoding, f float64) (interface{}, bool) {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f, false
+	}
+	switch enc.NonFinite {
+	case NonFiniteString:
+		switch {
+		case math.IsNaN(f):
+			return "NaN", true
+		case f > 0:
+			return "+Inf", true
+		}
+		return "-Inf", true
+	case NonFiniteNull:
+		return nil, true
+	}
+	return f, false
+}
+
+func encodeInt(enc *NumberEncoding, i int64) (interface{}, bool) {
+	if enc.BigIntsAsStrings && (i > maxSafeInteger || i < -maxSafeInteger) {
+		return strconv.FormatInt(i, 10), true
+	}
+	return i, false
+}
+
+func encodeUint(enc *NumberEncoding, u uint64) (interface{}, bool) {
+	if enc.BigIntsAsStrings && u > maxSafeInteger {
+		return strconv.FormatUint(u, 10), true
+	}
+	return u, false
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberEncoding(t *testing.T) {
+	defer SetNumberEncoding(NumberEncoding{})
+	data := func() M {
+		return M{
+			"nan":    math.NaN(),
+			"inf":    math.Inf(1),
+			"neginf": float32(math.Inf(-1)),
+			"id":     int64(1 << 60),
+			"uid":    uint64(math.MaxUint64),
+			"small":  1<<53 - 1,
+			"nested": M{"ok": 1.5, "list": []interface{}{math.NaN(), int64(-1 << 62)}},
+			"floats": []float64{1, math.Inf(1)},
+		}
+	}
+
+	tests := []struct {
+		enc      NumberEncoding
+		expected string
+	}{
+		{
+			enc: NumberEncoding{NonFinite: NonFiniteString, BigIntsAsStrings: true},
+			expected: `{"floats":[1,"+Inf"],"id":"1152921504606846976","inf":"+Inf","nan":"NaN",` +
+				`"neginf":"-Inf","nested":{"list":["NaN","-4611686018427387904"],"ok":1.5},` +
+				`"small":9007199254740991,"uid":"18446744073709551615"}`,
+		},
+		{
+			enc: NumberEncoding{NonFinite: NonFiniteNull},
+			expected: `{"floats":[1,null],"id":1152921504606846976,"inf":null,"nan":null,` +
+				`"neginf":null,"nested":{"list":[null,-4611686018427387904],"ok":1.5},` +
+				`"small":9007199254740991,"uid":18446744073709551615}`,
+		},
+	}
+	for _, test := range tests {
+		SetNumberEncoding(test.enc)
+		d := data()
+		nested := d["nested"]
+		assert.Equal(t, test.expected, formatJSON(d))
+		// values nested in the caller's maps are copied, not modified
+		assert.True(t, math.IsNaN(nested.(M)["list"].([]interface{})[0].(float64)))
+	}
+
+	SetNumberEncoding(NumberEncoding{})
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, JSONFormatter, out)
+	l.InfoD("t", M{"nan": math.NaN()})
+	assert.Contains(t, out.String(), `"nan":"Error marshaling value in map`)
+}
@@ -0,0 +1,82 @@
+package logger
+
+import "time"
+
+// otelSeverities maps levels to OpenTelemetry severity texts and numbers.
+var otelSeverities = map[string]struct {
+	text   string
+	number int
+}{
+	"trace":    {"TRACE", 1},
+	"debug":    {"DEBUG", 5},
+	"info":     {"INFO", 9},
+	"warning":  {"WARN", 13},
+	"error":    {"ERROR", 17},
+	"critical": {"FATAL", 21},
+}
+
+// otelResourceFields maps kayvee fields describing the service to OpenTelemetry resource
+// attributes.
+var otelResourceFields = map[string]string{
+	"source":     "service.name",
+	"deploy_env": "deployment.environment",
+}
+
+// otelAttributeFields maps kayvee fields, including the ones logged by the kayvee middleware,
+// to OpenTelemetry semantic convention attributes.
+var otelAttributeFields = map[string]string{
+	"error":         "exception.message",
+	"error_type":    "exception.type",
+	"stack":         "exception.stacktrace",
+	"stacktrace":    "exception.stacktrace",
+	"stack_trace":   "exception.stacktrace",
+	"method":        "http.request.method",
+	"path":          "url.path",
+	"params":        "url.query",
+	"ip":            "client.address",
+	"status-code":   "http.response.status_code",
+	"response-size": "http.response.body.size",
+	"db_system":     "db.system",
+	"db_name":       "db.namespace",
+	"table":         "db.collection.name",
+	"query":         "db.query.text",
+}
+
+// OTelFormatter is a Formatter emitting entries shaped like the OpenTelemetry log data
+// model: the title becomes the body, the level becomes severity_text and severity_number,
+// source and deploy_env become resource attributes, trace and span ids are lifted to the top
+// level, and the remaining fields become attributes, renamed to their semantic convention
+// names (http.*, url.*, db.*, exception.*) where there is one. Set KAYVEE_FORMAT=otel to
+// enable it for loggers created with New.
+func OTelFormatter(data map[string]interface{}) string {
+	resource := map[string]interface{}{}
+	attributes := map[string]interface{}{}
+	out := map[string]interface{}{
+		"timestamp":  clock().UTC().Format(time.RFC3339Nano),
+		"resource":   resource,
+		"attributes": attributes,
+	}
+	for k, v := range data {
+		switch k {
+		case "title":
+			out["body"] = v
+		case "level":
+			level, _ := v.(string)
+			if sev, ok := otelSeverities[level]; ok {
+				out["severity_text"] = sev.text
+				out["severity_number"] = sev.number
+			}
+		case "trace_id", "trace-id":
+			out["trace_id"] = v
+		case "span_id", "span-id":
+			out["span_id"] = v
+		default:
+			if to, ok := otelResourceFields[k]; ok {
+				resource[to] = v
+				continue
+			}
+			mapFields(attributes, map[string]interface{}{k: v}, otelAttributeFields)
+		}
+	}
+	return formatJSON(out)
+}
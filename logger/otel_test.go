@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTelFormatter(t *testing.T) {
+	SetClock(func() time.Time { return time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC) })
+	defer SetClock(nil)
+	os.Setenv("KAYVEE_FORMAT", "otel")
+	defer os.Unsetenv("KAYVEE_FORMAT")
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetOutput(buf)
+	l.WarnD("slow-query", M{
+		"trace-id": "abc",
+		"query":    "SELECT 1",
+		"method":   "GET",
+		"user":     "u1",
+	})
+
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "2024-01-01T10:00:00Z", e["timestamp"])
+	assert.Equal(t, "slow-query", e["body"])
+	assert.Equal(t, "WARN", e["severity_text"])
+	assert.Equal(t, float64(13), e["severity_number"])
+	assert.Equal(t, "abc", e["trace_id"])
+	assert.Equal(t, "my-app", e["resource"].(map[string]interface{})["service.name"])
+	attributes := e["attributes"].(map[string]interface{})
+	assert.Equal(t, "SELECT 1", attributes["db.query.text"])
+	assert.Equal(t, "GET", attributes["http.request.method"])
+	assert.Equal(t, "u1", attributes["user"])
+	assert.NotContains(t, attributes, "title")
+	assert.NotContains(t, attributes, "source")
+}
@@ -0,0 +1,251 @@
+// Package parser reads kayvee output, either JSON or logfmt lines, back into logger.Entry
+// values, for tools consuming kayvee logs programmatically like tailers, test harnesses and
+// replayers.
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// TimeFields are the fields checked, in order, for the time of an entry. Times are either RFC
+// 3339 strings or seconds since the epoch. The field the time was read from is removed from
+// the entry's Fields.
+var TimeFields = []string{"timestamp", "time", "@timestamp", "ts"}
+
+// ErrEmptyLine is returned when parsing a blank line.
+var ErrEmptyLine = errors.New("empty line")
+
+// Parse parses a JSON or logfmt line, detected from its first character.
+func Parse(line []byte) (logger.Entry, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) > 0 && line[0] == '{' {
+		return ParseJSON(line)
+	}
+	return ParseLogfmt(line)
+}
+
+// ParseJSON parses a kayvee JSON line. Integers are decoded as int64 and other numbers as
+// float64, including in nested objects and arrays.
+func ParseJSON(line []byte) (logger.Entry, error) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return logger.Entry{}, ErrEmptyLine
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	data := map[string]interface{}{}
+	if err := dec.Decode(&data); err != nil {
+		return logger.Entry{}, err
+	}
+	if dec.More() {
+		return logger.Entry{}, errors.New("unexpected data after JSON object")
+	}
+	for k, v := range data {
+		data[k] = inferNumbers(v)
+	}
+	return toEntry(data), nil
+}
+
+// ParseLogfmt parses a logfmt line, e.g. `level=info title="request finished" status=200`.
+// Unquoted values are inferred to be booleans, int64s or float64s when they parse as such,
+// and are strings otherwise. Quoted values are always strings. Keys without a value are true.
+func ParseLogfmt(line []byte) (logger.Entry, error) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return logger.Entry{}, ErrEmptyLine
+	}
+	data := map[string]interface{}{}
+	for i := 0; i < len(line); {
+		if isSpace(line[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && !isSpace(line[i]) {
+			if line[i] == '"' {
+				return logger.Entry{}, fmt.Errorf("unexpected quote in key at offset %d", i)
+			}
+			i++
+		}
+		key := string(line[start:i])
+		if i == len(line) || line[i] != '=' {
+			data[key] = true
+			continue
+		}
+		i++
+		if i < len(line) && line[i] == '"' {
+			end, err := quotedEnd(line, i)
+			if err != nil {
+				return logger.Entry{}, err
+			}
+			value, err := strconv.Unquote(string(line[i:end]))
+			if err != nil {
+				return logger.Entry{}, fmt.Errorf("invalid quoted value for %q: %v", key, err)
+			}
+			data[key] = value
+			i = end
+			continue
+		}
+		start = i
+		for i < len(line) && !isSpace(line[i]) {
+			i++
+		}
+		data[key] = inferValue(string(line[start:i]))
+	}
+	return toEntry(data), nil
+}
+
+// ParseError is returned by Reader.Read for lines that couldn't be parsed.
+type ParseError struct {
+	// Line is the 1-based number of the line.
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads entries from a stream of JSON or logfmt lines, which may be mixed.
+type Reader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewReader returns a Reader reading from `r`.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next entry, skipping blank lines. Lines that can't be parsed return a
+// *ParseError, after which reading can continue. Read returns io.EOF at the end of the input.
+func (r *Reader) Read() (logger.Entry, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return logger.Entry{}, err
+		}
+		r.line++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		entry, perr := Parse(line)
+		if perr != nil {
+			return logger.Entry{}, &ParseError{Line: r.line, Err: perr}
+		}
+		return entry, nil
+	}
+}
+
+// toEntry moves the title, level, source and time of `data` into an Entry. A level that isn't
+// a known level name is left in Fields, and the entry gets the Info level.
+func toEntry(data map[string]interface{}) logger.Entry {
+	e := logger.Entry{Level: logger.Info, Fields: logger.M{}}
+	if title, ok := data["title"].(string); ok {
+		e.Title = title
+		delete(data, "title")
+	}
+	if source, ok := data["source"].(string); ok {
+		e.Source = source
+		delete(data, "source")
+	}
+	if name, ok := data["level"].(string); ok {
+		if lvl, err := logger.ParseLevel(name); err == nil {
+			e.Level = lvl
+			delete(data, "level")
+		}
+	}
+	for _, field := range TimeFields {
+		if t, ok := parseTime(data[field]); ok {
+			e.Time = t
+			delete(data, field)
+			break
+		}
+	}
+	for k, v := range data {
+		e.Fields[k] = v
+	}
+	return e
+}
+
+func parseTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		return parsed, err == nil
+	case int64:
+		return time.Unix(t, 0), true
+	case float64:
+		sec, frac := math.Modf(t)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))), true
+	}
+	return time.Time{}, false
+}
+
+// inferNumbers replaces the json.Numbers in `v` with int64s or float64s.
+func inferNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}:
+		for k, elem := range t {
+			t[k] = inferNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range t {
+			t[i] = inferNumbers(elem)
+		}
+	}
+	return v
+}
+
+// inferValue converts an unquoted logfmt value to a bool, int64 or float64 when possible.
+func inferValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	return s
+}
+
+// quotedEnd returns the offset just past the closing quote of the string starting at
+// line[start].
+func quotedEnd(line []byte, start int) (int, error) {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted value at offset %d", start)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
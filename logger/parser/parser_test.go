@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected logger.Entry
+	}{
+		{
+			name: "json",
+			line: `{"title":"request-finished","level":"warning","source":"api","status":500,"ms":1.5,"ok":false,"nested":{"n":2,"l":[1,2.5]}}`,
+			expected: logger.Entry{
+				Level:  logger.Warning,
+				Title:  "request-finished",
+				Source: "api",
+				Fields: logger.M{
+					"status": int64(500),
+					"ms":     1.5,
+					"ok":     false,
+					"nested": map[string]interface{}{"n": int64(2), "l": []interface{}{int64(1), 2.5}},
+				},
+			},
+		},
+		{
+			name: "json with time",
+			line: `{"title":"t","level":"info","timestamp":"2024-01-01T10:00:00Z"}`,
+			expected: logger.Entry{
+				Level:  logger.Info,
+				Title:  "t",
+				Time:   time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+				Fields: logger.M{},
+			},
+		},
+		{
+			name: "logfmt",
+			line: `level=error title="payment failed" source=api status=500 ms=1.5 retried=true id=abc empty= flag msg="a \"quoted\"\nline"`,
+			expected: logger.Entry{
+				Level:  logger.Error,
+				Title:  "payment failed",
+				Source: "api",
+				Fields: logger.M{
+					"status":  int64(500),
+					"ms":      1.5,
+					"retried": true,
+					"id":      "abc",
+					"empty":   "",
+					"flag":    true,
+					"msg":     "a \"quoted\"\nline",
+				},
+			},
+		},
+		{
+			name: "logfmt keeps quoted values as strings",
+			line: `title=t status="500" ts=1704103200`,
+			expected: logger.Entry{
+				Level:  logger.Info,
+				Title:  "t",
+				Time:   time.Unix(1704103200, 0),
+				Fields: logger.M{"status": "500"},
+			},
+		},
+		{
+			name: "unknown levels are kept as fields",
+			line: `{"title":"t","level":"loud"}`,
+			expected: logger.Entry{
+				Level:  logger.Info,
+				Title:  "t",
+				Fields: logger.M{"level": "loud"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := Parse([]byte(test.line))
+			require.NoError(t, err)
+			if !test.expected.Time.IsZero() {
+				assert.True(t, test.expected.Time.Equal(entry.Time), entry.Time)
+				entry.Time = test.expected.Time
+			}
+			assert.Equal(t, test.expected, entry)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{
+		"",
+		`{"title":`,
+		`{"title":"t"} {}`,
+		`title="unterminated`,
+		`ti"tle=t`,
+	} {
+		_, err := Parse([]byte(line))
+		assert.Error(t, err, line)
+	}
+}
+
+func TestRoundTripsLoggerOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := logger.New("my-app")
+	l.SetConfig("my-app", logger.Debug, kv.Format, buf)
+	l.DebugD("cache-miss", logger.M{"key": "user:1", "size": 12, "ratio": 0.25})
+
+	entry, err := Parse(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, logger.Debug, entry.Level)
+	assert.Equal(t, "cache-miss", entry.Title)
+	assert.Equal(t, "my-app", entry.Source)
+	assert.Equal(t, "user:1", entry.Fields["key"])
+	assert.Equal(t, int64(12), entry.Fields["size"])
+	assert.Equal(t, 0.25, entry.Fields["ratio"])
+}
+
+func TestReader(t *testing.T) {
+	input := `{"title":"a","level":"info"}
+
+title=b level=debug
+title="c
+{"title":"d","level":"error"}`
+	r := NewReader(strings.NewReader(input))
+
+	var titles []string
+	var parseErr *ParseError
+	for {
+		entry, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if errors.As(err, &parseErr) {
+			continue
+		}
+		require.NoError(t, err)
+		titles = append(titles, entry.Title)
+	}
+	assert.Equal(t, []string{"a", "b", "d"}, titles)
+	assert.Equal(t, 4, parseErr.Line)
+}
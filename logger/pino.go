@@ -0,0 +1,41 @@
+package logger
+
+import "os"
+
+// pinoVersion is the log record version pino and bunyan write as "v".
+const pinoVersion = 1
+
+// pinoLevels maps levels to the numeric levels used by pino and bunyan.
+var pinoLevels = map[string]int{
+	"trace":    10,
+	"debug":    20,
+	"info":     30,
+	"warning":  40,
+	"error":    50,
+	"critical": 60,
+}
+
+var (
+	pinoPID         = os.Getpid()
+	pinoHostname, _ = os.Hostname()
+)
+
+// PinoFormatter is a Formatter emitting entries in the shape pino and bunyan tooling
+// expects: a numeric level, time in milliseconds since the epoch, the title as msg, the
+// source as name, the pid and hostname of the process, and the record version as v. Other
+// fields are kept as is.
+// Set KAYVEE_FORMAT=pino to enable it for loggers created with New.
+func PinoFormatter(data map[string]interface{}) string {
+	out := make(map[string]interface{}, len(data)+4)
+	mapFields(out, data, map[string]string{"title": "msg", "source": "name"})
+	if level, ok := data["level"].(string); ok {
+		if n, ok := pinoLevels[level]; ok {
+			out["level"] = n
+		}
+	}
+	out["time"] = clock().UnixMilli()
+	out["pid"] = pinoPID
+	out["hostname"] = pinoHostname
+	out["v"] = pinoVersion
+	return formatJSON(out)
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinoFormatter(t *testing.T) {
+	SetClock(func() time.Time { return time.UnixMilli(1704103200123) })
+	defer SetClock(nil)
+	os.Setenv("KAYVEE_FORMAT", "pino")
+	defer os.Unsetenv("KAYVEE_FORMAT")
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetOutput(buf)
+	l.ErrorD("request-failed", M{"user": "u1"})
+	l.Trace("tracing")
+
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 2)
+	e := entries[0]
+	assert.Equal(t, float64(50), e["level"])
+	assert.Equal(t, float64(1704103200123), e["time"])
+	assert.Equal(t, "request-failed", e["msg"])
+	assert.Equal(t, "my-app", e["name"])
+	assert.Equal(t, float64(os.Getpid()), e["pid"])
+	assert.Equal(t, float64(1), e["v"])
+	assert.Equal(t, "u1", e["user"])
+	assert.NotContains(t, e, "title")
+	assert.Equal(t, float64(10), entries[1]["level"])
+}
@@ -0,0 +1,360 @@
+// Package pipeline builds the logging topology of a service from its kvconfig.yml: besides
+// the routing rules under "routes", the file can define named sinks, enrichers and a
+// redaction policy, and which routing rules feed which sinks.
+//
+//	routes:
+//	  payment-events:
+//	    matchers:
+//	      title: ["payment-succeeded", "payment-failed"]
+//	    output:
+//	      type: "analytics"
+//	      series: "payments"
+//	sinks:
+//	  payments-firehose:
+//	    type: firehose
+//	    stream: "${PAYMENTS_STREAM}"
+//	    rules: ["payment-events"]
+//	  errors-file:
+//	    type: file
+//	    path: /var/log/errors.log
+//	    min_level: error
+//	  loki:
+//	    type: loki
+//	    url: http://loki:3100
+//	    labels: {app: payments}
+//	enrichers:
+//	  - type: static
+//	    fields: {team: payments}
+//	  - type: env
+//	    fields: {pod: _POD_ID}
+//	redaction:
+//	  fields: [password, card_number]
+//	  patterns: ['\d{3}-\d{2}-\d{4}']
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+	"github.com/caido/dependency-kayvee-go/v6/router"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultQueueSize is the number of entries queued for a sink that doesn't set queue_size.
+const defaultQueueSize = 1000
+
+// defaultReplacement replaces redacted values when the redaction policy doesn't set one.
+const defaultReplacement = "[REDACTED]"
+
+// Config is the pipeline part of a kvconfig.yml file.
+type Config struct {
+	Sinks     map[string]SinkConfig `yaml:"sinks"`
+	Enrichers []EnricherConfig      `yaml:"enrichers"`
+	Redaction *RedactionConfig      `yaml:"redaction"`
+}
+
+// SinkConfig configures a named sink.
+type SinkConfig struct {
+	// Type is the registered type of the sink, e.g. "file", "firehose" or "loki".
+	Type string `yaml:"type"`
+	// Rules are the names of the routing rules feeding the sink. Entries matching none of them
+	// aren't sent to it. An empty list feeds it every entry.
+	Rules []string `yaml:"rules"`
+	// MinLevel is the lowest level of the entries sent to the sink. Defaults to every level the
+	// logger logs.
+	MinLevel string `yaml:"min_level"`
+	// QueueSize is the number of entries queued for the sink before dropping. Defaults to 1000.
+	QueueSize int `yaml:"queue_size"`
+	// Settings are the other keys of the sink, handed to its type. String values have
+	// ${ENV_VAR} substitutions performed.
+	Settings map[string]interface{} `yaml:",inline"`
+}
+
+// EnricherConfig configures fields added to every entry. Type "static" adds `fields` as is,
+// while type "env" adds the values of the environment variables named by `fields`.
+type EnricherConfig struct {
+	Type   string            `yaml:"type"`
+	Fields map[string]string `yaml:"fields"`
+}
+
+// RedactionConfig configures the values replaced before entries leave the process.
+type RedactionConfig struct {
+	// Fields are the names of the fields whose values are replaced.
+	Fields []string `yaml:"fields"`
+	// Patterns are regular expressions whose matches are replaced in string values.
+	Patterns []string `yaml:"patterns"`
+	// Replacement replaces redacted values. Defaults to "[REDACTED]".
+	Replacement string `yaml:"replacement"`
+}
+
+// Pipeline is a logging topology built from a Config.
+type Pipeline struct {
+	router    router.Router
+	sinks     []*sink
+	enrichers map[string]string
+	redactor  *redactor
+}
+
+// sink is a configured sink, fed entries through an IsolatedSink.
+type sink struct {
+	name     string
+	rules    map[string]bool
+	minLevel logger.LogLevel
+	redactor *redactor
+	out      io.Writer
+	isolated *logger.IsolatedSink
+}
+
+// Load builds the Pipeline configured in the kvconfig.yml file at `filename`.
+func Load(filename string) (*Pipeline, error) {
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p, err := LoadBytes(bs)
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing kayvee pipeline from file '%s':\n%s", filename, err.Error())
+	}
+	return p, nil
+}
+
+// LoadBytes builds the Pipeline configured in the contents of a kvconfig.yml file. The sinks
+// are created, so their outputs are opened, but they receive nothing until Apply.
+func LoadBytes(bs []byte) (*Pipeline, error) {
+	var config struct {
+		Config `yaml:",inline"`
+		Routes map[string]interface{} `yaml:"routes"`
+	}
+	if err := yaml.Unmarshal(bs, &config); err != nil {
+		return nil, err
+	}
+	p := &Pipeline{enrichers: map[string]string{}}
+	if config.Routes != nil {
+		r, err := router.NewFromConfigBytes(bs)
+		if err != nil {
+			return nil, err
+		}
+		p.router = r
+	}
+
+	for _, e := range config.Enrichers {
+		switch e.Type {
+		case "static":
+			for k, v := range e.Fields {
+				p.enrichers[k] = v
+			}
+		case "env":
+			for k, env := range e.Fields {
+				if v := os.Getenv(env); v != "" {
+					p.enrichers[k] = v
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown enricher type '%s'", e.Type)
+		}
+	}
+
+	if config.Redaction != nil {
+		r, err := newRedactor(*config.Redaction)
+		if err != nil {
+			return nil, err
+		}
+		p.redactor = r
+	}
+
+	// create the sinks in a stable order, so that errors are reproducible
+	names := make([]string, 0, len(config.Sinks))
+	for name := range config.Sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s, err := newSink(name, config.Sinks[name], config.Routes, p.redactor)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.sinks = append(p.sinks, s)
+	}
+	return p, nil
+}
+
+func newSink(name string, c SinkConfig, routes map[string]interface{}, r *redactor) (*sink, error) {
+	s := &sink{name: name, minLevel: logger.Trace, redactor: r}
+	if len(c.Rules) > 0 {
+		s.rules = map[string]bool{}
+		for _, rule := range c.Rules {
+			if _, ok := routes[rule]; !ok {
+				return nil, fmt.Errorf("sink '%s' is fed by unknown rule '%s'", name, rule)
+			}
+			s.rules[rule] = true
+		}
+	}
+	if c.MinLevel != "" {
+		lvl, err := logger.ParseLevel(c.MinLevel)
+		if err != nil {
+			return nil, fmt.Errorf("sink '%s': %s", name, err.Error())
+		}
+		s.minLevel = lvl
+	}
+	settings, err := expandSettings(c.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("sink '%s': %s", name, err.Error())
+	}
+	out, err := openSink(c.Type, settings)
+	if err != nil {
+		return nil, fmt.Errorf("sink '%s': %s", name, err.Error())
+	}
+	s.out = out
+	queueSize := c.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	s.isolated = logger.NewIsolatedSink(logger.SinkFunc(s.write), queueSize)
+	return s, nil
+}
+
+// Apply makes `l` log according to the pipeline: the routing rules are set as its router,
+// the enrichers are added to its context, the sinks are added to it, and, if a redaction
+// policy is configured, its formatter is replaced with a redacting logger.JSONFormatter.
+func (p *Pipeline) Apply(l logger.KayveeLogger) {
+	if p.router != nil {
+		l.SetRouter(p.router)
+	}
+	for k, v := range p.enrichers {
+		l.AddContext(k, v)
+	}
+	if p.redactor != nil {
+		l.SetFormatter(p.Formatter(logger.JSONFormatter))
+	}
+	for _, s := range p.sinks {
+		l.AddSink(s)
+	}
+}
+
+// Formatter returns a Formatter applying the redaction policy before `next`.
+func (p *Pipeline) Formatter(next logger.Formatter) logger.Formatter {
+	if p.redactor == nil {
+		return next
+	}
+	return func(data map[string]interface{}) string {
+		return next(p.redactor.redact(data))
+	}
+}
+
+// Dropped returns the number of entries dropped so far because their sink's queue was full,
+// per sink.
+func (p *Pipeline) Dropped() map[string]uint64 {
+	out := map[string]uint64{}
+	for _, s := range p.sinks {
+		out[s.name] = s.isolated.Dropped()
+	}
+	return out
+}
+
+// Close hands the queued entries to the sinks and closes their outputs.
+func (p *Pipeline) Close() error {
+	var firstErr error
+	for _, s := range p.sinks {
+		s.isolated.Close()
+		if c, ok := s.out.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// WriteEntry implements the logger.Sink interface. It queues `e` if it's fed to the sink.
+func (s *sink) WriteEntry(e logger.Entry) {
+	if e.Level < s.minLevel || !s.feeds(e) {
+		return
+	}
+	s.isolated.WriteEntry(e)
+}
+
+// feeds returns true if `e` matched one of the rules feeding the sink.
+func (s *sink) feeds(e logger.Entry) bool {
+	if s.rules == nil {
+		return true
+	}
+	meta, _ := e.Fields["_kvmeta"].(map[string]interface{})
+	routes, _ := meta["routes"].([]map[string]interface{})
+	for _, route := range routes {
+		if rule, _ := route["rule"].(string); s.rules[rule] {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes `e` to the sink's output as a line of kayvee JSON.
+func (s *sink) write(e logger.Entry) {
+	data := make(map[string]interface{}, len(e.Fields)+4)
+	for k, v := range e.Fields {
+		if k != "_kvmeta" {
+			data[k] = v
+		}
+	}
+	data["title"] = e.Title
+	data["level"] = e.Level.String()
+	data["source"] = e.Source
+	data["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	if s.redactor != nil {
+		data = s.redactor.redact(data)
+	}
+	if line := logger.JSONFormatter(data); line != "" {
+		s.out.Write([]byte(line + "\n"))
+	}
+}
+
+// redactor replaces the values covered by a redaction policy.
+type redactor struct {
+	fields      map[string]bool
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+func newRedactor(c RedactionConfig) (*redactor, error) {
+	r := &redactor{fields: map[string]bool{}, replacement: c.Replacement}
+	if r.replacement == "" {
+		r.replacement = defaultReplacement
+	}
+	for _, f := range c.Fields {
+		r.fields[f] = true
+	}
+	for _, pattern := range c.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern '%s': %s", pattern, err.Error())
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// redact returns a copy of `data` with the values covered by the policy replaced.
+func (r *redactor) redact(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if r.fields[k] {
+			out[k] = r.replacement
+			continue
+		}
+		if s, ok := v.(string); ok && k != "title" && k != "level" && k != "source" {
+			for _, re := range r.patterns {
+				s = re.ReplaceAllString(s, r.replacement)
+			}
+			v = s
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink is a sink type keeping the lines written, for tests.
+type memorySink struct {
+	mu    sync.Mutex
+	lines []map[string]interface{}
+}
+
+func (m *memorySink) Write(p []byte) (int, error) {
+	var line map[string]interface{}
+	if err := json.Unmarshal(p, &line); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lines = append(m.lines, line)
+	return len(p), nil
+}
+
+func (m *memorySink) titles() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	titles := []string{}
+	for _, line := range m.lines {
+		titles = append(titles, line["title"].(string))
+	}
+	return titles
+}
+
+const testConfig = `
+routes:
+  payments:
+    matchers:
+      title: ["payment-succeeded", "payment-failed"]
+    output:
+      type: "analytics"
+      series: "payments"
+sinks:
+  payments:
+    type: memory
+    name: payments
+    rules: ["payments"]
+  errors:
+    type: memory
+    name: errors
+    min_level: error
+  everything:
+    type: memory
+    name: "${PIPELINE_TEST_SINK}"
+enrichers:
+  - type: static
+    fields: {team: payments}
+  - type: env
+    fields: {pod: PIPELINE_TEST_POD}
+redaction:
+  fields: [password]
+  patterns: ['\d{3}-\d{2}-\d{4}']
+`
+
+func TestPipeline(t *testing.T) {
+	sinks := map[string]*memorySink{}
+	RegisterSinkType("memory", func(settings Settings) (io.Writer, error) {
+		s := &memorySink{}
+		sinks[settings.String("name")] = s
+		return s, nil
+	})
+	os.Setenv("PIPELINE_TEST_SINK", "everything")
+	defer os.Unsetenv("PIPELINE_TEST_SINK")
+	os.Setenv("PIPELINE_TEST_POD", "pod-1")
+	defer os.Unsetenv("PIPELINE_TEST_POD")
+
+	p, err := LoadBytes([]byte(testConfig))
+	require.NoError(t, err)
+	require.Len(t, sinks, 3)
+
+	out := &bytes.Buffer{}
+	l := logger.New("my-app")
+	l.SetOutput(out)
+	p.Apply(l)
+
+	l.InfoD("payment-succeeded", logger.M{"password": "hunter2"})
+	l.InfoD("user-created", logger.M{"ssn": "123-45-6789"})
+	l.Error("payment-failed")
+	require.NoError(t, p.Close())
+
+	assert.Equal(t, []string{"payment-succeeded", "payment-failed"}, sinks["payments"].titles())
+	assert.Equal(t, []string{"payment-failed"}, sinks["errors"].titles())
+	assert.Equal(t, []string{"payment-succeeded", "user-created", "payment-failed"}, sinks["everything"].titles())
+
+	lines := sinks["everything"].lines
+	assert.Equal(t, "[REDACTED]", lines[0]["password"])
+	assert.Equal(t, "[REDACTED]", lines[1]["ssn"])
+	assert.Equal(t, "payments", lines[0]["team"])
+	assert.Equal(t, "pod-1", lines[0]["pod"])
+	assert.Equal(t, "my-app", lines[0]["source"])
+	assert.NotContains(t, lines[0], "_kvmeta")
+
+	// the output is enriched, redacted and routed too
+	outLines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, outLines, 3)
+	var first map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(outLines[0]), &first))
+	assert.Equal(t, "[REDACTED]", first["password"])
+	assert.Equal(t, "payments", first["team"])
+	routes := first["_kvmeta"].(map[string]interface{})["routes"].([]interface{})
+	assert.Equal(t, "payments", routes[0].(map[string]interface{})["rule"])
+
+	assert.Equal(t, map[string]uint64{"errors": 0, "everything": 0, "payments": 0}, p.Dropped())
+}
+
+func TestPipelineFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.log")
+	p, err := LoadBytes([]byte("sinks:\n  errors:\n    type: file\n    path: " + path + "\n"))
+	require.NoError(t, err)
+	l := logger.New("my-app")
+	l.SetOutput(ioutil.Discard)
+	p.Apply(l)
+	l.Error("disk-full")
+	require.NoError(t, p.Close())
+
+	bs, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(bs), `"title":"disk-full"`)
+}
+
+func TestPipelineLokiSink(t *testing.T) {
+	var mu sync.Mutex
+	var pushes []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/push", r.URL.Path)
+		var push map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&push))
+		mu.Lock()
+		defer mu.Unlock()
+		pushes = append(pushes, push)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := "sinks:\n  loki:\n    type: loki\n    url: " + server.URL + "\n    labels: {app: my-app}\n    batch_size: 2\n"
+	p, err := LoadBytes([]byte(config))
+	require.NoError(t, err)
+	l := logger.New("my-app")
+	l.SetOutput(ioutil.Discard)
+	p.Apply(l)
+	for _, title := range []string{"a", "b", "c"} {
+		l.Info(title)
+	}
+	require.NoError(t, p.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, pushes, 2)
+	stream := pushes[0]["streams"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"app": "my-app"}, stream["stream"])
+	assert.Len(t, stream["values"], 2)
+}
+
+func TestPipelineErrors(t *testing.T) {
+	for _, test := range []struct {
+		config string
+		err    string
+	}{
+		{"sinks:\n  x:\n    type: nope\n", "sink 'x': unknown sink type 'nope'"},
+		{"sinks:\n  x:\n    type: file\n", "sink 'x': file sinks require a path"},
+		{"sinks:\n  x:\n    type: file\n    path: /tmp/x\n    rules: [missing]\n", "sink 'x' is fed by unknown rule 'missing'"},
+		{"sinks:\n  x:\n    type: file\n    path: ${PIPELINE_TEST_UNSET}\n", "sink 'x': \tEnvironment variable 'PIPELINE_TEST_UNSET' not set"},
+		{"sinks:\n  x:\n    type: file\n    path: /tmp/x\n    min_level: loud\n", "sink 'x': "},
+		{"enrichers:\n  - type: magic\n", "unknown enricher type 'magic'"},
+		{"redaction:\n  patterns: ['(']\n", "invalid redaction pattern '('"},
+	} {
+		_, err := LoadBytes([]byte(test.config))
+		if assert.Error(t, err, test.config) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger/analytics"
+)
+
+// Settings are the settings of a sink, as written in kvconfig.yml.
+type Settings map[string]interface{}
+
+// String returns the string setting `key`, or "" if it isn't set.
+func (s Settings) String(key string) string {
+	v, _ := s[key].(string)
+	return v
+}
+
+// Int returns the integer setting `key`, or `def` if it isn't set.
+func (s Settings) Int(key string, def int) int {
+	switch v := s[key].(type) {
+	case int:
+		return v
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// StringMap returns the map setting `key`, e.g. labels.
+func (s Settings) StringMap(key string) map[string]string {
+	out := map[string]string{}
+	if m, ok := s[key].(map[string]interface{}); ok {
+		for k, v := range m {
+			out[k] = fmt.Sprint(v)
+		}
+	}
+	return out
+}
+
+// SinkType opens the output of a sink from its settings. The output receives lines of kayvee
+// JSON. If it implements io.Closer, it's closed with the Pipeline.
+type SinkType func(settings Settings) (io.Writer, error)
+
+var (
+	sinkTypesL sync.RWMutex
+	sinkTypes  = map[string]SinkType{
+		"file":     openFileSink,
+		"firehose": openFirehoseSink,
+		"loki":     openLokiSink,
+	}
+)
+
+// RegisterSinkType makes sinks of type `name` available to kvconfig.yml files, replacing any
+// previously registered type of the same name.
+func RegisterSinkType(name string, t SinkType) {
+	sinkTypesL.Lock()
+	defer sinkTypesL.Unlock()
+	sinkTypes[name] = t
+}
+
+func openSink(typ string, settings Settings) (io.Writer, error) {
+	sinkTypesL.RLock()
+	t, ok := sinkTypes[typ]
+	sinkTypesL.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type '%s'", typ)
+	}
+	return t(settings)
+}
+
+var envVarToken = regexp.MustCompile(`\$\{.+?\}`)
+
+// expandSettings converts the settings of a sink as decoded from YAML, performing ${ENV_VAR}
+// substitutions in string values. An error is returned if an env-var is not set.
+func expandSettings(raw map[string]interface{}) (Settings, error) {
+	missing := []string{}
+	var convert func(v interface{}) interface{}
+	convert = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case string:
+			return envVarToken.ReplaceAllStringFunc(v, func(token string) string {
+				name := token[2 : len(token)-1]
+				val := os.Getenv(name)
+				if val == "" {
+					missing = append(missing, fmt.Sprintf("\tEnvironment variable '%s' not set", name))
+				}
+				return val
+			})
+		case map[interface{}]interface{}:
+			m := make(map[string]interface{}, len(v))
+			for k, elem := range v {
+				m[fmt.Sprint(k)] = convert(elem)
+			}
+			return m
+		case []interface{}:
+			s := make([]interface{}, len(v))
+			for i, elem := range v {
+				s[i] = convert(elem)
+			}
+			return s
+		}
+		return v
+	}
+	settings := make(Settings, len(raw))
+	for k, v := range raw {
+		settings[k] = convert(v)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(missing, "\n"))
+	}
+	return settings, nil
+}
+
+// openFileSink appends to the file at `path`.
+func openFileSink(settings Settings) (io.Writer, error) {
+	path := settings.String("path")
+	if path == "" {
+		return nil, fmt.Errorf("file sinks require a path")
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+// openFirehoseSink sends to the Firehose `stream`, or to the ark `db`, in `region`.
+func openFirehoseSink(settings Settings) (io.Writer, error) {
+	c := analytics.Config{
+		StreamName:                       settings.String("stream"),
+		DBName:                           settings.String("db"),
+		Environment:                      settings.String("environment"),
+		Region:                           settings.String("region"),
+		FirehosePutRecordBatchMaxRecords: settings.Int("batch_max_records", 0),
+	}
+	if c.StreamName == "" && c.DBName == "" {
+		return nil, fmt.Errorf("firehose sinks require a stream or a db")
+	}
+	if maxTime := settings.String("batch_max_time"); maxTime != "" {
+		d, err := time.ParseDuration(maxTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch_max_time: %s", err.Error())
+		}
+		c.FirehosePutRecordBatchMaxTime = d
+	}
+	return analytics.New(c)
+}
+
+// lokiWriter pushes lines to the Loki push API in batches.
+type lokiWriter struct {
+	url       string
+	labels    map[string]string
+	batchSize int
+	client    *http.Client
+
+	mu      sync.Mutex
+	batch   [][2]string
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// openLokiSink pushes to the Loki server at `url`, with the stream labels `labels`. Lines are
+// pushed every `batch_size` lines (100 by default) and every `batch_interval` (1s by default).
+func openLokiSink(settings Settings) (io.Writer, error) {
+	url := settings.String("url")
+	if url == "" {
+		return nil, fmt.Errorf("loki sinks require a url")
+	}
+	interval := time.Second
+	if s := settings.String("batch_interval"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch_interval: %s", err.Error())
+		}
+		interval = d
+	}
+	w := &lokiWriter{
+		url:       strings.TrimRight(url, "/") + "/loki/api/v1/push",
+		labels:    settings.StringMap("labels"),
+		batchSize: settings.Int("batch_size", 100),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go w.run(interval)
+	return w, nil
+}
+
+// Write implements io.Writer.
+func (w *lokiWriter) Write(p []byte) (int, error) {
+	line := string(bytes.TrimRight(p, "\n"))
+	ts := strconv.FormatInt(time.Now().UnixNano(), 10)
+	w.mu.Lock()
+	w.batch = append(w.batch, [2]string{ts, line})
+	full := len(w.batch) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush pushes the batched lines.
+func (w *lokiWriter) flush() error {
+	w.mu.Lock()
+	batch := w.batch
+	w.batch = nil
+	w.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"streams": []map[string]interface{}{{"stream": w.labels, "values": batch}},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("loki push failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *lokiWriter) run(interval time.Duration) {
+	defer close(w.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Close pushes the batched lines and stops the periodic pushes.
+func (w *lokiWriter) Close() error {
+	close(w.stop)
+	<-w.stopped
+	return w.flush()
+}
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// LevelPattern assigns Level to plain-text lines matching Pattern.
+type LevelPattern struct {
+	Pattern *regexp.Regexp
+	Level   LogLevel
+}
+
+// DefaultLevelPatterns are the level inference rules used by NewPlainTextWriter. They are
+// checked in order, so more severe levels win when a line matches several patterns.
+var DefaultLevelPatterns = []LevelPattern{
+	{Pattern: regexp.MustCompile(`(?i)\b(CRITICAL|FATAL|PANIC)\b`), Level: Critical},
+	{Pattern: regexp.MustCompile(`(?i)\bERR(OR)?\b`), Level: Error},
+	{Pattern: regexp.MustCompile(`(?i)\bWARN(ING)?\b`), Level: Warning},
+	{Pattern: regexp.MustCompile(`(?i)\bDEBUG\b`), Level: Debug},
+	{Pattern: regexp.MustCompile(`(?i)\bTRACE\b`), Level: Trace},
+}
+
+// PlainTextWriter is an io.Writer that converts plain-text lines, e.g. from legacy code using
+// the standard library's log package, into kayvee log entries. Each line is logged through
+// Logger (and so through routing) with the text under the "msg" field and a level inferred
+// from LevelPatterns:
+//
+//	log.SetOutput(logger.NewPlainTextWriter("legacy-worker"))
+type PlainTextWriter struct {
+	// Logger receives the converted entries.
+	Logger KayveeLogger
+	// Title is the title of every converted entry.
+	Title string
+	// DefaultLevel is used for lines that don't match any of LevelPatterns.
+	DefaultLevel LogLevel
+	// LevelPatterns are checked in order; the first match determines the level.
+	LevelPatterns []LevelPattern
+	// Fields are added to every converted entry.
+	Fields M
+
+	lines lineSplitter
+}
+
+// NewPlainTextWriter returns a PlainTextWriter logging through a new logger with the given
+// source, using DefaultLevelPatterns and Info as the default level.
+func NewPlainTextWriter(source string) *PlainTextWriter {
+	return &PlainTextWriter{
+		Logger:        New(source),
+		Title:         "plaintext-log",
+		DefaultLevel:  Info,
+		LevelPatterns: DefaultLevelPatterns,
+	}
+}
+
+// Write logs every complete line in `p`. Incomplete trailing lines are buffered until the
+// rest of the line is written or Flush is called.
+func (w *PlainTextWriter) Write(p []byte) (int, error) {
+	for _, line := range w.lines.split(p) {
+		w.logLine(line, nil)
+	}
+	return len(p), nil
+}
+
+// Flush logs any buffered incomplete line.
+func (w *PlainTextWriter) Flush() {
+	w.logLine(w.lines.rest(), nil)
+}
+
+// logLine logs `line` with the writer's Fields and `extra`.
+func (w *PlainTextWriter) logLine(line string, extra M) {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	data := M{}
+	for k, v := range w.Fields {
+		data[k] = v
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	data["msg"] = line
+	logAtLevel(w.Logger, w.levelFor(line), w.Title, data)
+}
+
+func (w *PlainTextWriter) levelFor(line string) LogLevel {
+	for _, p := range w.LevelPatterns {
+		if p.Pattern.MatchString(line) {
+			return p.Level
+		}
+	}
+	return w.DefaultLevel
+}
+
+// lineSplitter buffers written bytes and splits them into complete lines.
+type lineSplitter struct {
+	mu      sync.Mutex
+	partial []byte
+}
+
+// split appends `p` to the buffer and returns the complete lines it now contains.
+func (s *lineSplitter) split(p []byte) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partial = append(s.partial, p...)
+	var lines []string
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, string(s.partial[:i]))
+		s.partial = s.partial[i+1:]
+	}
+	return lines
+}
+
+// rest returns and clears the buffered incomplete line.
+func (s *lineSplitter) rest() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line := string(s.partial)
+	s.partial = nil
+	return line
+}
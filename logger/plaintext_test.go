@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m), line)
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestPlainTextWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewPlainTextWriter("legacy")
+	w.Logger.SetOutput(buf)
+	w.Fields = M{"origin": "stdlib"}
+
+	stdlog := log.New(w, "", 0)
+	stdlog.Println("starting up")
+	stdlog.Println("WARN: disk almost full")
+	stdlog.Printf("error: could not connect")
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 3)
+	assert.Equal(t, "info", lines[0]["level"])
+	assert.Equal(t, "starting up", lines[0]["msg"])
+	assert.Equal(t, "plaintext-log", lines[0]["title"])
+	assert.Equal(t, "legacy", lines[0]["source"])
+	assert.Equal(t, "stdlib", lines[0]["origin"])
+	assert.Equal(t, "warning", lines[1]["level"])
+	assert.Equal(t, "error", lines[2]["level"])
+}
+
+func TestPlainTextWriterPartialLines(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewPlainTextWriter("legacy")
+	w.Logger.SetOutput(buf)
+	w.LevelPatterns = []LevelPattern{{Pattern: regexp.MustCompile(`^!!`), Level: Critical}}
+
+	w.Write([]byte("first ha"))
+	assert.Equal(t, 0, buf.Len())
+	w.Write([]byte("lf\n\n!! second"))
+	w.Flush()
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "first half", lines[0]["msg"])
+	assert.Equal(t, "info", lines[0]["level"])
+	assert.Equal(t, "!! second", lines[1]["msg"])
+	assert.Equal(t, "critical", lines[1]["level"])
+}
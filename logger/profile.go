@@ -0,0 +1,38 @@
+package logger
+
+import "sort"
+
+// mapFields copies `data` into `out`, renaming keys found in `fields`. Errors are replaced
+// by their message so that they don't marshal to {}. Renamed keys override the others, and
+// when several are renamed to the same key, the last one in sorted order wins, so that the
+// output doesn't depend on map iteration order.
+func mapFields(out, data map[string]interface{}, fields map[string]string) {
+	var renamed []string
+	for k, v := range data {
+		if _, ok := fields[k]; ok {
+			renamed = append(renamed, k)
+			continue
+		}
+		out[k] = fieldValue(v)
+	}
+	sort.Strings(renamed)
+	for _, k := range renamed {
+		out[fields[k]] = fieldValue(data[k])
+	}
+}
+
+// fieldValue returns `v`, or its message if it's an error.
+func fieldValue(v interface{}) interface{} {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return v
+}
+
+// formatJSON marshals an entry like kv.Format does, without adding kayvee's environment
+// fields: output profiles have already mapped those to their own keys. It returns "" if the
+// entry must be dropped according to the MarshalFailurePolicy.
+func formatJSON(data map[string]interface{}) string {
+	bs, _ := marshalEntry(data)
+	return string(bs)
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultProgressInterval is the minimum time between the entries of a ProgressUpdater.
+const DefaultProgressInterval = 10 * time.Second
+
+// ProgressUpdater logs the progress of a long operation, e.g. a migration or a backfill,
+// without logging every item. See Progress.
+type ProgressUpdater struct {
+	l        KayveeLogger
+	name     string
+	total    int64
+	interval time.Duration
+
+	mu       sync.Mutex
+	done     int64
+	start    time.Time
+	last     time.Time
+	finished bool
+}
+
+// Progress returns a ProgressUpdater for the operation `name` of `total` items, logging with
+// the logger of `ctx`. A total of 0 or less means it's unknown, and entries don't have a
+// percentage or an ETA:
+//
+//	p := logger.Progress(ctx, "backfill-sections", count)
+//	for rows.Next() {
+//		...
+//		p.Update(1)
+//	}
+//	p.Finish()
+func Progress(ctx context.Context, name string, total int64) *ProgressUpdater {
+	now := clock()
+	return &ProgressUpdater{
+		l:        FromContext(ctx),
+		name:     name,
+		total:    total,
+		interval: DefaultProgressInterval,
+		start:    now,
+		last:     now,
+	}
+}
+
+// SetInterval sets the minimum time between entries, DefaultProgressInterval by default.
+func (p *ProgressUpdater) SetInterval(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval = d
+}
+
+// Update adds `n` items to the items done, and logs a "progress" entry if the interval has
+// elapsed since the last one, or if every item is done. Entries have the name of the
+// operation, the items done and total, the percent done, the rate in items per second, the
+// elapsed seconds and the estimated seconds left in eta_s.
+func (p *ProgressUpdater) Update(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	now := clock()
+	complete := p.total > 0 && p.done >= p.total
+	if p.finished || (now.Sub(p.last) < p.interval && !complete) {
+		return
+	}
+	p.last = now
+	p.log("progress", now)
+	if complete {
+		p.finished = true
+	}
+}
+
+// Finish logs a "progress-finished" entry with the items done, unless every item is already
+// reported done.
+func (p *ProgressUpdater) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	p.log("progress-finished", clock())
+}
+
+func (p *ProgressUpdater) log(title string, now time.Time) {
+	elapsed := now.Sub(p.start).Seconds()
+	data := M{"name": p.name, "done": p.done, "elapsed_s": elapsed}
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed
+		data["rate"] = rate
+	}
+	if p.total > 0 {
+		data["total"] = p.total
+		data["percent"] = 100 * float64(p.done) / float64(p.total)
+		if left := p.total - p.done; left <= 0 {
+			data["eta_s"] = 0.0
+		} else if rate > 0 {
+			data["eta_s"] = float64(left) / rate
+		}
+	}
+	p.l.InfoD(title, data)
+}
@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestProgress(t *testing.T) {
+	now := time.Unix(0, 0)
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	p := Progress(NewContext(context.Background(), l), "backfill", 100)
+
+	for i := 0; i < 100; i++ {
+		now = now.Add(time.Second)
+		p.Update(1)
+	}
+	p.Finish()
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 10, "one entry every 10s, the last one when every item is done")
+	assert.Equal(t, "progress", lines[0]["title"])
+	assert.Equal(t, "backfill", lines[0]["name"])
+	assert.Equal(t, 10.0, lines[0]["done"])
+	assert.Equal(t, 100.0, lines[0]["total"])
+	assert.Equal(t, 10.0, lines[0]["percent"])
+	assert.Equal(t, 1.0, lines[0]["rate"])
+	assert.Equal(t, 90.0, lines[0]["eta_s"])
+	assert.Equal(t, 10.0, lines[0]["elapsed_s"])
+	assert.Equal(t, 100.0, lines[9]["percent"])
+	assert.Equal(t, 0.0, lines[9]["eta_s"])
+}
+
+func TestProgressUnknownTotal(t *testing.T) {
+	now := time.Unix(0, 0)
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	p := Progress(NewContext(context.Background(), l), "migration", 0)
+	p.SetInterval(time.Minute)
+
+	now = now.Add(30 * time.Second)
+	p.Update(50)
+	now = now.Add(30 * time.Second)
+	p.Update(70)
+	now = now.Add(5 * time.Second)
+	p.Update(10)
+	p.Finish()
+	p.Finish()
+
+	lines := decodeLines(t, buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "progress", lines[0]["title"])
+	assert.Equal(t, 120.0, lines[0]["done"])
+	assert.Equal(t, 2.0, lines[0]["rate"])
+	assert.Nil(t, lines[0]["percent"])
+	assert.Nil(t, lines[0]["eta_s"])
+	assert.Equal(t, "progress-finished", lines[1]["title"])
+	assert.Equal(t, 130.0, lines[1]["done"])
+}
@@ -0,0 +1,269 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Protobuf wire types, see https://protobuf.dev/programming-guides/encoding.
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+// maxProtobufEntrySize bounds the length prefix ReadProtobufEntry accepts.
+const maxProtobufEntrySize = 64 << 20
+
+var errMalformedProtobuf = errors.New("malformed protobuf entry")
+
+// NewProtobufLogger creates a logger like New does, but writes entries to `output` as
+// length-prefixed protobuf messages instead of JSON lines, which is cheaper to produce and
+// smaller on the wire for high-volume transport between services and collectors. The
+// message schema is kayvee.proto. Formatters set on the logger are ignored.
+func NewProtobufLogger(source string, output io.Writer) KayveeLogger {
+	l := New(source)
+	l.setFormatLogger(&protobufFormatLogger{output: output})
+	return l
+}
+
+// protobufFormatLogger implements the formatLogger interface by writing entries as
+// length-prefixed protobuf messages.
+type protobufFormatLogger struct {
+	mu     sync.Mutex
+	output io.Writer
+	buf    []byte
+}
+
+// formatAndLog implements the formatLogger interface for *protobufFormatLogger.
+func (fl *protobufFormatLogger) formatAndLog(data map[string]interface{}) {
+	msg := marshalProtobufEntry(data, clock())
+	if msg == nil {
+		// dropped because of the MarshalFailurePolicy
+		return
+	}
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.buf = binary.AppendUvarint(fl.buf[:0], uint64(len(msg)))
+	fl.buf = append(fl.buf, msg...)
+	fl.output.Write(fl.buf)
+}
+
+// setFormatter implements the formatLogger interface for *protobufFormatLogger. Entries are
+// always protobuf encoded, so the formatter is ignored.
+func (fl *protobufFormatLogger) setFormatter(formatter Formatter) {}
+
+// setOutput implements the formatLogger interface for *protobufFormatLogger.
+func (fl *protobufFormatLogger) setOutput(output io.Writer) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.output = output
+}
+
+// marshalProtobufEntry encodes `data` as a kayvee.Entry message. It returns nil if the entry
+// must be dropped according to the MarshalFailurePolicy.
+func marshalProtobufEntry(data map[string]interface{}, now time.Time) []byte {
+	b := []byte{}
+	for k, v := range data {
+		switch k {
+		case "title", "source":
+			field := uint64(1)
+			if k == "source" {
+				field = 3
+			}
+			if s := fmt.Sprint(v); s != "" {
+				b = pbAppendString(b, field, s)
+			}
+		case "level":
+			name, _ := v.(string)
+			if lvl := levelFromName(name, Info); lvl != Trace {
+				b = pbAppendVarint(b, 2, uint64(lvl))
+			}
+		default:
+			value, err := marshalProtobufValue(v)
+			if err != nil {
+				policy, placeholder := marshalFailed(v, err)
+				switch policy {
+				case MarshalDropEntry:
+					return nil
+				case MarshalDropField:
+					continue
+				}
+				value = pbAppendString(nil, 1, placeholder)
+			}
+			entry := pbAppendString(nil, 1, k)
+			entry = pbAppendBytes(entry, 2, value)
+			b = pbAppendBytes(b, 5, entry)
+		}
+	}
+	return pbAppendVarint(b, 4, uint64(now.UnixNano()))
+}
+
+// marshalProtobufValue encodes `v` as a kayvee.Value message.
+func marshalProtobufValue(v interface{}) ([]byte, error) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return pbAppendString(nil, 1, rv.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return pbAppendVarint(nil, 2, uint64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() <= math.MaxInt64 {
+			return pbAppendVarint(nil, 2, rv.Uint()), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		b := binary.AppendUvarint(nil, 3<<3|pbFixed64)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(rv.Float())), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return pbAppendVarint(nil, 4, 1), nil
+		}
+		return pbAppendVarint(nil, 4, 0), nil
+	}
+	js, err := marshalJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	return pbAppendString(nil, 5, string(js)), nil
+}
+
+// ReadProtobufEntry reads one entry written by a logger created with NewProtobufLogger. It
+// returns the entry's fields, including title, level and source, and the time it was
+// logged. It returns io.EOF when there are no more entries.
+func ReadProtobufEntry(r *bufio.Reader) (M, time.Time, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if size > maxProtobufEntrySize {
+		return nil, time.Time{}, errMalformedProtobuf
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, time.Time{}, io.ErrUnexpectedEOF
+	}
+
+	entry := M{"level": Trace.String()}
+	var t time.Time
+	err = pbRange(msg, func(field uint64, varint uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			entry["title"] = string(bytes)
+		case 2:
+			entry["level"] = LogLevel(varint).String()
+		case 3:
+			entry["source"] = string(bytes)
+		case 4:
+			t = time.Unix(0, int64(varint))
+		case 5:
+			var key string
+			var value interface{}
+			if err := pbRange(bytes, func(field uint64, _ uint64, bytes []byte) error {
+				var err error
+				switch field {
+				case 1:
+					key = string(bytes)
+				case 2:
+					value, err = unmarshalProtobufValue(bytes)
+				}
+				return err
+			}); err != nil {
+				return err
+			}
+			entry[key] = value
+		}
+		return nil
+	})
+	return entry, t, err
+}
+
+func unmarshalProtobufValue(msg []byte) (interface{}, error) {
+	var value interface{}
+	err := pbRange(msg, func(field uint64, varint uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			value = string(bytes)
+		case 2:
+			value = int64(varint)
+		case 3:
+			value = math.Float64frombits(varint)
+		case 4:
+			value = varint != 0
+		case 5:
+			return json.Unmarshal(bytes, &value)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// pbRange calls `f` for each field of a protobuf message. Varint and fixed fields are passed
+// in `varint`, length-delimited fields in `bytes`.
+func pbRange(msg []byte, f func(field uint64, varint uint64, bytes []byte) error) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errMalformedProtobuf
+		}
+		msg = msg[n:]
+		var varint uint64
+		var bytes []byte
+		switch tag & 7 {
+		case pbVarint:
+			varint, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return errMalformedProtobuf
+			}
+			msg = msg[n:]
+		case pbFixed64:
+			if len(msg) < 8 {
+				return errMalformedProtobuf
+			}
+			varint, msg = binary.LittleEndian.Uint64(msg), msg[8:]
+		case pbFixed32:
+			if len(msg) < 4 {
+				return errMalformedProtobuf
+			}
+			varint, msg = uint64(binary.LittleEndian.Uint32(msg)), msg[4:]
+		case pbBytes:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				return errMalformedProtobuf
+			}
+			bytes, msg = msg[n:n+int(size)], msg[n+int(size):]
+		default:
+			return errMalformedProtobuf
+		}
+		if err := f(tag>>3, varint, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pbAppendVarint(b []byte, field, v uint64) []byte {
+	b = binary.AppendUvarint(b, field<<3|pbVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func pbAppendBytes(b []byte, field uint64, v []byte) []byte {
+	b = binary.AppendUvarint(b, field<<3|pbBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbAppendString(b []byte, field uint64, v string) []byte {
+	return pbAppendBytes(b, field, []byte(v))
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtobufLoggerRoundTrip(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 123, time.UTC)
+	SetClock(func() time.Time { return now })
+	defer SetClock(nil)
+
+	buf := &bytes.Buffer{}
+	l := NewProtobufLogger("my-app", buf)
+	l.AddContext("team", "eng")
+	l.ErrorD("request-failed", M{
+		"status-code": 500,
+		"latency":     1.5,
+		"canary":      false,
+		"err":         errors.New("boom"),
+		"tags":        []string{"a", "b"},
+	})
+	l.Trace("tracing")
+
+	r := bufio.NewReader(buf)
+	entry, logged, err := ReadProtobufEntry(r)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(logged))
+	assert.Equal(t, "request-failed", entry["title"])
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "my-app", entry["source"])
+	assert.Equal(t, "eng", entry["team"])
+	assert.Equal(t, int64(500), entry["status-code"])
+	assert.Equal(t, 1.5, entry["latency"])
+	assert.Equal(t, false, entry["canary"])
+	assert.Equal(t, "boom", entry["err"])
+	assert.Equal(t, []interface{}{"a", "b"}, entry["tags"])
+
+	entry, _, err = ReadProtobufEntry(r)
+	require.NoError(t, err)
+	assert.Equal(t, "tracing", entry["title"])
+	assert.Equal(t, "trace", entry["level"])
+
+	_, _, err = ReadProtobufEntry(r)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMarshalProtobufValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{"hi", []byte{0x0a, 0x02, 'h', 'i'}},
+		{150, []byte{0x10, 0x96, 0x01}},
+		{int64(-1), []byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{1.0, []byte{0x19, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}},
+		{true, []byte{0x20, 0x01}},
+		{nil, []byte{0x2a, 0x04, 'n', 'u', 'l', 'l'}},
+	}
+	for _, tt := range tests {
+		value, err := marshalProtobufValue(tt.value)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, value, "%#v", tt.value)
+	}
+}
+
+func TestReadProtobufEntryMalformed(t *testing.T) {
+	_, _, err := ReadProtobufEntry(bufio.NewReader(bytes.NewReader([]byte{0x02, 0x0a, 0x05})))
+	assert.Equal(t, errMalformedProtobuf, err)
+	_, _, err = ReadProtobufEntry(bufio.NewReader(bytes.NewReader([]byte{0x05, 0x0a})))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// recentEntries is the buffer enabled with KeepRecentEntries, or nil.
+var recentEntries atomic.Pointer[recentBuffer]
+
+// recentBuffer is a ring buffer of the last entries logged.
+type recentBuffer struct {
+	includeSuppressed bool
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// KeepRecentEntries keeps the last `n` entries logged by any logger in memory, so that crash
+// reports can include them with DumpRecent. If `includeSuppressed` is true, entries below the
+// level of their logger are kept too, so that the dump has debug context even when loggers
+// are set to a higher level. Keeping suppressed entries has a cost, since they are no longer
+// discarded before being built. `n` <= 0 stops keeping entries.
+func KeepRecentEntries(n int, includeSuppressed bool) {
+	if n <= 0 {
+		recentEntries.Store(nil)
+		return
+	}
+	recentEntries.Store(&recentBuffer{
+		includeSuppressed: includeSuppressed,
+		entries:           make([]Entry, n),
+	})
+}
+
+// keepsSuppressedEntries reports whether entries below the level of their logger must still
+// be built, for KeepRecentEntries.
+func keepsSuppressedEntries() bool {
+	b := recentEntries.Load()
+	return b != nil && b.includeSuppressed
+}
+
+func (b *recentBuffer) add(e Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// snapshot returns the kept entries, oldest first.
+func (b *recentBuffer) snapshot() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]Entry(nil), b.entries[:b.next]...)
+	}
+	return append(append([]Entry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+}
+
+// RecentEntries returns the entries kept since KeepRecentEntries was called, oldest first.
+func RecentEntries() []Entry {
+	b := recentEntries.Load()
+	if b == nil {
+		return nil
+	}
+	return b.snapshot()
+}
+
+// DumpRecent writes the entries kept since KeepRecentEntries was called to `w` as JSON lines,
+// oldest first. Each line has the time the entry was logged.
+func DumpRecent(w io.Writer) error {
+	for _, e := range RecentEntries() {
+		data := make(map[string]interface{}, len(e.Fields)+4)
+		for k, v := range e.Fields {
+			if k != "_kvmeta" {
+				data[k] = v
+			}
+		}
+		data["title"] = e.Title
+		data["level"] = e.Level.String()
+		data["source"] = e.Source
+		data["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+		if _, err := io.WriteString(w, formatJSON(data)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DumpRecentOnPanic writes the kept entries to `w` if the goroutine is panicking, then resumes
+// panicking. It must be deferred directly, usually at the top of main or of a goroutine:
+//
+//	defer logger.DumpRecentOnPanic(os.Stderr)
+func DumpRecentOnPanic(w io.Writer) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(w, "panic: %v\nrecent log entries:\n", r)
+	DumpRecent(w)
+	panic(r)
+}
@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestKeepRecentEntries(t *testing.T) {
+	SetClock(func() time.Time { return time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC) })
+	defer SetClock(nil)
+	defer KeepRecentEntries(0, false)
+
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, &bytes.Buffer{})
+
+	KeepRecentEntries(3, false)
+	l.Debug("suppressed")
+	for _, title := range []string{"a", "b", "c", "d"} {
+		l.InfoD(title, M{"n": title})
+	}
+	titles := []string{}
+	for _, e := range RecentEntries() {
+		titles = append(titles, e.Title)
+	}
+	assert.Equal(t, []string{"b", "c", "d"}, titles)
+
+	KeepRecentEntries(3, true)
+	l.Debug("suppressed")
+	l.Log(Trace, "typed", KV("k", 1))
+	l.Warn("shown")
+	entries := RecentEntries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, Debug, entries[0].Level)
+	assert.Equal(t, "my-app", entries[0].Source)
+	assert.Equal(t, "typed", entries[1].Title)
+	assert.Equal(t, int64(1), entries[1].Fields["k"])
+	assert.Equal(t, Warning, entries[2].Level)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, DumpRecent(out))
+	lines := decodeLines(t, out)
+	require.Len(t, lines, 3)
+	assert.Equal(t, "suppressed", lines[0]["title"])
+	assert.Equal(t, "debug", lines[0]["level"])
+	assert.Equal(t, "2024-01-01T10:00:00Z", lines[0]["time"])
+
+	KeepRecentEntries(0, true)
+	l.Info("not kept")
+	assert.Empty(t, RecentEntries())
+}
+
+func TestDumpRecentOnPanic(t *testing.T) {
+	defer KeepRecentEntries(0, false)
+	KeepRecentEntries(10, true)
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, &bytes.Buffer{})
+	l.Debug("before-crash")
+
+	out := &bytes.Buffer{}
+	assert.PanicsWithValue(t, "boom", func() {
+		defer DumpRecentOnPanic(out)
+		panic("boom")
+	})
+	assert.Contains(t, out.String(), "panic: boom\n")
+	assert.Contains(t, out.String(), `"title":"before-crash"`)
+
+	out.Reset()
+	func() {
+		defer DumpRecentOnPanic(out)
+	}()
+	assert.Empty(t, out.String())
+}
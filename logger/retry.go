@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+)
+
+type retryKeyType struct{}
+
+var retryKey = retryKeyType{}
+
+// WithAttempt returns a context whose logger stamps the entries of one attempt of a retried
+// operation with `attempt` and `retry_of` fields. retry_of is an id shared by all the
+// attempts of the operation, generated on the first call and carried by the returned
+// context, so the context must be threaded through the retry loop:
+//
+//	for attempt := 1; attempt <= 3; attempt++ {
+//		ctx = logger.WithAttempt(ctx, attempt)
+//		if err = call(ctx); err == nil {
+//			break
+//		}
+//	}
+//	logger.FromContext(logger.FinalAttempt(ctx)).InfoD("call-finished", logger.M{"error": err})
+//
+// The logger is a copy of the one in `ctx`, or a new logger if there is none. Loggers other
+// than the default implementation are used as is, without the fields.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	id, _ := ctx.Value(retryKey).(string)
+	if id == "" {
+		b := make([]byte, 8)
+		io.ReadFull(entropy, b)
+		id = hex.EncodeToString(b)
+		ctx = context.WithValue(ctx, retryKey, id)
+	}
+	return withLoggerFields(ctx, M{"attempt": attempt, "retry_of": id})
+}
+
+// FinalAttempt returns a context whose logger also stamps entries with `final_attempt: true`,
+// to tell the outcome of a retried operation apart from the failures of intermediate
+// attempts.
+func FinalAttempt(ctx context.Context) context.Context {
+	return withLoggerFields(ctx, M{"final_attempt": true})
+}
+
+// withLoggerFields returns a context holding a copy of its logger with `fields` added.
+func withLoggerFields(ctx context.Context, fields M) context.Context {
+	l, ok := FromContext(ctx).(*Logger)
+	if !ok {
+		return ctx
+	}
+	return NewContext(ctx, l.withFields(fields))
+}
+
+// withFields returns a copy of `l` with `fields` added to its globals.
+func (l *Logger) withFields(fields M) *Logger {
+	l.globalsL.RLock()
+	defer l.globalsL.RUnlock()
+	globals := make(map[string]interface{}, len(l.globals)+len(fields))
+	for k, v := range l.globals {
+		globals[k] = v
+	}
+	for k, v := range fields {
+		globals[k] = v
+	}
+	return &Logger{
+		globals:   globals,
+		logLvl:    l.logLvl,
+		fLogger:   l.fLogger,
+		logRouter: l.logRouter,
+		sinks:     append([]Sink(nil), l.sinks...),
+	}
+}
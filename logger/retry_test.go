@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestWithAttempt(t *testing.T) {
+	SetEntropy(strings.NewReader("\x01\x02\x03\x04\x05\x06\x07\x08"))
+	defer SetEntropy(nil)
+
+	out := &bytes.Buffer{}
+	base := New("my-app")
+	base.SetConfig("my-app", Info, kv.Format, out)
+	ctx := NewContext(context.Background(), base)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		ctx = WithAttempt(ctx, attempt)
+		FromContext(ctx).Warn("call-failed")
+	}
+	FromContext(FinalAttempt(ctx)).Error("call-finished")
+	base.Info("unrelated")
+
+	lines := decodeLines(t, out)
+	require.Len(t, lines, 5)
+	for i, line := range lines[:4] {
+		assert.Equal(t, "0102030405060708", line["retry_of"])
+		assert.Equal(t, float64(min(i+1, 3)), line["attempt"])
+	}
+	assert.NotContains(t, lines[2], "final_attempt")
+	assert.Equal(t, true, lines[3]["final_attempt"])
+	assert.NotContains(t, lines[4], "attempt")
+	assert.NotContains(t, lines[4], "retry_of")
+
+	// operations retried separately get separate ids
+	other := WithAttempt(context.Background(), 1)
+	assert.NotEqual(t, "0102030405060708", other.Value(retryKey))
+}
@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// ringFile is a write-ahead ring buffer of lines kept in a memory-mapped file, so that lines
+// queued by an AsyncWriter survive the process crashing before they are written out.
+//
+// The file starts with a header holding a magic string and the logical head and tail offsets
+// of the ring, followed by the ring itself. Each record is a 4 byte length, a 1 byte state
+// and the line. Records may wrap around the end of the ring.
+type ringFile struct {
+	f    *os.File
+	mem  []byte
+	data []byte
+	head uint64
+	tail uint64
+}
+
+const (
+	ringMagic          = "KVR1"
+	ringHeaderSize     = 24
+	ringRecordOverhead = 5
+
+	ringStatePending byte = 1
+	ringStateDone    byte = 2
+)
+
+// openRingFile opens or creates the ring file at `path`. New files are `size` bytes long;
+// existing files keep their size so that their pending records can be recovered.
+func openRingFile(path string, size int) (*ringFile, error) {
+	if size <= ringHeaderSize+ringRecordOverhead {
+		return nil, fmt.Errorf("ring file size must be larger than %d bytes", ringHeaderSize+ringRecordOverhead)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	valid := fi.Size() > ringHeaderSize+ringRecordOverhead
+	if valid {
+		magic := make([]byte, len(ringMagic))
+		if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != ringMagic {
+			valid = false
+		}
+	}
+	if valid {
+		size = int(fi.Size())
+	} else if err := f.Truncate(int64(size)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	mem, err := mmapFile(f, size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := &ringFile{f: f, mem: mem, data: mem[ringHeaderSize:]}
+	if valid {
+		r.head = binary.LittleEndian.Uint64(mem[8:16])
+		r.tail = binary.LittleEndian.Uint64(mem[16:24])
+		if r.tail > r.head || r.head-r.tail > uint64(len(r.data)) {
+			valid = false
+		}
+	}
+	if !valid {
+		copy(mem, ringMagic)
+		r.head, r.tail = 0, 0
+		r.storeOffsets()
+	}
+	return r, nil
+}
+
+// pending returns the lines of records that were appended but never marked as done.
+func (r *ringFile) pending() [][]byte {
+	lines := [][]byte{}
+	for pos := r.tail; pos < r.head; {
+		n, state := r.recordAt(pos)
+		if pos+ringRecordOverhead+uint64(n) > r.head {
+			break // torn record
+		}
+		if state == ringStatePending {
+			lines = append(lines, r.read(pos+ringRecordOverhead, n))
+		}
+		pos += ringRecordOverhead + uint64(n)
+	}
+	return lines
+}
+
+// reset discards all records.
+func (r *ringFile) reset() {
+	r.tail = r.head
+	r.storeOffsets()
+}
+
+// append adds a pending record for `line` and returns its position, or false if the ring
+// doesn't have enough free space for it.
+func (r *ringFile) append(line []byte) (uint64, bool) {
+	need := uint64(ringRecordOverhead + len(line))
+	if uint64(len(r.data))-(r.head-r.tail) < need {
+		return 0, false
+	}
+	pos := r.head
+	hdr := make([]byte, ringRecordOverhead)
+	binary.LittleEndian.PutUint32(hdr, uint32(len(line)))
+	hdr[4] = ringStatePending
+	r.write(pos, hdr)
+	r.write(pos+ringRecordOverhead, line)
+	r.head += need
+	r.storeOffsets()
+	return pos, true
+}
+
+// markDone marks the record at `pos` as done, and frees the space of done records at the
+// tail of the ring.
+func (r *ringFile) markDone(pos uint64) {
+	r.write(pos+4, []byte{ringStateDone})
+	for r.tail < r.head {
+		n, state := r.recordAt(r.tail)
+		if state != ringStateDone {
+			break
+		}
+		r.tail += ringRecordOverhead + uint64(n)
+	}
+	r.storeOffsets()
+}
+
+// close unmaps and closes the file, leaving pending records in place.
+func (r *ringFile) close() error {
+	err := munmapFile(r.mem)
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r *ringFile) recordAt(pos uint64) (uint32, byte) {
+	hdr := r.read(pos, ringRecordOverhead)
+	return binary.LittleEndian.Uint32(hdr), hdr[4]
+}
+
+func (r *ringFile) storeOffsets() {
+	binary.LittleEndian.PutUint64(r.mem[8:16], r.head)
+	binary.LittleEndian.PutUint64(r.mem[16:24], r.tail)
+}
+
+func (r *ringFile) write(pos uint64, b []byte) {
+	for len(b) > 0 {
+		i := pos % uint64(len(r.data))
+		n := copy(r.data[i:], b)
+		b = b[n:]
+		pos += uint64(n)
+	}
+}
+
+func (r *ringFile) read(pos uint64, n uint32) []byte {
+	out := make([]byte, n)
+	for b := out; len(b) > 0; {
+		i := pos % uint64(len(r.data))
+		c := copy(b, r.data[i:])
+		b = b[c:]
+		pos += uint64(c)
+	}
+	return out
+}
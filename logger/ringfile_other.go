@@ -0,0 +1,18 @@
+//go:build !unix
+
+package logger
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("persistent async writers are not supported on this platform")
+
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmapFile(mem []byte) error {
+	return errMmapUnsupported
+}
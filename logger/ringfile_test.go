@@ -0,0 +1,81 @@
+//go:build unix
+
+package logger
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingFileRecoversPendingRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	r, err := openRingFile(path, 64)
+	require.NoError(t, err)
+
+	a, ok := r.append([]byte("a"))
+	require.True(t, ok)
+	_, ok = r.append([]byte("bb"))
+	require.True(t, ok)
+	_, ok = r.append([]byte("ccc"))
+	require.True(t, ok)
+	r.markDone(a)
+	require.NoError(t, r.close())
+
+	// the size of an existing file is kept
+	r, err = openRingFile(path, 1024)
+	require.NoError(t, err)
+	defer r.close()
+	assert.Len(t, r.data, 64-ringHeaderSize)
+	assert.Equal(t, [][]byte{[]byte("bb"), []byte("ccc")}, r.pending())
+	r.reset()
+	assert.Empty(t, r.pending())
+}
+
+func TestRingFileWrapsAround(t *testing.T) {
+	r, err := openRingFile(filepath.Join(t.TempDir(), "ring"), ringHeaderSize+20)
+	require.NoError(t, err)
+	defer r.close()
+
+	line := []byte("0123456")
+	for i := 0; i < 10; i++ {
+		pos, ok := r.append(line)
+		require.True(t, ok)
+		assert.Equal(t, [][]byte{line}, r.pending())
+		r.markDone(pos)
+	}
+
+	// a record that's not done yet holds on to its space
+	_, ok := r.append(line)
+	require.True(t, ok)
+	_, ok = r.append(bytes.Repeat([]byte("x"), 10))
+	assert.False(t, ok)
+}
+
+func TestPersistentAsyncWriterReplaysPendingLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	r, err := openRingFile(path, 1024)
+	require.NoError(t, err)
+	r.append(asyncLine("info", "left-over"))
+	require.NoError(t, r.close())
+
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	w, err := NewPersistentAsyncWriter(out, 10, path, 1024)
+	require.NoError(t, err)
+	w.Write(asyncLine("info", "new"))
+	require.NoError(t, w.Close())
+	assert.Equal(t, []string{
+		`{"level":"info","title":"left-over"}`,
+		`{"level":"info","title":"new"}`,
+	}, out.lines())
+
+	// everything was written, so nothing is replayed the next time
+	r, err = openRingFile(path, 1024)
+	require.NoError(t, err)
+	defer r.close()
+	assert.Empty(t, r.pending())
+}
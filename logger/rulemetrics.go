@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ruleKey identifies a routing rule in the match counts.
+type ruleKey struct {
+	rule, outputType string
+}
+
+// ruleMatches holds a *uint64 per ruleKey, counting the entries every logger routed with it.
+var ruleMatches sync.Map
+
+// countRuleMatches counts the rules of the routing metadata `kvmeta`.
+func countRuleMatches(kvmeta map[string]interface{}) {
+	routes, _ := kvmeta["routes"].([]map[string]interface{})
+	for _, route := range routes {
+		rule, _ := route["rule"].(string)
+		outputType, _ := route["type"].(string)
+		key := ruleKey{rule, outputType}
+		counter, ok := ruleMatches.Load(key)
+		if !ok {
+			counter, _ = ruleMatches.LoadOrStore(key, new(uint64))
+		}
+		atomic.AddUint64(counter.(*uint64), 1)
+	}
+}
+
+// RuleMatchCounts returns the number of entries routed with each routing rule by every logger
+// of the process, keyed by rule name. Rules that never matched are absent.
+func RuleMatchCounts() map[string]uint64 {
+	out := map[string]uint64{}
+	ruleMatches.Range(func(key, counter interface{}) bool {
+		out[key.(ruleKey).rule] += atomic.LoadUint64(counter.(*uint64))
+		return true
+	})
+	return out
+}
+
+// RuleMetricsHandler returns an http.Handler exposing the routing rule match counts in the
+// OpenMetrics text format, as the kayvee_rule_matches counter labeled with the rule name and
+// output type. Alerting can scrape it to check that the rules expected to fire during
+// synthetic tests actually matched in each service.
+func RuleMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		w.Write([]byte(formatRuleMetrics()))
+	})
+}
+
+// formatRuleMetrics returns the rule match counts in the OpenMetrics text format, sorted by
+// rule name so that scrapes are stable.
+func formatRuleMetrics() string {
+	type sample struct {
+		key   ruleKey
+		count uint64
+	}
+	samples := []sample{}
+	ruleMatches.Range(func(key, counter interface{}) bool {
+		samples = append(samples, sample{key.(ruleKey), atomic.LoadUint64(counter.(*uint64))})
+		return true
+	})
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].key.rule != samples[j].key.rule {
+			return samples[i].key.rule < samples[j].key.rule
+		}
+		return samples[i].key.outputType < samples[j].key.outputType
+	})
+
+	b := &strings.Builder{}
+	b.WriteString("# TYPE kayvee_rule_matches counter\n")
+	b.WriteString("# HELP kayvee_rule_matches Log entries routed with each kayvee routing rule.\n")
+	for _, s := range samples {
+		fmt.Fprintf(b, "kayvee_rule_matches_total{rule=\"%s\",type=\"%s\"} %d\n",
+			escapeLabelValue(s.key.rule), escapeLabelValue(s.key.outputType), s.count)
+	}
+	b.WriteString("# EOF\n")
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes `s` for use as an OpenMetrics label value.
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/router"
+)
+
+func TestRuleMetrics(t *testing.T) {
+	r, err := router.NewFromRoutes(map[string]router.Rule{
+		"metrics-test-errors": {
+			Matchers: router.RuleMatchers{"title": []string{"failed"}},
+			Output:   router.RuleOutput{"type": "alerts", "series": "errors"},
+		},
+		`metrics-test-"quoted"`: {
+			Matchers: router.RuleMatchers{"title": []string{"quoted"}},
+			Output:   router.RuleOutput{"type": "analytics"},
+		},
+	})
+	require.NoError(t, err)
+	l := New("my-app")
+	l.SetOutput(&bytes.Buffer{})
+	l.SetLogLevel(Info)
+	l.SetRouter(r)
+
+	l.Error("failed")
+	l.Error("failed")
+	l.Info("quoted")
+	l.Info("unrouted")
+	// entries below the log level aren't routed, so they don't count
+	l.Debug("failed")
+
+	counts := RuleMatchCounts()
+	assert.Equal(t, uint64(2), counts["metrics-test-errors"])
+	assert.Equal(t, uint64(1), counts[`metrics-test-"quoted"`])
+
+	rec := httptest.NewRecorder()
+	RuleMetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "application/openmetrics-text; version=1.0.0; charset=utf-8", rec.Header().Get("Content-Type"))
+	body, _ := ioutil.ReadAll(rec.Body)
+	assert.Contains(t, string(body), "# TYPE kayvee_rule_matches counter\n")
+	assert.Contains(t, string(body), `kayvee_rule_matches_total{rule="metrics-test-errors",type="alerts"} 2`+"\n")
+	assert.Contains(t, string(body), `kayvee_rule_matches_total{rule="metrics-test-\"quoted\"",type="analytics"} 1`+"\n")
+	assert.Regexp(t, "# EOF\n$", string(body))
+}
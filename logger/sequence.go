@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+)
+
+// WithSequence returns a Formatter that stamps entries with sequence numbers and a checksum,
+// so that downstream pipelines can detect and quantify log loss and reordering:
+//
+//   - _seq_stream is random and identifies the formatter, i.e. the logger it's set on, since
+//     sequence numbers restart from 1 when the process does.
+//   - _seq increases by 1 for every entry formatted.
+//   - _crc is the CRC-32 (IEEE) of the line before `,"_crc":<crc>` was appended to it. It's
+//     only added when `formatter` produces JSON objects.
+//
+// Set it per logger with SetFormatter, e.g. l.SetFormatter(logger.WithSequence(kv.Format)).
+func WithSequence(formatter Formatter) Formatter {
+	var seq uint64
+	id := make([]byte, 8)
+	io.ReadFull(entropy, id)
+	stream := hex.EncodeToString(id)
+	return func(data map[string]interface{}) string {
+		data["_seq_stream"] = stream
+		data["_seq"] = atomic.AddUint64(&seq, 1)
+		line := formatter(data)
+		if len(line) < 2 || line[0] != '{' || line[len(line)-1] != '}' {
+			return line
+		}
+		crc := crc32.ChecksumIEEE([]byte(line))
+		return line[:len(line)-1] + `,"_crc":` + strconv.FormatUint(uint64(crc), 10) + "}"
+	}
+}
+
+var crcSuffix = regexp.MustCompile(`,"_crc":(\d+)\}$`)
+
+// ErrNoChecksum is returned by VerifyChecksum for lines that weren't formatted by WithSequence.
+var ErrNoChecksum = errors.New("line has no _crc field")
+
+// VerifyChecksum checks the _crc field of a line formatted by WithSequence, returning false
+// if the line was corrupted or altered.
+func VerifyChecksum(line []byte) (bool, error) {
+	m := crcSuffix.FindSubmatchIndex(line)
+	if m == nil {
+		return false, ErrNoChecksum
+	}
+	expected, err := strconv.ParseUint(string(line[m[2]:m[3]]), 10, 32)
+	if err != nil {
+		return false, err
+	}
+	original := append(append([]byte{}, line[:m[0]]...), '}')
+	return crc32.ChecksumIEEE(original) == uint32(expected), nil
+}
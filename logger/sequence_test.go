@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestWithSequence(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, WithSequence(kv.Format), buf)
+	l.Info("first")
+	l.InfoD("second", M{"user": "u1"})
+
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, float64(1), entries[0]["_seq"])
+	assert.Equal(t, float64(2), entries[1]["_seq"])
+	assert.Len(t, entries[0]["_seq_stream"], 16)
+	assert.Equal(t, entries[0]["_seq_stream"], entries[1]["_seq_stream"])
+
+	other := WithSequence(kv.Format)(M{"title": "x"})
+	assert.NotContains(t, other, entries[0]["_seq_stream"].(string))
+	assert.Contains(t, other, `"_seq":1`)
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		ok, err := VerifyChecksum([]byte(line))
+		require.NoError(t, err)
+		assert.True(t, ok, line)
+
+		tampered := strings.Replace(line, "my-app", "my-app2", 1)
+		ok, err = VerifyChecksum([]byte(tampered))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	_, err := VerifyChecksum([]byte(`{"title":"x"}`))
+	assert.Equal(t, ErrNoChecksum, err)
+}
+
+func TestWithSequenceNonJSON(t *testing.T) {
+	devColors = false
+	defer func() { devColors = os.Getenv("NO_COLOR") == "" }()
+	line := WithSequence(DevFormatter)(M{"title": "x", "level": "info"})
+	assert.NotContains(t, line, "_crc")
+	assert.Contains(t, line, "_seq")
+}
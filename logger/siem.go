@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SIEMConfig configures the headers and field mapping of the CEF and LEEF formatters.
+type SIEMConfig struct {
+	// Vendor, Product and Version identify the device in the CEF or LEEF header.
+	Vendor  string
+	Product string
+	Version string
+	// Fields maps entry fields to extension keys. Fields missing from it keep their name,
+	// sanitized to be a valid key. Defaults to DefaultSIEMFields.
+	Fields map[string]string
+}
+
+// DefaultSIEMFields maps common kayvee fields, including the ones logged by the kayvee
+// middleware, to CEF extension keys, which LEEF also understands.
+var DefaultSIEMFields = map[string]string{
+	"source":        "dproc",
+	"ip":            "src",
+	"user":          "suser",
+	"user_id":       "suid",
+	"method":        "requestMethod",
+	"path":          "request",
+	"status-code":   "outcome",
+	"response-size": "out",
+	"message":       "msg",
+}
+
+// siemSeverities maps levels to CEF severities (0-10), also used for LEEF's sev attribute.
+var siemSeverities = map[string]int{
+	"trace":    0,
+	"debug":    1,
+	"info":     3,
+	"warning":  6,
+	"error":    8,
+	"critical": 10,
+}
+
+// siemSkippedKeys are rendered in the header, or are routing metadata.
+var siemSkippedKeys = map[string]bool{
+	"title":   true,
+	"level":   true,
+	"_kvmeta": true,
+}
+
+var siemInvalidKeyChars = regexp.MustCompile(`[^A-Za-z0-9_.]`)
+
+// NewCEFFormatter returns a Formatter emitting ArcSight Common Event Format lines. The title
+// is used as both the signature id and the name of the event, the level is mapped to a
+// severity, and the remaining fields become extensions.
+func NewCEFFormatter(c SIEMConfig) Formatter {
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	value := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	return func(data map[string]interface{}) string {
+		title := header.Replace(fmt.Sprint(data["title"]))
+		level, _ := data["level"].(string)
+		b := &strings.Builder{}
+		fmt.Fprintf(b, "CEF:0|%s|%s|%s|%s|%s|%d|", header.Replace(c.Vendor), header.Replace(c.Product),
+			header.Replace(c.Version), title, title, siemSeverities[level])
+		for i, kv := range siemExtensions(c, data) {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(kv[0] + "=" + value.Replace(kv[1]))
+		}
+		return b.String()
+	}
+}
+
+// NewLEEFFormatter returns a Formatter emitting IBM QRadar Log Event Extended Format 1.0
+// lines. The title is used as the event id, the level is mapped to the sev attribute, and
+// the remaining fields become tab separated attributes.
+func NewLEEFFormatter(c SIEMConfig) Formatter {
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	value := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	return func(data map[string]interface{}) string {
+		level, _ := data["level"].(string)
+		b := &strings.Builder{}
+		fmt.Fprintf(b, "LEEF:1.0|%s|%s|%s|%s|sev=%d", header.Replace(c.Vendor), header.Replace(c.Product),
+			header.Replace(c.Version), header.Replace(fmt.Sprint(data["title"])), siemSeverities[level])
+		for _, kv := range siemExtensions(c, data) {
+			b.WriteString("\t" + kv[0] + "=" + value.Replace(kv[1]))
+		}
+		return b.String()
+	}
+}
+
+// siemExtensions returns the key-value pairs of the extension of an entry, sorted by key.
+func siemExtensions(c SIEMConfig, data map[string]interface{}) [][2]string {
+	fields := c.Fields
+	if fields == nil {
+		fields = DefaultSIEMFields
+	}
+	exts := make([][2]string, 0, len(data))
+	for k, v := range data {
+		if siemSkippedKeys[k] || v == nil {
+			continue
+		}
+		key, ok := fields[k]
+		if !ok {
+			key = siemInvalidKeyChars.ReplaceAllString(k, "_")
+		}
+		exts = append(exts, [2]string{key, fmt.Sprint(v)})
+	}
+	sort.Slice(exts, func(i, j int) bool { return exts[i][0] < exts[j][0] })
+	return exts
+}
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCEFFormatter(t *testing.T) {
+	f := NewCEFFormatter(SIEMConfig{Vendor: "Clever", Product: "my|app", Version: "1.0"})
+	line := f(map[string]interface{}{
+		"title":    "login-failed",
+		"level":    "warning",
+		"source":   "auth",
+		"ip":       "10.0.0.1",
+		"reason":   "a=b\nc",
+		"pod-id":   "p1",
+		"_kvmeta":  map[string]interface{}{},
+		"optional": nil,
+	})
+	assert.Equal(t, `CEF:0|Clever|my\|app|1.0|login-failed|login-failed|6|`+
+		`dproc=auth pod_id=p1 reason=a\=b\nc src=10.0.0.1`, line)
+}
+
+func TestLEEFFormatter(t *testing.T) {
+	f := NewLEEFFormatter(SIEMConfig{
+		Vendor:  "Clever",
+		Product: "my-app",
+		Version: "1.0",
+		Fields:  map[string]string{"ip": "src", "user": "usrName"},
+	})
+	line := f(map[string]interface{}{
+		"title":  "login-failed",
+		"level":  "critical",
+		"ip":     "10.0.0.1",
+		"user":   "u1",
+		"reason": "a\tb",
+	})
+	assert.Equal(t, "LEEF:1.0|Clever|my-app|1.0|login-failed|sev=10\treason=a b\tsrc=10.0.0.1\tusrName=u1", line)
+}
@@ -0,0 +1,50 @@
+package logger
+
+import "time"
+
+// Entry is a log entry, as handed to Sinks.
+type Entry struct {
+	Level  LogLevel
+	Title  string
+	Source string
+	Time   time.Time
+	// Fields are all the other fields of the entry, including globals and _kvmeta.
+	Fields M
+}
+
+// Sink receives the entries of a logger directly, without going through a Formatter. It's
+// meant for in-process consumers like metrics aggregators. WriteEntry is called synchronously
+// by the goroutine logging, so it should be quick; wrap slow ones in an IsolatedSink. The entry
+// must not be modified.
+type Sink interface {
+	WriteEntry(e Entry)
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(e Entry)
+
+// WriteEntry implements the Sink interface.
+func (f SinkFunc) WriteEntry(e Entry) {
+	f(e)
+}
+
+// newEntry builds the Entry of a data map about to be formatted.
+func newEntry(logLvl LogLevel, data map[string]interface{}, now time.Time) Entry {
+	e := Entry{
+		Level:  logLvl,
+		Time:   now,
+		Fields: make(M, len(data)),
+	}
+	for k, v := range data {
+		switch k {
+		case "title":
+			e.Title, _ = v.(string)
+		case "source":
+			e.Source, _ = v.(string)
+		case "level":
+		default:
+			e.Fields[k] = v
+		}
+	}
+	return e
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestAddSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, buf)
+	l.AddContext("team", "eng")
+
+	entries := []Entry{}
+	l.AddSink(SinkFunc(func(e Entry) { entries = append(entries, e) }))
+	before := time.Now()
+	l.WarnD("disk-full", M{"free_bytes": 0})
+	l.Debug("not-logged")
+
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, Warning, e.Level)
+	assert.Equal(t, "disk-full", e.Title)
+	assert.Equal(t, "my-app", e.Source)
+	assert.False(t, e.Time.Before(before))
+	assert.Equal(t, 0, e.Fields["free_bytes"])
+	assert.Equal(t, "eng", e.Fields["team"])
+	assert.NotContains(t, e.Fields, "title")
+	assert.NotContains(t, e.Fields, "level")
+
+	// entries are still written to the output
+	assert.Len(t, decodeLines(t, buf), 1)
+}
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+)
+
+// TeeFilter decides whether a formatted line is written to a TeeWriter destination.
+type TeeFilter func(line []byte) bool
+
+// TeeDestination is a destination of a TeeWriter. A nil Filter accepts every line.
+type TeeDestination struct {
+	Writer io.Writer
+	Filter TeeFilter
+}
+
+// TeeWriter duplicates lines to several destinations, each with an optional filter. Unlike
+// io.MultiWriter, a failing destination doesn't prevent writing to the others. It's a
+// building block for outputs below the routing layer, e.g. sending everything to stderr and
+// only errors to a file.
+type TeeWriter struct {
+	destinations []TeeDestination
+}
+
+// NewTeeWriter returns a TeeWriter writing to `destinations`.
+func NewTeeWriter(destinations ...TeeDestination) *TeeWriter {
+	return &TeeWriter{destinations: destinations}
+}
+
+// Write implements io.Writer. It returns the first error returned by a destination, after
+// writing to all of them.
+func (t *TeeWriter) Write(p []byte) (int, error) {
+	var firstErr error
+	for _, d := range t.destinations {
+		if d.Filter != nil && !d.Filter(p) {
+			continue
+		}
+		n, err := d.Writer.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	return len(p), nil
+}
+
+// LevelAtLeast returns a TeeFilter accepting kayvee JSON lines logged at `lvl` or above.
+// Lines without a level are treated as Info.
+func LevelAtLeast(lvl LogLevel) TeeFilter {
+	return func(line []byte) bool {
+		return levelOfLine(line) >= lvl
+	}
+}
+
+// TitleMatches returns a TeeFilter accepting kayvee JSON lines whose title matches one of
+// `patterns`, which use path.Match syntax, e.g. "payment-*".
+func TitleMatches(patterns ...string) TeeFilter {
+	return func(line []byte) bool {
+		var entry struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return false
+		}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, entry.Title); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestTeeWriter(t *testing.T) {
+	all := &bytes.Buffer{}
+	errs := &bytes.Buffer{}
+	payments := &bytes.Buffer{}
+	failing := &flakyWriter{failing: true}
+	tee := NewTeeWriter(
+		TeeDestination{Writer: failing},
+		TeeDestination{Writer: all},
+		TeeDestination{Writer: errs, Filter: LevelAtLeast(Error)},
+		TeeDestination{Writer: payments, Filter: TitleMatches("payment-*", "refund")},
+	)
+
+	l := New("my-app")
+	l.SetConfig("my-app", Trace, kv.Format, tee)
+	l.Info("payment-started")
+	l.Error("payment-failed")
+	l.Warn("refund")
+	l.Critical("crashed")
+
+	titles := func(buf *bytes.Buffer) []string {
+		out := []string{}
+		for _, e := range decodeLines(t, buf) {
+			out = append(out, e["title"].(string))
+		}
+		return out
+	}
+	assert.Equal(t, []string{"payment-started", "payment-failed", "refund", "crashed"}, titles(all))
+	assert.Equal(t, []string{"payment-failed", "crashed"}, titles(errs))
+	assert.Equal(t, []string{"payment-started", "payment-failed", "refund"}, titles(payments))
+
+	_, err := tee.Write([]byte("x\n"))
+	assert.Equal(t, errors.New("unavailable"), err)
+}
@@ -0,0 +1,126 @@
+/*
+Package temporal adapts kayvee loggers to the log.Logger interface of the Temporal Go SDK
+(go.temporal.io/sdk/log), so that workflows, activities and workers log through the same
+pipeline as the rest of a service:
+
+	c, err := client.Dial(client.Options{Logger: temporal.New(logger.New("my-worker"))})
+
+The fields Temporal attaches to the entries of workflows and activities are renamed to kayvee
+field names, e.g. WorkflowID to workflow_id, RunID to run_id and ActivityType to
+activity_type. Workflow code can suppress its entries while the workflow is being replayed
+with WithReplayCheck:
+
+	lggr := temporal.New(kvlogger).WithReplayCheck(func() bool { return workflow.IsReplaying(ctx) })
+*/
+package temporal
+
+import (
+	"fmt"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// Fields maps the keys Temporal attaches to entries to kayvee field names. Other keys are kept.
+var Fields = map[string]string{
+	"Namespace":    "namespace",
+	"TaskQueue":    "task_queue",
+	"WorkerID":     "worker_id",
+	"WorkflowType": "workflow_type",
+	"WorkflowID":   "workflow_id",
+	"RunID":        "run_id",
+	"Attempt":      "attempt",
+	"ActivityType": "activity_type",
+	"ActivityID":   "activity_id",
+}
+
+// extraField holds the last value of keyvals with an odd length.
+const extraField = "extra"
+
+// Logger implements the log.Logger interface of the Temporal Go SDK with a kayvee logger.
+// Temporal's messages are the titles of the entries, and their keyvals are fields.
+type Logger struct {
+	kv        logger.KayveeLogger
+	fields    logger.M
+	replaying func() bool
+}
+
+// New returns a Logger logging with `kv`.
+func New(kv logger.KayveeLogger) *Logger {
+	return &Logger{kv: kv}
+}
+
+// With returns a Logger adding the fields of `keyvals` to its entries. Temporal's log.With
+// wraps loggers itself, since the signature of its log.WithLogger interface requires the SDK.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := l.data(keyvals)
+	return &Logger{kv: l.kv, fields: fields, replaying: l.replaying}
+}
+
+// WithReplayCheck returns a Logger dropping entries while `replaying` returns true, e.g.
+// workflow.IsReplaying, so that replayed workflow code doesn't log its entries twice.
+func (l *Logger) WithReplayCheck(replaying func() bool) *Logger {
+	return &Logger{kv: l.kv, fields: l.fields, replaying: replaying}
+}
+
+// Debug logs at the debug level.
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	if l.logs() {
+		l.kv.DebugD(msg, l.data(keyvals))
+	}
+}
+
+// Info logs at the info level.
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	if l.logs() {
+		l.kv.InfoD(msg, l.data(keyvals))
+	}
+}
+
+// Warn logs at the warning level.
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	if l.logs() {
+		l.kv.WarnD(msg, l.data(keyvals))
+	}
+}
+
+// Error logs at the error level.
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	if l.logs() {
+		l.kv.ErrorD(msg, l.data(keyvals))
+	}
+}
+
+func (l *Logger) logs() bool {
+	return l.replaying == nil || !l.replaying()
+}
+
+// data returns the fields of the logger with those of `keyvals`, renamed per Fields. Errors are
+// logged as their message.
+func (l *Logger) data(keyvals []interface{}) logger.M {
+	data := make(logger.M, len(l.fields)+len(keyvals)/2)
+	for k, v := range l.fields {
+		data[k] = v
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			data[extraField] = value(keyvals[i])
+			break
+		}
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		if name, ok := Fields[key]; ok {
+			key = name
+		}
+		data[key] = value(keyvals[i+1])
+	}
+	return data
+}
+
+func value(v interface{}) interface{} {
+	if err, ok := v.(error); ok && err != nil {
+		return err.Error()
+	}
+	return v
+}
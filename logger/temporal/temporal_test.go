@@ -0,0 +1,89 @@
+package temporal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// temporalLogger is the log.Logger interface of go.temporal.io/sdk/log.
+type temporalLogger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+var _ temporalLogger = &Logger{}
+
+func newLogger() (*Logger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	kvl := logger.New("my-worker")
+	kvl.SetConfig("my-worker", logger.Debug, kv.Format, out)
+	return New(kvl), out
+}
+
+func lines(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var ls []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		ls = append(ls, m)
+	}
+	return ls
+}
+
+func TestLogger(t *testing.T) {
+	l, out := newLogger()
+	activity := l.With("WorkflowID", "wf-1", "RunID", "run-1", "ActivityType", "Charge")
+	activity.Info("Activity started", "Attempt", 2)
+	activity.Error("Activity error.", "Error", errors.New("card declined"), "custom", "x")
+	l.Warn("Odd keyvals", "key")
+	l.Debug("Polling", 42, "not a string key")
+
+	ls := lines(t, out)
+	require.Len(t, ls, 4)
+	assert.Equal(t, "Activity started", ls[0]["title"])
+	assert.Equal(t, "info", ls[0]["level"])
+	assert.Equal(t, "wf-1", ls[0]["workflow_id"])
+	assert.Equal(t, "run-1", ls[0]["run_id"])
+	assert.Equal(t, "Charge", ls[0]["activity_type"])
+	assert.Equal(t, 2.0, ls[0]["attempt"])
+
+	assert.Equal(t, "error", ls[1]["level"])
+	assert.Equal(t, "card declined", ls[1]["Error"])
+	assert.Equal(t, "x", ls[1]["custom"])
+	assert.Equal(t, "wf-1", ls[1]["workflow_id"])
+
+	assert.Equal(t, "warning", ls[2]["level"])
+	assert.Equal(t, "key", ls[2]["extra"])
+	assert.Nil(t, ls[2]["workflow_id"], "With doesn't change its receiver")
+
+	assert.Equal(t, "debug", ls[3]["level"])
+	assert.Equal(t, "not a string key", ls[3]["42"])
+}
+
+func TestReplayCheck(t *testing.T) {
+	l, out := newLogger()
+	replaying := true
+	wl := l.With("WorkflowID", "wf-1").WithReplayCheck(func() bool { return replaying })
+	wl.Info("Replayed")
+	replaying = false
+	wl.Info("Executed")
+
+	ls := lines(t, out)
+	require.Len(t, ls, 1)
+	assert.Equal(t, "Executed", ls[0]["title"])
+	assert.Equal(t, "wf-1", ls[0]["workflow_id"])
+}
@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TokenKey is a tokenization key as exported by a Tokenizer.
+type TokenKey struct {
+	ID      string `json:"id"`
+	Secret  []byte `json:"secret"`
+	Current bool   `json:"current"`
+}
+
+// Tokenizer replaces user identifiers with keyed tokens of the form "<key id>:<hmac>".
+// Tokens can't be reversed, and can only be linked back to an identifier while the key
+// that produced them is retained. Destroying a key therefore "forgets" every identifier
+// tokenized with it, which is how deletion requests are satisfied.
+type Tokenizer struct {
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	order   []string
+	current string
+}
+
+// NewTokenizer creates a Tokenizer whose current key is `secret`, identified by `id`.
+func NewTokenizer(id string, secret []byte) (*Tokenizer, error) {
+	t := &Tokenizer{keys: map[string][]byte{}}
+	if err := t.Rotate(id, secret); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Tokenize returns the token for `value` under the current key.
+func (t *Tokenizer) Tokenize(value string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return tokenize(t.current, t.keys[t.current], value)
+}
+
+// TokenizeFields replaces the string values of `fields` in `data` with their tokens.
+// Fields that are missing or aren't strings are left untouched.
+func (t *Tokenizer) TokenizeFields(data map[string]interface{}, fields ...string) {
+	for _, f := range fields {
+		if v, ok := data[f].(string); ok {
+			data[f] = t.Tokenize(v)
+		}
+	}
+}
+
+// TokensFor returns the tokens `value` has under every retained key, keyed by key id.
+// Use it to find all the records belonging to an identifier before destroying keys.
+func (t *Tokenizer) TokensFor(value string) map[string]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make(map[string]string, len(t.keys))
+	for id, secret := range t.keys {
+		out[id] = tokenize(id, secret, value)
+	}
+	return out
+}
+
+// ExportMapping returns the identifier to token mapping for `values` under every retained
+// key, e.g. to hand over alongside a data export.
+func (t *Tokenizer) ExportMapping(values []string) map[string]map[string]string {
+	out := make(map[string]map[string]string, len(values))
+	for _, v := range values {
+		out[v] = t.TokensFor(v)
+	}
+	return out
+}
+
+// Matches returns true if `token` was produced from `value` by any retained key.
+func (t *Tokenizer) Matches(value, token string) bool {
+	id, _, ok := strings.Cut(token, ":")
+	if !ok {
+		return false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	secret, ok := t.keys[id]
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(tokenize(id, secret, value)), []byte(token))
+}
+
+// Rotate makes `secret` the current key. Previously current keys are retained (so
+// Matches and TokensFor keep working for old tokens) until they are destroyed. Rotation
+// is safe to do while other goroutines are tokenizing.
+func (t *Tokenizer) Rotate(id string, secret []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid token key id '%s'", id)
+	}
+	if len(secret) == 0 {
+		return errors.New("token key secret must not be empty")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.keys[id]; ok {
+		return fmt.Errorf("token key '%s' already exists", id)
+	}
+	t.keys[id] = append([]byte(nil), secret...)
+	t.order = append(t.order, id)
+	t.current = id
+	return nil
+}
+
+// DestroyKey removes a retained key. The current key can't be destroyed; rotate first.
+func (t *Tokenizer) DestroyKey(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id == t.current {
+		return fmt.Errorf("cannot destroy current token key '%s'", id)
+	}
+	secret, ok := t.keys[id]
+	if !ok {
+		return fmt.Errorf("unknown token key '%s'", id)
+	}
+	for i := range secret {
+		secret[i] = 0
+	}
+	delete(t.keys, id)
+	for i, k := range t.order {
+		if k == id {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// ExportKeys returns copies of all retained keys, oldest first.
+func (t *Tokenizer) ExportKeys() []TokenKey {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]TokenKey, 0, len(t.order))
+	for _, id := range t.order {
+		out = append(out, TokenKey{
+			ID:      id,
+			Secret:  append([]byte(nil), t.keys[id]...),
+			Current: id == t.current,
+		})
+	}
+	return out
+}
+
+// NewTokenizerFromKeys recreates a Tokenizer from keys previously returned by ExportKeys.
+func NewTokenizerFromKeys(keys []TokenKey) (*Tokenizer, error) {
+	t := &Tokenizer{keys: map[string][]byte{}}
+	current := ""
+	for _, k := range keys {
+		if err := t.Rotate(k.ID, k.Secret); err != nil {
+			return nil, err
+		}
+		if k.Current {
+			current = k.ID
+		}
+	}
+	if current == "" {
+		return nil, errors.New("no current token key")
+	}
+	t.current = current
+	return t, nil
+}
+
+func tokenize(id string, secret []byte, value string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+	return id + ":" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizer(t *testing.T) {
+	tk, err := NewTokenizer("k1", []byte("secret-one"))
+	require.NoError(t, err)
+
+	tok1 := tk.Tokenize("user-123")
+	assert.Regexp(t, "^k1:[0-9a-f]{64}$", tok1)
+	assert.Equal(t, tok1, tk.Tokenize("user-123"), "tokens should be stable")
+	assert.NotEqual(t, tok1, tk.Tokenize("user-456"))
+
+	require.NoError(t, tk.Rotate("k2", []byte("secret-two")))
+	tok2 := tk.Tokenize("user-123")
+	assert.Regexp(t, "^k2:", tok2)
+	assert.True(t, tk.Matches("user-123", tok1), "old tokens match until the key is destroyed")
+	assert.True(t, tk.Matches("user-123", tok2))
+	assert.Equal(t, map[string]string{"k1": tok1, "k2": tok2}, tk.TokensFor("user-123"))
+
+	assert.Error(t, tk.DestroyKey("k2"), "current key can't be destroyed")
+	require.NoError(t, tk.DestroyKey("k1"))
+	assert.False(t, tk.Matches("user-123", tok1))
+	assert.Equal(t, map[string]string{"k2": tok2}, tk.TokensFor("user-123"))
+}
+
+func TestTokenizerFields(t *testing.T) {
+	tk, err := NewTokenizer("k1", []byte("secret"))
+	require.NoError(t, err)
+	data := M{"user_id": "abc", "count": 3}
+	tk.TokenizeFields(data, "user_id", "count", "missing")
+	assert.Equal(t, tk.Tokenize("abc"), data["user_id"])
+	assert.Equal(t, 3, data["count"])
+}
+
+func TestTokenizerExportImport(t *testing.T) {
+	tk, err := NewTokenizer("k1", []byte("secret-one"))
+	require.NoError(t, err)
+	require.NoError(t, tk.Rotate("k2", []byte("secret-two")))
+
+	keys := tk.ExportKeys()
+	require.Len(t, keys, 2)
+	assert.False(t, keys[0].Current)
+	assert.True(t, keys[1].Current)
+
+	restored, err := NewTokenizerFromKeys(keys)
+	require.NoError(t, err)
+	assert.Equal(t, tk.Tokenize("user"), restored.Tokenize("user"))
+	assert.Equal(t, tk.ExportMapping([]string{"user"}), restored.ExportMapping([]string{"user"}))
+
+	_, err = NewTokenizerFromKeys([]TokenKey{{ID: "k1", Secret: []byte("x")}})
+	assert.Error(t, err)
+	assert.Error(t, tk.Rotate("k2", []byte("again")), "key ids must be unique")
+	assert.Error(t, tk.Rotate("bad:id", []byte("x")))
+}
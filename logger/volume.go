@@ -0,0 +1,227 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// volumeSizeBounds are the upper bounds, in bytes, of the entry size histogram buckets. Larger
+// entries count in a last, unbounded bucket.
+var volumeSizeBounds = []int{128, 256, 512, 1024, 2048, 4096, 8192, 16384, 65536}
+
+// maxVolumeTitles bounds the number of titles tracked separately, so that titles built from
+// unbounded values can't grow the tracker without limit. Further titles count as
+// OtherTitles.
+const maxVolumeTitles = 1000
+
+// OtherTitles is the title the volume of untracked titles is reported under, see TrackVolume.
+const OtherTitles = "(other)"
+
+// volumeStats is the tracker enabled with TrackVolume, or nil.
+var volumeStats atomic.Pointer[volumeTracker]
+
+// volumeTracker counts the entries and bytes written, per title and per size.
+type volumeTracker struct {
+	entries uint64
+	bytes   uint64
+	sizes   []uint64
+
+	titles    sync.Map // title -> *titleCounter
+	numTitles int64
+}
+
+type titleCounter struct {
+	entries, bytes uint64
+}
+
+// TrackVolume starts or stops counting the size of the entries written by every logger of the
+// process, along with their number and bytes per title, so that teams can find which entries
+// drive their log ingestion bill. Sizes are those of the formatted lines. Starting again
+// resets the counts. Read them with Volume, StartVolumeReport or VolumeHandler.
+func TrackVolume(enabled bool) {
+	if !enabled {
+		volumeStats.Store(nil)
+		return
+	}
+	volumeStats.Store(&volumeTracker{sizes: make([]uint64, len(volumeSizeBounds)+1)})
+}
+
+// countVolume counts an entry of `size` bytes titled `title`, if volume is tracked.
+func countVolume(title interface{}, size int) {
+	v := volumeStats.Load()
+	if v == nil {
+		return
+	}
+	atomic.AddUint64(&v.entries, 1)
+	atomic.AddUint64(&v.bytes, uint64(size))
+	atomic.AddUint64(&v.sizes[sort.SearchInts(volumeSizeBounds, size)], 1)
+
+	t, _ := title.(string)
+	counter, ok := v.titles.Load(t)
+	if !ok {
+		if atomic.LoadInt64(&v.numTitles) >= maxVolumeTitles {
+			t = OtherTitles
+		}
+		var loaded bool
+		if counter, loaded = v.titles.LoadOrStore(t, &titleCounter{}); !loaded && t != OtherTitles {
+			atomic.AddInt64(&v.numTitles, 1)
+		}
+	}
+	atomic.AddUint64(&counter.(*titleCounter).entries, 1)
+	atomic.AddUint64(&counter.(*titleCounter).bytes, uint64(size))
+}
+
+// SizeBucket counts the entries of at most UpTo bytes, and more than the previous bucket.
+// UpTo is 0 for the last bucket, which is unbounded.
+type SizeBucket struct {
+	UpTo  int    `json:"up_to"`
+	Count uint64 `json:"count"`
+}
+
+// TitleVolume is the volume of the entries with a title.
+type TitleVolume struct {
+	Title   string `json:"title"`
+	Entries uint64 `json:"entries"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// VolumeSnapshot is the volume counted since TrackVolume was called.
+type VolumeSnapshot struct {
+	Entries uint64       `json:"entries"`
+	Bytes   uint64       `json:"bytes"`
+	Sizes   []SizeBucket `json:"sizes"`
+	// TopTitles are the titles with the most bytes, largest first.
+	TopTitles []TitleVolume `json:"top_titles"`
+}
+
+// Volume returns the volume counted since TrackVolume was called, with the `topN` titles with
+// the most bytes. It returns a zero VolumeSnapshot if volume isn't tracked.
+func Volume(topN int) VolumeSnapshot {
+	v := volumeStats.Load()
+	if v == nil {
+		return VolumeSnapshot{}
+	}
+	s := VolumeSnapshot{
+		Entries: atomic.LoadUint64(&v.entries),
+		Bytes:   atomic.LoadUint64(&v.bytes),
+	}
+	for i := range v.sizes {
+		b := SizeBucket{Count: atomic.LoadUint64(&v.sizes[i])}
+		if i < len(volumeSizeBounds) {
+			b.UpTo = volumeSizeBounds[i]
+		}
+		s.Sizes = append(s.Sizes, b)
+	}
+	titles := []TitleVolume{}
+	v.titles.Range(func(title, counter interface{}) bool {
+		c := counter.(*titleCounter)
+		titles = append(titles, TitleVolume{
+			Title:   title.(string),
+			Entries: atomic.LoadUint64(&c.entries),
+			Bytes:   atomic.LoadUint64(&c.bytes),
+		})
+		return true
+	})
+	sort.Slice(titles, func(i, j int) bool {
+		if titles[i].Bytes != titles[j].Bytes {
+			return titles[i].Bytes > titles[j].Bytes
+		}
+		return titles[i].Title < titles[j].Title
+	})
+	if topN >= 0 && len(titles) > topN {
+		titles = titles[:topN]
+	}
+	s.TopTitles = titles
+	return s
+}
+
+// VolumeReportConfig configures StartVolumeReport.
+type VolumeReportConfig struct {
+	// Interval is the time between reports. Defaults to 5 minutes.
+	Interval time.Duration
+	// TopN is the number of titles reported. Defaults to 10.
+	TopN int
+	// Title is the title of report entries. Defaults to "kayvee-volume".
+	Title string
+}
+
+// StartVolumeReport logs the volume tracked with TrackVolume to `l` every interval until `ctx`
+// is canceled. Reports carry the total entries and bytes, the entry size histogram as
+// size_histogram, keyed by the upper bound of each bucket ("+Inf" for the last one), and the
+// TopN titles by bytes as top_titles. Reports are themselves counted.
+func StartVolumeReport(ctx context.Context, l Leveled, c VolumeReportConfig) {
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Minute
+	}
+	if c.TopN <= 0 {
+		c.TopN = 10
+	}
+	if c.Title == "" {
+		c.Title = "kayvee-volume"
+	}
+	go func() {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if volumeStats.Load() == nil {
+				continue
+			}
+			l.InfoD(c.Title, volumeReport(Volume(c.TopN)))
+		}
+	}()
+}
+
+// volumeReport returns the fields of a report of `s`.
+func volumeReport(s VolumeSnapshot) map[string]interface{} {
+	sizes := map[string]interface{}{}
+	for _, b := range s.Sizes {
+		key := "+Inf"
+		if b.UpTo > 0 {
+			key = strconv.Itoa(b.UpTo)
+		}
+		sizes[key] = b.Count
+	}
+	titles := make([]interface{}, 0, len(s.TopTitles))
+	for _, t := range s.TopTitles {
+		titles = append(titles, map[string]interface{}{
+			"title":   t.Title,
+			"entries": t.Entries,
+			"bytes":   t.Bytes,
+		})
+	}
+	return M{
+		"entries":        s.Entries,
+		"bytes":          s.Bytes,
+		"size_histogram": sizes,
+		"top_titles":     titles,
+	}
+}
+
+// VolumeHandler returns an http.Handler serving the volume tracked with TrackVolume as a JSON
+// VolumeSnapshot, for debug endpoints. It reports the `topN` titles with the most bytes,
+// unless the request has a `top` query parameter.
+func VolumeHandler(topN int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := topN
+		if top := r.URL.Query().Get("top"); top != "" {
+			var err error
+			if n, err = strconv.Atoi(top); err != nil || n < 0 {
+				http.Error(w, "invalid top", http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Volume(n))
+	})
+}
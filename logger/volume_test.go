@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+func TestTrackVolume(t *testing.T) {
+	TrackVolume(true)
+	defer TrackVolume(false)
+
+	out := &bytes.Buffer{}
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, out)
+	l.InfoD("big", M{"payload": strings.Repeat("x", 1000)})
+	l.InfoD("big", M{"payload": strings.Repeat("x", 1000)})
+	l.Info("small")
+	// entries below the log level aren't written, so they don't count
+	l.Debug("small")
+
+	s := Volume(10)
+	assert.Equal(t, uint64(3), s.Entries)
+	assert.Equal(t, uint64(out.Len()), s.Bytes)
+	require.Len(t, s.TopTitles, 2)
+	assert.Equal(t, "big", s.TopTitles[0].Title)
+	assert.Equal(t, uint64(2), s.TopTitles[0].Entries)
+	assert.Equal(t, TitleVolume{Title: "small", Entries: 1, Bytes: s.Bytes - s.TopTitles[0].Bytes}, s.TopTitles[1])
+
+	require.Len(t, s.Sizes, len(volumeSizeBounds)+1)
+	assert.Equal(t, SizeBucket{UpTo: 128, Count: 1}, s.Sizes[0])
+	assert.Equal(t, SizeBucket{UpTo: 2048, Count: 2}, s.Sizes[4])
+	assert.Equal(t, SizeBucket{UpTo: 0, Count: 0}, s.Sizes[len(s.Sizes)-1])
+
+	assert.Equal(t, []TitleVolume{s.TopTitles[0]}, Volume(1).TopTitles)
+
+	// restarting resets the counts
+	TrackVolume(true)
+	assert.Equal(t, uint64(0), Volume(10).Entries)
+	TrackVolume(false)
+	l.Info("small")
+	assert.Equal(t, VolumeSnapshot{}, Volume(10))
+}
+
+func TestTrackVolumeTitleLimit(t *testing.T) {
+	TrackVolume(true)
+	defer TrackVolume(false)
+
+	for i := 0; i < maxVolumeTitles+5; i++ {
+		countVolume(fmt.Sprintf("title-%d", i), 10)
+	}
+	countVolume("title-0", 10)
+
+	s := Volume(-1)
+	assert.Len(t, s.TopTitles, maxVolumeTitles+1)
+	assert.Equal(t, TitleVolume{Title: OtherTitles, Entries: 5, Bytes: 50}, s.TopTitles[0])
+	assert.Equal(t, TitleVolume{Title: "title-0", Entries: 2, Bytes: 20}, s.TopTitles[1])
+}
+
+func TestVolumeHandler(t *testing.T) {
+	TrackVolume(true)
+	defer TrackVolume(false)
+	countVolume("a", 100)
+	countVolume("b", 300)
+
+	rec := httptest.NewRecorder()
+	VolumeHandler(10).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/kayvee/volume?top=1", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	s := VolumeSnapshot{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&s))
+	assert.Equal(t, uint64(400), s.Bytes)
+	assert.Equal(t, []TitleVolume{{Title: "b", Entries: 1, Bytes: 300}}, s.TopTitles)
+
+	rec = httptest.NewRecorder()
+	VolumeHandler(10).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/kayvee/volume?top=many", nil))
+	assert.Equal(t, 400, rec.Code)
+}
+
+func TestStartVolumeReport(t *testing.T) {
+	TrackVolume(true)
+	defer TrackVolume(false)
+	countVolume("noisy", 5000)
+
+	out := &gatedWriter{gate: make(chan struct{})}
+	close(out.gate)
+	l := New("my-app")
+	l.SetConfig("my-app", Info, kv.Format, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	StartVolumeReport(ctx, l, VolumeReportConfig{Interval: 5 * time.Millisecond, TopN: 1})
+	require.Eventually(t, func() bool { return out.lines()[0] != "" }, time.Second, time.Millisecond)
+	cancel()
+
+	out.mu.Lock()
+	entries := decodeLines(t, &out.buf)
+	out.mu.Unlock()
+	assert.Equal(t, "kayvee-volume", entries[0]["title"])
+	assert.Equal(t, float64(1), entries[0]["entries"])
+	assert.Equal(t, float64(5000), entries[0]["bytes"])
+	assert.Equal(t, float64(1), entries[0]["size_histogram"].(map[string]interface{})["8192"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"title": "noisy", "entries": float64(1), "bytes": float64(5000)},
+	}, entries[0]["top_titles"])
+}
@@ -0,0 +1,224 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WatchdogPolicy is how a Watchdog reacts when its writer stalls.
+type WatchdogPolicy int
+
+const (
+	// WatchdogDiagnose only writes diagnostic entries when the writer stalls and recovers.
+	WatchdogDiagnose WatchdogPolicy = iota
+	// WatchdogFailover also sends writes to WatchdogConfig.Fallback while the writer is stalled.
+	WatchdogFailover
+	// WatchdogStderr also sends writes to os.Stderr while the writer is stalled.
+	WatchdogStderr
+)
+
+func (p WatchdogPolicy) String() string {
+	switch p {
+	case WatchdogFailover:
+		return "failover"
+	case WatchdogStderr:
+		return "stderr"
+	}
+	return "diagnose"
+}
+
+// WatchdogConfig configures a Watchdog.
+type WatchdogConfig struct {
+	// MaxLatency is the longest a write may take, including a write still in progress. 0
+	// disables the latency check.
+	MaxLatency time.Duration
+	// QueueDepth returns the number of entries waiting to be written, e.g. AsyncWriter.Queued.
+	// MaxQueueDepth is the most that may be waiting. The queue check is disabled when either
+	// is unset.
+	QueueDepth    func() int
+	MaxQueueDepth int
+	// For is how long a threshold must be exceeded before the writer is considered stalled.
+	// Defaults to 5s.
+	For time.Duration
+	// CheckInterval is how often the thresholds are checked. Defaults to 1s.
+	CheckInterval time.Duration
+	// Policy is how the Watchdog reacts to a stall.
+	Policy WatchdogPolicy
+	// Fallback receives the writes while the writer is stalled, with WatchdogFailover.
+	Fallback io.Writer
+	// Diagnostics receives the diagnostic entries, as kayvee JSON lines. Defaults to os.Stderr.
+	Diagnostics io.Writer
+}
+
+// WatchdogStats describes the state of a Watchdog.
+type WatchdogStats struct {
+	// Stalled is whether the writer is currently considered stalled.
+	Stalled bool
+	// Stalls is the number of times the writer stalled.
+	Stalls uint64
+	// Diverted is the number of writes sent to the fallback instead of the writer.
+	Diverted uint64
+}
+
+// Watchdog wraps a log output, like a network connection or an AsyncWriter, and detects when
+// it stalls: when its write latency or queue depth stays above a threshold for a while. It
+// then writes a diagnostic entry and, depending on its policy, diverts writes elsewhere until
+// the output recovers, so a hung destination is noticed and worked around automatically.
+type Watchdog struct {
+	w    io.Writer
+	c    WatchdogConfig
+	now  func() time.Time
+	done chan struct{}
+	once sync.Once
+
+	mu            sync.Mutex
+	inflight      map[uint64]time.Time
+	nextID        uint64
+	lastLatency   time.Duration
+	exceededSince time.Time
+	stalled       bool
+	stats         WatchdogStats
+}
+
+// NewWatchdog returns a Watchdog checking writes to `w` according to `c`.
+func NewWatchdog(w io.Writer, c WatchdogConfig) *Watchdog {
+	if c.For == 0 {
+		c.For = 5 * time.Second
+	}
+	if c.CheckInterval == 0 {
+		c.CheckInterval = time.Second
+	}
+	if c.Diagnostics == nil {
+		c.Diagnostics = os.Stderr
+	}
+	wd := &Watchdog{
+		w:        w,
+		c:        c,
+		now:      clock,
+		done:     make(chan struct{}),
+		inflight: map[uint64]time.Time{},
+	}
+	go wd.run()
+	return wd
+}
+
+// Write implements io.Writer.
+func (wd *Watchdog) Write(p []byte) (int, error) {
+	wd.mu.Lock()
+	if fallback := wd.fallback(); wd.stalled && fallback != nil {
+		wd.stats.Diverted++
+		wd.mu.Unlock()
+		return fallback.Write(p)
+	}
+	id := wd.nextID
+	wd.nextID++
+	start := wd.now()
+	wd.inflight[id] = start
+	stalls := wd.stats.Stalls
+	wd.mu.Unlock()
+
+	n, err := wd.w.Write(p)
+
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	delete(wd.inflight, id)
+	// a write that hung when the writer stalled says nothing about whether it recovered
+	if wd.stats.Stalls == stalls {
+		wd.lastLatency = wd.now().Sub(start)
+	}
+	return n, err
+}
+
+// fallback returns the writer receiving writes while stalled, or nil to keep writing to the
+// stalled writer.
+func (wd *Watchdog) fallback() io.Writer {
+	switch wd.c.Policy {
+	case WatchdogFailover:
+		return wd.c.Fallback
+	case WatchdogStderr:
+		return os.Stderr
+	}
+	return nil
+}
+
+// Stats returns the state of the Watchdog.
+func (wd *Watchdog) Stats() WatchdogStats {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	stats := wd.stats
+	stats.Stalled = wd.stalled
+	return stats
+}
+
+// Close stops checking the writer. It doesn't close the writer.
+func (wd *Watchdog) Close() error {
+	wd.once.Do(func() { close(wd.done) })
+	return nil
+}
+
+func (wd *Watchdog) run() {
+	ticker := time.NewTicker(wd.c.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wd.done:
+			return
+		case <-ticker.C:
+			wd.check()
+		}
+	}
+}
+
+// check compares the writer to the thresholds and reacts if it stalled or recovered.
+func (wd *Watchdog) check() {
+	depth := 0
+	if wd.c.QueueDepth != nil {
+		depth = wd.c.QueueDepth()
+	}
+	now := wd.now()
+
+	wd.mu.Lock()
+	latency := wd.lastLatency
+	for _, start := range wd.inflight {
+		if d := now.Sub(start); d > latency {
+			latency = d
+		}
+	}
+	exceeded := (wd.c.MaxLatency > 0 && latency > wd.c.MaxLatency) ||
+		(wd.c.QueueDepth != nil && wd.c.MaxQueueDepth > 0 && depth > wd.c.MaxQueueDepth)
+	if !exceeded {
+		wd.exceededSince = time.Time{}
+	} else if wd.exceededSince.IsZero() {
+		wd.exceededSince = now
+	}
+	title := ""
+	switch {
+	case exceeded && !wd.stalled && now.Sub(wd.exceededSince) >= wd.c.For:
+		wd.stalled = true
+		wd.stats.Stalls++
+		wd.lastLatency = 0
+		title = "kayvee-output-stalled"
+	case !exceeded && wd.stalled:
+		wd.stalled = false
+		title = "kayvee-output-recovered"
+	}
+	wd.mu.Unlock()
+
+	if title == "" {
+		return
+	}
+	level := Warning
+	if title == "kayvee-output-recovered" {
+		level = Info
+	}
+	io.WriteString(wd.c.Diagnostics, formatJSON(map[string]interface{}{
+		"title":       title,
+		"level":       level.String(),
+		"source":      "kayvee-watchdog",
+		"latency_ms":  latency.Milliseconds(),
+		"queue_depth": depth,
+		"policy":      wd.c.Policy.String(),
+	})+"\n")
+}
@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter blocks writes while blocked is set, until unblock is called.
+type blockingWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	blocked chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	blocked := w.blocked
+	w.mu.Unlock()
+	if blocked != nil {
+		<-blocked
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) block() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.blocked = make(chan struct{})
+}
+
+func (w *blockingWriter) unblock() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	close(w.blocked)
+	w.blocked = nil
+}
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// newTestWatchdog returns a Watchdog on a fake clock, whose checks are only run by the test.
+func newTestWatchdog(w *blockingWriter, c WatchdogConfig) (*Watchdog, func(time.Duration)) {
+	c.CheckInterval = time.Hour
+	wd := NewWatchdog(w, c)
+	var mu sync.Mutex
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wd.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+		wd.check()
+	}
+	return wd, advance
+}
+
+func TestWatchdogFailsOverOnHungWrite(t *testing.T) {
+	primary := &blockingWriter{}
+	fallback := &bytes.Buffer{}
+	diagnostics := &bytes.Buffer{}
+	wd, advance := newTestWatchdog(primary, WatchdogConfig{
+		MaxLatency:  time.Second,
+		For:         5 * time.Second,
+		Policy:      WatchdogFailover,
+		Fallback:    fallback,
+		Diagnostics: diagnostics,
+	})
+	defer wd.Close()
+
+	wd.Write([]byte("a\n"))
+	primary.block()
+	hung := make(chan struct{})
+	go func() {
+		wd.Write([]byte("b\n"))
+		close(hung)
+	}()
+	require.Eventually(t, func() bool {
+		wd.mu.Lock()
+		defer wd.mu.Unlock()
+		return len(wd.inflight) == 1
+	}, time.Second, time.Millisecond)
+
+	advance(2 * time.Second)
+	assert.False(t, wd.Stats().Stalled)
+	advance(5 * time.Second)
+	assert.True(t, wd.Stats().Stalled)
+
+	wd.Write([]byte("c\n"))
+	assert.Equal(t, "c\n", fallback.String())
+
+	primary.unblock()
+	<-hung
+	advance(time.Second)
+	assert.Equal(t, WatchdogStats{Stalls: 1, Diverted: 1}, wd.Stats())
+	wd.Write([]byte("d\n"))
+	assert.Equal(t, "a\nb\nd\n", primary.String())
+
+	lines := decodeLines(t, diagnostics)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "kayvee-output-stalled", lines[0]["title"])
+	assert.Equal(t, "warning", lines[0]["level"])
+	assert.Equal(t, float64(7000), lines[0]["latency_ms"])
+	assert.Equal(t, "failover", lines[0]["policy"])
+	assert.Equal(t, "kayvee-output-recovered", lines[1]["title"])
+}
+
+func TestWatchdogQueueDepth(t *testing.T) {
+	depth := 0
+	diagnostics := &bytes.Buffer{}
+	wd, advance := newTestWatchdog(&blockingWriter{}, WatchdogConfig{
+		QueueDepth:    func() int { return depth },
+		MaxQueueDepth: 10,
+		For:           3 * time.Second,
+		Diagnostics:   diagnostics,
+	})
+	defer wd.Close()
+
+	depth = 20
+	advance(time.Second)
+	advance(2 * time.Second)
+	depth = 5
+	advance(time.Second)
+	depth = 20
+	advance(time.Second)
+	advance(2 * time.Second)
+	assert.False(t, wd.Stats().Stalled, "the depth must stay over the threshold for 3s")
+	advance(time.Second)
+	assert.True(t, wd.Stats().Stalled)
+
+	// with WatchdogDiagnose writes still go to the writer
+	wd.Write([]byte("a\n"))
+	assert.Equal(t, uint64(0), wd.Stats().Diverted)
+
+	lines := decodeLines(t, diagnostics)
+	require.Len(t, lines, 1)
+	assert.Equal(t, float64(20), lines[0]["queue_depth"])
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+var globalDebugHeader *DebugHeaderConfig
+
+// DefaultDebugHeader is the request header opting a request in to debug logging.
+const DefaultDebugHeader = "X-Debug-Log"
+
+// DebugHeaderConfig configures opting single requests in to debug logging with a header, see
+// EnableDebugHeader. The header must hold one of Tokens, or a token signed with Secret by
+// SignDebugToken that hasn't expired.
+type DebugHeaderConfig struct {
+	// Header is the request header holding the token. Defaults to DefaultDebugHeader.
+	Header string
+	// Tokens are the accepted static tokens.
+	Tokens []string
+	// Secret is the key of signed tokens.
+	Secret []byte
+	// FlightRecorder, when positive, makes the logger in the context of opted-in requests a
+	// FlightRecorder, holding up to that many of its entries below Info. They're written if the
+	// request-finished entry is an error, i.e. for statuses of 499 and above, and dropped
+	// otherwise.
+	FlightRecorder int
+}
+
+// EnableDebugHeader turns on debug logging for the kv middleware requests carrying a valid
+// debug header, so that support engineers can get verbose traces of a single call in
+// production. The logger of those requests logs at Debug even if KAYVEE_LOG_LEVEL is higher,
+// and their request-finished entry has "debug-log": true.
+func EnableDebugHeader(config DebugHeaderConfig) error {
+	if len(config.Tokens) == 0 && len(config.Secret) == 0 {
+		return errors.New("debug header requires Tokens or a Secret")
+	}
+	if config.Header == "" {
+		config.Header = DefaultDebugHeader
+	}
+	globalDebugHeader = &config
+	return nil
+}
+
+// SignDebugToken returns a token for the debug header signed with `secret`, valid until
+// `expires`.
+func SignDebugToken(secret []byte, expires time.Time) string {
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	return expiry + "." + debugSignature(secret, expiry)
+}
+
+func debugSignature(secret []byte, expiry string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// allows returns true if `req` carries a valid debug header.
+func (c *DebugHeaderConfig) allows(req *http.Request) bool {
+	token := req.Header.Get(c.Header)
+	if token == "" {
+		return false
+	}
+	for _, t := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	if len(c.Secret) == 0 {
+		return false
+	}
+	expiry, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(debugSignature(c.Secret, expiry))) {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	return err == nil && logger.Now().Unix() < expires
+}
+
+// loggersAboveDebug returns true if the loggers created with logger.New log above Debug, as
+// set with KAYVEE_LOG_LEVEL.
+func loggersAboveDebug() bool {
+	switch strings.ToLower(os.Getenv("KAYVEE_LOG_LEVEL")) {
+	case "info", "warning", "error", "critical":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m), line)
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestDebugHeader(t *testing.T) {
+	t.Setenv("KAYVEE_LOG_LEVEL", "info")
+	secret := []byte("s3cret")
+	require.NoError(t, EnableDebugHeader(DebugHeaderConfig{Tokens: []string{"support-token"}, Secret: secret}))
+	defer func() { globalDebugHeader = nil }()
+
+	for _, test := range []struct {
+		desc  string
+		token string
+		debug bool
+	}{
+		{"no header", "", false},
+		{"allowlisted token", "support-token", true},
+		{"unknown token", "guess", false},
+		{"signed token", SignDebugToken(secret, time.Now().Add(time.Hour)), true},
+		{"expired token", SignDebugToken(secret, time.Now().Add(-time.Second)), false},
+		{"token signed with another secret", SignDebugToken([]byte("other"), time.Now().Add(time.Hour)), false},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			handler := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				l := logger.FromContext(r.Context())
+				l.SetOutput(out)
+				l.Debug("verbose")
+			}), "my-source")
+			req := &http.Request{Method: "GET", URL: &url.URL{Path: "path"}, Header: http.Header{}}
+			if test.token != "" {
+				req.Header.Set(DefaultDebugHeader, test.token)
+			}
+			handler.ServeHTTP(&bufferWriter{}, req)
+
+			lines := decodeLines(t, out)
+			if !test.debug {
+				require.Len(t, lines, 1)
+				assert.Nil(t, lines[0]["debug-log"])
+				return
+			}
+			require.Len(t, lines, 2)
+			assert.Equal(t, "verbose", lines[0]["title"])
+			assert.Equal(t, true, lines[1]["debug-log"])
+		})
+	}
+
+	assert.EqualError(t, EnableDebugHeader(DebugHeaderConfig{}), "debug header requires Tokens or a Secret")
+}
+
+func TestDebugHeaderFlightRecorder(t *testing.T) {
+	require.NoError(t, EnableDebugHeader(DebugHeaderConfig{Header: "X-Support", Tokens: []string{"t"}, FlightRecorder: 10}))
+	defer func() { globalDebugHeader = nil }()
+
+	for _, status := range []int{200, 499, 503} {
+		out := &bytes.Buffer{}
+		handler := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorded := logger.FromContext(r.Context())
+			recorded.SetOutput(out)
+			recorded.Debug("step")
+			w.WriteHeader(status)
+		}), "my-source")
+		req := &http.Request{Method: "GET", URL: &url.URL{Path: "path"}, Header: http.Header{"X-Support": {"t"}}}
+		handler.ServeHTTP(&bufferWriter{}, req)
+
+		lines := decodeLines(t, out)
+		if status < 499 {
+			require.Len(t, lines, 1)
+		} else {
+			require.Len(t, lines, 2)
+			assert.Equal(t, "step", lines[0]["title"])
+		}
+		assert.Equal(t, "request-finished", lines[len(lines)-1]["title"])
+	}
+}
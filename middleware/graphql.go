@@ -0,0 +1,279 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// maxGraphQLResponse is the size of the responses buffered to count their errors. Errors of
+// larger responses aren't counted.
+const maxGraphQLResponse = 1 << 20
+
+// GraphQLOperation describes a GraphQL operation served, for the "graphql-operation" entry.
+type GraphQLOperation struct {
+	// Name is the operation name, or "" for anonymous operations.
+	Name string
+	// Type is "query", "mutation" or "subscription".
+	Type string
+	// Query is the document of the operation. Only its hash is logged.
+	Query string
+	// Hash is the hash of Query. Defaults to its hex SHA-256, or to the hash of the persisted
+	// query when Query is empty.
+	Hash string
+	// Complexity is the complexity computed by the server, or 0 if unknown.
+	Complexity int
+	// Errors is the number of errors in the response, e.g. of resolvers.
+	Errors   int
+	Duration time.Duration
+}
+
+// Fields returns the kayvee fields of the operation. Servers that don't use NewGraphQL, e.g.
+// with a gqlgen AroundResponses interceptor, can log them themselves:
+//
+//	srv.AroundResponses(func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+//		oc := graphql.GetOperationContext(ctx)
+//		resp := next(ctx)
+//		op := middleware.GraphQLOperation{Name: oc.OperationName, Query: oc.RawQuery,
+//			Errors: len(resp.Errors), Duration: graphql.Now().Sub(oc.Stats.OperationStart)}
+//		if oc.Operation != nil {
+//			op.Type = string(oc.Operation.Operation)
+//		}
+//		logger.FromContext(ctx).InfoD("graphql-operation", op.Fields())
+//		return resp
+//	})
+func (op GraphQLOperation) Fields() logger.M {
+	name := op.Name
+	if name == "" {
+		name = "anonymous"
+	}
+	hash := op.Hash
+	if hash == "" && op.Query != "" {
+		sum := sha256.Sum256([]byte(op.Query))
+		hash = hex.EncodeToString(sum[:])
+	}
+	fields := logger.M{
+		"operation-name":   name,
+		"query-hash":       hash,
+		"error-count":      op.Errors,
+		"response-time":    logger.FormatDuration(op.Duration),
+		"response-time-ms": op.Duration.Nanoseconds() / int64(time.Millisecond),
+		"count":            1,
+	}
+	if op.Type != "" {
+		fields["operation-type"] = op.Type
+	}
+	if op.Complexity > 0 {
+		fields["complexity"] = op.Complexity
+	}
+	return fields
+}
+
+type graphqlComplexityKey struct{}
+
+// SetGraphQLComplexity records the complexity of the operation served with `ctx` by NewGraphQL,
+// e.g. from the complexity limit of the server. It's ignored outside of NewGraphQL, and for
+// batched requests.
+func SetGraphQLComplexity(ctx context.Context, complexity int) {
+	if c, ok := ctx.Value(graphqlComplexityKey{}).(*int64); ok {
+		atomic.StoreInt64(c, int64(complexity))
+	}
+}
+
+// graphqlRequest is the body of a GraphQL request, or its URL query for GETs.
+type graphqlRequest struct {
+	Query         string `json:"query"`
+	OperationName string `json:"operationName"`
+	Extensions    struct {
+		PersistedQuery struct {
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// graphqlResponse is the body of a GraphQL response.
+type graphqlResponse struct {
+	Errors []json.RawMessage `json:"errors"`
+}
+
+type graphqlHandler struct {
+	h      http.Handler
+	source string
+}
+
+// NewGraphQL wraps the GraphQL endpoint `h`, logging a "graphql-operation" entry per
+// operation with its name, type, query hash, complexity (see SetGraphQLComplexity), the
+// number of errors in its response, and its latency, since the access logs of a single
+// /graphql endpoint don't tell operations apart. Batched requests log an entry per
+// operation, with the latency of the batch. Like New, it puts a logger for `source` in
+// req.Context(). Websocket upgrades aren't logged.
+func NewGraphQL(h http.Handler, source string) http.Handler {
+	return &graphqlHandler{h: h, source: source}
+}
+
+func (g *graphqlHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		g.h.ServeHTTP(w, req)
+		return
+	}
+	start := logger.Now()
+	lggr := logger.New(g.source)
+	complexity := new(int64)
+	ctx := logger.NewContext(req.Context(), lggr)
+	ctx = context.WithValue(ctx, graphqlComplexityKey{}, complexity)
+	req = req.WithContext(ctx)
+
+	ops, batched := readGraphQLRequests(req)
+	grw := &graphqlResponseWriter{loggedResponseWriter: loggedResponseWriter{status: 200, ResponseWriter: w}}
+	g.h.ServeHTTP(grw, req)
+	duration := logger.Now().Sub(start)
+
+	errs := grw.errorCounts(batched)
+	for i, op := range ops {
+		op.Duration = duration
+		if i < len(errs) {
+			op.Errors = errs[i]
+		}
+		if !batched {
+			op.Complexity = int(atomic.LoadInt64(complexity))
+		}
+		fields := op.Fields()
+		fields["status-code"] = grw.status
+		fields["via"] = "kayvee-graphql"
+		switch {
+		case logLevelFromStatus(grw.status) == logger.Error:
+			lggr.ErrorD("graphql-operation", fields)
+		case op.Errors > 0:
+			lggr.WarnD("graphql-operation", fields)
+		default:
+			lggr.InfoD("graphql-operation", fields)
+		}
+	}
+}
+
+// readGraphQLRequests returns the operations of `req`, and whether it's a batch, restoring its
+// body for the wrapped handler.
+func readGraphQLRequests(req *http.Request) ([]GraphQLOperation, bool) {
+	var reqs []graphqlRequest
+	batched := false
+	if req.Method == http.MethodGet {
+		q := req.URL.Query()
+		r := graphqlRequest{Query: q.Get("query"), OperationName: q.Get("operationName")}
+		if ext := q.Get("extensions"); ext != "" {
+			json.Unmarshal([]byte(ext), &r.Extensions)
+		}
+		reqs = []graphqlRequest{r}
+	} else if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return nil, false
+		}
+		body = bytes.TrimSpace(body)
+		if len(body) > 0 && body[0] == '[' {
+			batched = true
+			json.Unmarshal(body, &reqs)
+		} else {
+			var r graphqlRequest
+			json.Unmarshal(body, &r)
+			reqs = []graphqlRequest{r}
+		}
+	}
+
+	ops := make([]GraphQLOperation, len(reqs))
+	for i, r := range reqs {
+		ops[i] = GraphQLOperation{
+			Name:  r.OperationName,
+			Type:  operationType(r.Query, r.OperationName),
+			Query: r.Query,
+		}
+		if r.Query == "" {
+			ops[i].Hash = r.Extensions.PersistedQuery.SHA256Hash
+		}
+	}
+	return ops, batched
+}
+
+var operationDefinition = regexp.MustCompile(`(?:^|[\s}])(query|mutation|subscription)\b\s*([_A-Za-z][_0-9A-Za-z]*)?`)
+
+// operationType returns the type of the operation `name` of the document `query`, or of its
+// first operation if `name` is empty. It doesn't parse the document, so it can be fooled by
+// strings and comments.
+func operationType(query, name string) string {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return ""
+	}
+	matches := operationDefinition.FindAllStringSubmatch(query, -1)
+	for _, m := range matches {
+		if name == "" || m[2] == name {
+			return m[1]
+		}
+	}
+	if strings.HasPrefix(query, "{") {
+		// the query shorthand
+		return "query"
+	}
+	return ""
+}
+
+// graphqlResponseWriter buffers the start of the response to count its errors.
+type graphqlResponseWriter struct {
+	loggedResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *graphqlResponseWriter) Write(b []byte) (int, error) {
+	if !w.truncated {
+		if w.body.Len()+len(b) > maxGraphQLResponse {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.loggedResponseWriter.Write(b)
+}
+
+// Flush supports streamed responses, e.g. of incremental delivery.
+func (w *graphqlResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// errorCounts returns the number of errors of every response of the batch, or of the response.
+func (w *graphqlResponseWriter) errorCounts(batched bool) []int {
+	if w.truncated {
+		return nil
+	}
+	var resps []graphqlResponse
+	if batched {
+		if json.Unmarshal(w.body.Bytes(), &resps) != nil {
+			return nil
+		}
+	} else {
+		var resp graphqlResponse
+		if json.Unmarshal(w.body.Bytes(), &resp) != nil {
+			return nil
+		}
+		resps = []graphqlResponse{resp}
+	}
+	counts := make([]int, len(resps))
+	for i, resp := range resps {
+		counts[i] = len(resp.Errors)
+	}
+	return counts
+}
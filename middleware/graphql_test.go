@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestGraphQL(t *testing.T) {
+	logger.SetClock(func() time.Time { return time.Unix(0, 0) })
+	defer logger.SetClock(nil)
+
+	for _, test := range []struct {
+		desc     string
+		body     string
+		response string
+		expected []map[string]interface{}
+	}{
+		{
+			desc:     "named query",
+			body:     `{"query":"query Me { me { id } }","operationName":"Me"}`,
+			response: `{"data":{"me":{"id":"1"}}}`,
+			expected: []map[string]interface{}{{
+				"level":          "info",
+				"operation-name": "Me",
+				"operation-type": "query",
+				"query-hash":     "2e3e49f19828396c35f7f9415f5558239d37f5d85b674851f58bf43337f5aaab",
+				"error-count":    0.0,
+				"complexity":     12.0,
+			}},
+		},
+		{
+			desc:     "anonymous mutation with errors",
+			body:     `{"query":"mutation { del(id: 1) }"}`,
+			response: `{"errors":[{"message":"no"},{"message":"never"}],"data":null}`,
+			expected: []map[string]interface{}{{
+				"level":          "warning",
+				"operation-name": "anonymous",
+				"operation-type": "mutation",
+				"error-count":    2.0,
+				"complexity":     12.0,
+			}},
+		},
+		{
+			desc: "batch",
+			body: `[{"query":"query A { a } mutation B { b }","operationName":"B"},` +
+				`{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}]`,
+			response: `[{"data":{"b":1}},{"errors":[{"message":"PersistedQueryNotFound"}]}]`,
+			expected: []map[string]interface{}{
+				{"operation-name": "B", "operation-type": "mutation", "error-count": 0.0},
+				{"operation-name": "anonymous", "query-hash": "abc", "error-count": 1.0},
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			handler := NewGraphQL(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				logger.FromContext(r.Context()).SetOutput(out)
+				SetGraphQLComplexity(r.Context(), 12)
+				w.Write([]byte(test.response))
+			}), "my-source")
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(test.body))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			lines := decodeLines(t, out)
+			require.Len(t, lines, len(test.expected))
+			for i, expected := range test.expected {
+				if _, ok := expected["query-hash"]; !ok {
+					assert.Len(t, lines[i]["query-hash"], 64)
+				}
+				for k, v := range expected {
+					assert.Equal(t, v, lines[i][k], k)
+				}
+				assert.Equal(t, "graphql-operation", lines[i]["title"])
+				assert.Equal(t, 200.0, lines[i]["status-code"])
+				assert.Equal(t, "kayvee-graphql", lines[i]["via"])
+				assert.Equal(t, 0.0, lines[i]["response-time"])
+				if test.desc == "batch" {
+					assert.Nil(t, lines[i]["complexity"])
+				}
+			}
+		})
+	}
+}
+
+func TestGraphQLGet(t *testing.T) {
+	out := &bytes.Buffer{}
+	handler := NewGraphQL(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).SetOutput(out)
+		w.WriteHeader(500)
+	}), "my-source")
+	q := url.Values{"query": {"{ me { id } }"}}
+	req := httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := decodeLines(t, out)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "error", lines[0]["level"])
+	assert.Equal(t, "query", lines[0]["operation-type"])
+	assert.Equal(t, 500.0, lines[0]["status-code"])
+}
+
+func TestGraphQLBodyForwarded(t *testing.T) {
+	var body string
+	handler := NewGraphQL(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).SetOutput(&bytes.Buffer{})
+		b := &bytes.Buffer{}
+		b.ReadFrom(r.Body)
+		body = b.String()
+	}), "my-source")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ a }"}`)))
+	assert.Equal(t, `{"query":"{ a }"}`, body)
+}
+
+func TestOperationType(t *testing.T) {
+	assert.Equal(t, "query", operationType("{ a }", ""))
+	assert.Equal(t, "query", operationType("query { a }", ""))
+	assert.Equal(t, "subscription", operationType("query A { a }\nsubscription B { b }", "B"))
+	assert.Equal(t, "mutation", operationType("fragment F on T { f } mutation M { m(x: 1) { ...F } }", ""))
+	assert.Equal(t, "", operationType("", ""))
+}
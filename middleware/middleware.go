@@ -5,7 +5,6 @@ Package middleware provides a customizable Kayvee logging middleware for HTTP se
 		// Add Gorilla mux vars to the log, just because
 		return mux.Vars(req)
 	})
-
 */
 package middleware
 
@@ -15,7 +14,7 @@ import (
 	"strings"
 	"time"
 
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 var defaultHandler = func(req *http.Request) map[string]interface{} {
@@ -23,7 +22,7 @@ var defaultHandler = func(req *http.Request) map[string]interface{} {
 		"method": req.Method,
 		"path":   req.URL.Path,
 		"params": req.URL.RawQuery,
-		"ip":     getIP(req),
+		"ip":     logger.RequestIP(req),
 	}
 
 	// TODO: wag should inject metadata into the req context
@@ -42,10 +41,19 @@ type logHandler struct {
 }
 
 func (l *logHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	start := time.Now()
+	start := logger.Now()
 
 	// create and inject a logger into req.Context
 	lggr := logger.New(l.source)
+	debug := globalDebugHeader != nil && globalDebugHeader.allows(req)
+	var recorder *logger.FlightRecorder
+	if debug {
+		if n := globalDebugHeader.FlightRecorder; n > 0 {
+			lggr, recorder = logger.NewFlightRecorder(l.source, logger.Info, n)
+		} else if loggersAboveDebug() {
+			lggr.SetLogLevel(logger.Debug)
+		}
+	}
 	req = req.WithContext(logger.NewContext(req.Context(), lggr))
 
 	lrw := &loggedResponseWriter{
@@ -54,7 +62,14 @@ func (l *logHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		length:         0,
 	}
 	l.h.ServeHTTP(lrw, req)
-	duration := time.Since(start)
+	duration := logger.Now().Sub(start)
+	if recorder != nil {
+		if logLevelFromStatus(lrw.status) == logger.Error {
+			recorder.Flush()
+		} else {
+			recorder.Discard()
+		}
+	}
 
 	data := l.applyHandlers(req, map[string]interface{}{
 		"response-time":    duration,
@@ -65,16 +80,27 @@ func (l *logHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		"via":              "kayvee-middleware",
 		"canary":           l.isCanary,
 	})
+	if debug {
+		data["debug-log"] = true
+	}
 
 	if silent, ok := data["silent"].(bool); ok && silent {
 		return
 	}
 
+	if globalAccessLog != nil {
+		globalAccessLog.Log(req, lrw.status, lrw.length, start, duration)
+		if globalAccessLog.config.ReplaceJSON {
+			return
+		}
+	}
+
 	// check if the user has opted in to rolling up middleware logs
 	if globalRollupRouter != nil && globalRollupRouter.ShouldRollup(data) {
 		globalRollupRouter.Process(data)
 		return
 	}
+	logger.FormatDurations(data)
 
 	switch logLevelFromStatus(lrw.status) {
 	case logger.Error:
@@ -158,14 +184,6 @@ func (w *loggedResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-func getIP(req *http.Request) string {
-	forwarded := req.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		return forwarded
-	}
-	return req.RemoteAddr
-}
-
 func logLevelFromStatus(status int) logger.LogLevel {
 	if status >= 499 {
 		return logger.Error
@@ -8,10 +8,12 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	kv "gopkg.in/Clever/kayvee-go.v6"
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 type bufferWriter struct {
@@ -204,3 +206,47 @@ func TestMiddlewareIsAddedToContext(t *testing.T) {
 		t.Fatalf("invalid log title %s", result["title"])
 	}
 }
+
+func TestMiddlewareSetClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	logger.SetClock(func() time.Time {
+		now = now.Add(25 * time.Millisecond)
+		return now
+	})
+	defer logger.SetClock(nil)
+
+	out := &bytes.Buffer{}
+	handler := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).SetConfig("my-source", logger.Info, kv.Format, out)
+	}), "my-source")
+	handler.ServeHTTP(&bufferWriter{}, &http.Request{Method: "GET", URL: &url.URL{Path: "path"}})
+
+	var result map[string]interface{}
+	assert.Nil(t, json.NewDecoder(out).Decode(&result))
+	assert.Equal(t, float64(25*time.Millisecond), result["response-time"])
+	assert.Equal(t, float64(25), result["response-time-ms"])
+
+	logger.SetDurationFormat(logger.DurationISO8601)
+	defer logger.SetDurationFormat(logger.DurationNanoseconds)
+	out.Reset()
+	handler.ServeHTTP(&bufferWriter{}, &http.Request{Method: "GET", URL: &url.URL{Path: "path"}})
+	assert.Nil(t, json.NewDecoder(out).Decode(&result))
+	assert.Equal(t, "PT0.025S", result["response-time"])
+}
+
+func TestMiddlewareDurationFields(t *testing.T) {
+	logger.SetDurationFormat(logger.DurationISO8601)
+	defer logger.SetDurationFormat(logger.DurationNanoseconds)
+
+	out := &bytes.Buffer{}
+	handler := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).SetConfig("my-source", logger.Info, kv.Format, out)
+	}), "my-source", func(*http.Request) map[string]interface{} {
+		return map[string]interface{}{"db-time": 1500 * time.Millisecond}
+	})
+	handler.ServeHTTP(&bufferWriter{}, &http.Request{Method: "GET", URL: &url.URL{Path: "path"}})
+
+	var result map[string]interface{}
+	assert.Nil(t, json.NewDecoder(out).Decode(&result))
+	assert.Equal(t, "PT1.5S", result["db-time"])
+}
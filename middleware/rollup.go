@@ -7,7 +7,7 @@ import (
 	"sync/atomic"
 	"time"
 
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 var globalRollupRouter *RollupRouter
@@ -154,6 +154,8 @@ func (r *logRollup) report() {
 		sum := r.rollupResponseTimeNsSum / int64(time.Millisecond)
 		r.rollupMsg["response-time-ms-sum"] = sum
 		r.rollupMsg["response-time-ms"] = sum / r.rollupMsg["count"].(int64)
+		r.rollupMsg["response-time"] = time.Duration(r.rollupResponseTimeNsSum / r.rollupMsg["count"].(int64))
+		logger.FormatDurations(r.rollupMsg)
 
 		switch logLevelFromStatus(r.StatusCode) {
 		case logger.Error:
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 type RollupLoggerCall struct {
@@ -88,6 +90,7 @@ func TestProcess(t *testing.T) {
 				"count":                int64(100),
 				"op":                   "healthCheck",
 				"method":               "GET",
+				"response-time":        100 * time.Millisecond,
 				"response-time-ms":     int64(100),
 				"response-time-ms-sum": int64(100 * 100),
 				"status-code":          200,
@@ -97,6 +100,22 @@ func TestProcess(t *testing.T) {
 	})
 }
 
+func TestRollupDurationFormat(t *testing.T) {
+	logger.SetDurationFormat(logger.DurationMilliseconds)
+	defer logger.SetDurationFormat(logger.DurationNanoseconds)
+
+	mockLogger := &MockRollupLogger{}
+	rollup := &logRollup{Logger: mockLogger, StatusCode: 200, Op: "healthCheck", HTTPMethod: "GET"}
+	rollup.add(map[string]interface{}{"response-time": 100 * time.Millisecond})
+	rollup.add(map[string]interface{}{"response-time": 50 * time.Millisecond})
+	rollup.report()
+
+	calls := mockLogger.InfoDCalls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, 75.0, calls[0].Data["response-time"])
+	assert.Equal(t, int64(75), calls[0].Data["response-time-ms"])
+}
+
 func TestShouldRollup(t *testing.T) {
 	mockLogger := &MockRollupLogger{}
 	reportingDelay := 1 * time.Second
@@ -0,0 +1,321 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// RPC protocols, the "rpc-protocol" of "rpc-finished" entries.
+const (
+	RPCProtocolGRPC    = "grpc"
+	RPCProtocolGRPCWeb = "grpc-web"
+	RPCProtocolConnect = "connect"
+)
+
+// Envelope flags: the last message of gRPC-Web responses holds the trailers, and that of
+// Connect streams the end of the stream.
+const (
+	grpcWebTrailerFlag    = 0x80
+	connectEndStreamFlag  = 0x02
+	maxCapturedRPCPayload = 64 << 10
+)
+
+// rpcCodes are the names of the gRPC status codes, as Connect names them.
+var rpcCodes = []string{
+	"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded", "not_found",
+	"already_exists", "permission_denied", "resource_exhausted", "failed_precondition",
+	"aborted", "out_of_range", "unimplemented", "internal", "unavailable", "data_loss",
+	"unauthenticated",
+}
+
+// rpcServerErrors are the codes logged at the error level, the others being the client's fault.
+var rpcServerErrors = map[string]bool{
+	"unknown": true, "deadline_exceeded": true, "unimplemented": true, "internal": true,
+	"unavailable": true, "data_loss": true,
+}
+
+type rpcHandler struct {
+	h      http.Handler
+	source string
+}
+
+// NewRPC wraps the handler of a Connect, gRPC or gRPC-Web service `h`, e.g. one built with
+// connect-go, or grpc-go's Server.ServeHTTP behind a gRPC-Web wrapper, logging an
+// "rpc-finished" entry per call. Entries hold:
+//   - rpc-protocol: "connect", "grpc" or "grpc-web"
+//   - rpc-stream: false for unary calls, true for streaming ones
+//   - rpc-service and rpc-method, from the path
+//   - rpc-code: the status code of the call, e.g. "ok" or "not_found"
+//   - rpc-messages-received and rpc-messages-sent: the number of messages of each stream
+//
+// Calls with the codes blamed on the server, e.g. "internal", are logged at the error level.
+// Like New, it puts a logger for `source` in req.Context(). Other requests aren't logged.
+func NewRPC(h http.Handler, source string) http.Handler {
+	return &rpcHandler{h: h, source: source}
+}
+
+func (r *rpcHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	protocol, stream, text := rpcProtocol(req)
+	if protocol == "" {
+		r.h.ServeHTTP(w, req)
+		return
+	}
+	start := logger.Now()
+	lggr := logger.New(r.source)
+	req = req.WithContext(logger.NewContext(req.Context(), lggr))
+
+	received := &envelopeCounter{text: text}
+	if stream && req.Body != nil {
+		req.Body = &countingBody{ReadCloser: req.Body, counter: received}
+	}
+	sent := &envelopeCounter{text: text}
+	switch protocol {
+	case RPCProtocolGRPCWeb:
+		sent.capture = grpcWebTrailerFlag
+	case RPCProtocolConnect:
+		sent.capture = connectEndStreamFlag
+	}
+	rw := &rpcResponseWriter{
+		loggedResponseWriter: loggedResponseWriter{status: 200, ResponseWriter: w},
+		stream:               stream,
+		counter:              sent,
+	}
+	r.h.ServeHTTP(rw, req)
+	duration := logger.Now().Sub(start)
+
+	data := logger.M{
+		"rpc-protocol":     protocol,
+		"rpc-stream":       stream,
+		"rpc-code":         rw.code(protocol),
+		"status-code":      rw.status,
+		"response-time":    logger.FormatDuration(duration),
+		"response-time-ms": duration.Nanoseconds() / int64(time.Millisecond),
+		"count":            1,
+		"via":              "kayvee-rpc",
+	}
+	if stream {
+		data["rpc-messages-received"] = received.messages
+		data["rpc-messages-sent"] = sent.messages
+	} else {
+		data["rpc-messages-received"] = 1
+		data["rpc-messages-sent"] = 0
+		if data["rpc-code"] == "ok" {
+			data["rpc-messages-sent"] = 1
+		}
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) >= 2 {
+		data["rpc-service"] = parts[len(parts)-2]
+		data["rpc-method"] = parts[len(parts)-1]
+	}
+	if rpcServerErrors[data["rpc-code"].(string)] {
+		lggr.ErrorD("rpc-finished", data)
+	} else {
+		lggr.InfoD("rpc-finished", data)
+	}
+}
+
+// rpcProtocol returns the protocol of `req`, whether it's a streaming call, and whether its
+// messages are base64 encoded, or "" if it isn't an RPC.
+func rpcProtocol(req *http.Request) (protocol string, stream, text bool) {
+	contentType := req.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web-text"):
+		return RPCProtocolGRPCWeb, true, true
+	case strings.HasPrefix(contentType, "application/grpc-web"):
+		return RPCProtocolGRPCWeb, true, false
+	case strings.HasPrefix(contentType, "application/grpc"):
+		return RPCProtocolGRPC, true, false
+	case strings.HasPrefix(contentType, "application/connect+"):
+		return RPCProtocolConnect, true, false
+	case req.Header.Get("Connect-Protocol-Version") != "":
+		return RPCProtocolConnect, false, false
+	case req.Method == http.MethodGet && req.URL.Query().Get("connect") == "v1":
+		return RPCProtocolConnect, false, false
+	}
+	return "", false, false
+}
+
+// countingBody counts the messages of a request body as they're read.
+type countingBody struct {
+	io.ReadCloser
+	counter *envelopeCounter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.counter.Write(p[:n])
+	return n, err
+}
+
+// rpcResponseWriter counts the messages of the response, and keeps what holds its code.
+type rpcResponseWriter struct {
+	loggedResponseWriter
+	stream  bool
+	counter *envelopeCounter
+	// body is the start of the response of unary calls, for their errors
+	body bytes.Buffer
+}
+
+func (w *rpcResponseWriter) Write(b []byte) (int, error) {
+	if w.stream {
+		w.counter.Write(b)
+	} else if w.body.Len() < maxCapturedRPCPayload {
+		w.body.Write(b[:min(len(b), maxCapturedRPCPayload-w.body.Len())])
+	}
+	return w.loggedResponseWriter.Write(b)
+}
+
+// Flush is required by streaming handlers, e.g. grpc-go's.
+func (w *rpcResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// code returns the status code of the call.
+func (w *rpcResponseWriter) code(protocol string) string {
+	switch {
+	case protocol == RPCProtocolConnect && !w.stream:
+		if w.status == http.StatusOK {
+			return "ok"
+		}
+		var e struct {
+			Code string `json:"code"`
+		}
+		if json.Unmarshal(w.body.Bytes(), &e) == nil && e.Code != "" {
+			return e.Code
+		}
+		return connectCodeFromStatus(w.status)
+	case protocol == RPCProtocolConnect:
+		var end struct {
+			Error *struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		if !w.counter.captured {
+			return "unknown"
+		}
+		if json.Unmarshal(w.counter.payload.Bytes(), &end) != nil {
+			return "unknown"
+		}
+		if end.Error == nil {
+			return "ok"
+		}
+		return end.Error.Code
+	}
+	// gRPC statuses are in the headers of trailers-only responses, the trailers of gRPC
+	// responses, or the trailers message of gRPC-Web ones
+	status := w.Header().Get("Grpc-Status")
+	if status == "" {
+		status = w.Header().Get(http.TrailerPrefix + "Grpc-Status")
+	}
+	if status == "" && w.counter.captured {
+		for _, line := range strings.Split(w.counter.payload.String(), "\r\n") {
+			if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), "grpc-status") {
+				status = strings.TrimSpace(v)
+			}
+		}
+	}
+	if n, err := strconv.Atoi(status); err == nil && n >= 0 && n < len(rpcCodes) {
+		return rpcCodes[n]
+	}
+	return "unknown"
+}
+
+// connectCodeFromStatus returns the code of a Connect error without one, from its HTTP status.
+func connectCodeFromStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "internal"
+	case http.StatusUnauthorized:
+		return "unauthenticated"
+	case http.StatusForbidden:
+		return "permission_denied"
+	case http.StatusNotFound:
+		return "unimplemented"
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return "unavailable"
+	}
+	return "unknown"
+}
+
+// envelopeCounter counts the enveloped messages of a stream: each has a flags byte and a
+// 4-byte big-endian length. It keeps the payload of the first message with a `capture` flag,
+// which isn't counted.
+type envelopeCounter struct {
+	// text streams are base64 encoded, like gRPC-Web text ones
+	text    bool
+	pending []byte
+
+	capture   byte
+	header    [5]byte
+	headerLen int
+	remaining uint32
+	capturing bool
+
+	messages int
+	captured bool
+	payload  bytes.Buffer
+}
+
+func (c *envelopeCounter) Write(b []byte) {
+	if c.text {
+		b = c.decode(b)
+	}
+	for len(b) > 0 {
+		if c.headerLen < len(c.header) {
+			n := copy(c.header[c.headerLen:], b)
+			c.headerLen += n
+			b = b[n:]
+			if c.headerLen < len(c.header) {
+				return
+			}
+			c.remaining = binary.BigEndian.Uint32(c.header[1:])
+			c.capturing = c.capture != 0 && c.header[0]&c.capture != 0 && !c.captured
+			if c.capture == 0 || c.header[0]&c.capture == 0 {
+				c.messages++
+			}
+		}
+		n := int(min(uint32(len(b)), c.remaining))
+		if c.capturing && c.payload.Len() < maxCapturedRPCPayload {
+			c.payload.Write(b[:min(n, maxCapturedRPCPayload-c.payload.Len())])
+		}
+		c.remaining -= uint32(n)
+		b = b[n:]
+		if c.remaining == 0 {
+			if c.capturing {
+				c.captured, c.capturing = true, false
+			}
+			c.headerLen = 0
+		}
+	}
+}
+
+// decode decodes the base64 of `b`, keeping incomplete quanta for the next write. Padding can
+// occur mid-stream, since each write is encoded on its own.
+func (c *envelopeCounter) decode(b []byte) []byte {
+	c.pending = append(c.pending, b...)
+	n := len(c.pending) / 4 * 4
+	out := make([]byte, 0, n/4*3)
+	for i := 0; i < n; i += 4 {
+		var quantum [3]byte
+		m, err := base64.StdEncoding.Decode(quantum[:], c.pending[i:i+4])
+		if err != nil {
+			continue
+		}
+		out = append(out, quantum[:m]...)
+	}
+	c.pending = append(c.pending[:0], c.pending[n:]...)
+	return out
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+// envelope returns the message `payload` with the envelope of streams.
+func envelope(flags byte, payload string) []byte {
+	bs := make([]byte, 5, 5+len(payload))
+	bs[0] = flags
+	binary.BigEndian.PutUint32(bs[1:], uint32(len(payload)))
+	return append(bs, payload...)
+}
+
+func TestRPC(t *testing.T) {
+	for _, test := range []struct {
+		desc        string
+		contentType string
+		header      http.Header
+		body        []byte
+		handler     func(w http.ResponseWriter)
+		expected    map[string]interface{}
+	}{
+		{
+			desc:        "connect unary",
+			contentType: "application/json",
+			header:      http.Header{"Connect-Protocol-Version": {"1"}},
+			body:        []byte(`{"id":1}`),
+			handler:     func(w http.ResponseWriter) { w.Write([]byte(`{"name":"x"}`)) },
+			expected: map[string]interface{}{
+				"level": "info", "rpc-protocol": "connect", "rpc-stream": false, "rpc-code": "ok",
+				"rpc-messages-received": 1.0, "rpc-messages-sent": 1.0, "status-code": 200.0,
+			},
+		},
+		{
+			desc:        "connect unary error",
+			contentType: "application/proto",
+			header:      http.Header{"Connect-Protocol-Version": {"1"}},
+			handler: func(w http.ResponseWriter) {
+				w.WriteHeader(404)
+				w.Write([]byte(`{"code":"not_found","message":"no such user"}`))
+			},
+			expected: map[string]interface{}{
+				"level": "info", "rpc-code": "not_found", "rpc-messages-sent": 0.0, "status-code": 404.0,
+			},
+		},
+		{
+			desc:        "connect stream",
+			contentType: "application/connect+proto",
+			body:        append(envelope(0, "a"), envelope(0, "bc")...),
+			handler: func(w http.ResponseWriter) {
+				w.Write(envelope(0, "1"))
+				w.Write(envelope(0, "2")[:3])
+				w.Write(envelope(0, "2")[3:])
+				w.Write(envelope(connectEndStreamFlag, `{"error":{"code":"internal","message":"boom"}}`))
+			},
+			expected: map[string]interface{}{
+				"level": "error", "rpc-protocol": "connect", "rpc-stream": true, "rpc-code": "internal",
+				"rpc-messages-received": 2.0, "rpc-messages-sent": 2.0,
+			},
+		},
+		{
+			desc:        "grpc-web",
+			contentType: "application/grpc-web+proto",
+			body:        envelope(0, "req"),
+			handler: func(w http.ResponseWriter) {
+				w.Write(envelope(0, "resp"))
+				w.Write(envelope(grpcWebTrailerFlag, "grpc-status: 5\r\ngrpc-message: gone\r\n"))
+			},
+			expected: map[string]interface{}{
+				"level": "info", "rpc-protocol": "grpc-web", "rpc-code": "not_found",
+				"rpc-messages-received": 1.0, "rpc-messages-sent": 1.0,
+			},
+		},
+		{
+			desc:        "grpc-web text",
+			contentType: "application/grpc-web-text",
+			body:        []byte(base64.StdEncoding.EncodeToString(envelope(0, "req"))),
+			handler: func(w http.ResponseWriter) {
+				w.Write([]byte(base64.StdEncoding.EncodeToString(envelope(0, "resp"))))
+				w.Write([]byte(base64.StdEncoding.EncodeToString(envelope(grpcWebTrailerFlag, "grpc-status: 0\r\n"))))
+			},
+			expected: map[string]interface{}{
+				"rpc-protocol": "grpc-web", "rpc-code": "ok", "rpc-messages-received": 1.0, "rpc-messages-sent": 1.0,
+			},
+		},
+		{
+			desc:        "grpc",
+			contentType: "application/grpc",
+			body:        envelope(0, "req"),
+			handler: func(w http.ResponseWriter) {
+				w.Header().Set("Trailer", "Grpc-Status")
+				w.Write(envelope(0, "resp"))
+				w.Header().Set("Grpc-Status", "14")
+			},
+			expected: map[string]interface{}{
+				"level": "error", "rpc-protocol": "grpc", "rpc-code": "unavailable",
+				"rpc-messages-received": 1.0, "rpc-messages-sent": 1.0,
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			handler := NewRPC(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				logger.FromContext(r.Context()).SetOutput(out)
+				b := &bytes.Buffer{}
+				b.ReadFrom(r.Body)
+				test.handler(w)
+			}), "my-source")
+			req := httptest.NewRequest("POST", "/acme.user.v1.UserService/GetUser", bytes.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			lines := decodeLines(t, out)
+			require.Len(t, lines, 1)
+			assert.Equal(t, "rpc-finished", lines[0]["title"])
+			assert.Equal(t, "acme.user.v1.UserService", lines[0]["rpc-service"])
+			assert.Equal(t, "GetUser", lines[0]["rpc-method"])
+			assert.Equal(t, "kayvee-rpc", lines[0]["via"])
+			for k, v := range test.expected {
+				assert.Equal(t, v, lines[0][k], k)
+			}
+		})
+	}
+}
+
+func TestRPCIgnoresOtherRequests(t *testing.T) {
+	called := false
+	handler := NewRPC(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), "my-source")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", strings.NewReader("")))
+	assert.True(t, called)
+}
+
+func TestConnectGet(t *testing.T) {
+	req := httptest.NewRequest("GET", "/svc/Method?connect=v1&encoding=json&message=%7B%7D", nil)
+	protocol, stream, _ := rpcProtocol(req)
+	assert.Equal(t, RPCProtocolConnect, protocol)
+	assert.False(t, stream)
+}
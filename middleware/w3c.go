@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+var globalAccessLog *W3CAccessLog
+
+// DefaultW3CFields are the fields written by a W3CAccessLog when none are configured.
+var DefaultW3CFields = []string{
+	"date", "time", "c-ip", "cs-method", "cs-uri-stem", "cs-uri-query",
+	"sc-status", "sc-bytes", "time-taken", "cs(User-Agent)", "cs(Referer)",
+}
+
+// W3CAccessLogConfig configures a W3CAccessLog.
+type W3CAccessLogConfig struct {
+	// Output is where access log lines are written.
+	Output io.Writer
+	// Fields are the W3C field identifiers to write, in order. Supported identifiers are date,
+	// time, c-ip, cs-method, cs-uri, cs-uri-stem, cs-uri-query, cs-host, sc-status, sc-bytes,
+	// time-taken (in seconds) and cs(Header) for any request header. Defaults to
+	// DefaultW3CFields.
+	Fields []string
+	// ReplaceJSON turns off the kayvee JSON log of requests, so that only the access log is
+	// written. By default both are.
+	ReplaceJSON bool
+}
+
+// W3CAccessLog writes an access log of kv middleware requests in the W3C Extended Log File
+// Format (https://www.w3.org/TR/WD-logfile.html), for integrations that require that exact
+// format.
+type W3CAccessLog struct {
+	config W3CAccessLogConfig
+
+	mu            sync.Mutex
+	headerWritten bool
+}
+
+// EnableW3CAccessLog turns on a W3C extended log format access log for kv middleware requests.
+func EnableW3CAccessLog(config W3CAccessLogConfig) error {
+	a, err := NewW3CAccessLog(config)
+	if err != nil {
+		return err
+	}
+	globalAccessLog = a
+	return nil
+}
+
+// NewW3CAccessLog creates a W3CAccessLog, validating its fields.
+func NewW3CAccessLog(config W3CAccessLogConfig) (*W3CAccessLog, error) {
+	if config.Output == nil {
+		return nil, fmt.Errorf("W3C access log output is required")
+	}
+	if len(config.Fields) == 0 {
+		config.Fields = DefaultW3CFields
+	}
+	for _, field := range config.Fields {
+		if _, ok := w3cRequestHeader(field); !ok && !w3cFields[field] {
+			return nil, fmt.Errorf("unsupported W3C field %q", field)
+		}
+	}
+	return &W3CAccessLog{config: config}, nil
+}
+
+var w3cFields = map[string]bool{
+	"date": true, "time": true, "c-ip": true, "cs-method": true, "cs-uri": true,
+	"cs-uri-stem": true, "cs-uri-query": true, "cs-host": true, "sc-status": true,
+	"sc-bytes": true, "time-taken": true,
+}
+
+// Log writes the access log line of a request, preceded by the directives header if it's the
+// first line written.
+func (a *W3CAccessLog) Log(req *http.Request, status, size int, start time.Time, duration time.Duration) error {
+	values := make([]string, 0, len(a.config.Fields))
+	utc := start.UTC()
+	for _, field := range a.config.Fields {
+		var v string
+		if header, ok := w3cRequestHeader(field); ok {
+			v = req.Header.Get(header)
+		} else {
+			switch field {
+			case "date":
+				v = utc.Format("2006-01-02")
+			case "time":
+				v = utc.Format("15:04:05")
+			case "c-ip":
+				v = logger.RequestIP(req)
+			case "cs-method":
+				v = req.Method
+			case "cs-uri":
+				v = req.URL.RequestURI()
+			case "cs-uri-stem":
+				v = req.URL.Path
+			case "cs-uri-query":
+				v = req.URL.RawQuery
+			case "cs-host":
+				v = req.Host
+			case "sc-status":
+				v = strconv.Itoa(status)
+			case "sc-bytes":
+				v = strconv.Itoa(size)
+			case "time-taken":
+				v = strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+			}
+		}
+		values = append(values, w3cValue(v))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b := &strings.Builder{}
+	if !a.headerWritten {
+		fmt.Fprintf(b, "#Version: 1.0\n#Date: %s\n#Fields: %s\n",
+			utc.Format("2006-01-02 15:04:05"), strings.Join(a.config.Fields, " "))
+	}
+	b.WriteString(strings.Join(values, " "))
+	b.WriteByte('\n')
+	if _, err := io.WriteString(a.config.Output, b.String()); err != nil {
+		return err
+	}
+	a.headerWritten = true
+	return nil
+}
+
+// w3cRequestHeader returns the header name of a cs(Header) field.
+func w3cRequestHeader(field string) (string, bool) {
+	if strings.HasPrefix(field, "cs(") && strings.HasSuffix(field, ")") && len(field) > 4 {
+		return field[3 : len(field)-1], true
+	}
+	return "", false
+}
+
+// w3cValue escapes a field value: fields are space separated, so spaces are replaced with
+// '+' like IIS does, and empty values are written as '-'.
+func w3cValue(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return strings.NewReplacer(" ", "+", "\n", "+", "\r", "+").Replace(v)
+}
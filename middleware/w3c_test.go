@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kv "gopkg.in/Clever/kayvee-go.v6"
+
+	"github.com/caido/dependency-kayvee-go/v6/logger"
+)
+
+func TestW3CAccessLog(t *testing.T) {
+	out := &bytes.Buffer{}
+	a, err := NewW3CAccessLog(W3CAccessLogConfig{Output: out})
+	require.NoError(t, err)
+
+	req := &http.Request{
+		Method: "GET",
+		URL:    &url.URL{Path: "/users", RawQuery: "q=a"},
+		Header: http.Header{"User-Agent": {"Mozilla/5.0 (X11)"}},
+	}
+	req.RemoteAddr = "10.0.0.1"
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, a.Log(req, 200, 15, start, 1500*time.Millisecond))
+	require.NoError(t, a.Log(req, 404, 0, start, 0))
+
+	assert.Equal(t, "#Version: 1.0\n"+
+		"#Date: 2024-01-01 10:00:00\n"+
+		"#Fields: date time c-ip cs-method cs-uri-stem cs-uri-query sc-status sc-bytes time-taken cs(User-Agent) cs(Referer)\n"+
+		"2024-01-01 10:00:00 10.0.0.1 GET /users q=a 200 15 1.500 Mozilla/5.0+(X11) -\n"+
+		"2024-01-01 10:00:00 10.0.0.1 GET /users q=a 404 0 0.000 Mozilla/5.0+(X11) -\n", out.String())
+}
+
+func TestW3CAccessLogValidatesFields(t *testing.T) {
+	_, err := NewW3CAccessLog(W3CAccessLogConfig{Output: &bytes.Buffer{}, Fields: []string{"date", "cs(X-Op)"}})
+	assert.NoError(t, err)
+	_, err = NewW3CAccessLog(W3CAccessLogConfig{Output: &bytes.Buffer{}, Fields: []string{"s-sitename"}})
+	assert.Error(t, err)
+	_, err = NewW3CAccessLog(W3CAccessLogConfig{})
+	assert.Error(t, err)
+}
+
+func TestMiddlewareW3CAccessLog(t *testing.T) {
+	for _, replaceJSON := range []bool{false, true} {
+		accessLog := &bytes.Buffer{}
+		require.NoError(t, EnableW3CAccessLog(W3CAccessLogConfig{
+			Output:      accessLog,
+			Fields:      []string{"cs-method", "cs-uri-stem", "sc-status"},
+			ReplaceJSON: replaceJSON,
+		}))
+
+		out := &bytes.Buffer{}
+		handler := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.FromContext(r.Context()).SetConfig("my-source", logger.Info, kv.Format, out)
+			w.WriteHeader(201)
+		}), "my-source")
+		handler.ServeHTTP(&bufferWriter{}, &http.Request{Method: "POST", URL: &url.URL{Path: "/things"}})
+		globalAccessLog = nil
+
+		lines := strings.Split(strings.TrimSpace(accessLog.String()), "\n")
+		assert.Equal(t, "POST /things 201", lines[len(lines)-1])
+		assert.Equal(t, !replaceJSON, strings.Contains(out.String(), "request-finished"))
+	}
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// RouteChange is how the routing of a log line differs between two configurations.
+type RouteChange struct {
+	// Index is the position of the log line in the corpus.
+	Index int
+	// Msg is the log line.
+	Msg map[string]interface{}
+	// Added are the outputs only the new configuration produces, and Removed the ones only
+	// the previous one produces. A rule whose output changed appears in both.
+	Added, Removed []map[string]interface{}
+}
+
+// DiffRoutes routes each log line of `corpus` with both `before` and `after`, and returns the
+// lines whose outputs differ, so that a routing change can be reviewed against real traffic
+// before it's deployed. The corpus is typically a sample of production logs, or entries dumped by
+// logger.DumpRecent. Routing metadata already present in the lines is ignored.
+func DiffRoutes(before, after Router, corpus []map[string]interface{}) []RouteChange {
+	changes := []RouteChange{}
+	for i, msg := range corpus {
+		if _, ok := msg["_kvmeta"]; ok {
+			stripped := make(map[string]interface{}, len(msg))
+			for k, v := range msg {
+				stripped[k] = v
+			}
+			delete(stripped, "_kvmeta")
+			msg = stripped
+		}
+		oldRoutes := routesOf(before, msg)
+		newRoutes := routesOf(after, msg)
+		change := RouteChange{
+			Index:   i,
+			Msg:     msg,
+			Added:   missingFrom(oldRoutes, newRoutes),
+			Removed: missingFrom(newRoutes, oldRoutes),
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// routesOf returns the outputs `r` routes `msg` to, keyed by their JSON encoding.
+func routesOf(r Router, msg map[string]interface{}) map[string]map[string]interface{} {
+	routes := map[string]map[string]interface{}{}
+	meta := r.Route(msg)
+	outputs, _ := meta["routes"].([]map[string]interface{})
+	for _, output := range outputs {
+		// json.Marshal sorts map keys, so equal outputs have equal encodings
+		bs, err := json.Marshal(output)
+		if err != nil {
+			continue
+		}
+		routes[string(bs)] = output
+	}
+	return routes
+}
+
+// missingFrom returns the outputs of `b` that aren't in `a`, ordered by their encoding.
+func missingFrom(a, b map[string]map[string]interface{}) []map[string]interface{} {
+	keys := []string{}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := make([]map[string]interface{}, len(keys))
+	for i, k := range keys {
+		out[i] = b[k]
+	}
+	return out
+}
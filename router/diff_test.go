@@ -0,0 +1,71 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	before, err := NewFromConfigBytes([]byte(`
+routes:
+  errors:
+    matchers:
+      level: ["error"]
+    output:
+      type: "alerts"
+      series: "errors"
+      dimensions: []
+      stat_type: "counter"
+  signups:
+    matchers:
+      title: ["signup"]
+    output:
+      type: "analytics"
+      series: "signups"
+`))
+	require.NoError(t, err)
+	after, err := NewFromConfigBytes([]byte(`
+routes:
+  errors:
+    matchers:
+      level: ["error", "critical"]
+    output:
+      type: "alerts"
+      series: "errors"
+      dimensions: []
+      stat_type: "counter"
+  signups:
+    matchers:
+      title: ["signup"]
+    output:
+      type: "analytics"
+      series: "signups-v2"
+`))
+	require.NoError(t, err)
+
+	corpus := []map[string]interface{}{
+		{"title": "request-failed", "level": "error"},
+		{"title": "db-down", "level": "critical", "_kvmeta": map[string]interface{}{"routes": []interface{}{}}},
+		{"title": "signup", "level": "info"},
+		{"title": "login", "level": "info"},
+	}
+	changes := DiffRoutes(before, after, corpus)
+	require.Len(t, changes, 2)
+
+	assert.Equal(t, 1, changes[0].Index)
+	assert.NotContains(t, changes[0].Msg, "_kvmeta")
+	require.Len(t, changes[0].Added, 1)
+	assert.Equal(t, "errors", changes[0].Added[0]["rule"])
+	assert.Empty(t, changes[0].Removed)
+
+	assert.Equal(t, 2, changes[1].Index)
+	require.Len(t, changes[1].Added, 1)
+	require.Len(t, changes[1].Removed, 1)
+	assert.Equal(t, "signups-v2", changes[1].Added[0]["series"])
+	assert.Equal(t, "signups", changes[1].Removed[0]["series"])
+
+	// the corpus isn't modified
+	assert.Contains(t, corpus[1], "_kvmeta")
+}
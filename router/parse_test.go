@@ -373,3 +373,34 @@ routes:
 	_, err = NewFromConfigBytes(invalidConf)
 	assert.Error(t, err)
 }
+
+func TestSIEMOutput(t *testing.T) {
+	confTmpl := `
+routes:
+  security-events:
+    matchers:
+      title: ["login-failed"]
+    output:
+      type: "siem"
+      format: "%s"
+      vendor: "Clever"
+      product: "${SIEM_PRODUCT}"
+`
+	os.Setenv("SIEM_PRODUCT", "my-app")
+	defer os.Unsetenv("SIEM_PRODUCT")
+
+	for _, format := range []string{"cef", "leef"} {
+		router, err := NewFromConfigBytes([]byte(fmt.Sprintf(confTmpl, format)))
+		assert.Nil(t, err)
+		r := router.(*RuleRouter)
+		assert.Equal(t, RuleOutput{
+			"type":    "siem",
+			"format":  format,
+			"vendor":  "Clever",
+			"product": "my-app",
+		}, r.rules[0].Output)
+	}
+
+	_, err := NewFromConfigBytes([]byte(fmt.Sprintf(confTmpl, "syslog")))
+	assert.Error(t, err)
+}
@@ -3,7 +3,7 @@
 package router
 
 var routerSchema = `{
-  "description": "Last modified: 11/05/2019",
+  "description": "Last modified: 10/16/2026",
   "required": ["routes"],
   "properties": {
     "routes": { "$ref": "#/definitions/routes" }
@@ -40,7 +40,8 @@ var routerSchema = `{
         { "$ref": "#/definitions/metricsOutput" },
         { "$ref": "#/definitions/alertsOutput" },
         { "$ref": "#/definitions/analyticsOutput" },
-        { "$ref": "#/definitions/notificationsOutput" }
+        { "$ref": "#/definitions/notificationsOutput" },
+        { "$ref": "#/definitions/siemOutput" }
       ]
     },
     "metricsOutput": {
@@ -129,6 +130,21 @@ var routerSchema = `{
         "user": { "$ref": "#/definitions/envVarSubstValue" }
       }
     },
+    "siemOutput": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["type", "format", "vendor", "product"],
+      "properties": {
+        "type": {
+          "type": "string",
+          "pattern": "^siem$"
+        },
+        "format": { "type": "string", "enum": ["cef", "leef"] },
+        "vendor": { "$ref": "#/definitions/envVarSubstValue" },
+        "product": { "$ref": "#/definitions/envVarSubstValue" },
+        "version": { "$ref": "#/definitions/envVarSubstValue" }
+      }
+    },
     "flatValue": {
       "type": "string",
       "pattern": "^[^%\\${}]+$"
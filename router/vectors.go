@@ -0,0 +1,176 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	kv "gopkg.in/Clever/kayvee-go.v6"
+)
+
+// TestVector is an example log line, and the outputs it's routed to.
+type TestVector struct {
+	Title string                 `json:"title"`
+	Input map[string]interface{} `json:"input"`
+	// Routes are the outputs of the rules matching the line, ordered by rule name.
+	Routes []map[string]interface{} `json:"routes"`
+}
+
+// TestVectors are test vectors generated from a kvconfig, with which kayvee implementations in
+// other languages can check that they route like this one.
+type TestVectors struct {
+	// Version is the kayvee version that generated them.
+	Version string `json:"version"`
+	// Env holds the environment variables substituted in the outputs, which implementations
+	// must set to get the same routes.
+	Env map[string]string `json:"env"`
+	// Vectors are ordered by rule name.
+	Vectors []TestVector `json:"vectors"`
+}
+
+// GenerateTestVectors returns test vectors for the kvconfig `fileBytes`. For each rule, they
+// hold an example log line matching it, lines matching it with each of the other values of
+// its matchers, and lines that each fail one of its matchers. Fields substituted in its
+// output are set too.
+func GenerateTestVectors(fileBytes []byte) (TestVectors, error) {
+	routes, err := parse(fileBytes)
+	if err != nil {
+		return TestVectors{}, err
+	}
+	r, err := NewFromRoutes(routes)
+	if err != nil {
+		return TestVectors{}, err
+	}
+
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	vectors := TestVectors{Version: kv.Version, Env: map[string]string{}, Vectors: []TestVector{}}
+	add := func(title string, msg map[string]interface{}) {
+		routes, _ := r.Route(msg)["routes"].([]map[string]interface{})
+		sort.SliceStable(routes, func(i, j int) bool {
+			return fmt.Sprint(routes[i]["rule"]) < fmt.Sprint(routes[j]["rule"])
+		})
+		vectors.Vectors = append(vectors.Vectors, TestVector{Title: title, Input: msg, Routes: routes})
+	}
+
+	for _, name := range names {
+		rule := routes[name]
+		for _, token := range tokensOf(rule.Output, envvarTokens) {
+			vectors.Env[token] = os.Getenv(token)
+		}
+		fields := make([]string, 0, len(rule.Matchers))
+		for field := range rule.Matchers {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		// base returns a line matching the rule, with `field` set to `value` if it's set
+		base := func(field, value string) map[string]interface{} {
+			msg := map[string]interface{}{}
+			for _, f := range tokensOf(rule.Output, fieldTokens) {
+				setFieldPath(msg, f, "example-"+f)
+			}
+			for _, f := range fields {
+				setFieldPath(msg, f, exampleValue(rule.Matchers[f]))
+			}
+			if field != "" {
+				setFieldPath(msg, field, value)
+			}
+			return msg
+		}
+
+		add(name+" matches", base("", ""))
+		for _, f := range fields {
+			values := rule.Matchers[f]
+			for _, v := range values[1:] {
+				add(fmt.Sprintf("%s matches %s=%q", name, f, v), base(f, v))
+			}
+			v := mismatchValue(values)
+			add(fmt.Sprintf("%s doesn't match %s=%q", name, f, v), base(f, v))
+		}
+	}
+	return vectors, nil
+}
+
+// exampleValue returns a value matching `values`.
+func exampleValue(values []string) string {
+	if values[0] == "*" {
+		return "example"
+	}
+	return values[0]
+}
+
+// mismatchValue returns a value not matching `values`.
+func mismatchValue(values []string) string {
+	if values[0] == "*" {
+		// the wildcard doesn't match empty strings
+		return ""
+	}
+	v := "not-" + values[0]
+	for contains(values, v) {
+		v = "not-" + v
+	}
+	return v
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// tokensOf returns the names in the tokens of `tokenMatcher` in the values of `output`,
+// sorted.
+func tokensOf(output map[string]interface{}, tokenMatcher *regexp.Regexp) []string {
+	seen := map[string]bool{}
+	find := func(s string) {
+		for _, token := range tokenMatcher.FindAllString(s, -1) {
+			seen[token[2:len(token)-1]] = true
+		}
+	}
+	for _, v := range output {
+		switch v := v.(type) {
+		case string:
+			find(v)
+		case []string:
+			for _, s := range v {
+				find(s)
+			}
+		case []interface{}:
+			for _, s := range v {
+				if s, ok := s.(string); ok {
+					find(s)
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setFieldPath sets `field` of `obj` to `value`, interpreting dots in `field` as denoting
+// subobjects like lookupField.
+func setFieldPath(obj map[string]interface{}, field string, value interface{}) {
+	path := strings.Split(field, ".")
+	for _, part := range path[:len(path)-1] {
+		sub, ok := obj[part].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			obj[part] = sub
+		}
+		obj = sub
+	}
+	obj[path[len(path)-1]] = value
+}
@@ -0,0 +1,69 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTestVectors(t *testing.T) {
+	t.Setenv("SERIES_PREFIX", "prod")
+	vectors, err := GenerateTestVectors([]byte(`
+routes:
+  errors:
+    matchers:
+      level: ["error", "critical"]
+      team: ["*"]
+    output:
+      type: "alerts"
+      series: "${SERIES_PREFIX}.errors"
+      dimensions: ["team"]
+      stat_type: "counter"
+  signups:
+    matchers:
+      user.plan: ["free"]
+    output:
+      type: "notifications"
+      channel: "#signups"
+      icon: ":tada:"
+      message: "signup from %{source}"
+      user: "kayvee"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SERIES_PREFIX": "prod"}, vectors.Env)
+
+	titles := []string{}
+	for _, v := range vectors.Vectors {
+		titles = append(titles, v.Title)
+	}
+	assert.Equal(t, []string{
+		`errors matches`,
+		`errors matches level="critical"`,
+		`errors doesn't match level="not-error"`,
+		`errors doesn't match team=""`,
+		`signups matches`,
+		`signups doesn't match user.plan="not-free"`,
+	}, titles)
+
+	assert.Equal(t, map[string]interface{}{"level": "error", "team": "example"}, vectors.Vectors[0].Input)
+	require.Len(t, vectors.Vectors[0].Routes, 1)
+	assert.Equal(t, "errors", vectors.Vectors[0].Routes[0]["rule"])
+	assert.Equal(t, "prod.errors", vectors.Vectors[0].Routes[0]["series"])
+	assert.Empty(t, vectors.Vectors[2].Routes)
+	assert.Empty(t, vectors.Vectors[3].Routes)
+
+	signup := vectors.Vectors[4]
+	assert.Equal(t, map[string]interface{}{
+		"user":   map[string]interface{}{"plan": "free"},
+		"source": "example-source",
+	}, signup.Input)
+	require.Len(t, signup.Routes, 1)
+	assert.Equal(t, "signup from example-source", signup.Routes[0]["message"])
+	assert.Empty(t, vectors.Vectors[5].Routes)
+}
+
+func TestGenerateTestVectorsInvalidConfig(t *testing.T) {
+	_, err := GenerateTestVectors([]byte(`routes: {errors: {matchers: {}}}`))
+	assert.Error(t, err)
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"strings"
 
-	"gopkg.in/Clever/kayvee-go.v6/logger"
+	"github.com/caido/dependency-kayvee-go/v6/logger"
 )
 
 // ValueType represents the valuve type of a Kayvee field, where applicable.